@@ -16,8 +16,8 @@ func GetCPUInfo() ([]datetype.CPUData, error) {
 		return nil, err
 	}
 
-	// 获取 CPU 使用率信息
-	usage, err := cpu.Percent(1*time.Second, false)
+	// 获取 CPU 使用率信息，多条 CPU 信息时按逻辑核分别统计，避免下标越界
+	usage, err := cpu.Percent(1*time.Second, len(info) > 1)
 	if err != nil {
 		zap.L().Error("获取 CPU 使用率失败", zap.Error(err))
 		return nil, err
@@ -32,7 +32,9 @@ func GetCPUInfo() ([]datetype.CPUData, error) {
 			PhysicalID: cpuInfo.PhysicalID,
 			Cores:      cpuInfo.Cores,
 			Mhz:        cpuInfo.Mhz,
-			Usage:      usage[i], // 使用 CPU 使用率
+		}
+		if i < len(usage) {
+			data.Usage = usage[i] // 使用 CPU 使用率
 		}
 		cpuData = append(cpuData, data)
 	}
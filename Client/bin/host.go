@@ -0,0 +1,46 @@
+package bin
+
+import (
+	"Client/datetype"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/mem"
+	"go.uber.org/zap"
+	"time"
+)
+
+// GetHostFacts 获取操作系统、内核、CPU 型号和开机时间等主机信息
+func GetHostFacts() (datetype.HostFacts, error) {
+	var facts datetype.HostFacts
+
+	info, err := host.Info()
+	if err != nil {
+		zap.L().Error("获取主机信息失败", zap.Error(err))
+		return facts, err
+	}
+	facts.HostName = info.Hostname
+	facts.OS = info.OS
+	facts.Platform = info.Platform
+	facts.PlatformVersion = info.PlatformVersion
+	facts.KernelVersion = info.KernelVersion
+	facts.KernelArch = info.KernelArch
+	facts.BootTime = time.Unix(int64(info.BootTime), 0)
+	facts.Uptime = info.Uptime
+
+	// CPU 和内存信息获取失败不影响注册
+	if cpus, err := cpu.Info(); err == nil && len(cpus) > 0 {
+		facts.CPUModel = cpus[0].ModelName
+	} else if err != nil {
+		zap.L().Error("获取 CPU 信息失败", zap.Error(err))
+	}
+	if cores, err := cpu.Counts(true); err == nil {
+		facts.CPUCores = cores
+	}
+	if vm, err := mem.VirtualMemory(); err == nil {
+		facts.MemTotal = vm.Total
+	} else {
+		zap.L().Error("获取内存信息失败", zap.Error(err))
+	}
+
+	return facts, nil
+}
@@ -28,6 +28,7 @@ func PingWithCancel(ctx context.Context, address string, count int, outputChan c
 
 	// 逐行读取命令输出
 	reader := bufio.NewReader(stdout)
+readLoop:
 	for {
 		select {
 		case <-ctx.Done():
@@ -40,7 +41,7 @@ func PingWithCancel(ctx context.Context, address string, count int, outputChan c
 			line, err := reader.ReadBytes('\n')
 			if err != nil {
 				if err == io.EOF {
-					break
+					break readLoop
 				}
 				return fmt.Errorf("error reading ping output: %v", err)
 			}
@@ -36,17 +36,100 @@ type MonitorData struct {
 	CPU     []CPUData     `json:"cpu"`
 	Disk    []DiskData    `json:"disk"`
 }
+type MetricsReport struct {
+	AgentID   string      `json:"agent_id"`
+	ClientIP  string      `json:"client_ip"`
+	HostName  string      `json:"hostname"`
+	Timestamp time.Time   `json:"timestamp"`
+	Metrics   MonitorData `json:"metrics"`
+}
 type TaskStatus struct {
 	RequestId  string `json:"request_id"`
 	TaskType   string `json:"task_type"`
 	TaskId     string `json:"task_id"`
 	TaskStatus string `json:"task_status"`
 }
-type TaskLogRequest struct {
-	RequestId     string `json:"request_id"`
-	TaskType      string `json:"task_type"`
-	ClientTaskLog `json:"client_task_log"`
+
+// RunStart 开始执行任务时上报，服务端返回本次执行的 run_id
+type RunStart struct {
+	RequestId string    `json:"request_id"`
+	TaskType  string    `json:"task_type"`
+	TaskId    string    `json:"task_id"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// TaskOptions 任务的执行选项，由服务端随任务下发
+type TaskOptions struct {
+	Timeout           int    `json:"timeout"`            // 执行超时秒数，0 表示不限制
+	ConcurrencyPolicy string `json:"concurrency_policy"` // allow、skip、queue、replace，为空时为 allow
+	MaxRetries        int    `json:"max_retries"`        // 失败后的最大重试次数
+	RetryBackoff      int    `json:"retry_backoff"`      // 首次重试前等待的秒数，之后每次翻倍
+	RetryOn           string `json:"retry_on"`           // 逗号分隔的需要重试的退出码，为空时任何失败都重试
+}
+
+// RunSkip 因并发策略跳过一次触发时上报
+type RunSkip struct {
+	RequestId string    `json:"request_id"`
+	TaskType  string    `json:"task_type"`
+	TaskId    string    `json:"task_id"`
+	SkipTime  time.Time `json:"skip_time"`
+	Reason    string    `json:"reason"`
+}
+
+// OutputLine 执行过程中输出的一行
+type OutputLine struct {
+	Seq     int64     `json:"seq"`
+	Attempt int       `json:"attempt"`
+	Stream  string    `json:"stream"` // stdout 或 stderr
+	Line    string    `json:"line"`
+	Time    time.Time `json:"time"`
+}
+
+// TaskOutput 执行过程中分批上报的输出
+type TaskOutput struct {
+	RunId  string       `json:"run_id"`
+	TaskId string       `json:"task_id"`
+	Lines  []OutputLine `json:"lines"`
+}
+
+// RunFinish 执行结束时上报结果
+type RunFinish struct {
+	RequestId   string       `json:"request_id"`
+	TaskType    string       `json:"task_type"`
+	RunId       string       `json:"run_id"`
+	TaskId      string       `json:"task_id"`
+	Status      string       `json:"status"` // succeeded、failed、timed_out、cancelled
+	ExitCode    *int         `json:"exit_code"`
+	Signal      string       `json:"signal"` // 被信号终止时的信号名，如 SIGKILL
+	StdoutBytes int64        `json:"stdout_bytes"`
+	StderrBytes int64        `json:"stderr_bytes"`
+	TimedOut    bool         `json:"timed_out"`
+	StartTime   time.Time    `json:"start_time"`
+	EndTime     time.Time    `json:"end_time"`
+	Error       string       `json:"error"`
+	Attempts    []RunAttempt `json:"attempts"` // 各次尝试，最后一次为最终结果
+}
+
+// RunAttempt 一次执行中的单次尝试
+type RunAttempt struct {
+	Status      string    `json:"status"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	ExitCode    *int      `json:"exit_code"`
+	Signal      string    `json:"signal"`
+	StdoutBytes int64     `json:"stdout_bytes"`
+	StderrBytes int64     `json:"stderr_bytes"`
+	TimedOut    bool      `json:"timed_out"`
+	Error       string    `json:"error"`
 }
+
+// CommandAck 应用服务端指令后的确认，与服务端一致
+type CommandAck struct {
+	Seq   int64  `json:"seq"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
 type TaskRequest struct {
 	RequestId string   `json:"request_id"`
 	TaskId    []string `json:"task_id"`
@@ -59,12 +142,21 @@ type TaskReceive struct {
 	TaskBash    string `json:"task_bash"`
 	TaskWorkDir string `json:"task_work_dir"`
 }
-type ClientTaskLog struct {
-	LogID          int       `json:"log_id" db:"log_id"`                   // 日志 ID
-	ClientIP       string    `json:"client_ip" db:"client_ip"`             // 客户端 IP
-	TaskID         string    `json:"task_id" db:"task_id"`                 // 任务 ID，外键关联 task_records
-	ExecutionTime  time.Time `json:"execution_time" db:"execution_time"`   // 任务执行时间
-	Output         string    `json:"output" db:"output"`                   // 任务输出内容
-	CompletionTime time.Time `json:"completion_time" db:"completion_time"` // 任务结束时间
-	Remarks        string    `json:"remarks" db:"remarks"`                 // 备注
+
+// HostFacts 注册到服务端主机清单的主机信息
+type HostFacts struct {
+	AgentID         string    `json:"agent_id"`
+	ClientIP        string    `json:"client_ip"`
+	HostName        string    `json:"hostname"`
+	OS              string    `json:"os"`
+	Platform        string    `json:"platform"`
+	PlatformVersion string    `json:"platform_version"`
+	KernelVersion   string    `json:"kernel_version"`
+	KernelArch      string    `json:"kernel_arch"`
+	CPUModel        string    `json:"cpu_model"`
+	CPUCores        int       `json:"cpu_cores"`
+	MemTotal        uint64    `json:"mem_total"`
+	BootTime        time.Time `json:"boot_time"`
+	Uptime          uint64    `json:"uptime"` // 秒
+	AgentVersion    string    `json:"agent_version"`
 }
@@ -8,11 +8,19 @@ type AppConfig struct {
 	StartTime     string `mapstructure:"start_time"`
 	MachineId     int64  `mapstructure:"machine_id"`
 	ClientIp      string `mapstructure:"clientip"`
+	AgentIDFile   string `mapstructure:"agent_id_file"`
+	AgentID       string `mapstructure:"-"` // 启动时从 AgentIDFile 读取或生成
+	EnrollToken   string `mapstructure:"enrollment_token"`
+	CredFile      string `mapstructure:"credential_file"`
+	Credential    string `mapstructure:"-"` // 注册后获得的长期凭证，保存在 CredFile
 	WorkDir       string `mapstructure:"workdir"`
+	MaxParallel   int    `mapstructure:"max_parallel_tasks"` // 同时执行的任务数上限，0 表示不限制
 	*LogConfig    `mapstructure:"log"`
 	*ServerConfig `mapstructure:"server"`
 	*EtcdConfig   `mapstructure:"akile"`
 	*WebSocket    `mapstructure:"websocket"`
+	*Metrics      `mapstructure:"metrics"`
+	TLS           *TLSConfig `mapstructure:"tls"`
 }
 
 type ServerConfig struct {
@@ -46,3 +54,18 @@ type WebSocket struct {
 	ReconnectInterval         int `mapstructure:"reconnect_interval"`
 	MaxTotalReconnectAttempts int `mapstructure:"max_total_reconnect_attempts"`
 }
+
+type Metrics struct {
+	Enable   bool `mapstructure:"enable"`
+	Interval int  `mapstructure:"interval"` // 采集上报间隔（秒）
+}
+
+// TLSConfig 与服务端之间的 https/wss 与 mTLS 配置
+type TLSConfig struct {
+	Enable      bool   `mapstructure:"enable"`
+	CAFile      string `mapstructure:"ca_file"`      // 校验服务端证书的 CA，为空时使用系统根证书
+	CertFile    string `mapstructure:"cert_file"`    // 注册时由服务端签发的客户端证书
+	KeyFile     string `mapstructure:"key_file"`     // 客户端私钥
+	ServerName  string `mapstructure:"server_name"`  // 服务端证书中的域名，为空时使用 server.ip
+	RenewBefore int    `mapstructure:"renew_before"` // 证书到期前多少天自动轮换
+}
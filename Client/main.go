@@ -2,6 +2,8 @@ package main
 
 import (
 	"Client/logger"
+	"Client/mode"
+	"Client/monitor"
 	"Client/route"
 	"Client/setting"
 	"Client/taskexce"
@@ -30,6 +32,22 @@ func main() {
 		return
 	}
 
+	// 读取或生成客户端 ID
+	agentID, err := setting.LoadAgentID(setting.Conf.AgentIDFile)
+	if err != nil {
+		fmt.Printf("load agent id failed, err:%v\n", err)
+		return
+	}
+	setting.Conf.AgentID = agentID
+
+	// 读取已保存的长期凭证，未注册时为空
+	credential, err := setting.LoadCredential(setting.Conf.CredFile)
+	if err != nil {
+		fmt.Printf("load credential failed, err:%v\n", err)
+		return
+	}
+	setting.Conf.Credential = credential
+
 	// 2. 初始化日志
 	if err := logger.Init(setting.Conf.LogConfig); err != nil {
 		fmt.Printf("init logger failed, err:%v\n", err)
@@ -92,8 +110,17 @@ func initTaskManagerAndExecutor() {
 		TaskList: make(map[string]taskmanager.Task), // 初始化任务列表的 map
 	}
 
+	// 下载脚本和上传日志与 WebSocket 使用相同的 TLS 配置
+	tlsConfig, err := ws.TLSClientConfig()
+	if err != nil {
+		log.Fatalf("Failed to load TLS config: %v", err)
+	}
+	if tlsConfig != nil {
+		mode.HTTPClient = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	}
+
 	// 连接 WebSocket 并请求 Token
-	client, err := ws.ConnectWebSocketAndRequestToken(setting.Conf.ServerConfig.Ip, setting.Conf.ClientIp, setting.Conf.ServerConfig.Port)
+	client, err := ws.ConnectWebSocketAndRequestToken(setting.Conf.ServerConfig.Ip, setting.Conf.AgentID, setting.Conf.ClientIp, setting.Conf.ServerConfig.Port)
 	if err != nil {
 		log.Fatalf("Failed to connect WebSocket: %v", err)
 	}
@@ -111,8 +138,22 @@ func initTaskManagerAndExecutor() {
 	// 初始化任务执行器，并将 WebSocketManager 和 TaskManager 分开传递
 	executor := taskexce.InitExecutor(tm, wsManager)
 	executor.Start()
+	// 服务端下发的任务指令交由执行器调度
+	wsManager.TaskManager = executor
 
-	go func() {
-		ws.ListenToServerAndManageTasks(client)
-	}()
+	// 监听协程由 ListenToServerAndManageTasks 内部启动，需在指标上报前完成注册
+	ws.ListenToServerAndManageTasks(client)
+
+	// 登记主机信息到服务端主机清单
+	if err := ws.RegisterHost(wsManager); err != nil {
+		zap.L().Error("主机注册失败", zap.Error(err))
+	}
+
+	// 客户端证书缺失或即将到期时申请新证书
+	if err := ws.EnsureCertificate(wsManager); err != nil {
+		zap.L().Error("客户端证书轮换失败", zap.Error(err))
+	}
+
+	// 启动主机指标定时上报
+	go monitor.StartReporter(wsManager)
 }
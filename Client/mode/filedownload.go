@@ -11,8 +11,35 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 )
 
+// HTTPClient 下载和上传使用的客户端，启用 TLS 时替换为携带客户端证书的客户端
+var HTTPClient = http.DefaultClient
+
+var (
+	agentAuthMu sync.RWMutex
+	agentID     string
+	agentToken  string
+)
+
+// SetAgentAuth 保存当前会话的客户端 ID 和 token，下载和上传请求以此向服务端认证，重连后需重新设置
+func SetAgentAuth(id, token string) {
+	agentAuthMu.Lock()
+	defer agentAuthMu.Unlock()
+	agentID, agentToken = id, token
+}
+
+// setAgentHeaders 为请求附加客户端认证头
+func setAgentHeaders(req *http.Request) {
+	agentAuthMu.RLock()
+	defer agentAuthMu.RUnlock()
+	if agentID != "" {
+		req.Header.Set("X-Agent-ID", agentID)
+		req.Header.Set("X-Agent-Token", agentToken)
+	}
+}
+
 // DownloadFile 函数用于下载多个文件并保存到指定的工作目录
 func DownloadFile(fileIDs []string, address string, workdir string) error {
 
@@ -33,9 +60,15 @@ func DownloadFile(fileIDs []string, address string, workdir string) error {
 		}
 
 		// 发送 POST 请求进行下载
-		resp, err := http.Post(downloadURL, "application/json", bytes.NewBuffer(requestBody))
+		req, err := http.NewRequest("POST", downloadURL, bytes.NewBuffer(requestBody))
+		if err != nil {
+			return fmt.Errorf("创建 HTTP 请求失败: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		setAgentHeaders(req)
+		resp, err := HTTPClient.Do(req)
 		if err != nil {
-			return fmt.Errorf("下载任务 %s 失败: %v", fileID, err)
+			return fmt.Errorf("下载任务 %d 失败: %v", fileID, err)
 		}
 		defer resp.Body.Close()
 
@@ -78,64 +111,74 @@ func DownloadFile(fileIDs []string, address string, workdir string) error {
 
 // UploadFiles 函数用于将多个文件上传到指定的服务器地址
 func UploadFiles(filePaths []string, address string) error {
-
 	for _, filePath := range filePaths {
-		// 打开文件
-		file, err := os.Open(filePath)
-		if err != nil {
-			return fmt.Errorf("无法打开文件 '%s': %v", filePath, err)
+		if _, err := uploadFile(filePath, address); err != nil {
+			return err
 		}
-		defer file.Close()
+		fmt.Printf("文件 %s 已成功上传到服务器\n", filepath.Base(filePath))
+	}
+	return nil
+}
 
-		// 创建一个缓冲区存储 multipart/form-data 请求
-		body := &bytes.Buffer{}
-		writer := multipart.NewWriter(body)
+// UploadTaskOutput 上传一次执行的输出文件，返回服务端的响应内容
+func UploadTaskOutput(filePath string, address string) ([]byte, error) {
+	return uploadFile(filePath, address)
+}
 
-		// 添加文件字段到 multipart 表单
-		part, err := writer.CreateFormFile("file", filepath.Base(file.Name()))
-		if err != nil {
-			return fmt.Errorf("创建 multipart 表单失败: %v", err)
-		}
+// uploadFile 以 multipart 表单的 file 字段上传文件，返回服务端的响应内容
+func uploadFile(filePath string, address string) ([]byte, error) {
+	// 打开文件
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开文件 '%s': %v", filePath, err)
+	}
+	defer file.Close()
 
-		_, err = io.Copy(part, file)
-		if err != nil {
-			return fmt.Errorf("写入文件到 multipart 表单失败: %v", err)
-		}
+	// 创建一个缓冲区存储 multipart/form-data 请求
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
 
-		// 关闭 multipart writer，以完成请求体的构建
-		err = writer.Close()
-		if err != nil {
-			return fmt.Errorf("关闭 multipart writer 失败: %v", err)
-		}
+	// 添加文件字段到 multipart 表单
+	part, err := writer.CreateFormFile("file", filepath.Base(file.Name()))
+	if err != nil {
+		return nil, fmt.Errorf("创建 multipart 表单失败: %v", err)
+	}
 
-		// 发送 POST 请求进行文件上传
-		req, err := http.NewRequest("POST", address, body)
-		if err != nil {
-			return fmt.Errorf("创建 HTTP 请求失败: %v", err)
-		}
+	_, err = io.Copy(part, file)
+	if err != nil {
+		return nil, fmt.Errorf("写入文件到 multipart 表单失败: %v", err)
+	}
 
-		// 设置 Content-Type 为 multipart/form-data
-		req.Header.Set("Content-Type", writer.FormDataContentType())
+	// 关闭 multipart writer，以完成请求体的构建
+	err = writer.Close()
+	if err != nil {
+		return nil, fmt.Errorf("关闭 multipart writer 失败: %v", err)
+	}
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("文件上传任务失败: %v", err)
-		}
-		defer resp.Body.Close()
+	// 发送 POST 请求进行文件上传
+	req, err := http.NewRequest("POST", address, body)
+	if err != nil {
+		return nil, fmt.Errorf("创建 HTTP 请求失败: %v", err)
+	}
 
-		// 读取响应
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("读取服务器响应失败: %v", err)
-		}
+	// 设置 Content-Type 为 multipart/form-data
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	setAgentHeaders(req)
 
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("服务器响应错误: %s, 响应内容: %s", resp.Status, string(respBody))
-		}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("文件上传任务失败: %v", err)
+	}
+	defer resp.Body.Close()
 
-		fmt.Printf("文件 %s 已成功上传到服务器\n", filepath.Base(filePath))
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取服务器响应失败: %v", err)
 	}
 
-	return nil
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("服务器响应错误: %s, 响应内容: %s", resp.Status, string(respBody))
+	}
+	return respBody, nil
 }
@@ -0,0 +1,75 @@
+package monitor
+
+import (
+	"Client/bin"
+	"Client/datetype"
+	"Client/setting"
+	"Client/ws"
+	"go.uber.org/zap"
+	"os"
+	"time"
+)
+
+const defaultInterval = 60 * time.Second
+
+// StartReporter 按配置的间隔采集主机指标，并通过 WebSocket 推送到服务端
+func StartReporter(wsManager *ws.WebSocketManager) {
+	cfg := setting.Conf.Metrics
+	if cfg == nil || !cfg.Enable {
+		zap.L().Info("指标上报未启用")
+		return
+	}
+
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report := Collect()
+		if err := ws.ReportMetrics(wsManager, report); err != nil {
+			zap.L().Error("指标上报失败", zap.Error(err))
+		}
+		<-ticker.C
+	}
+}
+
+// Collect 采集 CPU、内存、磁盘和网络数据，单项采集失败不影响其他项
+func Collect() datetype.MetricsReport {
+	hostName, _ := os.Hostname()
+	report := datetype.MetricsReport{
+		AgentID:   setting.Conf.AgentID,
+		ClientIP:  setting.Conf.ClientIp,
+		HostName:  hostName,
+		Timestamp: time.Now(),
+	}
+
+	if cpuData, err := bin.GetCPUInfo(); err == nil {
+		report.Metrics.CPU = cpuData
+	} else {
+		zap.L().Error("获取 CPU 数据出错", zap.Error(err))
+	}
+
+	if memData, err := bin.GetMemoryData(); err == nil {
+		report.Metrics.Memory = memData
+	} else {
+		zap.L().Error("获取内存数据出错", zap.Error(err))
+	}
+
+	if diskData, err := bin.GetDiskInfo(); err == nil {
+		report.Metrics.Disk = diskData
+	} else {
+		zap.L().Error("获取磁盘数据出错", zap.Error(err))
+	}
+
+	if netData, err := bin.GetNetworkData(); err == nil {
+		report.Metrics.Network = netData
+	} else {
+		zap.L().Error("获取网络数据出错", zap.Error(err))
+	}
+
+	return report
+}
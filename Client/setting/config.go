@@ -29,3 +29,16 @@ func Init(configfile string) (err error) {
 	})
 	return
 }
+
+// TLSEnabled 是否通过 https/wss 连接服务端
+func TLSEnabled() bool {
+	return Conf.TLS != nil && Conf.TLS.Enable
+}
+
+// ServerURL 拼接服务端地址，scheme 为 http 或 ws，启用 TLS 时自动切换为 https/wss
+func ServerURL(scheme, path string) string {
+	if TLSEnabled() {
+		scheme += "s"
+	}
+	return fmt.Sprintf("%s://%s:%d%s", scheme, Conf.ServerConfig.Ip, Conf.ServerConfig.Port, path)
+}
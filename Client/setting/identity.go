@@ -0,0 +1,88 @@
+package setting
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultAgentIDFile    = "conf/agent_id"
+	defaultCredentialFile = "conf/agent_credential"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// LoadAgentID 读取本机的客户端 ID，首次启动时生成 UUID 并写入文件，之后保持不变
+func LoadAgentID(path string) (string, error) {
+	if path == "" {
+		path = defaultAgentIDFile
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		id := strings.ToLower(strings.TrimSpace(string(data)))
+		if uuidPattern.MatchString(id) {
+			return id, nil
+		}
+		return "", fmt.Errorf("客户端 ID 文件 %s 内容无效", path)
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("读取客户端 ID 文件失败: %v", err)
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("创建客户端 ID 目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("写入客户端 ID 文件失败: %v", err)
+	}
+	return id, nil
+}
+
+// newUUID 生成随机的 UUID v4
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("生成客户端 ID 失败: %v", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// LoadCredential 读取注册后保存的长期凭证，文件不存在时返回空字符串
+func LoadCredential(path string) (string, error) {
+	if path == "" {
+		path = defaultCredentialFile
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("读取凭证文件失败: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SaveCredential 保存长期凭证，文件仅当前用户可读
+func SaveCredential(path, credential string) error {
+	if path == "" {
+		path = defaultCredentialFile
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建凭证目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(credential+"\n"), 0o600); err != nil {
+		return fmt.Errorf("写入凭证文件失败: %v", err)
+	}
+	return nil
+}
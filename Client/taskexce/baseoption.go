@@ -2,8 +2,9 @@ package taskexce
 
 import (
 	"Client/datetype"
-	"Client/setting"
 	"Client/ws"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -29,126 +30,166 @@ func (te *TaskExecutor) ExecuteTask(taskID string, scriptPath string) {
 	// 创建日志记录器
 	logger := log.New(f, "", log.LstdFlags)
 
-	// 更新任务状态为 "running"
-	_, err = ws.UpdateTaskStatus(te.wsManager, taskID, "running")
+	// 超过全局并行上限时等待其他任务执行结束
+	release := te.acquireSlot()
+	defer func() { release() }()
+
+	// 向服务端登记本次执行，服务端拒绝（如任务已取消）时不执行
+	startTime := time.Now()
+	runID, err := ws.StartRun(te.wsManager, taskID, startTime)
 	if err != nil {
-		logger.Printf("更新任务 %s 的状态为 'running' 失败: %v\n", taskID, err)
+		logger.Printf("登记任务 %s 的执行失败，跳过本次执行: %v\n", taskID, err)
 		return
 	}
 
 	// 记录任务开始执行
-	logger.Printf("开始执行任务 %s，脚本路径: %s\n", taskID, scriptPath)
+	logger.Printf("开始执行任务 %s，执行 ID: %s，脚本路径: %s\n", taskID, runID, scriptPath)
+
+	// 登记执行中的实例，收到 kill 指令时终止
+	opts := te.taskOptions(taskID)
+	ctx, rt := te.trackRun(taskID)
+	defer te.untrackRun(taskID, rt)
+
+	// 执行过程中的输出实时上报到服务端
+	out := newOutputStream(te.wsManager, runID, taskID)
+
+	// 按重试策略执行，每次尝试单独记录
+	var attempts []*execResult
+	for attempt := 1; ; attempt++ {
+		out.setAttempt(attempt)
+		res := te.runAttempt(ctx, rt, taskID, scriptPath, opts, logger, out)
+		attempts = append(attempts, res)
+		logger.Printf("任务 %s 第 %d 次尝试 退出码: %d，信号: %s，标准输出 %d 字节，标准错误 %d 字节，超时: %t，终止: %t\n",
+			taskID, attempt, res.ExitCode, res.Signal, res.StdoutBytes, res.StderrBytes, res.TimedOut, res.Cancelled)
+		if attempt > opts.MaxRetries || !shouldRetry(res, opts) {
+			break
+		}
+		delay := retryDelay(opts.RetryBackoff, attempt)
+		logger.Printf("任务 %s 第 %d 次尝试失败，%s 后重试\n", taskID, attempt, delay)
+		var ok bool
+		if release, ok = te.waitRetry(ctx, release, delay); !ok {
+			logger.Printf("任务 %s 在等待重试时被终止\n", taskID)
+			break
+		}
+	}
+
+	// 输出全部上报后再上报执行结果，服务端在执行结束时关闭实时查看的连接
+	out.Close()
+
+	// 上报执行结果，等待重试时被终止的执行记为 cancelled
+	result := runFinish(runID, taskID, attempts)
+	if rt.killed.Load() && result.Status != ws.TaskCancelled {
+		result.Status, result.Error = ws.TaskCancelled, "等待重试时被终止"
+	}
+	if err := ws.FinishRun(te.wsManager, result); err != nil {
+		logger.Printf("上报任务 %s 的执行结果失败: %v\n", taskID, err)
+	}
+
+	// 上传完整的输出，服务端保存后关联到执行记录
+	if _, err := ws.UploadRunOutput(taskID, runID, logFile); err != nil {
+		logger.Printf("上传任务 %s 的输出失败: %v\n", taskID, err)
+	}
+
+	// 记录任务完成
+	logger.Printf("任务 %s 执行完成\n", taskID)
+}
+
+// runAttempt 执行一次脚本，超过任务的执行超时时终止
+func (te *TaskExecutor) runAttempt(ctx context.Context, rt *runningTask, taskID, scriptPath string, opts datetype.TaskOptions,
+	logger *log.Logger, out *outputStream) *execResult {
+	timeout := time.Duration(opts.Timeout) * time.Second
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	// 判断操作系统类型并执行任务
+	var res *execResult
 	switch runtime.GOOS {
 	case "windows":
 		logger.Printf("在 Windows 平台上执行任务 %s，脚本路径: %s\n", taskID, scriptPath)
-		te.executeWindowsScript(taskID, scriptPath, logger)
+		res = te.executeWindowsScript(ctx, taskID, scriptPath, logger, out)
 	case "linux":
 		logger.Printf("在 Linux 平台上执行任务 %s，脚本路径: %s\n", taskID, scriptPath)
-		te.executeLinuxScript(taskID, scriptPath, logger)
+		res = te.executeLinuxScript(ctx, taskID, scriptPath, logger, out)
 	default:
-		logger.Printf("不支持的操作系统: %s\n", runtime.GOOS)
-		return
-	}
-
-	// 更新任务状态为 "completed"
-	_, err = ws.UpdateTaskStatus(te.wsManager, taskID, "completed")
-	if err != nil {
-		logger.Printf("更新任务 %s 的状态为 'completed' 失败: %v\n", taskID, err)
-		return
+		res = failedResult(time.Now(), fmt.Errorf("不支持的操作系统: %s", runtime.GOOS))
+		logger.Println(res.Err)
 	}
-
-	// 创建任务日志结构体，将日志文件名作为 Output 字段
-	taskLog := datetype.ClientTaskLog{
-		ClientIP:       setting.Conf.ClientIp,
-		TaskID:         taskID,
-		ExecutionTime:  time.Now(),
-		Output:         logFile, // 将日志文件的路径作为任务输出
-		CompletionTime: time.Now(),
-		Remarks:        "任务执行日志",
+	if rt.killed.Load() {
+		res.Cancelled, res.TimedOut = true, false
+		res.Err = fmt.Errorf("执行被终止")
+	} else if res.TimedOut {
+		res.Err = fmt.Errorf("执行超过 %s 被终止", timeout)
 	}
-
-	// 发送日志到服务端
-	_, err = ws.TaskLogPut(te.wsManager, taskLog)
-	if err != nil {
-		logger.Printf("发送任务 %s 的日志到服务端失败: %v\n", taskID, err)
-	}
-
-	// 记录任务完成
-	logger.Printf("任务 %s 执行完成\n", taskID)
+	return res
 }
 
 // executeWindowsScript 执行 Windows 平台上的脚本
-func (te *TaskExecutor) executeWindowsScript(taskID string, scriptPath string, logger *log.Logger) {
+func (te *TaskExecutor) executeWindowsScript(ctx context.Context, taskID string, scriptPath string, logger *log.Logger,
+	out *outputStream) *execResult {
 	fmt.Printf("正在 Windows 平台上执行任务 %s，脚本路径: %s\n", taskID, scriptPath)
 
 	if strings.HasSuffix(scriptPath, ".bat") || strings.HasSuffix(scriptPath, ".cmd") {
-		cmd := exec.Command("cmd", "/C", scriptPath)
-		runScript(cmd, logger)
+		return runScript(ctx, exec.CommandContext(ctx, "cmd", "/C", scriptPath), logger, out)
 	} else if strings.HasSuffix(scriptPath, ".ps1") {
-		cmd := exec.Command("powershell", "-ExecutionPolicy", "Bypass", "-File", scriptPath)
-		runScript(cmd, logger)
+		return runScript(ctx, exec.CommandContext(ctx, "powershell", "-ExecutionPolicy", "Bypass", "-File", scriptPath), logger, out)
 	} else if strings.HasSuffix(scriptPath, ".py") {
-		cmd := exec.Command("python", scriptPath)
-		runScript(cmd, logger)
+		return runScript(ctx, exec.CommandContext(ctx, "python", scriptPath), logger, out)
 	} else if strings.HasSuffix(scriptPath, ".java") {
-		cmd := exec.Command("java", scriptPath)
-		runScript(cmd, logger)
-	} else {
-		logger.Println("未知的 Windows 脚本类型")
+		return runScript(ctx, exec.CommandContext(ctx, "java", scriptPath), logger, out)
 	}
+	logger.Println("未知的 Windows 脚本类型")
+	return failedResult(time.Now(), fmt.Errorf("未知的 Windows 脚本类型: %s", scriptPath))
 }
 
 // executeLinuxScript 执行 Linux 平台上的脚本
-func (te *TaskExecutor) executeLinuxScript(taskID string, scriptPath string, logger *log.Logger) {
+func (te *TaskExecutor) executeLinuxScript(ctx context.Context, taskID string, scriptPath string, logger *log.Logger,
+	out *outputStream) *execResult {
 	fmt.Printf("正在 Linux 平台上执行任务 %s，脚本路径: %s\n", taskID, scriptPath)
 
 	if strings.HasSuffix(scriptPath, ".sh") {
-		cmd := exec.Command("/bin/sh", scriptPath)
-		runScript(cmd, logger)
+		return runScript(ctx, exec.CommandContext(ctx, "/bin/sh", scriptPath), logger, out)
 	} else if strings.HasSuffix(scriptPath, ".py") {
-		cmd := exec.Command("python", scriptPath)
-		runScript(cmd, logger)
+		return runScript(ctx, exec.CommandContext(ctx, "python", scriptPath), logger, out)
 	} else if strings.HasSuffix(scriptPath, ".java") {
-		cmd := exec.Command("java", scriptPath)
-		runScript(cmd, logger)
-	} else {
-		logger.Println("未知的 Linux 脚本类型")
+		return runScript(ctx, exec.CommandContext(ctx, "java", scriptPath), logger, out)
 	}
+	logger.Println("未知的 Linux 脚本类型")
+	return failedResult(time.Now(), fmt.Errorf("未知的 Linux 脚本类型: %s", scriptPath))
 }
 
-// runScript 运行脚本并捕获输出到日志文件
-func runScript(cmd *exec.Cmd, logger *log.Logger) {
-	// 创建管道捕获输出
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		logger.Printf("无法获取标准输出: %v\n", err)
-		return
-	}
+// runScript 运行脚本，输出写入日志文件和实时输出流并统计字节数，返回退出码、终止信号和起止时间。
+// ctx 超时或取消时结束脚本所在的整个进程组
+func runScript(ctx context.Context, cmd *exec.Cmd, logger *log.Logger, out *outputStream) *execResult {
+	setProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		logger.Printf("无法获取标准错误输出: %v\n", err)
-		return
-	}
+	var stdout, stderr countingWriter
+	stdoutLines, stderrLines := out.writer(ws.StreamStdout), out.writer(ws.StreamStderr)
+	defer stdoutLines.Flush()
+	defer stderrLines.Flush()
+	cmd.Stdout = io.MultiWriter(logger.Writer(), &stdout, stdoutLines)
+	cmd.Stderr = io.MultiWriter(logger.Writer(), &stderr, stderrLines)
 
-	// 启动命令执行
+	res := &execResult{StartTime: time.Now()}
 	if err := cmd.Start(); err != nil {
 		logger.Printf("命令启动失败: %v\n", err)
-		return
+		return failedResult(res.StartTime, err)
 	}
 
-	// 将标准输出和错误输出写入日志
-	go io.Copy(logger.Writer(), stdout)
-	go io.Copy(logger.Writer(), stderr)
-
-	// 等待命令完成
-	if err := cmd.Wait(); err != nil {
-		logger.Printf("命令执行失败: %v\n", err)
-	} else {
-		logger.Println("命令执行成功")
+	// Wait 会等待输出全部写入后返回
+	res.Err = cmd.Wait()
+	res.collect(cmd)
+	res.StdoutBytes, res.StderrBytes = stdout.Count(), stderr.Count()
+	res.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if res.Err != nil {
+		logger.Printf("命令执行失败: %v\n", res.Err)
+		return res
 	}
+	logger.Println("命令执行成功")
+	return res
 }
 
 func (te *TaskExecutor) getLogFilePath(taskID string) string {
@@ -0,0 +1,83 @@
+package taskexce
+
+import (
+	"Client/ws"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// concurrencyWrapper 按任务的并发策略包装定时任务，决定上一次执行未结束时如何处理新的触发
+func (te *TaskExecutor) concurrencyWrapper(taskID, policy string) cron.JobWrapper {
+	switch policy {
+	case ws.ConcurrencySkip:
+		return te.skipIfRunning(taskID)
+	case ws.ConcurrencyQueue:
+		return te.queueOne(taskID)
+	case ws.ConcurrencyReplace:
+		return te.replaceRunning(taskID)
+	}
+	return func(j cron.Job) cron.Job { return j }
+}
+
+// skipIfRunning 上一次执行未结束时跳过本次触发
+func (te *TaskExecutor) skipIfRunning(taskID string) cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		token := make(chan struct{}, 1)
+		token <- struct{}{}
+		return cron.FuncJob(func() {
+			select {
+			case t := <-token:
+				defer func() { token <- t }()
+				j.Run()
+			default:
+				te.reportSkipped(taskID, "上一次执行尚未结束，跳过本次触发")
+			}
+		})
+	}
+}
+
+// queueOne 上一次执行未结束时排队等待，已有排队的触发时跳过
+func (te *TaskExecutor) queueOne(taskID string) cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		var mu sync.Mutex
+		waiting := make(chan struct{}, 1)
+		return cron.FuncJob(func() {
+			select {
+			case waiting <- struct{}{}:
+			default:
+				te.reportSkipped(taskID, "已有一次触发在排队，跳过本次触发")
+				return
+			}
+			mu.Lock()
+			<-waiting
+			defer mu.Unlock()
+			j.Run()
+		})
+	}
+}
+
+// replaceRunning 终止上一次执行后再执行，被终止的执行上报为 cancelled
+func (te *TaskExecutor) replaceRunning(taskID string) cron.JobWrapper {
+	return func(j cron.Job) cron.Job {
+		var mu sync.Mutex
+		return cron.FuncJob(func() {
+			if n := te.killRunning(taskID); n > 0 {
+				log.Printf("任务 %s 再次触发，终止 %d 个执行中的实例", taskID, n)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			j.Run()
+		})
+	}
+}
+
+// reportSkipped 向服务端上报跳过的触发
+func (te *TaskExecutor) reportSkipped(taskID, reason string) {
+	log.Printf("任务 %s: %s", taskID, reason)
+	if err := ws.SkipRun(te.wsManager, taskID, time.Now(), reason); err != nil {
+		log.Printf("上报任务 %s 跳过的触发失败: %v", taskID, err)
+	}
+}
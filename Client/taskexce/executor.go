@@ -1,31 +1,55 @@
 package taskexce
 
 import (
+	"Client/datetype"
 	"Client/mode"
 	"Client/setting"
 	"Client/taskmanager"
 	"Client/ws"
+	"context"
 	"fmt"
 	"github.com/robfig/cron/v3"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // TaskExecutor 任务执行器结构体，包含本地的任务管理器和定时调度器
 // TaskExecutor 用于管理任务执行和调度
 type TaskExecutor struct {
-	TM            *taskmanager.TaskManager // 任务管理器
-	CronScheduler *cron.Cron               // 定时任务调度器
-	taskEntryMap  map[string]cron.EntryID  // 记录 taskID 和 entryID 的映射关系
+	TM            *taskmanager.TaskManager        // 任务管理器
+	CronScheduler *cron.Cron                      // 定时任务调度器
+	taskEntryMap  map[string]cron.EntryID         // 记录 taskID 和 entryID 的映射关系
+	options       map[string]datetype.TaskOptions // 任务的执行选项
+	running       map[string]map[*runningTask]struct{}
+	mu            sync.Mutex    // 保护以上 map，服务端指令在独立协程中处理
+	slots         chan struct{} // 全局并行执行数限制，为 nil 时不限制
 	wsManager     *ws.WebSocketManager
 }
 
+// runningTask 执行中的任务实例
+type runningTask struct {
+	cancel context.CancelFunc
+	killed atomic.Bool // 由 kill 指令或停止任务终止，而非超时
+}
+
+// 终止脚本后等待其输出管道关闭的最长时间
+const waitDelay = 5 * time.Second
+
 // InitExecutor 初始化任务执行器
 func InitExecutor(tm *taskmanager.TaskManager, wsManager *ws.WebSocketManager) *TaskExecutor {
 	te := &TaskExecutor{
 		TM:            tm,
 		CronScheduler: cron.New(cron.WithSeconds()), // 初始化 Cron 支持秒级调度
+		taskEntryMap:  make(map[string]cron.EntryID),
+		options:       make(map[string]datetype.TaskOptions),
+		running:       make(map[string]map[*runningTask]struct{}),
 		wsManager:     wsManager,
 	}
+	if setting.Conf.MaxParallel > 0 {
+		te.slots = make(chan struct{}, setting.Conf.MaxParallel)
+	}
 
 	te.CronScheduler.Start() // 启动调度器
 
@@ -34,7 +58,6 @@ func InitExecutor(tm *taskmanager.TaskManager, wsManager *ws.WebSocketManager) *
 
 // Start 函数调度任务并记录任务的 entryID
 func (te *TaskExecutor) Start() {
-	te.taskEntryMap = make(map[string]cron.EntryID)
 
 	var activeTaskIDs []string
 	var fileIDs []string
@@ -95,17 +118,12 @@ func (te *TaskExecutor) Start() {
 
 		fmt.Printf("任务 %s 的脚本路径为 %s，开始调度\n", currentTaskID, currentScriptPath)
 
-		taskFunc := func() {
-			te.ExecuteTask(currentTaskID, currentScriptPath)
-		}
-
-		entryID, err := te.CronScheduler.AddFunc(crondExpression, taskFunc)
+		entryID, err := te.schedule(currentTaskID, crondExpression, currentScriptPath, ws.ParseTaskOptions(taskInfo))
 		if err != nil {
-			log.Printf("无法为任务 %s 添加调度: %v", currentTaskID, err)
+			log.Println(err)
 			continue
 		}
-
-		te.taskEntryMap[currentTaskID] = entryID
+		te.reportScheduled(currentTaskID)
 
 		te.CronScheduler.Start()
 
@@ -119,7 +137,7 @@ func (te *TaskExecutor) Start() {
 	}
 
 	// 下载文件
-	downloadAddress := fmt.Sprintf("http://%s:%d%s", setting.Conf.ServerConfig.Ip, setting.Conf.ServerConfig.Port, setting.Conf.ServerConfig.DownloadApi)
+	downloadAddress := setting.ServerURL("http", setting.Conf.ServerConfig.DownloadApi)
 
 	err = mode.DownloadFile(fileIDs, downloadAddress, setting.Conf.WorkDir)
 	if err != nil {
@@ -128,15 +146,21 @@ func (te *TaskExecutor) Start() {
 	}
 }
 
-// StopTask 通过 taskID 来关闭任务
+// StopTask 通过 taskID 来关闭任务，并终止执行中的实例
 func (te *TaskExecutor) StopTask(taskID string) error {
+	te.mu.Lock()
 	entryID, exists := te.taskEntryMap[taskID]
-	if !exists {
+	if exists {
+		te.CronScheduler.Remove(entryID)
+		delete(te.taskEntryMap, taskID)
+		delete(te.options, taskID)
+	}
+	te.mu.Unlock()
+	killed := te.killRunning(taskID)
+	if !exists && killed == 0 {
 		return fmt.Errorf("任务 %s 不存在或没有调度", taskID)
 	}
-
-	te.CronScheduler.Remove(entryID)
-	delete(te.taskEntryMap, taskID)
+	_ = te.TM.StopTask(taskID)
 
 	fmt.Printf("任务 %s 已被关闭\n", taskID)
 	return nil
@@ -147,27 +171,157 @@ func (te *TaskExecutor) Stop() {
 	te.CronScheduler.Stop() // 停止任务调度器
 }
 
-// AddTask 动态添加任务并调度
-func (te *TaskExecutor) AddTask(taskID string, crondExpression string, scriptPath string) error {
+// KillTask 终止任务执行中的实例，任务仍保持调度，被终止的执行上报为 cancelled
+func (te *TaskExecutor) KillTask(taskID string) error {
+	if te.killRunning(taskID) == 0 {
+		return fmt.Errorf("任务 %s 没有执行中的实例", taskID)
+	}
+	return nil
+}
+
+// AddTask 动态添加任务并调度。服务端下发的指令不含定时表达式和脚本路径时，向服务端查询任务详情并下载任务文件，
+// 调度成功后上报 scheduled
+func (te *TaskExecutor) AddTask(taskID string, crondExpression string, scriptPath string, opts datetype.TaskOptions) error {
 	// 检查任务是否已经存在
-	if _, exists := te.taskEntryMap[taskID]; exists {
+	te.mu.Lock()
+	_, exists := te.taskEntryMap[taskID]
+	te.mu.Unlock()
+	if exists {
 		return fmt.Errorf("任务 %s 已存在，无法重复添加", taskID)
 	}
 
-	// 定义任务执行函数
-	taskFunc := func() {
+	if crondExpression == "" || scriptPath == "" {
+		var err error
+		crondExpression, scriptPath, opts, err = te.fetchTask(taskID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := te.schedule(taskID, crondExpression, scriptPath, opts); err != nil {
+		return err
+	}
+	if err := te.TM.AddTask(taskID, crondExpression, scriptPath, opts); err != nil {
+		log.Printf("记录任务 %s 失败: %v", taskID, err)
+	}
+	te.reportScheduled(taskID)
+
+	fmt.Printf("任务 %s 已成功添加，crond 表达式: %s\n", taskID, crondExpression)
+	return nil
+}
+
+// schedule 按并发策略包装任务后加入调度，记录 entryID 和执行选项
+func (te *TaskExecutor) schedule(taskID, crondExpression, scriptPath string, opts datetype.TaskOptions) (cron.EntryID, error) {
+	job := cron.NewChain(te.concurrencyWrapper(taskID, opts.ConcurrencyPolicy)).Then(cron.FuncJob(func() {
 		te.ExecuteTask(taskID, scriptPath)
+	}))
+
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	if _, exists := te.taskEntryMap[taskID]; exists {
+		return 0, fmt.Errorf("任务 %s 已存在，无法重复添加", taskID)
+	}
+	entryID, err := te.CronScheduler.AddJob(crondExpression, job)
+	if err != nil {
+		return 0, fmt.Errorf("无法为任务 %s 添加调度: %v", taskID, err)
 	}
+	te.taskEntryMap[taskID] = entryID
+	te.options[taskID] = opts
+	return entryID, nil
+}
 
-	// 添加调度任务
-	entryID, err := te.CronScheduler.AddFunc(crondExpression, taskFunc)
+// fetchTask 查询任务的定时表达式、脚本路径和执行选项，并下载任务文件
+func (te *TaskExecutor) fetchTask(taskID string) (string, string, datetype.TaskOptions, error) {
+	var opts datetype.TaskOptions
+	taskDetails, err := ws.TaskInfoGet(te.wsManager, []string{taskID})
 	if err != nil {
-		return fmt.Errorf("无法为任务 %s 添加调度: %v", taskID, err)
+		return "", "", opts, fmt.Errorf("获取任务 %s 详情失败: %v", taskID, err)
+	}
+	taskInfo, ok := taskDetails[taskID].(map[string]interface{})
+	if !ok {
+		return "", "", opts, fmt.Errorf("任务 %s 的详情不存在", taskID)
+	}
+	crondExpression, _ := taskInfo["crond_expression"].(string)
+	scriptPath, _ := taskInfo["script_path"].(string)
+	if crondExpression == "" || scriptPath == "" {
+		return "", "", opts, fmt.Errorf("任务 %s 缺少 crond_expression 或 script_path", taskID)
 	}
+	if fileID, ok := taskInfo["file_id"].(float64); ok {
+		downloadAddress := setting.ServerURL("http", setting.Conf.ServerConfig.DownloadApi)
+		if err := mode.DownloadFile([]string{fmt.Sprintf("%.0f", fileID)}, downloadAddress, setting.Conf.WorkDir); err != nil {
+			return "", "", opts, fmt.Errorf("下载任务 %s 的文件失败: %v", taskID, err)
+		}
+	}
+	return crondExpression, scriptPath, ws.ParseTaskOptions(taskInfo), nil
+}
 
-	// 记录 entryID
-	te.taskEntryMap[taskID] = entryID
+// taskOptions 返回任务的执行选项
+func (te *TaskExecutor) taskOptions(taskID string) datetype.TaskOptions {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	return te.options[taskID]
+}
 
-	fmt.Printf("任务 %s 已成功添加，crond 表达式: %s\n", taskID, crondExpression)
-	return nil
+// acquireSlot 等待全局并行执行数的空位，返回释放函数
+func (te *TaskExecutor) acquireSlot() func() {
+	if te.slots == nil {
+		return func() {}
+	}
+	te.slots <- struct{}{}
+	return func() { <-te.slots }
+}
+
+// acquireSlotContext 与 acquireSlot 相同，ctx 取消时放弃等待并返回 false
+func (te *TaskExecutor) acquireSlotContext(ctx context.Context) (func(), bool) {
+	if te.slots == nil {
+		return func() {}, true
+	}
+	select {
+	case te.slots <- struct{}{}:
+		return func() { <-te.slots }, true
+	case <-ctx.Done():
+		return func() {}, false
+	}
+}
+
+// trackRun 登记一个执行中的实例，返回的 ctx 在实例被终止时取消
+func (te *TaskExecutor) trackRun(taskID string) (context.Context, *runningTask) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rt := &runningTask{cancel: cancel}
+	te.mu.Lock()
+	if te.running[taskID] == nil {
+		te.running[taskID] = make(map[*runningTask]struct{})
+	}
+	te.running[taskID][rt] = struct{}{}
+	te.mu.Unlock()
+	return ctx, rt
+}
+
+// untrackRun 执行结束后移除登记并释放 ctx
+func (te *TaskExecutor) untrackRun(taskID string, rt *runningTask) {
+	te.mu.Lock()
+	delete(te.running[taskID], rt)
+	if len(te.running[taskID]) == 0 {
+		delete(te.running, taskID)
+	}
+	te.mu.Unlock()
+	rt.cancel()
+}
+
+// killRunning 终止任务所有执行中的实例，返回终止的数量
+func (te *TaskExecutor) killRunning(taskID string) int {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	for rt := range te.running[taskID] {
+		rt.killed.Store(true)
+		rt.cancel()
+	}
+	return len(te.running[taskID])
+}
+
+// reportScheduled 向服务端上报任务已调度
+func (te *TaskExecutor) reportScheduled(taskID string) {
+	if _, err := ws.UpdateTaskStatus(te.wsManager, taskID, ws.TaskScheduled); err != nil {
+		log.Printf("上报任务 %s 的调度状态失败: %v", taskID, err)
+	}
 }
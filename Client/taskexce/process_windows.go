@@ -0,0 +1,21 @@
+//go:build windows
+
+package taskexce
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup 终止时用 taskkill 结束整个进程树
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+}
+
+// exitSignal Windows 上进程不会被信号终止
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
@@ -0,0 +1,108 @@
+package taskexce
+
+import (
+	"Client/datetype"
+	"Client/ws"
+	"os/exec"
+	"sync/atomic"
+	"time"
+)
+
+// execResult 一次脚本执行的结果
+type execResult struct {
+	StartTime   time.Time
+	EndTime     time.Time
+	ExitCode    int    // 进程未启动或被信号终止时为 -1
+	Signal      string // 被信号终止时的信号名
+	StdoutBytes int64
+	StderrBytes int64
+	TimedOut    bool
+	Cancelled   bool // 被 kill 指令或停止任务终止
+	Err         error
+}
+
+// failedResult 脚本未能启动时的结果
+func failedResult(start time.Time, err error) *execResult {
+	return &execResult{StartTime: start, EndTime: time.Now(), ExitCode: -1, Err: err}
+}
+
+// collect 从已结束的进程中读取退出码和终止信号
+func (r *execResult) collect(cmd *exec.Cmd) {
+	r.EndTime = time.Now()
+	r.ExitCode = -1
+	if cmd.ProcessState == nil {
+		return
+	}
+	r.ExitCode = cmd.ProcessState.ExitCode()
+	r.Signal = exitSignal(cmd.ProcessState)
+}
+
+// status 按执行结果得出执行结束状态
+func (r *execResult) status() string {
+	switch {
+	case r.Cancelled:
+		return ws.TaskCancelled
+	case r.TimedOut:
+		return ws.TaskTimedOut
+	case r.Err != nil || r.ExitCode != 0 || r.Signal != "":
+		return ws.TaskFailed
+	}
+	return ws.TaskSucceeded
+}
+
+// attempt 生成上报服务端的单次尝试记录
+func (r *execResult) attempt() datetype.RunAttempt {
+	a := datetype.RunAttempt{
+		Status:      r.status(),
+		StartTime:   r.StartTime,
+		EndTime:     r.EndTime,
+		Signal:      r.Signal,
+		StdoutBytes: r.StdoutBytes,
+		StderrBytes: r.StderrBytes,
+		TimedOut:    r.TimedOut,
+	}
+	if r.ExitCode >= 0 {
+		exitCode := r.ExitCode
+		a.ExitCode = &exitCode
+	}
+	if r.Err != nil {
+		a.Error = r.Err.Error()
+	}
+	return a
+}
+
+// runFinish 生成上报服务端的执行结果，最后一次尝试为最终结果，开始时间取第一次尝试
+func runFinish(runID, taskID string, attempts []*execResult) datetype.RunFinish {
+	last := attempts[len(attempts)-1].attempt()
+	result := datetype.RunFinish{
+		RunId:       runID,
+		TaskId:      taskID,
+		Status:      last.Status,
+		ExitCode:    last.ExitCode,
+		Signal:      last.Signal,
+		StdoutBytes: last.StdoutBytes,
+		StderrBytes: last.StderrBytes,
+		TimedOut:    last.TimedOut,
+		StartTime:   attempts[0].StartTime,
+		EndTime:     last.EndTime,
+		Error:       last.Error,
+	}
+	for _, r := range attempts {
+		result.Attempts = append(result.Attempts, r.attempt())
+	}
+	return result
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	atomic.AddInt64(&w.n, int64(len(p)))
+	return len(p), nil
+}
+
+func (w *countingWriter) Count() int64 {
+	return atomic.LoadInt64(&w.n)
+}
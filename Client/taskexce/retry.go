@@ -0,0 +1,79 @@
+package taskexce
+
+import (
+	"Client/datetype"
+	"Client/ws"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 重试等待时间的上限
+const maxRetryDelay = time.Hour
+
+// shouldRetry 判断失败的尝试是否需要重试。被终止的执行不重试；
+// 配置了 retry_on 时只重试退出码在列表中的失败
+func shouldRetry(res *execResult, opts datetype.TaskOptions) bool {
+	if res.Cancelled || res.status() == ws.TaskSucceeded {
+		return false
+	}
+	codes := parseExitCodes(opts.RetryOn)
+	if len(codes) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if res.ExitCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExitCodes 解析逗号分隔的退出码，忽略无效项
+func parseExitCodes(s string) []int {
+	var codes []int
+	for _, part := range strings.Split(s, ",") {
+		if code, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// retryDelay 第 attempt 次尝试失败后的等待时间，从 base 秒开始每次翻倍
+func retryDelay(base, attempt int) time.Duration {
+	delay := time.Duration(base) * time.Second
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// waitRetry 等待 delay 后重试。等待期间释放并行名额，避免空闲的任务占用名额，下次尝试前重新获取；
+// 返回新的释放函数，ctx 取消时返回 false，此时已不再持有名额
+func (te *TaskExecutor) waitRetry(ctx context.Context, release func(), delay time.Duration) (func(), bool) {
+	release()
+	if !sleepContext(ctx, delay) {
+		return func() {}, false
+	}
+	return te.acquireSlotContext(ctx)
+}
+
+// sleepContext 等待 d，ctx 取消时提前返回 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
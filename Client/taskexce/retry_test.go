@@ -0,0 +1,120 @@
+package taskexce
+
+import (
+	"Client/datetype"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		base, attempt int
+		want          time.Duration
+	}{
+		{0, 1, 0},
+		{1, 1, time.Second},
+		{1, 2, 2 * time.Second},
+		{1, 3, 4 * time.Second},
+		{5, 4, 40 * time.Second},
+		// 翻倍到上限后不再增长
+		{60, 7, maxRetryDelay},
+		{1, 100, maxRetryDelay},
+		{7200, 1, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.base, tt.attempt); got != tt.want {
+			t.Errorf("retryDelay(%d, %d) = %s, want %s", tt.base, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestParseExitCodes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"1", []int{1}},
+		{"1, 2,137", []int{1, 2, 137}},
+		{"abc", nil},
+		{"1,x,3,", []int{1, 3}},
+	}
+	for _, tt := range tests {
+		if got := parseExitCodes(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseExitCodes(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		res     execResult
+		retryOn string
+		want    bool
+	}{
+		{"succeeded", execResult{ExitCode: 0}, "", false},
+		{"failed without retry_on", execResult{ExitCode: 1}, "", true},
+		{"invalid retry_on retries any failure", execResult{ExitCode: 1}, "abc", true},
+		{"matching exit code", execResult{ExitCode: 2}, "1,2", true},
+		{"non-matching exit code", execResult{ExitCode: 3}, "1,2", false},
+		{"timed out", execResult{ExitCode: -1, TimedOut: true}, "", true},
+		{"cancelled", execResult{ExitCode: -1, Cancelled: true}, "", false},
+	}
+	for _, tt := range tests {
+		if got := shouldRetry(&tt.res, datetype.TaskOptions{RetryOn: tt.retryOn}); got != tt.want {
+			t.Errorf("%s: shouldRetry() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 等待重试期间释放并行名额，其他任务可以执行；等待结束后重新获取名额
+func TestWaitRetryReleasesSlot(t *testing.T) {
+	te := &TaskExecutor{slots: make(chan struct{}, 1)}
+	release := te.acquireSlot()
+
+	done := make(chan bool)
+	go func() {
+		next, ok := te.waitRetry(context.Background(), release, 200*time.Millisecond)
+		if ok {
+			next()
+		}
+		done <- ok
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	other, ok := te.acquireSlotContext(ctx)
+	if !ok {
+		t.Fatal("slot was not released while waiting to retry")
+	}
+	select {
+	case <-done:
+		t.Fatal("retry reacquired the slot while another task held it")
+	case <-time.After(300 * time.Millisecond):
+	}
+	other()
+	if !<-done {
+		t.Fatal("waitRetry() = false, want true")
+	}
+	if len(te.slots) != 0 {
+		t.Fatalf("slots in use = %d, want 0", len(te.slots))
+	}
+}
+
+func TestWaitRetryCancelled(t *testing.T) {
+	te := &TaskExecutor{slots: make(chan struct{}, 1)}
+	release := te.acquireSlot()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := te.waitRetry(ctx, release, time.Hour); ok {
+		t.Fatal("waitRetry() = true after cancel, want false")
+	}
+	if len(te.slots) != 0 {
+		t.Fatalf("slots in use = %d after cancel, want 0", len(te.slots))
+	}
+}
@@ -0,0 +1,189 @@
+package taskexce
+
+import (
+	"Client/datetype"
+	"Client/ws"
+	"bytes"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// 输出的上报间隔
+	outputFlushInterval = time.Second
+	// 缓存达到该行数时立即上报，与服务端单批上限一致
+	outputBatchLines = 500
+	// 上报失败时最多缓存的行数，超出后丢弃最早的行
+	outputBufferLines = 10000
+	// 单行的最大字节数，超出部分作为新的一行
+	outputMaxLine = 4096
+)
+
+// outputStream 将执行输出按行编号后分批上报到服务端，供用户实时查看
+type outputStream struct {
+	wsManager *ws.WebSocketManager
+	runID     string
+	taskID    string
+
+	mu      sync.Mutex
+	seq     int64
+	attempt int
+	pending []datetype.OutputLine
+	dropped int64
+
+	kick chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newOutputStream 创建输出流并启动后台上报
+func newOutputStream(wsManager *ws.WebSocketManager, runID, taskID string) *outputStream {
+	s := &outputStream{
+		wsManager: wsManager,
+		runID:     runID,
+		taskID:    taskID,
+		attempt:   1,
+		kick:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+// setAttempt 设置后续输出所属的尝试序号
+func (s *outputStream) setAttempt(attempt int) {
+	s.mu.Lock()
+	s.attempt = attempt
+	s.mu.Unlock()
+}
+
+// writer 返回写入指定来源的按行切分的 Writer
+func (s *outputStream) writer(stream string) *lineWriter {
+	return &lineWriter{s: s, stream: stream}
+}
+
+// append 为一行输出编号并加入待上报缓存
+func (s *outputStream) append(stream, line string) {
+	s.mu.Lock()
+	s.seq++
+	s.pending = append(s.pending, datetype.OutputLine{
+		Seq:     s.seq,
+		Attempt: s.attempt,
+		Stream:  stream,
+		Line:    line,
+		Time:    time.Now(),
+	})
+	if n := len(s.pending) - outputBufferLines; n > 0 {
+		s.pending = append(s.pending[:0], s.pending[n:]...)
+		s.dropped += int64(n)
+	}
+	full := len(s.pending) >= outputBatchLines
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *outputStream) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(outputFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.kick:
+		case <-s.done:
+			s.flush()
+			return
+		}
+		s.flush()
+	}
+}
+
+// flush 分批上报缓存的输出，上报失败时保留在缓存中等待下次重发
+func (s *outputStream) flush() {
+	for {
+		s.mu.Lock()
+		n := len(s.pending)
+		if n > outputBatchLines {
+			n = outputBatchLines
+		}
+		batch := make([]datetype.OutputLine, n)
+		copy(batch, s.pending)
+		s.mu.Unlock()
+		if n == 0 {
+			return
+		}
+
+		err := ws.SendTaskOutput(s.wsManager, datetype.TaskOutput{RunId: s.runID, TaskId: s.taskID, Lines: batch})
+		if err != nil {
+			log.Printf("上报任务 %s 的输出失败: %v", s.taskID, err)
+			return
+		}
+
+		// 上报期间缓存可能因超出上限丢弃了最早的行，只移除仍在缓存中的已上报行
+		s.mu.Lock()
+		last := batch[n-1].Seq
+		i := 0
+		for i < len(s.pending) && s.pending[i].Seq <= last {
+			i++
+		}
+		s.pending = append(s.pending[:0], s.pending[i:]...)
+		s.mu.Unlock()
+	}
+}
+
+// Close 停止后台上报并上报剩余的输出
+func (s *outputStream) Close() {
+	close(s.done)
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dropped > 0 {
+		log.Printf("任务 %s 的输出上报失败，丢弃了 %d 行", s.taskID, s.dropped)
+	}
+}
+
+// lineWriter 将写入的数据按行切分后加入输出流，未以换行结尾的部分在 Flush 时加入
+type lineWriter struct {
+	s      *outputStream
+	stream string
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.buf = append(w.buf, p...)
+			for len(w.buf) >= outputMaxLine {
+				w.s.append(w.stream, string(w.buf[:outputMaxLine]))
+				w.buf = append(w.buf[:0], w.buf[outputMaxLine:]...)
+			}
+			break
+		}
+		line := bytes.TrimSuffix(append(w.buf, p[:i]...), []byte{'\r'})
+		for len(line) > outputMaxLine {
+			w.s.append(w.stream, string(line[:outputMaxLine]))
+			line = line[outputMaxLine:]
+		}
+		w.s.append(w.stream, string(line))
+		w.buf = w.buf[:0]
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// Flush 将最后一段不完整的行加入输出流
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.s.append(w.stream, string(w.buf))
+		w.buf = w.buf[:0]
+	}
+}
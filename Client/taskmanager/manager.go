@@ -1,14 +1,17 @@
 package taskmanager
 
 import (
+	"Client/datetype"
 	"Client/ws"
+	"fmt"
 	"log"
 )
 
 type Task struct {
-	TaskID  string `json:"task_id"`
-	Content string `json:"content"`
-	Status  string `json:"status"` // inactive, active, completed 等状态
+	TaskID  string               `json:"task_id"`
+	Content string               `json:"content"`
+	Status  string               `json:"status"` // inactive, active, completed 等状态
+	Options datetype.TaskOptions `json:"options"`
 }
 
 type TaskManager struct {
@@ -16,7 +19,7 @@ type TaskManager struct {
 	WSManager *ws.WebSocketManager
 }
 
-func (tm *TaskManager) AddTask(taskID string, crondExpression string, scriptPath string) error {
+func (tm *TaskManager) AddTask(taskID string, crondExpression string, scriptPath string, opts datetype.TaskOptions) error {
 	// 检查任务是否已经存在
 	if _, exists := tm.TaskList[taskID]; exists {
 		return nil // 任务已存在，不需要添加
@@ -27,6 +30,7 @@ func (tm *TaskManager) AddTask(taskID string, crondExpression string, scriptPath
 		TaskID:  taskID,
 		Content: scriptPath,
 		Status:  "active",
+		Options: opts,
 	}
 
 	log.Printf("任务 %s 已成功添加，crond 表达式: %s\n", taskID, crondExpression)
@@ -49,6 +53,11 @@ func (tm *TaskManager) StopTask(taskID string) error {
 	return nil
 }
 
+// KillTask 任务清单只记录任务，没有执行中的实例可终止
+func (tm *TaskManager) KillTask(taskID string) error {
+	return fmt.Errorf("任务 %s 没有执行中的实例", taskID)
+}
+
 // InitTask 从服务器获取任务并初始化任务列表
 func (tm *TaskManager) InitTask() {
 	// 调用 crond 中的函数，从服务器获取任务
@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"Client/datetype"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+)
+
+// 服务端下发的任务指令带有客户端内连续递增的 seq，由单个协程按序应用并逐条确认。
+// 未确认的指令服务端会在重连或超时后重发，已应用过的只重新确认
+var (
+	commandQueue = make(chan map[string]interface{}, 100)
+	commandOnce  sync.Once
+
+	seqMu      sync.Mutex
+	appliedSeq int64
+	seqKnown   bool // 是否已从服务端得知已确认的序号
+)
+
+// setAppliedSeq 握手时同步服务端记录的已确认序号，本进程已应用但确认未送达的指令以本地为准
+func setAppliedSeq(value string) {
+	if value == "" {
+		return
+	}
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Invalid applied seq from server: %s", value)
+		return
+	}
+	seqMu.Lock()
+	defer seqMu.Unlock()
+	if !seqKnown || seq > appliedSeq {
+		appliedSeq = seq
+	}
+	seqKnown = true
+}
+
+// enqueueCommand 把带 seq 的指令交给应用协程。应用时需经监听协程接收响应，
+// 队列已满时丢弃而不阻塞监听协程，由服务端重发
+func enqueueCommand(msg map[string]interface{}) {
+	commandOnce.Do(func() { go applyCommands() })
+	select {
+	case commandQueue <- msg:
+	default:
+		log.Printf("Command queue is full, dropped command seq %v", msg["seq"])
+	}
+}
+
+func applyCommands() {
+	for msg := range commandQueue {
+		seq := int64(msg["seq"].(float64))
+		action, _ := msg["action"].(string)
+
+		seqMu.Lock()
+		last, known := appliedSeq, seqKnown
+		seqMu.Unlock()
+
+		switch {
+		case known && seq <= last:
+			// 重发的指令已应用过，确认丢失时需再次确认
+			sendCommandAck(datetype.CommandAck{Seq: seq, OK: true})
+			continue
+		case known && seq != last+1:
+			// 中间的指令尚未收到，等待服务端按序重发
+			log.Printf("Command seq %d arrived before %d, waiting for redelivery", seq, last+1)
+			continue
+		}
+
+		ack := datetype.CommandAck{Seq: seq, OK: true}
+		if err := handleTaskMessage(action, msg); err != nil {
+			ack.OK, ack.Error = false, err.Error()
+		}
+		seqMu.Lock()
+		appliedSeq, seqKnown = seq, true
+		seqMu.Unlock()
+		sendCommandAck(ack)
+	}
+}
+
+// sendCommandAck 确认失败时不重试，服务端重发后再次确认
+func sendCommandAck(ack datetype.CommandAck) {
+	if err := ensureConnection(WSManager); err != nil {
+		log.Printf("Failed to ack command %d: %v", ack.Seq, err)
+		return
+	}
+	requestData, err := json.Marshal(ack)
+	if err != nil {
+		log.Printf("Failed to marshal command ack: %v", err)
+		return
+	}
+	response, err := CommunicateWithServer(WSManager.Client, "command_ack", requestData)
+	if err != nil {
+		log.Printf("Failed to ack command %d: %v", ack.Seq, err)
+		return
+	}
+	var responseData map[string]interface{}
+	if err := json.Unmarshal([]byte(response.(string)), &responseData); err != nil {
+		log.Printf("Failed to unmarshal command ack response: %v", err)
+		return
+	}
+	if msg, ok := responseData["error"].(string); ok && msg != "" {
+		log.Printf("Server rejected ack of command %d: %s", ack.Seq, msg)
+	}
+}
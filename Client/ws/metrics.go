@@ -0,0 +1,41 @@
+package ws
+
+import (
+	"Client/datetype"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// ReportMetrics 将一次采集的主机指标推送到服务端
+func ReportMetrics(wsManager *WebSocketManager, report datetype.MetricsReport) error {
+	if err := ensureConnection(wsManager); err != nil {
+		return err
+	}
+
+	// 将结构体序列化为 JSON
+	requestData, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("Failed to marshal metrics report: %v", err)
+		return err
+	}
+
+	// 发送消息并接收响应
+	response, err := CommunicateWithServer(wsManager.Client, "metrics_report", requestData)
+	if err != nil {
+		log.Printf("Failed to send metrics report: %v", err)
+		return err
+	}
+
+	// 解析服务端返回的数据
+	var responseData map[string]interface{}
+	if err := json.Unmarshal([]byte(response.(string)), &responseData); err != nil {
+		log.Printf("Failed to unmarshal response: %v", err)
+		return err
+	}
+	if errMsg, ok := responseData["error"].(string); ok {
+		return fmt.Errorf("服务端拒绝指标数据: %s", errMsg)
+	}
+
+	return nil
+}
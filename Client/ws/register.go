@@ -0,0 +1,59 @@
+package ws
+
+import (
+	"Client/bin"
+	"Client/setting"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// RegisterHost 将本机信息登记到服务端主机清单
+func RegisterHost(wsManager *WebSocketManager) error {
+	if err := ensureConnection(wsManager); err != nil {
+		return err
+	}
+
+	facts, err := bin.GetHostFacts()
+	if err != nil {
+		return err
+	}
+	facts.AgentID = setting.Conf.AgentID
+	facts.ClientIP = setting.Conf.ClientIp
+	facts.AgentVersion = setting.Conf.Version
+
+	requestData, err := json.Marshal(facts)
+	if err != nil {
+		log.Printf("Failed to marshal host facts: %v", err)
+		return err
+	}
+
+	response, err := CommunicateWithServer(wsManager.Client, "host_register", requestData)
+	if err != nil {
+		log.Printf("Failed to register host: %v", err)
+		return err
+	}
+
+	var responseData map[string]interface{}
+	if err := json.Unmarshal([]byte(response.(string)), &responseData); err != nil {
+		log.Printf("Failed to unmarshal response: %v", err)
+		return err
+	}
+	if errMsg, ok := responseData["error"].(string); ok {
+		return fmt.Errorf("服务端拒绝主机注册: %s", errMsg)
+	}
+	log.Printf("Host registered, hostid: %v", responseData["hostid"])
+	return nil
+}
+
+// registerAfterReconnect 重连成功后重新注册，连接锁可能仍被持有，需在新协程中执行
+func registerAfterReconnect() {
+	if WSManager == nil {
+		return
+	}
+	go func() {
+		if err := RegisterHost(WSManager); err != nil {
+			log.Printf("Failed to register host after reconnect: %v", err)
+		}
+	}()
+}
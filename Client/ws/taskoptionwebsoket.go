@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 )
 
 func ensureConnection(wsManager *WebSocketManager) error {
@@ -30,7 +29,7 @@ func Receive(wsManager *WebSocketManager) (interface{}, error) {
 
 	// 创建消息请求结构体实例
 	request := datetype.TaskRequest{
-		RequestId: setting.Conf.ClientIp,
+		RequestId: setting.Conf.AgentID,
 		TaskType:  "select", // 自定义消息内容
 	}
 
@@ -81,14 +80,14 @@ func Receive(wsManager *WebSocketManager) (interface{}, error) {
 }
 func ReceiveTaskFile(wsManager *WebSocketManager) (interface{}, error) {
 	// 构建下载地址
-	address := fmt.Sprintf("http://%s:%d%s", setting.Conf.ServerConfig.Ip, setting.Conf.ServerConfig.Port, setting.Conf.ServerConfig.DownloadApi)
+	address := setting.ServerURL("http", setting.Conf.ServerConfig.DownloadApi)
 
 	if err := ensureConnection(wsManager); err != nil {
 		return nil, err
 	}
 	// 创建消息请求结构体实例
 	request := datetype.TaskRequest{
-		RequestId: setting.Conf.ClientIp,
+		RequestId: setting.Conf.AgentID,
 		TaskType:  "get_task", // 自定义消息内容
 	}
 
@@ -142,88 +141,10 @@ func ReceiveTaskFile(wsManager *WebSocketManager) (interface{}, error) {
 
 	return responseData, err
 }
-func TaskLogPut(wsManager *WebSocketManager, tasklog datetype.ClientTaskLog) (map[string]interface{}, error) {
-	// 构建上传地址
-	address := fmt.Sprintf("http://%s:%d%s", setting.Conf.ServerConfig.Ip, setting.Conf.ServerConfig.Port, setting.Conf.ServerConfig.UploadApi)
-	if err := ensureConnection(wsManager); err != nil {
-		return nil, err
-	}
-	// 构建请求体
-	request := datetype.TaskLogRequest{
-		RequestId:     setting.Conf.ClientIp,
-		TaskType:      "task_log_accepted",
-		ClientTaskLog: tasklog,
-	}
-	fmt.Println("发送的日志数据", request)
-
-	// 将结构体序列化为 JSON
-	requestData, err := json.Marshal(request)
-	if err != nil {
-		log.Printf("Failed to marshal request data: %v", err)
-		return nil, err
-	}
-
-	// 使用 tasklog 的 Output 字段作为日志文件的文件名
-	logFile := tasklog.Output
-
-	// 检查日志文件是否存在
-	if _, err := os.Stat(logFile); os.IsNotExist(err) {
-		return nil, fmt.Errorf("日志文件 %s 不存在", logFile)
-	}
-
-	// 上传文件
-	err = mode.UploadFiles([]string{logFile}, address) // 调整为传入文件路径
-	if err != nil {
-		log.Printf("Failed to upload log file: %v\n", err)
-		return nil, err
-	}
-
-	log.Printf("Log file %s uploaded successfully\n", logFile)
-
-	// 发送消息并接收响应
-	response, err := CommunicateWithServer(wsManager.Client, "task_request", requestData)
-	if err != nil {
-		log.Printf("Failed to send data: %v", err)
-		return nil, err
-	}
-
-	// 解析服务端返回的 JSON 数据，提取 file_id
-	var responseData struct {
-		Files []struct {
-			FileID   int64  `json:"file_id"`
-			FileName string `json:"file_name"`
-		} `json:"files"`
-		Status string `json:"status"`
-	}
-	err = json.Unmarshal([]byte(response.(string)), &responseData)
-	if err != nil {
-		log.Printf("Failed to unmarshal response: %v", err)
-		return nil, err
-	}
-
-	// 确认上传状态为 success
-	if responseData.Status != "success" {
-		return nil, fmt.Errorf("文件上传失败, 响应状态: %s", responseData.Status)
-	}
-
-	// 提取第一个文件的 file_id（假设只有一个文件）
-	if len(responseData.Files) == 0 {
-		return nil, fmt.Errorf("响应中没有 file_id")
-	}
-	fileID := responseData.Files[0].FileID
-
-	// 返回上传的文件ID信息
-	processedTasks := map[string]interface{}{
-		"file_id": fileID,
-		"status":  "文件上传成功",
-	}
-
-	return processedTasks, nil
-}
 func TaskInfoGet(wsManager *WebSocketManager, taskIDs []string) (map[string]interface{}, error) {
 	// 构建请求体
 	request := datetype.TaskRequest{
-		RequestId: setting.Conf.ClientIp,
+		RequestId: setting.Conf.AgentID,
 		TaskType:  "query_task",
 		TaskId:    taskIDs,
 	}
@@ -285,56 +206,51 @@ func TaskInfoGet(wsManager *WebSocketManager, taskIDs []string) (map[string]inte
 	// 返回预处理后的任务详情
 	return processedTasks, nil
 }
+
+// UpdateTaskStatus 上报任务状态，服务端校验状态迁移，拒绝时返回错误
 func UpdateTaskStatus(wsManager *WebSocketManager, taskID string, taskStatus string) (interface{}, error) {
-	// 构建请求体
-	request := datetype.TaskStatus{
-		RequestId:  setting.Conf.ClientIp,
+	return taskReply(wsManager, datetype.TaskStatus{
+		RequestId:  setting.Conf.AgentID,
 		TaskType:   "update_status",
 		TaskId:     taskID,
-		TaskStatus: taskStatus, // 自定义消息内容
-	}
-	if err := ensureConnection(wsManager); err != nil {
-		return nil, err
-	}
-	// 将结构体序列化为 JSON
-	requestData, err := json.Marshal(request)
-	if err != nil {
-		log.Printf("Failed to marshal request data: %v", err)
-		return nil, err
-	}
-
-	// 发送消息并接收响应
-	response, err := CommunicateWithServer(wsManager.Client, "task_request", requestData)
-	if err != nil {
-		log.Printf("Failed to send data: %v", err)
-		return nil, err
-	}
-
-	return response, err
+		TaskStatus: taskStatus,
+	})
 }
-func handleTaskMessage(action string, serverResponse map[string]interface{}) {
+
+// handleTaskMessage 应用服务端下发的任务指令，返回应用失败的原因
+func handleTaskMessage(action string, serverResponse map[string]interface{}) error {
 	taskID := serverResponse["task_id"].(string)
 	crondExpression, _ := serverResponse["crond_expression"].(string) // 如果没有可能是空字符串
 	scriptPath, _ := serverResponse["script_path"].(string)           // 同上
 
 	switch action {
 	case "add":
-		err := WSManager.TaskManager.AddTask(taskID, crondExpression, scriptPath)
+		err := WSManager.TaskManager.AddTask(taskID, crondExpression, scriptPath, ParseTaskOptions(serverResponse))
 		if err != nil {
 			log.Printf("添加任务 %s 失败: %v\n", taskID, err)
-		} else {
-			log.Printf("任务 %s 添加成功\n", taskID)
+			return err
 		}
-	case "stop":
+		log.Printf("任务 %s 添加成功\n", taskID)
+	case "stop", "delete":
 		err := WSManager.TaskManager.StopTask(taskID)
 		if err != nil {
 			log.Printf("停止任务 %s 失败: %v\n", taskID, err)
-		} else {
-			log.Printf("任务 %s 已停止\n", taskID)
+			return err
+		}
+		log.Printf("任务 %s 已停止\n", taskID)
+	case "kill":
+		// 只终止执行中的实例，任务仍保持调度
+		err := WSManager.TaskManager.KillTask(taskID)
+		if err != nil {
+			log.Printf("终止任务 %s 失败: %v\n", taskID, err)
+			return err
 		}
+		log.Printf("任务 %s 的执行已终止\n", taskID)
 	default:
 		log.Printf("未知操作: %s\n", action)
+		return fmt.Errorf("未知操作: %s", action)
 	}
+	return nil
 }
 func handleGeneralResponse(response map[string]interface{}) {
 	log.Printf("Received general response: %v", response)
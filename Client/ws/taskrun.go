@@ -0,0 +1,166 @@
+package ws
+
+import (
+	"Client/datetype"
+	"Client/mode"
+	"Client/setting"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+// 任务生命周期状态，与服务端一致
+const (
+	TaskScheduled = "scheduled"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+	TaskTimedOut  = "timed_out"
+	TaskCancelled = "cancelled"
+)
+
+// 任务的并发策略，与服务端一致
+const (
+	ConcurrencyAllow   = "allow"
+	ConcurrencySkip    = "skip"
+	ConcurrencyQueue   = "queue"
+	ConcurrencyReplace = "replace"
+)
+
+// ParseTaskOptions 从任务详情或服务端指令中读取执行选项
+func ParseTaskOptions(info map[string]interface{}) datetype.TaskOptions {
+	timeout, _ := info["timeout"].(float64)
+	policy, _ := info["concurrency_policy"].(string)
+	maxRetries, _ := info["max_retries"].(float64)
+	retryBackoff, _ := info["retry_backoff"].(float64)
+	retryOn, _ := info["retry_on"].(string)
+	return datetype.TaskOptions{
+		Timeout:           int(timeout),
+		ConcurrencyPolicy: policy,
+		MaxRetries:        int(maxRetries),
+		RetryBackoff:      int(retryBackoff),
+		RetryOn:           retryOn,
+	}
+}
+
+// taskReply 发送任务请求并解析响应，服务端返回 error 字段时作为错误返回
+func taskReply(wsManager *WebSocketManager, request interface{}) (map[string]interface{}, error) {
+	if err := ensureConnection(wsManager); err != nil {
+		return nil, err
+	}
+	requestData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request data: %v", err)
+	}
+	response, err := CommunicateWithServer(wsManager.Client, "task_request", requestData)
+	if err != nil {
+		return nil, err
+	}
+	var responseData map[string]interface{}
+	if err := json.Unmarshal([]byte(response.(string)), &responseData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	if msg, ok := responseData["error"].(string); ok && msg != "" {
+		return responseData, fmt.Errorf("服务端拒绝: %s", msg)
+	}
+	return responseData, nil
+}
+
+// StartRun 向服务端登记一次执行，返回 run_id。服务端拒绝时（如任务已取消）不应执行任务
+func StartRun(wsManager *WebSocketManager, taskID string, start time.Time) (string, error) {
+	responseData, err := taskReply(wsManager, datetype.RunStart{
+		RequestId: setting.Conf.AgentID,
+		TaskType:  "run_start",
+		TaskId:    taskID,
+		StartTime: start,
+	})
+	if err != nil {
+		return "", err
+	}
+	runID, ok := responseData["run_id"].(string)
+	if !ok || runID == "" {
+		return "", fmt.Errorf("响应中没有 run_id")
+	}
+	return runID, nil
+}
+
+// FinishRun 上报执行结果
+func FinishRun(wsManager *WebSocketManager, result datetype.RunFinish) error {
+	result.RequestId = setting.Conf.AgentID
+	result.TaskType = "run_finish"
+	_, err := taskReply(wsManager, result)
+	return err
+}
+
+// SkipRun 上报一次因并发策略跳过的触发
+func SkipRun(wsManager *WebSocketManager, taskID string, at time.Time, reason string) error {
+	_, err := taskReply(wsManager, datetype.RunSkip{
+		RequestId: setting.Conf.AgentID,
+		TaskType:  "run_skip",
+		TaskId:    taskID,
+		SkipTime:  at,
+		Reason:    reason,
+	})
+	return err
+}
+
+// 任务输出的来源，与服务端一致
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// SendTaskOutput 上报一批执行输出，服务端按序号去重，失败时可重发
+func SendTaskOutput(wsManager *WebSocketManager, output datetype.TaskOutput) error {
+	if err := ensureConnection(wsManager); err != nil {
+		return err
+	}
+	requestData, err := json.Marshal(output)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task output: %v", err)
+	}
+	response, err := CommunicateWithServer(wsManager.Client, "task_output", requestData)
+	if err != nil {
+		return err
+	}
+	var responseData map[string]interface{}
+	if err := json.Unmarshal([]byte(response.(string)), &responseData); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	if msg, ok := responseData["error"].(string); ok && msg != "" {
+		return fmt.Errorf("服务端拒绝: %s", msg)
+	}
+	return nil
+}
+
+// UploadRunOutput 执行结束后上传完整的输出文件，服务端压缩保存并关联到执行记录，返回输出 ID
+func UploadRunOutput(taskID, runID, logFile string) (int64, error) {
+	address := setting.ServerURL("http", fmt.Sprintf("/tasks/%s/runs/%s/output", url.PathEscape(taskID), runID))
+	body, err := mode.UploadTaskOutput(logFile, address)
+	if err != nil {
+		return 0, err
+	}
+	var responseData struct {
+		Code int             `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &responseData); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	if responseData.Code != 1000 {
+		return 0, fmt.Errorf("服务端拒绝: %s %s", responseData.Msg, string(responseData.Data))
+	}
+	var output struct {
+		OutputID  int64 `json:"output_id"`
+		Truncated bool  `json:"truncated"`
+	}
+	if err := json.Unmarshal(responseData.Data, &output); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	if output.Truncated {
+		log.Printf("任务 %s 执行 %s 的输出超过服务端大小限制，已被截断", taskID, runID)
+	}
+	return output.OutputID, nil
+}
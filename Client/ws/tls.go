@@ -0,0 +1,150 @@
+package ws
+
+import (
+	"Client/setting"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TLSClientConfig 构建连接服务端使用的 TLS 配置，未启用时返回 nil。
+// 客户端证书在每次握手时从文件读取，轮换后无需重启
+func TLSClientConfig() (*tls.Config, error) {
+	if !setting.TLSEnabled() {
+		return nil, nil
+	}
+	cfg := setting.Conf.TLS
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+			if err != nil {
+				// 尚未签发证书时不出示证书
+				return &tls.Certificate{}, nil
+			}
+			return &cert, nil
+		},
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = setting.Conf.ServerConfig.Ip
+	}
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("invalid ca file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// newCSR 生成新的私钥和以客户端 ID 为 CN 的证书请求
+func newCSR(agentID string) (*ecdsa.PrivateKey, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate key: %v", err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: agentID},
+	}, key)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create csr: %v", err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})), nil
+}
+
+// saveCertificate 保存签发的证书和对应私钥，先写私钥再写证书
+func saveCertificate(key *ecdsa.PrivateKey, certPEM string) error {
+	cfg := setting.Conf.TLS
+	if _, err := tls.X509KeyPair([]byte(certPEM), encodeKey(key)); err != nil {
+		return fmt.Errorf("certificate does not match key: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.CertFile), 0o755); err != nil {
+		return fmt.Errorf("failed to create certificate dir: %v", err)
+	}
+	if err := os.WriteFile(cfg.KeyFile, encodeKey(key), 0o600); err != nil {
+		return fmt.Errorf("failed to write key: %v", err)
+	}
+	if err := os.WriteFile(cfg.CertFile, []byte(certPEM), 0o644); err != nil {
+		return fmt.Errorf("failed to write certificate: %v", err)
+	}
+	return nil
+}
+
+func encodeKey(key *ecdsa.PrivateKey) []byte {
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// certificateNeedsRenewal 证书不存在、无法解析或即将到期时需要轮换
+func certificateNeedsRenewal() bool {
+	data, err := os.ReadFile(setting.Conf.TLS.CertFile)
+	if err != nil {
+		return true
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return true
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return true
+	}
+	renewBefore := time.Duration(setting.Conf.TLS.RenewBefore) * 24 * time.Hour
+	return time.Until(cert.NotAfter) < renewBefore
+}
+
+// RenewCertificate 生成新密钥并通过已建立的会话申请证书，新证书在下次连接时生效
+func RenewCertificate(wsManager *WebSocketManager) error {
+	if err := ensureConnection(wsManager); err != nil {
+		return err
+	}
+	key, csr, err := newCSR(setting.Conf.AgentID)
+	if err != nil {
+		return err
+	}
+	requestData, err := json.Marshal(map[string]string{"csr": csr})
+	if err != nil {
+		return err
+	}
+	response, err := CommunicateWithServer(wsManager.Client, "renew_cert", requestData)
+	if err != nil {
+		return err
+	}
+
+	var responseData map[string]string
+	if err := json.Unmarshal([]byte(response.(string)), &responseData); err != nil {
+		return fmt.Errorf("failed to unmarshal renew response: %v", err)
+	}
+	if msg := responseData["error"]; msg != "" {
+		return fmt.Errorf("server rejected certificate renewal: %s", msg)
+	}
+	if err := saveCertificate(key, responseData["certificate"]); err != nil {
+		return err
+	}
+	log.Printf("Client certificate renewed, expires at %s", responseData["expires_at"])
+	return nil
+}
+
+// EnsureCertificate 启用 TLS 时检查客户端证书，缺失或即将到期时自动轮换
+func EnsureCertificate(wsManager *WebSocketManager) error {
+	if !setting.TLSEnabled() || !certificateNeedsRenewal() {
+		return nil
+	}
+	return RenewCertificate(wsManager)
+}
@@ -1,26 +1,39 @@
 package ws
 
 import (
+	"Client/datetype"
+	"Client/mode"
 	"Client/setting"
+	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type TaskManager interface {
-	AddTask(taskID string, crondExpression string, scriptPath string) error
+	AddTask(taskID string, crondExpression string, scriptPath string, opts datetype.TaskOptions) error
 	StopTask(taskID string) error
+	KillTask(taskID string) error
 }
 
 // 定义全局的通道，用于监听服务器指令和处理请求响应
 var (
 	TaskActionChannel = make(chan map[string]interface{}) // 用于处理 action 相关的消息
-	ResponseChannel   = make(chan map[string]interface{}) // 用于处理普通响应数据
+	ResponseChannel   = make(chan []byte, 1)              // 监听协程启动后，请求的响应经此通道转交给 CommunicateWithServer
 )
 
+// 监听协程启动后连接只能由它读取，awaiting 标记当前是否有请求在等待响应
+var (
+	listening atomic.Bool
+	awaiting  atomic.Bool
+)
+
+const responseTimeout = 30 * time.Second
+
 // WebSocketClient 结构体
 type WebSocketClient struct {
 	Conn       *websocket.Conn
@@ -28,6 +41,8 @@ type WebSocketClient struct {
 	ExpiresAt  time.Time
 	ServerAddr string
 	ClientIP   string
+	AgentID    string
+	mu         sync.Mutex // 串行化请求与响应，避免多个协程同时写连接
 }
 
 // WebSocketManager 结构体，管理 WebSocket 连接
@@ -47,63 +62,146 @@ func GetWebSocketManager(taskManager TaskManager) *WebSocketManager {
 	return WSManager
 }
 
-// ConnectWebSocketAndRequestToken 连接 WebSocket 并请求 Token
-func ConnectWebSocketAndRequestToken(serverAddr, clientIP string, port int) (*WebSocketClient, error) {
-	// 拼接服务器地址和端口
-	u := fmt.Sprintf("ws://%s:%d/wsclient", serverAddr, port)
+// ConnectWebSocketAndRequestToken 连接 WebSocket 并以客户端 ID 请求 Token
+func ConnectWebSocketAndRequestToken(serverAddr, agentID, clientIP string, port int) (*WebSocketClient, error) {
+	// 拼接服务器地址和端口，启用 TLS 时使用 wss
+	scheme := "ws"
+	if setting.TLSEnabled() {
+		scheme = "wss"
+	}
+	u := fmt.Sprintf("%s://%s:%d/wsclient", scheme, serverAddr, port)
 	log.Printf("Connecting to %s", u)
 
-	// 尝试建立 WebSocket 连接
-	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	tlsConfig, err := TLSClientConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to WebSocket server: %v", err)
+		return nil, err
 	}
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = tlsConfig
 
-	client := &WebSocketClient{Conn: conn, ServerAddr: serverAddr, ClientIP: clientIP}
-
-	// 构建 token 请求
-	request := map[string]string{
-		"type":      "request_token",
-		"client_ip": clientIP,
-	}
-
-	requestJSON, err := json.Marshal(request)
+	// 尝试建立 WebSocket 连接
+	conn, _, err := dialer.Dial(u, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal token request: %v", err)
+		return nil, fmt.Errorf("failed to connect to WebSocket server: %v", err)
 	}
 
-	// 发送 token 请求
-	err = client.Conn.WriteMessage(websocket.TextMessage, requestJSON)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send token request: %v", err)
-	}
+	client := &WebSocketClient{Conn: conn, ServerAddr: serverAddr, ClientIP: clientIP, AgentID: agentID}
 
-	// 读取 token 响应
-	_, response, err := client.Conn.ReadMessage()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read token response: %v", err)
+	// 尚无长期凭证时先用注册令牌注册
+	if setting.Conf.Credential == "" {
+		gotCert, err := enroll(conn, agentID, clientIP)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		// 注册时签发了证书，重新连接以便在 TLS 握手中出示
+		if gotCert {
+			conn.Close()
+			return ConnectWebSocketAndRequestToken(serverAddr, agentID, clientIP, port)
+		}
 	}
 
-	// 解析 token 响应
-	var tokenResponse map[string]string
-	err = json.Unmarshal(response, &tokenResponse)
+	// 以长期凭证请求 token，IP 仅作为主机属性上报
+	tokenResponse, err := handshake(conn, map[string]string{
+		"type":       "request_token",
+		"agent_id":   agentID,
+		"client_ip":  clientIP,
+		"credential": setting.Conf.Credential,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal token response: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("token request failed: %v", err)
 	}
 
 	// 存储 token 和过期时间
 	client.Token = tokenResponse["Token"]
 	expiresAt, err := time.Parse(time.RFC3339, tokenResponse["ExpiresAt"])
 	if err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to parse expiresAt time: %v", err)
 	}
 	client.ExpiresAt = expiresAt
+	mode.SetAgentAuth(agentID, client.Token)
+	setAppliedSeq(tokenResponse["AppliedSeq"])
 
-	log.Printf("Received Token: %s, ExpiresAt: %s", client.Token, client.ExpiresAt)
+	log.Printf("Received session token, ExpiresAt: %s", client.ExpiresAt)
 
 	return client, nil
 }
 
+// enroll 使用配置的注册令牌换取长期凭证并保存，返回是否同时获得了客户端证书
+func enroll(conn *websocket.Conn, agentID, clientIP string) (bool, error) {
+	if setting.Conf.EnrollToken == "" {
+		return false, fmt.Errorf("agent is not enrolled and no enrollment_token is configured")
+	}
+	request := map[string]string{
+		"type":             "enroll",
+		"agent_id":         agentID,
+		"client_ip":        clientIP,
+		"enrollment_token": setting.Conf.EnrollToken,
+	}
+
+	// 启用 TLS 时同时提交证书请求，由服务端内置 CA 签发客户端证书
+	var key *ecdsa.PrivateKey
+	if setting.TLSEnabled() {
+		var csr string
+		var err error
+		if key, csr, err = newCSR(agentID); err != nil {
+			return false, err
+		}
+		request["csr"] = csr
+	}
+
+	response, err := handshake(conn, request)
+	if err != nil {
+		return false, fmt.Errorf("enrollment failed: %v", err)
+	}
+	credential := response["credential"]
+	if credential == "" {
+		return false, fmt.Errorf("enrollment failed: no credential in response")
+	}
+	if err := setting.SaveCredential(setting.Conf.CredFile, credential); err != nil {
+		return false, err
+	}
+	setting.Conf.Credential = credential
+
+	log.Printf("Agent %s enrolled successfully", agentID)
+
+	// 证书签发失败时仍可继续，连接建立后会再次申请
+	certPEM := response["certificate"]
+	if key == nil || certPEM == "" {
+		return false, nil
+	}
+	if err := saveCertificate(key, certPEM); err != nil {
+		log.Printf("Failed to save client certificate: %v", err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// handshake 在监听协程启动前发送握手消息并读取响应，服务端返回 error 时视为失败
+func handshake(conn *websocket.Conn, request map[string]string) (map[string]string, error) {
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s request: %v", request["type"], err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, requestJSON); err != nil {
+		return nil, fmt.Errorf("failed to send %s request: %v", request["type"], err)
+	}
+	_, response, err := conn.ReadMessage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %v", request["type"], err)
+	}
+	var result map[string]string
+	if err := json.Unmarshal(response, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s response: %v", request["type"], err)
+	}
+	if msg := result["error"]; msg != "" {
+		return nil, fmt.Errorf("server rejected %s: %s", request["type"], msg)
+	}
+	return result, nil
+}
+
 // Heartbeat 函数，定时发送心跳包
 func Heartbeat(client *WebSocketClient) {
 	ticker := time.NewTicker(30 * time.Second)
@@ -111,7 +209,8 @@ func Heartbeat(client *WebSocketClient) {
 
 	for {
 		<-ticker.C
-		if err := client.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+		// WriteControl 可与其他写操作并发调用
+		if err := client.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
 			log.Println("Heartbeat failed, attempting to reconnect...")
 			if reconnectErr := Reconnect(client); reconnectErr != nil {
 				log.Println("Reconnection failed:", reconnectErr)
@@ -129,7 +228,7 @@ func Reconnect(client *WebSocketClient) error {
 	reconnectInterval := 5 * time.Second
 
 	for attempt = 0; attempt < maxTotalAttempts; attempt++ {
-		newClient, err := ConnectWebSocketAndRequestToken(serverAddress, client.ClientIP, setting.Conf.ServerConfig.Port)
+		newClient, err := ConnectWebSocketAndRequestToken(serverAddress, client.AgentID, client.ClientIP, setting.Conf.ServerConfig.Port)
 		if err != nil {
 			log.Printf("Reconnect attempt %d/%d failed: %v", attempt+1, maxTotalAttempts, err)
 			time.Sleep(reconnectInterval)
@@ -142,6 +241,7 @@ func Reconnect(client *WebSocketClient) error {
 		client.ExpiresAt = newClient.ExpiresAt
 
 		log.Println("Reconnection successful!")
+		registerAfterReconnect()
 		return nil // 重连成功，返回 nil
 	}
 
@@ -166,6 +266,18 @@ func CommunicateWithServer(client *WebSocketClient, requesters string, message i
 		return nil, fmt.Errorf("failed to marshal authenticated message: %v", err)
 	}
 
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	// 清理上一次超时遗留的响应
+	select {
+	case stale := <-ResponseChannel:
+		log.Printf("Dropped stale server response: %s", string(stale))
+	default:
+	}
+	awaiting.Store(true)
+	defer awaiting.Store(false)
+
 	err = client.Conn.WriteMessage(websocket.TextMessage, authenticatedMessageJSON)
 	if err != nil {
 		log.Println("Send message failed, attempting to reconnect...")
@@ -173,12 +285,26 @@ func CommunicateWithServer(client *WebSocketClient, requesters string, message i
 			return nil, fmt.Errorf("failed to reconnect after message send failure: %v", reconnectErr)
 		}
 
+		// 重连后会话 token 可能已更新
+		authenticatedMessage["Token"] = client.Token
+		authenticatedMessageJSON, _ = json.Marshal(authenticatedMessage)
+
 		err = client.Conn.WriteMessage(websocket.TextMessage, authenticatedMessageJSON)
 		if err != nil {
 			return nil, fmt.Errorf("failed to send message after reconnect: %v", err)
 		}
 	}
 
+	if listening.Load() {
+		select {
+		case response := <-ResponseChannel:
+			log.Printf("Server response: %s", string(response))
+			return string(response), nil
+		case <-time.After(responseTimeout):
+			return nil, fmt.Errorf("timed out waiting for server response")
+		}
+	}
+
 	_, response, err := client.Conn.ReadMessage()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read server response: %v", err)
@@ -188,7 +314,9 @@ func CommunicateWithServer(client *WebSocketClient, requesters string, message i
 	return string(response), nil
 }
 func ListenToServerAndManageTasks(client *WebSocketClient) {
+	listening.Store(true)
 	go func() {
+		defer listening.Store(false)
 		for {
 			_, response, err := client.Conn.ReadMessage()
 			if err != nil {
@@ -209,16 +337,38 @@ func ListenToServerAndManageTasks(client *WebSocketClient) {
 				continue
 			}
 
-			// 校验消息是否包含 task_id, action, client_ip 字段
+			// 校验消息是否包含 task_id, action, agent_id 字段
 			_, hasTaskID := serverResponse["task_id"].(string)
 			action, hasAction := serverResponse["action"].(string)
-			clientIP, hasClientIP := serverResponse["client_ip"].(string)
+			agentID, hasAgentID := serverResponse["agent_id"].(string)
+
+			// 服务端要求轮换证书，请求需经监听协程转交响应，在新协程中执行
+			if action == "rotate_cert" && hasAgentID && agentID == setting.Conf.AgentID {
+				go func() {
+					if err := RenewCertificate(WSManager); err != nil {
+						log.Printf("Failed to rotate client certificate: %v", err)
+					}
+				}()
+				continue
+			}
 
-			// 检查 client_ip 是否匹配配置文件中的 IP
-			if hasTaskID && hasAction && hasClientIP && clientIP == setting.Conf.ClientIp {
+			// 检查 agent_id 是否为本机的客户端 ID
+			if hasTaskID && hasAction && hasAgentID && agentID == setting.Conf.AgentID {
 				// 符合条件的任务相关消息
-				fmt.Println("收到广播数据")
-				handleTaskMessage(action, serverResponse) // 处理任务相关的消息
+				fmt.Println("收到任务指令")
+				// 处理过程中会向服务端请求任务详情，需在监听协程之外执行；带序号的指令按序应用并确认
+				if _, hasSeq := serverResponse["seq"].(float64); hasSeq {
+					enqueueCommand(serverResponse)
+				} else {
+					go handleTaskMessage(action, serverResponse)
+				}
+			} else if !hasAction && awaiting.Load() {
+				// 正在等待的请求响应，转交给 CommunicateWithServer
+				select {
+				case ResponseChannel <- response:
+				default:
+					handleGeneralResponse(serverResponse)
+				}
 			} else {
 				// 不符合条件的普通响应
 				fmt.Println("未收到广播数据")
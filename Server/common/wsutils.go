@@ -1,10 +1,15 @@
 package common
 
 import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 )
@@ -13,13 +18,10 @@ import (
 type WebSocketClient struct {
 	Conn          *websocket.Conn
 	ClientIP      string
-	Authorization string
+	Authorization string // 握手后签发的会话 token，之后每条消息都需携带
 	ExpiresAt     time.Time
-	ClientID      string // 新增一个客户端ID，用于唯一标识客户端
-}
-type WebSocketManager struct {
-	Clients      map[*websocket.Conn]*WebSocketClient // 管理所有客户端
-	ClientsMutex sync.Mutex                           // 锁保护并发读写
+	ClientID      string // 客户端 ID，握手时由客户端上报，用于唯一标识客户端
+	PresenceLease int64  // /agents/online/<id> 的租约 ID，握手后登记，收到心跳时续约
 }
 
 // 全局客户端管理
@@ -28,34 +30,40 @@ var (
 	ClientsMutex = sync.Mutex{}
 )
 
-// WebSocket 升级器，允许跨域
+// 每个连接的写锁。gorilla/websocket 同一连接同一时间只允许一个写操作，
+// 读循环的响应、指令重发和转发的消息都经 WriteMessage 串行写入。
+// 与 ClientsMutex 分开加锁，持有 ClientsMutex 的处理器也能发送消息
+var writeLocks = struct {
+	sync.Mutex
+	m map[*websocket.Conn]*sync.Mutex
+}{
+	m: make(map[*websocket.Conn]*sync.Mutex),
+}
+
+// writeWait 单条消息的写超时，避免不读取的客户端长时间占用写锁
+const writeWait = 10 * time.Second
+
+// ErrConnClosed 连接已从全局管理中删除
+var ErrConnClosed = errors.New("连接已关闭")
+
+// WebSocket 升级器，客户端程序不带 Origin 头；浏览器发起的连接只允许同源
 
 var Upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
-		return true
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
 	},
 }
 
-// 消息处理器接口，处理不同类型的消息
-// NewWebSocketManager 创建并返回一个新的 WebSocketManager
-
-func NewWebSocketManager() *WebSocketManager {
-	return &WebSocketManager{
-		Clients: make(map[*websocket.Conn]*WebSocketClient),
-	}
-}
-
+// MessageHandler 消息处理器接口，处理不同类型的消息
 type MessageHandler interface {
 	HandleMessage(conn *websocket.Conn, msg map[string]interface{}) error
 }
 
-// GetClients 返回所有客户端连接
-func (manager *WebSocketManager) GetClients() map[*websocket.Conn]*WebSocketClient {
-	manager.ClientsMutex.Lock()
-	defer manager.ClientsMutex.Unlock()
-	return manager.Clients
-}
-
 // 处理器注册表，用于存储不同消息类型的处理器
 var handlerRegistry = struct {
 	sync.RWMutex
@@ -79,18 +87,170 @@ func GetHandler(msgType string) (MessageHandler, bool) {
 	return handler, exists
 }
 
-// AddClient 添加客户端到全局管理中
+// AddClient 添加客户端到全局管理中，并为连接创建写锁
 func AddClient(conn *websocket.Conn, client *WebSocketClient) {
+	writeLocks.Lock()
+	writeLocks.m[conn] = &sync.Mutex{}
+	writeLocks.Unlock()
+
 	ClientsMutex.Lock()
 	defer ClientsMutex.Unlock()
 	Clients[conn] = client
 }
 
-// RemoveClient 从全局管理中删除客户端
+// RemoveClient 从全局管理中删除客户端，之后对该连接的写入返回 ErrConnClosed
 func RemoveClient(conn *websocket.Conn) {
 	ClientsMutex.Lock()
-	defer ClientsMutex.Unlock()
 	delete(Clients, conn)
+	ClientsMutex.Unlock()
+
+	writeLocks.Lock()
+	defer writeLocks.Unlock()
+	delete(writeLocks.m, conn)
+}
+
+// WriteMessage 在连接的写锁内发送一条文本消息，所有数据帧都应经此或 SendJSONResponse 写入
+func WriteMessage(conn *websocket.Conn, data []byte) error {
+	writeLocks.Lock()
+	mu, ok := writeLocks.m[conn]
+	writeLocks.Unlock()
+	if !ok {
+		return ErrConnClosed
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// BindClientSession 握手成功后记录连接对应的客户端 ID、上报的 IP 和会话 token
+func BindClientSession(conn *websocket.Conn, clientID, clientIP, token string) {
+	ClientsMutex.Lock()
+	defer ClientsMutex.Unlock()
+	if client, ok := Clients[conn]; ok {
+		client.ClientID = clientID
+		client.Authorization = token
+		if clientIP != "" {
+			client.ClientIP = clientIP
+		}
+	}
+}
+
+// GetClientID 返回连接对应的客户端 ID，未握手时为空
+func GetClientID(conn *websocket.Conn) string {
+	ClientsMutex.Lock()
+	defer ClientsMutex.Unlock()
+	if client, ok := Clients[conn]; ok {
+		return client.ClientID
+	}
+	return ""
+}
+
+// SetPresenceLease 记录连接登记在线状态使用的租约
+func SetPresenceLease(conn *websocket.Conn, leaseID int64) {
+	ClientsMutex.Lock()
+	defer ClientsMutex.Unlock()
+	if client, ok := Clients[conn]; ok {
+		client.PresenceLease = leaseID
+	}
+}
+
+// GetPresence 返回连接的客户端 ID、IP 和在线租约，未握手时客户端 ID 为空
+func GetPresence(conn *websocket.Conn) (clientID, clientIP string, leaseID int64) {
+	ClientsMutex.Lock()
+	defer ClientsMutex.Unlock()
+	if client, ok := Clients[conn]; ok {
+		return client.ClientID, client.ClientIP, client.PresenceLease
+	}
+	return "", "", 0
+}
+
+// CheckSessionToken 校验消息携带的 token 是否为该连接握手时签发的会话 token
+func CheckSessionToken(conn *websocket.Conn, token string) bool {
+	ClientsMutex.Lock()
+	defer ClientsMutex.Unlock()
+	client, ok := Clients[conn]
+	if !ok || client.Authorization == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(client.Authorization), []byte(token)) == 1
+}
+
+// DisconnectClient 断开指定客户端的全部连接，返回断开的连接数。
+// 连接关闭后读循环退出，由 WebsocketHandler 负责清理
+func DisconnectClient(clientID, reason string) int {
+	ClientsMutex.Lock()
+	defer ClientsMutex.Unlock()
+	closed := 0
+	for conn, client := range Clients {
+		if client.ClientID != clientID {
+			continue
+		}
+		// WriteControl 可与其他写操作并发调用
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason), time.Now().Add(time.Second))
+		conn.Close()
+		client.Authorization = ""
+		closed++
+	}
+	return closed
+}
+
+// CheckAgentToken 校验客户端 HTTP 请求携带的会话 token，客户端必须在线
+func CheckAgentToken(clientID, token string) bool {
+	if clientID == "" || token == "" {
+		return false
+	}
+	ClientsMutex.Lock()
+	defer ClientsMutex.Unlock()
+	for _, client := range Clients {
+		if client.ClientID == clientID && client.Authorization != "" &&
+			subtle.ConstantTimeCompare([]byte(client.Authorization), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerCertificate 返回 wss 连接上客户端出示且已通过 CA 校验的证书，未出示时为 nil
+func PeerCertificate(conn *websocket.Conn) *x509.Certificate {
+	tlsConn, ok := conn.UnderlyingConn().(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := tlsConn.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
+
+// FindClientByID 按客户端 ID 查找连接，同一客户端重连时返回最新的连接
+func FindClientByID(clientID string) *WebSocketClient {
+	ClientsMutex.Lock()
+	defer ClientsMutex.Unlock()
+	var found *WebSocketClient
+	for _, client := range Clients {
+		if client.ClientID == clientID && (found == nil || client.ExpiresAt.After(found.ExpiresAt)) {
+			found = client
+		}
+	}
+	return found
+}
+
+// ConnectedAgentIDs 返回已在本实例完成握手的客户端 ID
+func ConnectedAgentIDs() []string {
+	ClientsMutex.Lock()
+	defer ClientsMutex.Unlock()
+	seen := make(map[string]bool)
+	ids := make([]string, 0, len(Clients))
+	for _, client := range Clients {
+		if client.ClientID != "" && !seen[client.ClientID] {
+			seen[client.ClientID] = true
+			ids = append(ids, client.ClientID)
+		}
+	}
+	return ids
 }
 
 // SendJSONResponse 发送 JSON 格式的数据到 WebSocket 连接
@@ -103,7 +263,7 @@ func SendJSONResponse(conn *websocket.Conn, message map[string]interface{}) erro
 	}
 
 	// 发送 JSON 消息到 WebSocket 连接
-	err = conn.WriteMessage(websocket.TextMessage, jsonData)
+	err = WriteMessage(conn, jsonData)
 	if err != nil {
 		log.Printf("Error sending message: %v", err)
 		return err
@@ -111,20 +271,3 @@ func SendJSONResponse(conn *websocket.Conn, message map[string]interface{}) erro
 
 	return nil
 }
-
-// BroadcastTaskMessage 向所有客户端广播任务信息
-func BroadcastTaskMessage(clients map[*websocket.Conn]*WebSocketClient, taskMessage map[string]interface{}) {
-	ClientsMutex.Lock()
-	defer ClientsMutex.Unlock()
-
-	for conn, client := range clients {
-		// 在消息中添加目标客户端的 IP
-		taskMessage["client_ip"] = client.ClientIP
-		err := conn.WriteJSON(taskMessage)
-		if err != nil {
-			log.Printf("Failed to broadcast to client %s: %v", client.ClientIP, err)
-			conn.Close()
-			delete(clients, conn)
-		}
-	}
-}
@@ -0,0 +1,164 @@
+package alarmwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/alarmoption"
+	"Server/models/alarmtype"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"strconv"
+)
+
+// ListRules 查询报警规则
+func ListRules(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+
+	hostIDs, ok := controller.ScopedHostIDs(c, db.(*sqlx.DB))
+	if !ok {
+		return
+	}
+	rules, err := alarmoption.ListRules(db.(*sqlx.DB))
+	if err != nil {
+		zap.L().Error("查询报警规则失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	// 非管理员只看到全局规则和所属主机组内主机的规则
+	if hostIDs != nil {
+		inScope := make(map[int64]bool, len(hostIDs))
+		for _, id := range hostIDs {
+			inScope[id] = true
+		}
+		scoped := make([]alarmtype.AlarmRule, 0, len(rules))
+		for _, r := range rules {
+			if r.HostID == 0 || inScope[r.HostID] {
+				scoped = append(scoped, r)
+			}
+		}
+		rules = scoped
+	}
+	controller.ResopnseSystemDataSuccess(c, rules)
+}
+
+// checkRuleScope 全局规则作用于所有主机，只允许管理员修改；主机规则需在用户的主机组范围内。
+// 无权修改时直接返回响应
+func checkRuleScope(c *gin.Context, db *sqlx.DB, hostID int64) bool {
+	if controller.IsAdmin(c) {
+		return true
+	}
+	if hostID == 0 {
+		controller.ResponseErrorwithMsg(c, controller.CodeForbidden, "只有管理员可以修改全局报警规则")
+		return false
+	}
+	return controller.CheckHostScope(c, db, hostID)
+}
+
+// loadRuleScope 查询已有规则并校验修改权限，失败时已返回响应
+func loadRuleScope(c *gin.Context, db *sqlx.DB, id int64) bool {
+	rule, err := alarmoption.GetRule(db, id)
+	if err != nil {
+		if errors.Is(err, alarmoption.ErrRuleNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return false
+		}
+		zap.L().Error("查询报警规则失败", zap.Int64("id", id), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return false
+	}
+	return checkRuleScope(c, db, rule.HostID)
+}
+
+// SaveRule 新增或修改报警规则，id 为 0 时新增
+func SaveRule(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p := new(alarmtype.AlarmRule)
+	if err := c.ShouldBindJSON(p); err != nil {
+		//请求参数有误,直接返回响应
+		var errs validator.ValidationErrors
+		ok := errors.As(err, &errs)
+		if !ok {
+			controller.ResopnseError(c, controller.CodeAlarminfo)
+			return
+		}
+		controller.ResponseErrorwithMsg(c, controller.CodeAlarminfo, controller.RemoveTopStruct(errs.Translate(controller.Trans)))
+		return
+	}
+
+	// 修改已有规则时，原规则和修改后的主机都需在权限范围内
+	if p.ID != 0 && !loadRuleScope(c, db.(*sqlx.DB), p.ID) {
+		return
+	}
+	if !checkRuleScope(c, db.(*sqlx.DB), p.HostID) {
+		return
+	}
+	id, err := alarmoption.SaveRule(db.(*sqlx.DB), p)
+	if err != nil {
+		zap.L().Error("保存报警规则失败", zap.Int64("id", p.ID), zap.Error(err))
+		controller.ResponseErrorwithMsg(c, controller.CodeServerBusy, err.Error())
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"id": id})
+}
+
+// DeleteRule 删除报警规则，未结束的报警在下一轮评估时自动恢复
+func DeleteRule(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return
+	}
+
+	if !loadRuleScope(c, db.(*sqlx.DB), id) {
+		return
+	}
+	if err := alarmoption.DeleteRule(db.(*sqlx.DB), id); err != nil {
+		zap.L().Error("删除报警规则失败", zap.Int64("id", id), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"id": id})
+}
+
+// ListEvents 查询报警触发和恢复记录
+func ListEvents(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p := new(alarmtype.AlarmEventQuery)
+	if err := c.ShouldBindQuery(p); err != nil {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return
+	}
+
+	// 非管理员只查询所属主机组内主机的报警
+	hostIDs, ok := controller.ScopedHostIDs(c, db.(*sqlx.DB))
+	if !ok {
+		return
+	}
+	p.HostIDs = hostIDs
+	events, err := alarmoption.ListAlarms(db.(*sqlx.DB), p)
+	if err != nil {
+		zap.L().Error("查询报警记录失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, events)
+}
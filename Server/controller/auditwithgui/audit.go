@@ -0,0 +1,36 @@
+package auditwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/auditoption"
+	"Server/models/audittype"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ListAuditLogs 查询审计日志，可按用户、动作和时间范围过滤
+func ListAuditLogs(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p := new(audittype.AuditQuery)
+	if err := c.ShouldBindQuery(p); err != nil {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return
+	}
+	if !p.Start.IsZero() && !p.End.IsZero() && p.End.Before(p.Start) {
+		controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, "结束时间不能早于开始时间")
+		return
+	}
+
+	logs, total, err := auditoption.List(db.(*sqlx.DB), p)
+	if err != nil {
+		zap.L().Error("查询审计日志失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"total": total, "list": logs})
+}
@@ -0,0 +1,228 @@
+package authwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/useroption"
+	"Server/models/authtype"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"strconv"
+)
+
+// idParam 解析路径中的 id
+func idParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return 0, false
+	}
+	return id, true
+}
+
+// bindJSON 解析请求体，参数有误时直接返回响应
+func bindJSON(c *gin.Context, p interface{}) bool {
+	if err := c.ShouldBindJSON(p); err != nil {
+		var errs validator.ValidationErrors
+		ok := errors.As(err, &errs)
+		if !ok {
+			controller.ResopnseError(c, controller.CodeServerApiType)
+			return false
+		}
+		controller.ResponseErrorwithMsg(c, controller.CodeServerApiType, controller.RemoveTopStruct(errs.Translate(controller.Trans)))
+		return false
+	}
+	return true
+}
+
+// respondError 用户或主机组不存在、用户名重复时返回参数错误，其余返回服务器忙
+func respondError(c *gin.Context, msg string, err error) {
+	if errors.Is(err, useroption.ErrUserNotFound) || errors.Is(err, useroption.ErrGroupNotFound) ||
+		errors.Is(err, useroption.ErrUserExist) {
+		controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+		return
+	}
+	zap.L().Error(msg, zap.Error(err))
+	controller.ResopnseError(c, controller.CodeServerBusy)
+}
+
+// Me 查询当前登录用户的信息和所属主机组
+func Me(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	userID, err := controller.GetCurrentUser(c)
+	if err != nil {
+		controller.ResopnseError(c, controller.CodeNeedLogin)
+		return
+	}
+	user, err := useroption.GetUser(db.(*sqlx.DB), userID)
+	if err != nil {
+		respondError(c, "查询当前用户失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, user)
+}
+
+// ListUsers 查询全部用户
+func ListUsers(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	users, err := useroption.ListUsers(db.(*sqlx.DB))
+	if err != nil {
+		respondError(c, "查询用户列表失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, users)
+}
+
+// CreateUser 创建用户并指定角色
+func CreateUser(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p := new(authtype.NewUser)
+	if !bindJSON(c, p) {
+		return
+	}
+	userID, err := useroption.CreateUser(db.(*sqlx.DB), p)
+	if err != nil {
+		respondError(c, "创建用户失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"user_id": userID, "username": p.Username, "role": p.Role})
+}
+
+// UpdateUser 修改用户的密码、角色或状态，管理员不能停用或降级自己
+func UpdateUser(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	userID, ok := idParam(c)
+	if !ok {
+		return
+	}
+	p := new(authtype.UserUpdate)
+	if !bindJSON(c, p) {
+		return
+	}
+	if current, err := controller.GetCurrentUser(c); err == nil && current == userID {
+		if (p.Role != nil && *p.Role != authtype.RoleAdmin) || (p.Status != nil && *p.Status != authtype.UserActive) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, "不能停用或降级当前登录的管理员")
+			return
+		}
+	}
+
+	if err := useroption.UpdateUser(db.(*sqlx.DB), userID, p); err != nil {
+		respondError(c, "修改用户失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"user_id": userID})
+}
+
+// SetUserGroups 替换用户所属主机组，决定 operator 和 viewer 可访问的主机范围
+func SetUserGroups(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	userID, ok := idParam(c)
+	if !ok {
+		return
+	}
+	p := new(authtype.UserGroups)
+	if !bindJSON(c, p) {
+		return
+	}
+	if err := useroption.SetUserGroups(db.(*sqlx.DB), userID, p.GroupIDs); err != nil {
+		respondError(c, "修改用户主机组失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"user_id": userID, "group_ids": p.GroupIDs})
+}
+
+// ListGroups 查询全部主机组
+func ListGroups(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	groups, err := useroption.ListGroups(db.(*sqlx.DB))
+	if err != nil {
+		respondError(c, "查询主机组失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, groups)
+}
+
+// CreateGroup 创建主机组
+func CreateGroup(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p := new(authtype.NewHostGroup)
+	if !bindJSON(c, p) {
+		return
+	}
+	id, err := useroption.CreateGroup(db.(*sqlx.DB), p)
+	if err != nil {
+		respondError(c, "创建主机组失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"id": id, "name": p.Name})
+}
+
+// DeleteGroup 删除主机组
+func DeleteGroup(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	groupID, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if err := useroption.DeleteGroup(db.(*sqlx.DB), groupID); err != nil {
+		respondError(c, "删除主机组失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"id": groupID})
+}
+
+// SetGroupHosts 替换主机组的成员
+func SetGroupHosts(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	groupID, ok := idParam(c)
+	if !ok {
+		return
+	}
+	p := new(authtype.GroupHosts)
+	if !bindJSON(c, p) {
+		return
+	}
+	if err := useroption.SetGroupHosts(db.(*sqlx.DB), groupID, p.HostIDs); err != nil {
+		respondError(c, "修改主机组成员失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"id": groupID, "hostids": p.HostIDs})
+}
@@ -0,0 +1,27 @@
+package clusterwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/clusteroption"
+
+	"github.com/gin-gonic/gin"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// ListServers 查询在线的服务端实例，leader 为当前执行单例任务的实例
+func ListServers(c *gin.Context) {
+	cli, exists := c.Get("etcd")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+
+	servers, err := clusteroption.ListServers(cli.(*clientv3.Client))
+	if err != nil {
+		zap.L().Error("查询服务端实例失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, servers)
+}
@@ -16,6 +16,8 @@ const (
 	CodeHostlist
 	CodeAlarminfo
 	CodeSelectSwitch
+	CodeForbidden
+	CodeTaskExist
 )
 
 var codeMsgMap = map[ResCode]string{
@@ -31,6 +33,8 @@ var codeMsgMap = map[ResCode]string{
 	CodeHostlist:        "主机已存在",
 	CodeAlarminfo:       "报警接口参数错误",
 	CodeSelectSwitch:    "交换机上联信息错误",
+	CodeForbidden:       "权限不足",
+	CodeTaskExist:       "任务已存在",
 }
 
 func (c ResCode) Msg() string {
@@ -0,0 +1,141 @@
+package cronwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/cronoption"
+	"Server/models/tasktype"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// ListJobs 查询 /cron/jobs/ 下的定时任务
+func ListJobs(c *gin.Context) {
+	cli, exists := c.Get("etcd")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+
+	jobs, _, err := cronoption.ListJobs(cli.(*clientv3.Client))
+	if err != nil {
+		zap.L().Error("查询定时任务失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, jobs)
+}
+
+// SaveJob 新增或修改定时任务，同名任务直接覆盖
+func SaveJob(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	cli, exists := c.Get("etcd")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p := new(tasktype.Job)
+	if err := c.ShouldBindJSON(p); err != nil {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return
+	}
+	controller.SetAuditTarget(c, p.Name)
+	if err := cronoption.ValidateJob(p); err != nil {
+		controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+		return
+	}
+
+	old, err := cronoption.SaveJob(cli.(*clientv3.Client), p)
+	if err != nil {
+		zap.L().Error("保存定时任务失败", zap.String("job", p.Name), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	// joblist 只用于展示，etcd 中的任务才是调度依据
+	if err := cronoption.SaveJobRecord(db.(*sqlx.DB), p); err != nil {
+		zap.L().Warn("同步定时任务记录失败", zap.String("job", p.Name), zap.Error(err))
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"job": p, "old": old})
+}
+
+// DeleteJob 删除定时任务，正在执行的一轮不受影响
+func DeleteJob(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	cli, exists := c.Get("etcd")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	name := c.Param("name")
+	controller.SetAuditTarget(c, name)
+
+	old, err := cronoption.DeleteJob(cli.(*clientv3.Client), name)
+	if err != nil {
+		if errors.Is(err, cronoption.ErrJobNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("删除定时任务失败", zap.String("job", name), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	if err := cronoption.DeleteJobRecord(db.(*sqlx.DB), name); err != nil {
+		zap.L().Warn("删除定时任务记录失败", zap.String("job", name), zap.Error(err))
+	}
+	controller.ResopnseSystemDataSuccess(c, old)
+}
+
+// KillJob 终止正在执行的定时任务，不影响后续调度
+func KillJob(c *gin.Context) {
+	cli, exists := c.Get("etcd")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	name := c.Param("name")
+	controller.SetAuditTarget(c, name)
+
+	if err := cronoption.KillJob(cli.(*clientv3.Client), name); err != nil {
+		if errors.Is(err, cronoption.ErrJobNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("终止定时任务失败", zap.String("job", name), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"name": name})
+}
+
+// ListJobLogs 查询定时任务的执行记录
+func ListJobLogs(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p := new(tasktype.JobLogQuery)
+	if err := c.ShouldBindQuery(p); err != nil {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return
+	}
+
+	logs, err := cronoption.ListJobLogs(db.(*sqlx.DB), c.Param("name"), p)
+	if err != nil {
+		zap.L().Error("查询定时任务执行记录失败", zap.String("job", c.Param("name")), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, logs)
+}
@@ -0,0 +1,208 @@
+package enrollwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/enrolloption"
+	"Server/models/enrolltype"
+	"Server/ws"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"strconv"
+)
+
+// CreateToken 创建注册令牌，明文令牌只在响应中返回一次
+func CreateToken(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p := new(enrolltype.NewEnrollmentToken)
+	if err := c.ShouldBindJSON(p); err != nil {
+		var errs validator.ValidationErrors
+		ok := errors.As(err, &errs)
+		if !ok {
+			controller.ResopnseError(c, controller.CodeServerApiType)
+			return
+		}
+		controller.ResponseErrorwithMsg(c, controller.CodeServerApiType, controller.RemoveTopStruct(errs.Translate(controller.Trans)))
+		return
+	}
+
+	token, err := enrolloption.CreateToken(db.(*sqlx.DB), p)
+	if err != nil {
+		zap.L().Error("创建注册令牌失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, token)
+}
+
+// ListTokens 查询注册令牌及使用情况
+func ListTokens(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	tokens, err := enrolloption.ListTokens(db.(*sqlx.DB))
+	if err != nil {
+		zap.L().Error("查询注册令牌失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, tokens)
+}
+
+// RevokeToken 吊销注册令牌，之后不能再用它注册新客户端
+func RevokeToken(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return
+	}
+
+	if err := enrolloption.RevokeToken(db.(*sqlx.DB), id); err != nil {
+		if errors.Is(err, enrolloption.ErrTokenNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("吊销注册令牌失败", zap.Int64("id", id), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"id": id, "revoked": true})
+}
+
+// ListCredentials 查询已注册客户端的凭证状态
+func ListCredentials(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	creds, err := enrolloption.ListCredentials(db.(*sqlx.DB))
+	if err != nil {
+		zap.L().Error("查询客户端凭证失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, creds)
+}
+
+// RevokeCredential 吊销客户端凭证并立即断开该客户端的连接
+func RevokeCredential(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	agentID := c.Param("id")
+
+	if err := enrolloption.RevokeCredential(db.(*sqlx.DB), agentID); err != nil {
+		if errors.Is(err, enrolloption.ErrCredentialNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("吊销客户端凭证失败", zap.String("agent_id", agentID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	// 会话 token 删除失败不影响吊销结果，重连时凭证校验会拒绝该客户端
+	if err := controller.RevokeTokenForClient(agentID); err != nil {
+		zap.L().Warn("删除客户端会话 token 失败", zap.String("agent_id", agentID), zap.Error(err))
+	}
+	closed := ws.DisconnectAgent(agentID, "credential revoked")
+	controller.ResopnseSystemDataSuccess(c, gin.H{"agent_id": agentID, "revoked": true, "disconnected": closed})
+}
+
+// ResetCredential 删除客户端凭证并断开连接，允许该客户端使用注册令牌重新注册
+func ResetCredential(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	agentID := c.Param("id")
+
+	if err := enrolloption.ResetCredential(db.(*sqlx.DB), agentID); err != nil {
+		if errors.Is(err, enrolloption.ErrCredentialNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("重置客户端凭证失败", zap.String("agent_id", agentID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	if err := controller.RevokeTokenForClient(agentID); err != nil {
+		zap.L().Warn("删除客户端会话 token 失败", zap.String("agent_id", agentID), zap.Error(err))
+	}
+	closed := ws.DisconnectAgent(agentID, "credential reset")
+	controller.ResopnseSystemDataSuccess(c, gin.H{"agent_id": agentID, "reset": true, "disconnected": closed})
+}
+
+// ListCertificates 查询客户端的证书
+func ListCertificates(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	certs, err := enrolloption.ListCertificates(db.(*sqlx.DB), c.Param("id"))
+	if err != nil {
+		zap.L().Error("查询客户端证书失败", zap.String("agent_id", c.Param("id")), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, certs)
+}
+
+// RotateCertificate 通知在线客户端生成新密钥并申请证书，旧证书到期前仍然有效
+func RotateCertificate(c *gin.Context) {
+	agentID := c.Param("id")
+	err := ws.SendToAgent(agentID, map[string]interface{}{
+		"action":   "rotate_cert",
+		"agent_id": agentID,
+	})
+	if err != nil {
+		if errors.Is(err, ws.ErrAgentOffline) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, "客户端不在线")
+			return
+		}
+		zap.L().Error("通知客户端轮换证书失败", zap.String("agent_id", agentID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"agent_id": agentID, "rotating": true})
+}
+
+// RevokeCertificate 吊销证书并断开该客户端，之后使用该证书的连接会被拒绝
+func RevokeCertificate(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	serial := c.Param("serial")
+
+	agentID, err := enrolloption.RevokeCertificate(db.(*sqlx.DB), serial)
+	if err != nil {
+		if errors.Is(err, enrolloption.ErrCertificateNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("吊销客户端证书失败", zap.String("serial", serial), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	closed := ws.DisconnectAgent(agentID, "certificate revoked")
+	controller.ResopnseSystemDataSuccess(c, gin.H{"serial": serial, "agent_id": agentID, "revoked": true, "disconnected": closed})
+}
@@ -0,0 +1,215 @@
+package hostwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/clientoption"
+	"Server/dao/hostoption"
+	"Server/models/hosttype"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jmoiron/sqlx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+)
+
+// hostParam 解析路径中的 hostid
+func hostParam(c *gin.Context) (int64, bool) {
+	hostID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || hostID <= 0 {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return 0, false
+	}
+	return hostID, true
+}
+
+// bindJSON 解析请求体，参数有误时直接返回响应
+func bindJSON(c *gin.Context, p interface{}) bool {
+	if err := c.ShouldBindJSON(p); err != nil {
+		var errs validator.ValidationErrors
+		ok := errors.As(err, &errs)
+		if !ok {
+			controller.ResopnseError(c, controller.CodeServerApiType)
+			return false
+		}
+		controller.ResponseErrorwithMsg(c, controller.CodeServerApiType, controller.RemoveTopStruct(errs.Translate(controller.Trans)))
+		return false
+	}
+	return true
+}
+
+// respondHostError 主机不存在时返回参数错误，其余返回服务器忙
+func respondHostError(c *gin.Context, hostID int64, msg string, err error) {
+	if errors.Is(err, hostoption.ErrHostNotFound) {
+		controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+		return
+	}
+	zap.L().Error(msg, zap.Int64("hostid", hostID), zap.Error(err))
+	controller.ResopnseError(c, controller.CodeServerBusy)
+}
+
+// onlineAgents 查询 /agents/online/ 下的在线客户端，失败时直接返回响应
+func onlineAgents(c *gin.Context) (map[string]hosttype.Presence, bool) {
+	cli, exists := c.Get("etcd")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return nil, false
+	}
+	online, _, err := clientoption.ListOnline(cli.(*clientv3.Client))
+	if err != nil {
+		zap.L().Error("查询在线客户端失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return nil, false
+	}
+	return online, true
+}
+
+// ListHosts 查询主机清单，可按状态、标签、关键字和在线状态过滤
+func ListHosts(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p := new(hosttype.HostQuery)
+	if err := c.ShouldBindQuery(p); err != nil {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return
+	}
+	if !controller.IsAdmin(c) {
+		userID, err := controller.GetCurrentUser(c)
+		if err != nil {
+			controller.ResopnseError(c, controller.CodeNeedLogin)
+			return
+		}
+		p.ScopeUserID = &userID
+	}
+
+	online, ok := onlineAgents(c)
+	if !ok {
+		return
+	}
+
+	hosts, err := hostoption.ListHosts(db.(*sqlx.DB), p, online)
+	if err != nil {
+		zap.L().Error("查询主机列表失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, hosts)
+}
+
+// GetHost 查询单个主机详情
+func GetHost(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	hostID, ok := hostParam(c)
+	if !ok {
+		return
+	}
+	if !controller.CheckHostScope(c, db.(*sqlx.DB), hostID) {
+		return
+	}
+
+	online, ok := onlineAgents(c)
+	if !ok {
+		return
+	}
+
+	host, err := hostoption.GetHost(db.(*sqlx.DB), hostID, online)
+	if err != nil {
+		respondHostError(c, hostID, "查询主机失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, host)
+}
+
+// ListOnlineAgents 列出在线客户端及其连接的服务端实例，包括尚未登记为主机的客户端
+func ListOnlineAgents(c *gin.Context) {
+	online, ok := onlineAgents(c)
+	if !ok {
+		return
+	}
+	agents := make([]hosttype.Presence, 0, len(online))
+	for _, p := range online {
+		agents = append(agents, p)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].AgentID < agents[j].AgentID })
+	controller.ResopnseSystemDataSuccess(c, agents)
+}
+
+// UpdateHost 修改主机负责人、位置、备注或状态
+func UpdateHost(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	hostID, ok := hostParam(c)
+	if !ok {
+		return
+	}
+	if !controller.CheckHostScope(c, db.(*sqlx.DB), hostID) {
+		return
+	}
+	p := new(hosttype.HostUpdate)
+	if !bindJSON(c, p) {
+		return
+	}
+
+	if err := hostoption.UpdateHost(db.(*sqlx.DB), hostID, p); err != nil {
+		respondHostError(c, hostID, "修改主机失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"hostid": hostID})
+}
+
+// SetHostTags 替换主机标签
+func SetHostTags(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	hostID, ok := hostParam(c)
+	if !ok {
+		return
+	}
+	if !controller.CheckHostScope(c, db.(*sqlx.DB), hostID) {
+		return
+	}
+	p := new(hosttype.HostTags)
+	if !bindJSON(c, p) {
+		return
+	}
+
+	if err := hostoption.SetHostTags(db.(*sqlx.DB), hostID, p.Tags); err != nil {
+		respondHostError(c, hostID, "修改主机标签失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"hostid": hostID, "tags": p.Tags})
+}
+
+// DecommissionHost 下线主机，保留清单记录和历史数据
+func DecommissionHost(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	hostID, ok := hostParam(c)
+	if !ok {
+		return
+	}
+
+	if err := hostoption.DecommissionHost(db.(*sqlx.DB), hostID); err != nil {
+		respondHostError(c, hostID, "下线主机失败", err)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{"hostid": hostID, "hoststatus": hosttype.HostDecommissioned})
+}
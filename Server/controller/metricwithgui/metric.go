@@ -0,0 +1,98 @@
+package metricwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/metricoption"
+	"Server/models/metrictype"
+	"Server/settings"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"time"
+)
+
+// bindMetricQuery 解析查询参数，缺省查询最近一小时
+func bindMetricQuery(c *gin.Context) (*metrictype.MetricQuery, bool) {
+	p := new(metrictype.MetricQuery)
+	if err := c.ShouldBindQuery(p); err != nil {
+		//请求参数有误,直接返回响应
+		var errs validator.ValidationErrors
+		ok := errors.As(err, &errs)
+		if !ok {
+			controller.ResopnseError(c, controller.CodeServerApiType)
+			return nil, false
+		}
+		controller.ResponseErrorwithMsg(c, controller.CodeServerApiType, controller.RemoveTopStruct(errs.Translate(controller.Trans)))
+		return nil, false
+	}
+	if p.End.IsZero() {
+		p.End = time.Now()
+	}
+	if p.Start.IsZero() {
+		p.Start = p.End.Add(-time.Hour)
+	}
+	if !p.Start.Before(p.End) {
+		controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, "start 必须早于 end")
+		return nil, false
+	}
+	return p, true
+}
+
+// QueryMetrics 查询客户端某项指标在时间区间内的序列
+func QueryMetrics(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p, ok := bindMetricQuery(c)
+	if !ok {
+		return
+	}
+	if !controller.CheckAgentScope(c, db.(*sqlx.DB), p.AgentID) {
+		return
+	}
+
+	data, err := metricoption.RangeQuery(db.(*sqlx.DB), p, settings.Conf.MetricsConfig)
+	if err != nil {
+		zap.L().Error("指标查询失败", zap.String("agent_id", p.AgentID), zap.String("metric", p.Metric), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResopnseSystemDataSuccess(c, data)
+}
+
+// AggregateMetrics 计算客户端某项指标在时间区间内的 avg/max/p95
+func AggregateMetrics(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p, ok := bindMetricQuery(c)
+	if !ok {
+		return
+	}
+	if !controller.CheckAgentScope(c, db.(*sqlx.DB), p.AgentID) {
+		return
+	}
+
+	aggregates, resolution, err := metricoption.AggregateQuery(db.(*sqlx.DB), p, settings.Conf.MetricsConfig)
+	if err != nil {
+		zap.L().Error("指标聚合失败", zap.String("agent_id", p.AgentID), zap.String("metric", p.Metric), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+
+	controller.ResopnseSystemDataSuccess(c, gin.H{
+		"agent_id":   p.AgentID,
+		"metric":     p.Metric,
+		"resolution": resolution,
+		"start":      p.Start,
+		"end":        p.End,
+		"aggregates": aggregates,
+	})
+}
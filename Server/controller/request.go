@@ -1,13 +1,26 @@
 package controller
 
 import (
+	"Server/dao/useroption"
+	"Server/models/authtype"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
 )
 
 var ErrorUserNotLogin = errors.New("用户未登录")
 
-const ContextUserIdKey = "userId"
+const (
+	ContextUserIdKey   = "userId"
+	ContextUserNameKey = "userName"
+	ContextUserRoleKey = "userRole"
+
+	ContextAuditActionKey = "auditAction" // 处理函数细化后的审计动作
+	ContextAuditTargetKey = "auditTarget" // 处理函数指定的操作对象
+	ContextResCodeKey     = "resCode"     // 本次响应的业务码
+	ContextResMsgKey      = "resMsg"      // 本次错误响应的提示信息
+)
 
 // 获取当前用户id
 
@@ -26,3 +39,97 @@ func GetCurrentUser(c *gin.Context) (userId int64, err error) {
 
 	return
 }
+
+// GetCurrentRole 获取当前用户的角色，由权限中间件从数据库读取
+func GetCurrentRole(c *gin.Context) string {
+	role, _ := c.Get(ContextUserRoleKey)
+	r, _ := role.(string)
+	return r
+}
+
+// IsAdmin 管理员不受主机组范围限制
+func IsAdmin(c *gin.Context) bool {
+	return GetCurrentRole(c) == authtype.RoleAdmin
+}
+
+// CheckAgentScope 非管理员只能操作所属主机组内的客户端，无权操作时直接返回响应
+func CheckAgentScope(c *gin.Context, db *sqlx.DB, agentID string) bool {
+	if IsAdmin(c) {
+		return true
+	}
+	userId, err := GetCurrentUser(c)
+	if err != nil {
+		ResopnseError(c, CodeNeedLogin)
+		return false
+	}
+	ok, err := useroption.AgentInScope(db, userId, agentID)
+	if err != nil {
+		zap.L().Error("查询用户主机范围失败", zap.String("agent_id", agentID), zap.Error(err))
+		ResopnseError(c, CodeServerBusy)
+		return false
+	}
+	if !ok {
+		ResopnseError(c, CodeForbidden)
+		return false
+	}
+	return true
+}
+
+// CheckHostScope 非管理员只能访问所属主机组内的主机，无权访问时直接返回响应
+func CheckHostScope(c *gin.Context, db *sqlx.DB, hostID int64) bool {
+	if IsAdmin(c) {
+		return true
+	}
+	userId, err := GetCurrentUser(c)
+	if err != nil {
+		ResopnseError(c, CodeNeedLogin)
+		return false
+	}
+	ok, err := useroption.HostInScope(db, userId, hostID)
+	if err != nil {
+		zap.L().Error("查询用户主机范围失败", zap.Int64("hostid", hostID), zap.Error(err))
+		ResopnseError(c, CodeServerBusy)
+		return false
+	}
+	if !ok {
+		ResopnseError(c, CodeForbidden)
+		return false
+	}
+	return true
+}
+
+// ScopedHostIDs 返回非管理员所属主机组内的主机，管理员不受限制时返回 nil。查询失败时直接返回响应
+func ScopedHostIDs(c *gin.Context, db *sqlx.DB) ([]int64, bool) {
+	if IsAdmin(c) {
+		return nil, true
+	}
+	userId, err := GetCurrentUser(c)
+	if err != nil {
+		ResopnseError(c, CodeNeedLogin)
+		return nil, false
+	}
+	ids, err := useroption.ScopedHostIDs(db, userId)
+	if err != nil {
+		zap.L().Error("查询用户主机范围失败", zap.Int64("user_id", userId), zap.Error(err))
+		ResopnseError(c, CodeServerBusy)
+		return nil, false
+	}
+	return ids, true
+}
+
+// GetCurrentUsername 获取当前用户名，取自登录令牌
+func GetCurrentUsername(c *gin.Context) string {
+	name, _ := c.Get(ContextUserNameKey)
+	n, _ := name.(string)
+	return n
+}
+
+// SetAuditAction 细化本次请求记录到审计日志的动作
+func SetAuditAction(c *gin.Context, action string) {
+	c.Set(ContextAuditActionKey, action)
+}
+
+// SetAuditTarget 指定本次请求记录到审计日志的操作对象
+func SetAuditTarget(c *gin.Context, target string) {
+	c.Set(ContextAuditTargetKey, target)
+}
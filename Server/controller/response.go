@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
@@ -24,6 +25,7 @@ type ResponseDate struct {
 }
 
 func ResopnseError(c *gin.Context, code ResCode) {
+	setResCode(c, code, nil)
 	rd := &ResponseDate{
 		Code: code,
 		Msg:  code.Msg(),
@@ -33,6 +35,7 @@ func ResopnseError(c *gin.Context, code ResCode) {
 }
 
 func ResopnseSuccess(c *gin.Context, data interface{}) {
+	setResCode(c, CodeSuccess, nil)
 	rd := &ResponseDate{
 		Code: CodeSuccess,
 		Msg:  CodeSuccess.Msg(),
@@ -41,6 +44,7 @@ func ResopnseSuccess(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, rd)
 }
 func ResopnseSystemDataSuccess(c *gin.Context, data interface{}) {
+	setResCode(c, CodeSuccess, nil)
 	rd := &ResponseDate{
 		Code: CodeSuccess,
 		Msg:  CodeSuccess.Msg(),
@@ -51,6 +55,7 @@ func ResopnseSystemDataSuccess(c *gin.Context, data interface{}) {
 
 // 自定义响应
 func ResponseErrorwithMsg(c *gin.Context, code ResCode, msg interface{}) {
+	setResCode(c, code, msg)
 	rd := &ResponseDate{
 		Code: code,
 		Msg:  code.Msg(),
@@ -58,3 +63,23 @@ func ResponseErrorwithMsg(c *gin.Context, code ResCode, msg interface{}) {
 	}
 	c.JSON(http.StatusOK, &rd)
 }
+
+// ResponseErrorwithStatus 以指定的 HTTP 状态码返回错误，用于需要客户端按状态码处理的情况
+func ResponseErrorwithStatus(c *gin.Context, status int, code ResCode, msg interface{}) {
+	setResCode(c, code, msg)
+	rd := &ResponseDate{
+		Code: code,
+		Msg:  code.Msg(),
+		Data: msg,
+	}
+	c.JSON(status, &rd)
+}
+
+// setResCode 记录本次响应的业务码，供审计日志判断操作结果
+func setResCode(c *gin.Context, code ResCode, msg interface{}) {
+	c.Set(ContextResCodeKey, code)
+	if msg == nil {
+		msg = code.Msg()
+	}
+	c.Set(ContextResMsgKey, fmt.Sprint(msg))
+}
@@ -0,0 +1,30 @@
+package taskwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/task/mysqloption"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// GetAgentCommands 对比客户端每个任务最近下发的指令与客户端已确认的指令，并列出未确认的指令
+func GetAgentCommands(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	agentID := c.Param("id")
+	if !controller.CheckAgentScope(c, db.(*sqlx.DB), agentID) {
+		return
+	}
+
+	result, err := mysqloption.GetAgentSync(db.(*sqlx.DB), agentID)
+	if err != nil {
+		zap.L().Error("查询客户端指令状态失败", zap.String("agent_id", agentID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, result)
+}
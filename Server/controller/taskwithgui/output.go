@@ -0,0 +1,381 @@
+package taskwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/task/mysqloption"
+	"Server/models/tasktype"
+	"Server/pkg/outputhub"
+	"Server/pkg/outputstore"
+	"Server/settings"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// 未配置时单次执行保存的最大 MB 数
+	defaultOutputMaxSize = 16
+	// 查询接口返回的最大输出字节数，完整内容通过下载接口获取
+	maxOutputContent = 1 << 20
+	// 上传请求中 multipart 边界和字段头允许的额外字节数
+	multipartOverhead = 1 << 20
+)
+
+// outputSettings 返回执行输出的存储目录和大小限制
+func outputSettings() (string, int64) {
+	dir, maxSize := outputstore.DefaultDir, int64(defaultOutputMaxSize)
+	if cfg := settings.Conf.OutputConfig; cfg != nil {
+		if cfg.Dir != "" {
+			dir = cfg.Dir
+		}
+		if cfg.MaxSize > 0 {
+			maxSize = cfg.MaxSize
+		}
+	}
+	return dir, maxSize << 20
+}
+
+// runParams 解析路径中的任务 ID 和执行 ID，失败时已返回响应
+func runParams(c *gin.Context) (string, int64, bool) {
+	runID, err := strconv.ParseInt(c.Param("run_id"), 10, 64)
+	if err != nil {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return "", 0, false
+	}
+	return c.Param("id"), runID, true
+}
+
+// UploadRunOutput 客户端在执行结束后上传完整输出，压缩保存并关联到执行记录，超过大小限制的部分截断
+func UploadRunOutput(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	agentID := c.GetString("agent_id")
+	if agentID == "" {
+		controller.ResopnseError(c, controller.CodeForbidden)
+		return
+	}
+	taskID, runID, ok := runParams(c)
+	if !ok {
+		return
+	}
+	if err := mysqloption.CheckRunAgent(db.(*sqlx.DB), taskID, runID, agentID); err != nil {
+		if errors.Is(err, mysqloption.ErrRunNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("查询执行记录失败", zap.Int64("run_id", runID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	dir, maxSize := outputSettings()
+	fh, ok := uploadedFile(c, maxSize)
+	if !ok {
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	defer f.Close()
+
+	res, err := outputstore.Save(dir, taskID, runID, f, maxSize)
+	if err != nil {
+		if errors.Is(err, outputstore.ErrInvalidTaskID) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("保存执行输出失败", zap.Int64("run_id", runID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	out := &tasktype.RunOutput{
+		RunID:      runID,
+		TaskID:     taskID,
+		AgentID:    agentID,
+		FilePath:   res.Path,
+		Size:       res.Size,
+		StoredSize: res.StoredSize,
+		Truncated:  res.Truncated,
+	}
+	if out.ID, err = mysqloption.SaveRunOutput(db.(*sqlx.DB), out); err != nil {
+		zap.L().Error("保存执行输出记录失败", zap.Int64("run_id", runID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	if res.Truncated {
+		zap.L().Warn("执行输出超过大小限制被截断", zap.String("task_id", taskID), zap.Int64("run_id", runID), zap.Int64("size", fh.Size))
+	}
+	controller.ResopnseSystemDataSuccess(c, out)
+}
+
+// uploadedFile 读取上传的 file 字段，请求体最多为 maxSize 加上 multipart 开销，超出时返回 413。
+// 失败时已返回响应
+func uploadedFile(c *gin.Context, maxSize int64) (*multipart.FileHeader, bool) {
+	limit := maxSize + multipartOverhead
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			controller.ResponseErrorwithStatus(c, http.StatusRequestEntityTooLarge, controller.CodeInvalidParam,
+				fmt.Sprintf("上传的输出超过 %d MB", maxSize>>20))
+			return nil, false
+		}
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return nil, false
+	}
+	return fh, true
+}
+
+// runOutput 查询执行上传的输出，失败时已返回响应
+func runOutput(c *gin.Context) (*tasktype.RunOutput, bool) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return nil, false
+	}
+	taskID, runID, ok := runParams(c)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := taskInScope(c, db.(*sqlx.DB), taskID); !ok {
+		return nil, false
+	}
+	out, err := mysqloption.GetRunOutput(db.(*sqlx.DB), taskID, runID)
+	if err != nil {
+		if errors.Is(err, mysqloption.ErrOutputNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return nil, false
+		}
+		zap.L().Error("查询执行输出失败", zap.Int64("run_id", runID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return nil, false
+	}
+	return out, true
+}
+
+// GetRunOutput 查询执行输出的信息和内容，内容超过 1MB 时只返回开头部分
+func GetRunOutput(c *gin.Context) {
+	out, ok := runOutput(c)
+	if !ok {
+		return
+	}
+	dir, _ := outputSettings()
+	r, err := outputstore.Open(dir, out.FilePath)
+	if err != nil {
+		zap.L().Error("读取执行输出失败", zap.Int64("run_id", out.RunID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	defer r.Close()
+	content, err := io.ReadAll(io.LimitReader(r, maxOutputContent))
+	if err != nil {
+		zap.L().Error("读取执行输出失败", zap.Int64("run_id", out.RunID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{
+		"output":            out,
+		"content":           string(content),
+		"content_truncated": out.Size > int64(len(content)),
+	})
+}
+
+// DownloadRunOutput 下载执行的完整输出
+func DownloadRunOutput(c *gin.Context) {
+	out, ok := runOutput(c)
+	if !ok {
+		return
+	}
+	dir, _ := outputSettings()
+	r, err := outputstore.Open(dir, out.FilePath)
+	if err != nil {
+		zap.L().Error("读取执行输出失败", zap.Int64("run_id", out.RunID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	defer r.Close()
+	c.DataFromReader(http.StatusOK, out.Size, "text/plain; charset=utf-8", r, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%s_%d.log", out.TaskID, out.RunID),
+	})
+}
+
+// 实时输出连接的心跳间隔，避免代理因空闲断开连接
+const outputHeartbeat = 15 * time.Second
+
+// outputPoll 从数据库补齐输出并检查执行状态的间隔。outputhub 只分发本实例收到的输出，
+// 客户端连接在其他实例时依靠轮询推送输出和结束事件
+const outputPoll = 2 * time.Second
+
+// outputTail 向一个 SSE 连接按序号推送执行输出
+type outputTail struct {
+	c       *gin.Context
+	db      *sqlx.DB
+	runID   int64
+	lastSeq int64
+}
+
+// send 推送序号大于 lastSeq 的行，重复的行直接跳过
+func (t *outputTail) send(lines []tasktype.OutputLine) error {
+	for i := range lines {
+		if lines[i].Seq <= t.lastSeq {
+			continue
+		}
+		data, err := json.Marshal(&lines[i])
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(t.c.Writer, "id: %d\nevent: line\ndata: %s\n\n", lines[i].Seq, data); err != nil {
+			return err
+		}
+		t.lastSeq = lines[i].Seq
+	}
+	t.c.Writer.Flush()
+	return nil
+}
+
+// catchUp 从数据库补齐 lastSeq 之后已保存的输出
+func (t *outputTail) catchUp() error {
+	for {
+		lines, err := mysqloption.ListOutput(t.db, t.runID, t.lastSeq, 1000)
+		if err != nil {
+			return err
+		}
+		if err := t.send(lines); err != nil {
+			return err
+		}
+		if len(lines) < 1000 {
+			return nil
+		}
+	}
+}
+
+// end 通知查看者执行已结束
+func (t *outputTail) end(status string) {
+	fmt.Fprintf(t.c.Writer, "event: end\ndata: {\"status\":%q}\n\n", status)
+	t.c.Writer.Flush()
+}
+
+// TailRunOutput 以 SSE 推送一次执行的输出，先补发已保存的输出，执行中时持续推送新的输出直到执行结束。
+// 断线重连时按 Last-Event-ID 或 after_seq 参数从中断处继续
+func TailRunOutput(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	taskID, runID, ok := runParams(c)
+	if !ok {
+		return
+	}
+	afterSeq := c.Query("after_seq")
+	if afterSeq == "" {
+		afterSeq = c.GetHeader("Last-Event-ID")
+	}
+	var lastSeq int64
+	if afterSeq != "" {
+		var err error
+		if lastSeq, err = strconv.ParseInt(afterSeq, 10, 64); err != nil || lastSeq < 0 {
+			controller.ResopnseError(c, controller.CodeInvalidParam)
+			return
+		}
+	}
+	if _, ok := taskInScope(c, db.(*sqlx.DB), taskID); !ok {
+		return
+	}
+
+	// 先订阅再查询执行状态，避免两者之间结束的执行漏掉结束通知
+	sub := outputhub.Subscribe(runID)
+	defer sub.Close()
+	run, err := mysqloption.GetRun(db.(*sqlx.DB), taskID, runID)
+	if err != nil {
+		if errors.Is(err, mysqloption.ErrRunNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("查询执行记录失败", zap.Int64("run_id", runID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	t := &outputTail{c: c, db: db.(*sqlx.DB), runID: runID, lastSeq: lastSeq}
+	if err := t.catchUp(); err != nil {
+		zap.L().Warn("推送任务输出失败", zap.Int64("run_id", runID), zap.Error(err))
+		return
+	}
+	if run.Status != tasktype.TaskRunning {
+		t.end(run.Status)
+		return
+	}
+
+	ticker := time.NewTicker(outputHeartbeat)
+	defer ticker.Stop()
+	poll := time.NewTicker(outputPoll)
+	defer poll.Stop()
+	for {
+		select {
+		case lines, ok := <-sub.C:
+			if !ok {
+				// 执行已结束，补齐最后一批后结束
+				if err := t.catchUp(); err != nil {
+					return
+				}
+				if run, err := mysqloption.GetRun(t.db, taskID, runID); err == nil {
+					t.end(run.Status)
+				}
+				return
+			}
+			// 推送过慢时会丢弃批次，发现序号不连续时从数据库补齐
+			if len(lines) > 0 && lines[0].Seq > t.lastSeq+1 {
+				err = t.catchUp()
+			} else {
+				err = t.send(lines)
+			}
+			if err != nil {
+				return
+			}
+		case <-poll.C:
+			if err := t.catchUp(); err != nil {
+				return
+			}
+			run, err := mysqloption.GetRun(t.db, taskID, runID)
+			if err != nil {
+				zap.L().Warn("查询执行记录失败", zap.Int64("run_id", runID), zap.Error(err))
+				continue
+			}
+			if run.Status != tasktype.TaskRunning {
+				// 结束前的输出可能与状态同时写入，再补齐一次
+				if err := t.catchUp(); err != nil {
+					return
+				}
+				t.end(run.Status)
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
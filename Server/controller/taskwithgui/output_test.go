@@ -0,0 +1,58 @@
+package taskwithgui
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadRequest 构造以 file 字段上传 size 字节的 multipart 请求
+func uploadRequest(t *testing.T, size int) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile("file", "output.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(bytes.Repeat([]byte("x"), size))
+	w.Close()
+	req := httptest.NewRequest(http.MethodPost, "/tasks/t/runs/1/output", body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestUploadedFileLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const maxSize = 1 << 20
+	tests := []struct {
+		name   string
+		size   int
+		ok     bool
+		status int
+	}{
+		{"within limit", maxSize, true, http.StatusOK},
+		// 超过 maxSize 但在 multipart 开销内，由保存时截断
+		{"truncated by save", maxSize + 1024, true, http.StatusOK},
+		{"over limit", maxSize + multipartOverhead + 1, false, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = uploadRequest(t, tt.size)
+		fh, ok := uploadedFile(c, maxSize)
+		if ok != tt.ok {
+			t.Fatalf("%s: uploadedFile() ok = %v, want %v", tt.name, ok, tt.ok)
+		}
+		if ok && fh.Size != int64(tt.size) {
+			t.Errorf("%s: file size = %d, want %d", tt.name, fh.Size, tt.size)
+		}
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package taskwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/task/mysqloption"
+	"Server/models/tasktype"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"strconv"
+)
+
+// taskInScope 查询任务并校验当前用户能否访问其所属客户端，失败时已返回响应
+func taskInScope(c *gin.Context, db *sqlx.DB, taskID string) (*tasktype.TaskState, bool) {
+	state, err := mysqloption.GetTaskState(db, taskID)
+	if err != nil {
+		if errors.Is(err, mysqloption.ErrTaskNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return nil, false
+		}
+		zap.L().Error("查询任务状态失败", zap.String("task_id", taskID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return nil, false
+	}
+	if !controller.CheckAgentScope(c, db, state.AgentID) {
+		return nil, false
+	}
+	return state, true
+}
+
+// GetTaskState 查询任务当前的生命周期状态
+func GetTaskState(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	state, ok := taskInScope(c, db.(*sqlx.DB), c.Param("id"))
+	if !ok {
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, state)
+}
+
+// ListTaskRuns 查询任务的执行记录，按开始时间倒序
+func ListTaskRuns(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	p := new(tasktype.RunQuery)
+	if err := c.ShouldBindQuery(p); err != nil {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return
+	}
+	taskID := c.Param("id")
+	if _, ok := taskInScope(c, db.(*sqlx.DB), taskID); !ok {
+		return
+	}
+
+	runs, err := mysqloption.ListRuns(db.(*sqlx.DB), taskID, p)
+	if err != nil {
+		zap.L().Error("查询任务执行记录失败", zap.String("task_id", taskID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, runs)
+}
+
+// ListRunAttempts 查询一次执行中按重试策略进行的各次尝试
+func ListRunAttempts(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	runID, err := strconv.ParseInt(c.Param("run_id"), 10, 64)
+	if err != nil {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return
+	}
+	taskID := c.Param("id")
+	if _, ok := taskInScope(c, db.(*sqlx.DB), taskID); !ok {
+		return
+	}
+
+	attempts, err := mysqloption.ListAttempts(db.(*sqlx.DB), taskID, runID)
+	if err != nil {
+		if errors.Is(err, mysqloption.ErrRunNotFound) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("查询执行尝试失败", zap.Int64("run_id", runID), zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, attempts)
+}
@@ -0,0 +1,137 @@
+package taskwithgui
+
+import (
+	"Server/controller"
+	"Server/dao/task/mysqloption"
+	"Server/models/tasktype"
+	"Server/pkg/outputstore"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"regexp"
+	"strings"
+)
+
+// 单次搜索最多读取的解压后字节数，避免一个请求扫描全部候选执行的完整输出
+const maxSearchBytes = 256 << 20
+
+// outputMatcher 按搜索参数构造行匹配函数
+func outputMatcher(q *tasktype.OutputSearch) (func(string) bool, error) {
+	if q.Regex {
+		expr := q.Query
+		if q.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+	if q.IgnoreCase {
+		sub := strings.ToLower(q.Query)
+		return func(line string) bool { return strings.Contains(strings.ToLower(line), sub) }, nil
+	}
+	return func(line string) bool { return strings.Contains(line, q.Query) }, nil
+}
+
+// SearchRunOutput 在已上传的执行输出中按子串或正则搜索，按任务、主机、执行状态和开始时间过滤，
+// 从最近的执行开始返回匹配行及其上下文
+func SearchRunOutput(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
+	}
+	q := new(tasktype.OutputSearch)
+	if err := c.ShouldBindQuery(q); err != nil {
+		controller.ResopnseError(c, controller.CodeInvalidParam)
+		return
+	}
+	if q.Limit <= 0 || q.Limit > 1000 {
+		q.Limit = 100
+	}
+	match, err := outputMatcher(q)
+	if err != nil {
+		controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+		return
+	}
+	// 非管理员只搜索所属主机组内主机的输出
+	hostIDs, ok := controller.ScopedHostIDs(c, db.(*sqlx.DB))
+	if !ok {
+		return
+	}
+	q.HostIDs = hostIDs
+
+	candidates, more, err := mysqloption.SearchCandidates(db.(*sqlx.DB), q)
+	if err != nil {
+		zap.L().Error("查询执行输出失败", zap.Error(err))
+		controller.ResopnseError(c, controller.CodeServerBusy)
+		return
+	}
+	dir, _ := outputSettings()
+	res, err := searchRuns(c.Request.Context(), dir, candidates, match, q, maxSearchBytes)
+	if err != nil {
+		// 请求已取消，不再返回结果
+		zap.L().Info("搜索执行输出的请求已取消", zap.Int("scanned_runs", res.scanned))
+		return
+	}
+	controller.ResopnseSystemDataSuccess(c, gin.H{
+		"matches":       res.matches,
+		"scanned_runs":  res.scanned,
+		"scanned_bytes": res.scannedBytes,
+		"truncated":     more || res.truncated, // 达到匹配数、扫描数或读取字节数上限，缩小过滤条件可查看更多结果
+	})
+}
+
+// runSearch 一次请求的搜索结果
+type runSearch struct {
+	matches      []tasktype.OutputMatch
+	scanned      int   // 已搜索的执行数
+	scannedBytes int64 // 已读取的解压后字节数
+	truncated    bool  // 达到匹配数或读取字节数上限
+}
+
+// searchRuns 按顺序搜索候选执行的输出，匹配数达到 q.Limit 或读取达到 budget 字节时停止。
+// ctx 取消时返回 ctx 的错误
+func searchRuns(ctx context.Context, dir string, candidates []tasktype.OutputCandidate, match func(string) bool,
+	q *tasktype.OutputSearch, budget int64) (*runSearch, error) {
+	res := &runSearch{matches: []tasktype.OutputMatch{}}
+	for _, run := range candidates {
+		if len(res.matches) >= q.Limit || res.scannedBytes >= budget {
+			res.truncated = true
+			break
+		}
+		res.scanned++
+		hits, n, err := outputstore.Search(ctx, dir, run.FilePath, match, q.Context, q.Limit-len(res.matches), budget-res.scannedBytes)
+		res.scannedBytes += n
+		if err != nil {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			if errors.Is(err, outputstore.ErrSearchBudget) {
+				res.truncated = true
+			} else {
+				zap.L().Warn("搜索执行输出失败", zap.Int64("run_id", run.RunID), zap.Error(err))
+				continue
+			}
+		}
+		for _, h := range hits {
+			res.matches = append(res.matches, tasktype.OutputMatch{
+				RunID:     run.RunID,
+				TaskID:    run.TaskID,
+				AgentID:   run.AgentID,
+				HostID:    run.HostID,
+				Status:    run.Status,
+				StartTime: run.StartTime,
+				LineNo:    h.LineNo,
+				Line:      h.Line,
+				Before:    h.Before,
+				After:     h.After,
+			})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,119 @@
+package taskwithgui
+
+import (
+	"Server/models/tasktype"
+	"Server/pkg/outputstore"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOutputMatcher(t *testing.T) {
+	tests := []struct {
+		name string
+		q    tasktype.OutputSearch
+		line string
+		want bool
+	}{
+		{"substring", tasktype.OutputSearch{Query: "err"}, "an error occurred", true},
+		{"substring case", tasktype.OutputSearch{Query: "ERR"}, "an error occurred", false},
+		{"substring ignore case", tasktype.OutputSearch{Query: "ERR", IgnoreCase: true}, "an error occurred", true},
+		{"regex", tasktype.OutputSearch{Query: `exit code \d+`, Regex: true}, "exit code 137", true},
+		{"regex no match", tasktype.OutputSearch{Query: `^exit`, Regex: true}, "an exit", false},
+		{"regex ignore case", tasktype.OutputSearch{Query: `^EXIT`, Regex: true, IgnoreCase: true}, "exit 1", true},
+		// 子串搜索不解释正则元字符
+		{"substring metachar", tasktype.OutputSearch{Query: "a.c"}, "abc", false},
+	}
+	for _, tt := range tests {
+		match, err := outputMatcher(&tt.q)
+		if err != nil {
+			t.Fatalf("%s: outputMatcher() error = %v", tt.name, err)
+		}
+		if got := match(tt.line); got != tt.want {
+			t.Errorf("%s: match(%q) = %v, want %v", tt.name, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestOutputMatcherInvalidRegex(t *testing.T) {
+	for _, expr := range []string{"(", "[a-", `\`} {
+		if _, err := outputMatcher(&tasktype.OutputSearch{Query: expr, Regex: true}); err == nil {
+			t.Errorf("outputMatcher(%q) error = nil, want error", expr)
+		}
+	}
+}
+
+// saveRun 保存一次执行的输出，返回候选记录
+func saveRun(t *testing.T, dir string, runID int64, content string) tasktype.OutputCandidate {
+	t.Helper()
+	res, err := outputstore.Save(dir, "task", runID, strings.NewReader(content), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tasktype.OutputCandidate{RunID: runID, TaskID: "task", FilePath: res.Path}
+}
+
+func TestSearchRunsBudget(t *testing.T) {
+	dir := t.TempDir()
+	line := strings.Repeat("x", 99) + "\n"
+	candidates := []tasktype.OutputCandidate{
+		saveRun(t, dir, 1, strings.Repeat(line, 10)),
+		saveRun(t, dir, 2, strings.Repeat(line, 10)),
+		saveRun(t, dir, 3, strings.Repeat(line, 10)),
+	}
+	never := func(string) bool { return false }
+	q := &tasktype.OutputSearch{Limit: 10}
+
+	// 预算在第二次执行中用完，不再搜索第三次执行
+	res, err := searchRuns(context.Background(), dir, candidates, never, q, 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.truncated || res.scanned != 2 || res.scannedBytes != 1500 {
+		t.Errorf("truncated, scanned, bytes = %v, %d, %d, want true, 2, 1500", res.truncated, res.scanned, res.scannedBytes)
+	}
+
+	// 预算正好读完前两次执行时停在第三次之前
+	res, err = searchRuns(context.Background(), dir, candidates, never, q, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.truncated || res.scanned != 2 {
+		t.Errorf("truncated, scanned = %v, %d, want true, 2", res.truncated, res.scanned)
+	}
+
+	res, err = searchRuns(context.Background(), dir, candidates, never, q, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.truncated || res.scanned != 3 || res.scannedBytes != 3000 {
+		t.Errorf("truncated, scanned, bytes = %v, %d, %d, want false, 3, 3000", res.truncated, res.scanned, res.scannedBytes)
+	}
+}
+
+func TestSearchRunsLimitAndCancel(t *testing.T) {
+	dir := t.TempDir()
+	candidates := []tasktype.OutputCandidate{
+		saveRun(t, dir, 1, "hit\nmiss\nhit\n"),
+		saveRun(t, dir, 2, "hit\n"),
+	}
+	match := func(line string) bool { return line == "hit" }
+
+	res, err := searchRuns(context.Background(), dir, candidates, match, &tasktype.OutputSearch{Limit: 2}, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.matches) != 2 || !res.truncated || res.scanned != 1 {
+		t.Errorf("matches, truncated, scanned = %d, %v, %d, want 2, true, 1", len(res.matches), res.truncated, res.scanned)
+	}
+	if res.matches[1].RunID != 1 || res.matches[1].LineNo != 3 {
+		t.Errorf("second match = run %d line %d, want run 1 line 3", res.matches[1].RunID, res.matches[1].LineNo)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := searchRuns(ctx, dir, candidates, match, &tasktype.OutputSearch{Limit: 10}, 1<<20); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
@@ -1,17 +1,18 @@
 package taskwithgui
 
 import (
-	"Server/common"
 	"Server/controller"
 	"Server/dao/task/mysqloption"
+	"Server/models/audittype"
 	"Server/models/tasktype"
+	"Server/ws"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/jmoiron/sqlx"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
-	"log"
 )
 
 func TaskManager(c *gin.Context) {
@@ -25,17 +26,6 @@ func TaskManager(c *gin.Context) {
 		controller.ResopnseError(c, controller.CodeServerApiType)
 		return
 	}
-	// 获取 WebSocket 管理器
-	wsManager, exists := c.Get("wsManager") // 假设 WebSocket 管理器也存储在 gin.Context 中
-	if !exists {
-		controller.ResopnseError(c, controller.CodeServerApiType)
-		return
-	}
-	manager, ok := wsManager.(*common.WebSocketManager) // 确保类型转换正确
-	if !ok {
-		log.Println("Invalid type for WebSocketManager in context")
-		return
-	}
 	p := new(tasktype.TaskRequestOption)
 	if err := c.ShouldBindJSON(&p); err != nil {
 		//请求参数有误,直接返回响应
@@ -48,9 +38,31 @@ func TaskManager(c *gin.Context) {
 		controller.ResponseErrorwithMsg(c, controller.CodeServerApiType, controller.RemoveTopStruct(errs.Translate(controller.Trans)))
 		return
 	}
-	data, err := mysqloption.TaskOptionCore(p, db.(*sqlx.DB), cli.(*clientv3.Client), manager.GetClients())
+	// 非管理员只能操作所属主机组内的客户端
+	agentID := p.Record.AgentID
+	if p.Option == "stop" {
+		agentID = p.TaskControl.AgentID
+	}
+	controller.SetAuditAction(c, audittype.ActionTask+"."+p.Option)
+	if p.Option == "stop" {
+		controller.SetAuditTarget(c, fmt.Sprintf("agent_id=%s,task_id=%s", agentID, p.TaskControl.TaskID))
+	} else {
+		controller.SetAuditTarget(c, fmt.Sprintf("agent_id=%s,file_id=%d", agentID, p.FileId))
+	}
+	if p.Option != "query" && !controller.CheckAgentScope(c, db.(*sqlx.DB), agentID) {
+		return
+	}
+	data, err := mysqloption.TaskOptionCore(p, db.(*sqlx.DB), cli.(*clientv3.Client), ws.DeliverCommand)
 	if err != nil {
 		zap.L().Error("参数请求错误", zap.String("ParameterType", p.Option), zap.Error(err))
+		if errors.Is(err, mysqloption.ErrTaskExists) {
+			controller.ResponseErrorwithMsg(c, controller.CodeTaskExist, err.Error())
+			return
+		}
+		if errors.Is(err, mysqloption.ErrInvalidAction) {
+			controller.ResponseErrorwithMsg(c, controller.CodeInvalidParam, err.Error())
+			return
+		}
 		controller.ResopnseError(c, controller.CodeUserNotExist)
 
 		return
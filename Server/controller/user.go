@@ -1,25 +1,33 @@
 package controller
 
 import (
-	"Server/dao/mysql"
+	"Server/dao/useroption"
 	"Server/models"
+	"Server/models/authtype"
+	"Server/pkg/jwt"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
-// LoginUserVerif 查询交换机端口
-// @Summary 查询交换机端口
-// @Description 查询交换机端口
+// LoginUserVerif 用户登录
+// @Summary 用户登录
+// @Description 校验用户名和密码，签发 JWT
 // @Accept  json
 // @Produce  json
-// @Param data body models.SelectSwitchMac true "查询交换机的参数"
-// @Success 200 {object} models.ClientSwitchInfo "成功"
+// @Param data body models.LoginUserinfo true "登录参数"
+// @Success 200 {object} authtype.LoginResult "成功"
 // @Failure 500 {object} models.ErrorResponse "内部错误"
-// @Router /Login [post]
+// @Router /login [post]
 
 func LoginUserVerif(c *gin.Context) {
+	db, exists := c.Get("db")
+	if !exists {
+		ResopnseError(c, CodeServerApiType)
+		return
+	}
 	p := new(models.LoginUserinfo)
 	if err := c.ShouldBindJSON(&p); err != nil {
 		//请求参数有误,直接返回响应
@@ -32,17 +40,34 @@ func LoginUserVerif(c *gin.Context) {
 		ResponseErrorwithMsg(c, CodeServerApiType, RemoveTopStruct(errs.Translate(Trans)))
 		return
 	}
-	var userinfo models.User
-	userinfo.Name = p.UserName
-	userinfo.UserId = p.UserCode
-	err := mysql.LoginCode(&userinfo)
+	SetAuditTarget(c, p.UserName)
+	user, err := useroption.Login(db.(*sqlx.DB), p.UserName, p.Password)
 	if err != nil {
-		zap.L().Error("用户信息核对失败", zap.String("ParameterType", p.UserName), zap.Error(err))
-		ResopnseError(c, CodeUserNotExist)
-
+		zap.L().Warn("用户信息核对失败", zap.String("username", p.UserName), zap.Error(err))
+		switch {
+		case errors.Is(err, useroption.ErrInvalidPassword):
+			ResopnseError(c, CodeInvalidPassword)
+		case errors.Is(err, useroption.ErrUserDisabled):
+			ResponseErrorwithMsg(c, CodeForbidden, err.Error())
+		default:
+			ResopnseError(c, CodeServerBusy)
+		}
 		return
 	}
+	// 审计日志记录登录成功的用户
+	c.Set(ContextUserIdKey, user.UserID)
+	c.Set(ContextUserNameKey, user.Username)
 	//3.返回响应
-
-	ResopnseSystemDataSuccess(c, "用户信息核对正确")
+	token, expiresAt, err := jwt.GenToken(user.UserID, user.Username, user.Role)
+	if err != nil {
+		zap.L().Error("签发登录令牌失败", zap.String("username", p.UserName), zap.Error(err))
+		ResopnseError(c, CodeServerBusy)
+		return
+	}
+	ResopnseSystemDataSuccess(c, authtype.LoginResult{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		Username:  user.Username,
+		Role:      user.Role,
+	})
 }
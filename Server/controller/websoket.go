@@ -3,16 +3,18 @@ package controller
 import (
 	"Server/dao/etcd"
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"log"
 	"time"
 )
 
-func GetTokenForClientFromController(clientIP string) (string, time.Time, error) {
-	key := fmt.Sprintf("client_ip:%s", clientIP)
+// GetTokenForClientFromController 按客户端 ID 获取有效 token，不存在或已过期时重新生成
+func GetTokenForClientFromController(agentID string) (string, time.Time, error) {
+	key := fmt.Sprintf("client_id:%s", agentID)
 
 	// 输出查询的 key 信息
-	log.Printf("Trying to get token for client IP: %s from etcd with key: %s", clientIP, key)
+	log.Printf("Trying to get token for agent: %s from etcd with key: %s", agentID, key)
 	if etcd.GJobMgr.Kv == nil {
 		log.Printf("Error: etcd.GJobMgr.Kv is nil")
 		return "", time.Time{}, fmt.Errorf("etcd client is not initialized")
@@ -25,10 +27,10 @@ func GetTokenForClientFromController(clientIP string) (string, time.Time, error)
 		return "", time.Time{}, fmt.Errorf("failed to get key from etcd: %v", err)
 	}
 
-	// 如果有数据，打印 etcd 返回的数据
+	// token 是客户端后续消息的凭证，日志中不记录其内容
 	if len(resp.Kvs) > 0 {
 		etcdValue := string(resp.Kvs[0].Value)
-		log.Printf("Found token in etcd: %s", etcdValue)
+		log.Printf("Found token in etcd for agent: %s", agentID)
 
 		tokenInfo, err := etcd.ParseTokenInfo(etcdValue)
 		if err != nil {
@@ -37,28 +39,60 @@ func GetTokenForClientFromController(clientIP string) (string, time.Time, error)
 		}
 		// 使用 ExpiresAtTime 字段调用 After 方法来比较时间
 		if tokenInfo.ExpiresAtTime.After(time.Now()) {
-			log.Printf("Token is valid, returning existing token for IP: %s", clientIP)
+			log.Printf("Token is valid, returning existing token for agent: %s", agentID)
 			return tokenInfo.Token, tokenInfo.ExpiresAtTime, nil
 		} else {
-			log.Printf("Token for IP %s has expired", clientIP)
+			log.Printf("Token for agent %s has expired", agentID)
 		}
 	} else {
-		log.Printf("No token found for IP: %s in etcd", clientIP)
+		log.Printf("No token found for agent: %s in etcd", agentID)
 	}
 
 	// 如果没有现有 token 或 token 已过期，则生成新 token
-	newToken := etcd.GenerateToken(clientIP) // 调用 etcd 包中的 token 生成函数
+	newToken, err := etcd.GenerateToken() // 调用 etcd 包中的 token 生成函数
+	if err != nil {
+		return "", time.Time{}, err
+	}
 	expiresAt := time.Now().Add(24 * time.Hour)
 
 	// 将新 token 存入 etcd，并设置 24 小时的 TTL
-	log.Printf("Storing new token for IP: %s with expiration time: %s", clientIP, expiresAt.Format(time.RFC3339))
+	log.Printf("Storing new token for agent: %s with expiration time: %s", agentID, expiresAt.Format(time.RFC3339))
 	_, err = etcd.GJobMgr.Kv.Put(context.Background(), key, fmt.Sprintf("token:%s,expires_at:%s", newToken, expiresAt.Format(time.RFC3339)))
 	if err != nil {
 		log.Printf("Error while storing new token to etcd: %v", err)
 		return "", time.Time{}, fmt.Errorf("failed to store new token in etcd: %v", err)
 	}
 
-	log.Printf("New token generated and stored for IP: %s, Token: %s, ExpiresAt: %s", clientIP, newToken, expiresAt.Format(time.RFC3339))
+	log.Printf("New token generated and stored for agent: %s, ExpiresAt: %s", agentID, expiresAt.Format(time.RFC3339))
 
 	return newToken, expiresAt, nil
 }
+
+// RevokeTokenForClient 删除客户端的会话 token，客户端重连时需重新校验凭证
+func RevokeTokenForClient(agentID string) error {
+	if etcd.GJobMgr.Kv == nil {
+		return fmt.Errorf("etcd client is not initialized")
+	}
+	key := fmt.Sprintf("client_id:%s", agentID)
+	if _, err := etcd.GJobMgr.Kv.Delete(context.Background(), key); err != nil {
+		return fmt.Errorf("failed to delete token from etcd: %v", err)
+	}
+	return nil
+}
+
+// VerifyTokenForClient 校验 token 是否为 etcd 中该客户端未过期的会话 token，
+// 客户端连接在其他实例时用于校验其 HTTP 请求
+func VerifyTokenForClient(agentID, token string) bool {
+	if etcd.GJobMgr.Kv == nil || agentID == "" || token == "" {
+		return false
+	}
+	resp, err := etcd.GJobMgr.Kv.Get(context.Background(), fmt.Sprintf("client_id:%s", agentID))
+	if err != nil || len(resp.Kvs) == 0 {
+		return false
+	}
+	tokenInfo, err := etcd.ParseTokenInfo(string(resp.Kvs[0].Value))
+	if err != nil || !tokenInfo.ExpiresAtTime.After(time.Now()) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(tokenInfo.Token), []byte(token)) == 1
+}
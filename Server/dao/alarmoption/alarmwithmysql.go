@@ -0,0 +1,204 @@
+package alarmoption
+
+import (
+	"Server/models/alarmtype"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ErrRuleNotFound 报警规则不存在
+var ErrRuleNotFound = errors.New("报警规则不存在")
+
+// ListRules 查询所有报警规则
+func ListRules(db *sqlx.DB) ([]alarmtype.AlarmRule, error) {
+	var rules []alarmtype.AlarmRule
+	query := `SELECT id, hostid, metric, label, operator, threshold, duration, alarmtype, enabled, note FROM alarm_rules ORDER BY id`
+	if err := db.Select(&rules, query); err != nil {
+		return nil, fmt.Errorf("查询报警规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// GetRule 查询报警规则，不存在时返回 ErrRuleNotFound
+func GetRule(db *sqlx.DB, id int64) (*alarmtype.AlarmRule, error) {
+	var rule alarmtype.AlarmRule
+	err := db.Get(&rule, `SELECT id, hostid, metric, label, operator, threshold, duration, alarmtype, enabled, note FROM alarm_rules WHERE id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrRuleNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询报警规则失败: %w", err)
+	}
+	return &rule, nil
+}
+
+// SaveRule 新增或更新报警规则，ID 为 0 时新增
+func SaveRule(db *sqlx.DB, rule *alarmtype.AlarmRule) (int64, error) {
+	if rule.ID == 0 {
+		query := `
+			INSERT INTO alarm_rules (hostid, metric, label, operator, threshold, duration, alarmtype, enabled, note)
+			VALUES (:hostid, :metric, :label, :operator, :threshold, :duration, :alarmtype, :enabled, :note)
+		`
+		result, err := db.NamedExec(query, rule)
+		if err != nil {
+			zap.L().Error("Failed to insert alarm rule", zap.Error(err))
+			return 0, fmt.Errorf("failed to insert alarm rule: %w", err)
+		}
+		return result.LastInsertId()
+	}
+
+	query := `
+		UPDATE alarm_rules SET hostid = :hostid, metric = :metric, label = :label, operator = :operator,
+		threshold = :threshold, duration = :duration, alarmtype = :alarmtype, enabled = :enabled, note = :note
+		WHERE id = :id
+	`
+	result, err := db.NamedExec(query, rule)
+	if err != nil {
+		zap.L().Error("Failed to update alarm rule", zap.Int64("id", rule.ID), zap.Error(err))
+		return 0, fmt.Errorf("failed to update alarm rule: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		if err := db.Get(new(int64), `SELECT id FROM alarm_rules WHERE id = ?`, rule.ID); errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("报警规则 %d 不存在", rule.ID)
+		}
+	}
+	return rule.ID, nil
+}
+
+// DeleteRule 删除报警规则
+func DeleteRule(db *sqlx.DB, id int64) error {
+	if _, err := db.Exec(`DELETE FROM alarm_rules WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("删除报警规则失败: %w", err)
+	}
+	return nil
+}
+
+// GetNotification 获取最新的通知配置
+func GetNotification(db *sqlx.DB) (*alarmtype.Notification, error) {
+	var n alarmtype.Notification
+	query := `SELECT id, cpuoption, memoryoption, systemdiskoption, thresholdstatus, workapiurl, workatuser, dingapiurl, dingatuser
+		FROM notification ORDER BY id DESC LIMIT 1`
+	if err := db.Get(&n, query); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询通知配置失败: %w", err)
+	}
+	return &n, nil
+}
+
+// LoadAlarmSettings 查询主机报警开关，key 为 hostid 和报警类型
+func LoadAlarmSettings(db *sqlx.DB) (map[[2]int64]alarmtype.AlarmSetting, error) {
+	var list []alarmtype.AlarmSetting
+	if err := db.Select(&list, `SELECT hostid, alarmtype, alarmstatus, alarmhostonwer FROM alarmsetting WHERE hostid IS NOT NULL`); err != nil {
+		return nil, fmt.Errorf("查询报警设置失败: %w", err)
+	}
+	settings := make(map[[2]int64]alarmtype.AlarmSetting, len(list))
+	for _, s := range list {
+		settings[[2]int64{s.HostID, int64(s.AlarmType)}] = s
+	}
+	return settings, nil
+}
+
+// LoadHosts 查询已绑定客户端的主机，key 为客户端 ID
+func LoadHosts(db *sqlx.DB) (map[string]alarmtype.HostRef, error) {
+	var list []alarmtype.HostRef
+	if err := db.Select(&list, `SELECT hostid, agent_id, hostname, hostip, hostowner, hoststatus FROM hostlist WHERE agent_id IS NOT NULL`); err != nil {
+		return nil, fmt.Errorf("查询主机列表失败: %w", err)
+	}
+	hosts := make(map[string]alarmtype.HostRef, len(list))
+	for _, h := range list {
+		hosts[h.AgentID.String] = h
+	}
+	return hosts, nil
+}
+
+// InsertAlarm 记录一次报警触发
+func InsertAlarm(db *sqlx.DB, stat *alarmtype.AlarmStatistic) error {
+	query := `
+		INSERT INTO alarmstatistics (alarmid, hostid, ruleid, alarmlabel, alarmstatus, alarmtype, alarminfo, alarmnote, alarmstarttime)
+		VALUES (:alarmid, :hostid, :ruleid, :alarmlabel, :alarmstatus, :alarmtype, :alarminfo, :alarmnote, :alarmstarttime)
+	`
+	if _, err := db.NamedExec(query, stat); err != nil {
+		zap.L().Error("Failed to insert alarm statistic", zap.Int64("alarmid", stat.AlarmID), zap.Error(err))
+		return fmt.Errorf("failed to insert alarm statistic: %w", err)
+	}
+	return nil
+}
+
+// ResolveAlarm 记录报警恢复，alarmstarttime 带 ON UPDATE 属性，需显式保留原值
+func ResolveAlarm(db *sqlx.DB, alarmID int64, note string, stopTime time.Time) error {
+	query := `UPDATE alarmstatistics SET alarmstatus = ?, alarmnote = ?, alarmstoptime = ?, alarmstarttime = alarmstarttime WHERE alarmid = ?`
+	if _, err := db.Exec(query, alarmtype.AlarmResolved, note, stopTime, alarmID); err != nil {
+		zap.L().Error("Failed to resolve alarm", zap.Int64("alarmid", alarmID), zap.Error(err))
+		return fmt.Errorf("failed to resolve alarm: %w", err)
+	}
+	return nil
+}
+
+// FiringAlarm 正在报警的记录及其主机绑定的客户端 ID
+type FiringAlarm struct {
+	alarmtype.AlarmStatistic
+	AgentID string `db:"agent_id"`
+}
+
+// LoadFiringAlarms 查询由规则引擎产生且尚未恢复的报警
+func LoadFiringAlarms(db *sqlx.DB) ([]FiringAlarm, error) {
+	var list []FiringAlarm
+	query := `
+		SELECT a.id, a.alarmid, a.hostid, a.ruleid, COALESCE(a.alarmlabel, '') AS alarmlabel, a.alarmstatus, a.alarmtype,
+		COALESCE(a.alarminfo, '') AS alarminfo, COALESCE(a.alarmnote, '') AS alarmnote, a.alarmstarttime, a.alarmstoptime,
+		COALESCE(h.agent_id, '') AS agent_id
+		FROM alarmstatistics a JOIN hostlist h ON a.hostid = h.hostid
+		WHERE a.alarmstatus = ? AND a.ruleid IS NOT NULL
+	`
+	if err := db.Select(&list, query, alarmtype.AlarmFiring); err != nil {
+		return nil, fmt.Errorf("查询未恢复报警失败: %w", err)
+	}
+	return list, nil
+}
+
+// ListAlarms 按条件查询报警记录
+func ListAlarms(db *sqlx.DB, q *alarmtype.AlarmEventQuery) ([]alarmtype.AlarmStatistic, error) {
+	query := `
+		SELECT id, alarmid, hostid, COALESCE(ruleid, 0) AS ruleid, COALESCE(alarmlabel, '') AS alarmlabel, alarmstatus, alarmtype,
+		COALESCE(alarminfo, '') AS alarminfo, COALESCE(alarmnote, '') AS alarmnote, alarmstarttime, alarmstoptime
+		FROM alarmstatistics WHERE 1 = 1
+	`
+	var args []interface{}
+	if q.HostID != 0 {
+		query += " AND hostid = ?"
+		args = append(args, q.HostID)
+	}
+	if q.HostIDs != nil {
+		if len(q.HostIDs) == 0 {
+			return []alarmtype.AlarmStatistic{}, nil
+		}
+		in, inArgs, err := sqlx.In(" AND hostid IN (?)", q.HostIDs)
+		if err != nil {
+			return nil, err
+		}
+		query += in
+		args = append(args, inArgs...)
+	}
+	if q.Status != nil {
+		query += " AND alarmstatus = ?"
+		args = append(args, *q.Status)
+	}
+	limit := q.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	query += fmt.Sprintf(" ORDER BY alarmstarttime DESC LIMIT %d", limit)
+
+	var list []alarmtype.AlarmStatistic
+	if err := db.Select(&list, query, args...); err != nil {
+		return nil, fmt.Errorf("查询报警记录失败: %w", err)
+	}
+	return list, nil
+}
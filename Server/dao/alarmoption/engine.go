@@ -0,0 +1,474 @@
+package alarmoption
+
+import (
+	"Server/dao/metricoption"
+	"Server/models/alarmtype"
+	"Server/models/hosttype"
+	"Server/models/metrictype"
+	"Server/pkg/medium"
+	"Server/pkg/snowflake"
+	"Server/settings"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultEvalInterval   = 30
+	defaultLegacyDuration = 5
+	defaultDedupWindow    = 30
+	defaultRateLimit      = 10
+	legacyAlarmType       = 1001 // 系统问题
+)
+
+// notification 表中旧阈值对应的内置规则，使用负数 ID 与 alarm_rules 区分
+const (
+	legacyCPURuleID  int64 = -1
+	legacyMemRuleID  int64 = -2
+	legacyDiskRuleID int64 = -3
+)
+
+type stateKey struct {
+	RuleID  int64
+	AgentID string
+	Label   string
+}
+
+// ruleState 单个 规则+主机+标签 的评估状态
+type ruleState struct {
+	PendingSince time.Time // 首次越过阈值的时间，未越过时为零值
+	Firing       bool
+	AlarmID      int64
+	StartTime    time.Time
+	Rule         alarmtype.AlarmRule // 最近一次评估使用的规则，用于渲染通知
+}
+
+// Engine 周期性地用最新指标评估报警规则，记录触发和恢复并发送通知
+type Engine struct {
+	db         *sqlx.DB
+	cfg        *settings.AlarmConfig
+	notifyCfg  *settings.NotifyConfig
+	dispatcher *medium.Dispatcher
+	states     map[stateKey]*ruleState
+	lastEval   time.Time
+}
+
+func NewEngine(db *sqlx.DB, cfg *settings.AlarmConfig, notifyCfg *settings.NotifyConfig) *Engine {
+	dedupWindow, rateLimit := defaultDedupWindow, defaultRateLimit
+	if notifyCfg != nil {
+		if notifyCfg.DedupWindow > 0 {
+			dedupWindow = notifyCfg.DedupWindow
+		}
+		if notifyCfg.RateLimit > 0 {
+			rateLimit = notifyCfg.RateLimit
+		}
+	}
+	return &Engine{
+		db:         db,
+		cfg:        cfg,
+		notifyCfg:  notifyCfg,
+		dispatcher: medium.NewDispatcher(time.Duration(dedupWindow)*time.Minute, rateLimit),
+		states:     make(map[stateKey]*ruleState),
+	}
+}
+
+// Start 恢复未结束的报警后按间隔循环评估，ctx 取消时返回。多实例部署时只在 leader 上运行，
+// 每次当选都从数据库重新恢复状态
+func (e *Engine) Start(ctx context.Context) {
+	e.states = make(map[stateKey]*ruleState)
+	e.lastEval = time.Time{}
+	if err := e.restore(); err != nil {
+		zap.L().Error("恢复报警状态失败", zap.Error(err))
+	}
+
+	interval := defaultEvalInterval
+	if e.cfg != nil && e.cfg.Interval > 0 {
+		interval = e.cfg.Interval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Evaluate(time.Now()); err != nil {
+			zap.L().Error("报警规则评估失败", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// restore 从 alarmstatistics 恢复正在报警的状态，避免重启后重复报警或漏掉恢复
+func (e *Engine) restore() error {
+	firing, err := LoadFiringAlarms(e.db)
+	if err != nil {
+		return err
+	}
+	for _, a := range firing {
+		// 任务报警和主机离线报警不由指标评估恢复
+		if a.RuleID == taskRuleID || a.RuleID == hostRuleID {
+			continue
+		}
+		e.states[stateKey{a.RuleID, a.AgentID, a.AlarmLabel}] = &ruleState{
+			PendingSince: a.AlarmStartTime,
+			Firing:       true,
+			AlarmID:      a.AlarmID,
+			StartTime:    a.AlarmStartTime,
+		}
+	}
+	return nil
+}
+
+// Evaluate 评估上次评估之后到 now 之间的样本
+func (e *Engine) Evaluate(now time.Time) error {
+	noti, err := GetNotification(e.db)
+	if err != nil {
+		return err
+	}
+	rules, err := e.loadRules(noti)
+	if err != nil {
+		return err
+	}
+	e.dispatcher.SetNotifiers(e.buildNotifiers(noti))
+	hosts, err := LoadHosts(e.db)
+	if err != nil {
+		return err
+	}
+	alarmSettings, err := LoadAlarmSettings(e.db)
+	if err != nil {
+		return err
+	}
+
+	// 按指标分组，区分全局规则和主机规则
+	type ruleSet struct {
+		global []alarmtype.AlarmRule
+		byHost map[int64][]alarmtype.AlarmRule
+	}
+	byMetric := make(map[string]*ruleSet)
+	active := make(map[int64]bool)
+	maxDuration := 0
+	for _, r := range rules {
+		set, ok := byMetric[r.Metric]
+		if !ok {
+			set = &ruleSet{byHost: make(map[int64][]alarmtype.AlarmRule)}
+			byMetric[r.Metric] = set
+		}
+		if r.HostID == 0 {
+			set.global = append(set.global, r)
+		} else {
+			set.byHost[r.HostID] = append(set.byHost[r.HostID], r)
+		}
+		active[r.ID] = true
+		if r.Duration > maxDuration {
+			maxDuration = r.Duration
+		}
+	}
+
+	since := e.lastEval
+	if since.IsZero() {
+		since = now.Add(-time.Duration(maxDuration+1) * time.Minute)
+	}
+	metrics := make([]string, 0, len(byMetric))
+	for m := range byMetric {
+		metrics = append(metrics, m)
+	}
+	samples, err := metricoption.QuerySamplesSince(e.db, metrics, since, now)
+	if err != nil {
+		return err
+	}
+	e.lastEval = now
+
+	for _, s := range samples {
+		set := byMetric[s.Metric]
+		host, registered := hosts[s.AgentID]
+		// 已下线的主机不再评估
+		if registered && host.Status == hosttype.HostDecommissioned {
+			continue
+		}
+		// 主机规则覆盖同一指标的全局规则
+		applicable := set.global
+		if registered {
+			if hostRules, ok := set.byHost[host.HostID]; ok {
+				applicable = hostRules
+			}
+		}
+		for i := range applicable {
+			rule := &applicable[i]
+			if rule.Label != "" && rule.Label != s.Label {
+				continue
+			}
+			e.observe(rule, s, host, registered, alarmSettings)
+		}
+	}
+
+	// 规则被删除或停用时结束其报警
+	for key, st := range e.states {
+		if active[key.RuleID] {
+			continue
+		}
+		if st.Firing {
+			e.resolve(key, st, "报警规则已删除或停用", 0, now, hosts[key.AgentID], alarmSettings)
+		}
+		delete(e.states, key)
+	}
+	return nil
+}
+
+// observe 用一条样本推进规则状态
+func (e *Engine) observe(rule *alarmtype.AlarmRule, s metrictype.MetricSample, host alarmtype.HostRef, registered bool,
+	alarmSettings map[[2]int64]alarmtype.AlarmSetting) {
+	key := stateKey{rule.ID, s.AgentID, s.Label}
+	st, ok := e.states[key]
+	if !ok {
+		st = &ruleState{}
+		e.states[key] = st
+	}
+	st.Rule = *rule
+
+	if !rule.Breached(s.Value) {
+		st.PendingSince = time.Time{}
+		if st.Firing {
+			note := fmt.Sprintf("当前值 %.2f，已恢复", s.Value)
+			e.resolve(key, st, note, s.Value, s.CollectTime, host, alarmSettings)
+		}
+		return
+	}
+
+	if st.PendingSince.IsZero() {
+		st.PendingSince = s.CollectTime
+	}
+	if st.Firing || s.CollectTime.Sub(st.PendingSince) < time.Duration(rule.Duration)*time.Minute {
+		return
+	}
+
+	st.Firing = true
+	st.StartTime = st.PendingSince
+	st.AlarmID = snowflake.GenID()
+	info := fmt.Sprintf("%s%s %s %.2f，当前值 %.2f，持续 %d 分钟", rule.Metric, labelSuffix(s.Label), rule.Operator, rule.Threshold, s.Value, rule.Duration)
+	if rule.Note != "" {
+		info += "，" + rule.Note
+	}
+
+	if registered {
+		stat := &alarmtype.AlarmStatistic{
+			AlarmID:        st.AlarmID,
+			HostID:         host.HostID,
+			RuleID:         rule.ID,
+			AlarmLabel:     s.Label,
+			AlarmStatus:    alarmtype.AlarmFiring,
+			AlarmType:      rule.AlarmType,
+			AlarmInfo:      info,
+			AlarmStartTime: st.StartTime,
+		}
+		if err := InsertAlarm(e.db, stat); err != nil {
+			zap.L().Error("记录报警失败", zap.Int64("ruleid", rule.ID), zap.String("agent_id", s.AgentID), zap.Error(err))
+		}
+	} else {
+		zap.L().Warn("主机未登记，报警不写入统计表", zap.String("agent_id", s.AgentID), zap.String("client_ip", s.ClientIP), zap.Int64("ruleid", rule.ID))
+	}
+
+	e.notify(key, st, alarmtype.AlarmEvent{
+		Status:    medium.StatusFiring,
+		Value:     s.Value,
+		Note:      rule.Note,
+		StartTime: st.StartTime,
+	}, host, registered, alarmSettings)
+}
+
+// resolve 结束一次报警并发送恢复通知
+func (e *Engine) resolve(key stateKey, st *ruleState, note string, value float64, at time.Time, host alarmtype.HostRef,
+	alarmSettings map[[2]int64]alarmtype.AlarmSetting) {
+	if err := ResolveAlarm(e.db, st.AlarmID, note, at); err != nil {
+		zap.L().Error("记录报警恢复失败", zap.Int64("alarmid", st.AlarmID), zap.Error(err))
+	}
+	e.notify(key, st, alarmtype.AlarmEvent{
+		Status:    medium.StatusResolved,
+		Value:     value,
+		Note:      note,
+		StartTime: st.StartTime,
+		EndTime:   at,
+		Elapsed:   at.Sub(st.StartTime).Truncate(time.Second),
+	}, host, host.HostID != 0, alarmSettings)
+	st.Firing = false
+	st.AlarmID = 0
+}
+
+// notify 补全报警信息后渲染模板并异步发送，主机屏蔽该类型报警时不发送
+func (e *Engine) notify(key stateKey, st *ruleState, event alarmtype.AlarmEvent, host alarmtype.HostRef, registered bool,
+	alarmSettings map[[2]int64]alarmtype.AlarmSetting) {
+	event.RuleID = key.RuleID
+	event.AgentID = key.AgentID
+	event.ClientIP = host.HostIP
+	if event.ClientIP == "" {
+		event.ClientIP = key.AgentID
+	}
+	event.Label = key.Label
+	event.HostName = host.HostName
+	event.Owner = host.Owner.String
+	event.Metric = st.Rule.Metric
+	event.Operator = st.Rule.Operator
+	event.Threshold = st.Rule.Threshold
+	event.Duration = st.Rule.Duration
+
+	if registered {
+		if setting, ok := alarmSettings[[2]int64{host.HostID, int64(st.Rule.AlarmType)}]; ok {
+			if setting.AlarmStatus == 0 {
+				zap.L().Info("主机已屏蔽该类型报警", zap.String("agent_id", key.AgentID), zap.Int("alarmtype", st.Rule.AlarmType))
+				return
+			}
+			if setting.HostOwner.Valid && setting.HostOwner.String != "" {
+				event.Owner = setting.HostOwner.String
+			}
+		}
+	}
+
+	msg, err := renderMessage(e.notifyCfg, &event)
+	if err != nil {
+		zap.L().Error("渲染报警消息失败", zap.Int64("ruleid", key.RuleID), zap.Error(err))
+		return
+	}
+	msg.Key = fmt.Sprintf("%d|%s|%s", key.RuleID, key.AgentID, key.Label)
+	go func() {
+		sent, err := e.dispatcher.Dispatch(msg)
+		if err != nil {
+			zap.L().Error("报警通知发送失败", zap.String("key", msg.Key), zap.Error(err))
+		} else if !sent {
+			zap.L().Info("报警通知已去重或限流", zap.String("key", msg.Key), zap.String("status", msg.Status))
+		}
+	}()
+}
+
+// buildNotifiers 根据 notification 表和配置文件构造通知渠道
+func (e *Engine) buildNotifiers(noti *alarmtype.Notification) []medium.Notifier {
+	cfg := e.notifyCfg
+	if cfg == nil {
+		cfg = &settings.NotifyConfig{}
+	}
+	attempts, backoff := cfg.RetryAttempts, time.Duration(cfg.RetryBackoff)*time.Second
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	var notifiers []medium.Notifier
+	if noti != nil && noti.WorkApiUrl.String != "" {
+		notifiers = append(notifiers, &medium.WeCom{
+			URL:       noti.WorkApiUrl.String,
+			Mentioned: splitUsers(noti.WorkAtuser.String),
+			MaxBytes:  cfg.WeComMaxBytes,
+		})
+	} else if settings.Conf.WXworkToke != nil && settings.Conf.ApiToken != "" {
+		notifiers = append(notifiers, medium.NewWeComWithKey(settings.Conf.ApiToken, cfg.WeComMaxBytes))
+	}
+	if noti != nil && noti.DingApiUrl.String != "" {
+		notifiers = append(notifiers, &medium.DingTalk{
+			URL:       noti.DingApiUrl.String,
+			Secret:    cfg.DingTalkSecret,
+			AtMobiles: splitUsers(noti.DingAtuser.String),
+		})
+	}
+	if cfg.WebhookURL != "" {
+		notifiers = append(notifiers, &medium.Webhook{URL: cfg.WebhookURL, Header: cfg.WebhookHeader})
+	}
+	if cfg.SMTP != nil && cfg.SMTP.Host != "" && len(cfg.SMTP.To) > 0 {
+		notifiers = append(notifiers, &medium.Email{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+			To:       cfg.SMTP.To,
+		})
+	}
+
+	for i, n := range notifiers {
+		notifiers[i] = medium.WithRetry(n, attempts, backoff)
+	}
+	return notifiers
+}
+
+// loadRules 合并 alarm_rules 中启用的规则和 notification 表中的旧阈值，
+// 旧阈值仅在没有同指标全局规则时生效
+func (e *Engine) loadRules(noti *alarmtype.Notification) ([]alarmtype.AlarmRule, error) {
+	stored, err := ListRules(e.db)
+	if err != nil {
+		return nil, err
+	}
+	var rules []alarmtype.AlarmRule
+	globalMetrics := make(map[string]bool)
+	for _, r := range stored {
+		if !r.Enabled {
+			continue
+		}
+		rules = append(rules, r)
+		if r.HostID == 0 {
+			globalMetrics[r.Metric] = true
+		}
+	}
+
+	if noti == nil || noti.ThresholdStatus.String != "1" {
+		return rules, nil
+	}
+	duration := defaultLegacyDuration
+	if e.cfg != nil && e.cfg.DefaultDuration > 0 {
+		duration = e.cfg.DefaultDuration
+	}
+	legacy := []struct {
+		id     int64
+		metric string
+		labels []string
+		option string
+		name   string
+	}{
+		{legacyCPURuleID, metrictype.MetricCPUUsage, []string{"total"}, noti.CpuOption.String, "CPU 使用率"},
+		{legacyMemRuleID, metrictype.MetricMemUsedPercent, []string{""}, noti.MemoryOption.String, "内存使用率"},
+		{legacyDiskRuleID, metrictype.MetricDiskUsedPercent, []string{"/", "C:\\"}, noti.SystemDiskOption.String, "系统盘使用率"},
+	}
+	for _, l := range legacy {
+		if globalMetrics[l.metric] {
+			continue
+		}
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(l.option), 64)
+		if err != nil || threshold <= 0 {
+			continue
+		}
+		for _, label := range l.labels {
+			rules = append(rules, alarmtype.AlarmRule{
+				ID:        l.id,
+				Metric:    l.metric,
+				Label:     label,
+				Operator:  ">=",
+				Threshold: threshold,
+				Duration:  duration,
+				AlarmType: legacyAlarmType,
+				Enabled:   true,
+				Note:      l.name + "超过阈值",
+			})
+		}
+	}
+	return rules, nil
+}
+
+func labelSuffix(label string) string {
+	if label == "" {
+		return ""
+	}
+	return "[" + label + "]"
+}
+
+// splitUsers 将逗号分隔的 @ 用户列表拆分为数组
+func splitUsers(users string) []string {
+	var list []string
+	for _, u := range strings.Split(users, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			list = append(list, u)
+		}
+	}
+	return list
+}
@@ -0,0 +1,94 @@
+package alarmoption
+
+import (
+	"Server/models/alarmtype"
+	"Server/models/hosttype"
+	"Server/pkg/medium"
+	"Server/pkg/snowflake"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// 主机离线的内置规则，客户端在线租约过期时报警，重新上线时恢复
+	hostRuleID    int64 = -5
+	hostAlarmType       = 1003 // 主机离线
+	hostMetric          = "online"
+)
+
+// PresenceChanged 处理 /agents/online/ 的变化：租约过期时触发主机离线报警，重新上线时恢复。
+// 未登记或已下线的主机不报警
+func (e *Engine) PresenceChanged(ev hosttype.PresenceEvent) {
+	host, registered, err := LoadHostByAgent(e.db, ev.AgentID)
+	if err != nil {
+		zap.L().Error("查询离线客户端所在主机失败", zap.String("agent_id", ev.AgentID), zap.Error(err))
+		return
+	}
+	if !registered || host.Status == hosttype.HostDecommissioned {
+		return
+	}
+	firing, err := FindFiringAlarm(e.db, hostRuleID, host.HostID, "")
+	if err != nil {
+		zap.L().Error("查询主机离线报警失败", zap.Int64("hostid", host.HostID), zap.Error(err))
+		return
+	}
+	if ev.Type == hosttype.PresenceJoin && firing == nil {
+		return
+	}
+	alarmSettings, err := LoadAlarmSettings(e.db)
+	if err != nil {
+		zap.L().Error("查询主机报警设置失败", zap.Error(err))
+		return
+	}
+	key := stateKey{hostRuleID, ev.AgentID, ""}
+	st := &ruleState{Rule: alarmtype.AlarmRule{ID: hostRuleID, Metric: hostMetric, AlarmType: hostAlarmType}}
+
+	if ev.Type == hosttype.PresenceJoin {
+		st.AlarmID, st.StartTime = firing.AlarmID, firing.AlarmStartTime
+		note := "客户端已重新上线"
+		if ev.Presence != nil && ev.Presence.Server != "" {
+			note = fmt.Sprintf("客户端已重新上线，连接到 %s", ev.Presence.Server)
+		}
+		if err := ResolveAlarm(e.db, firing.AlarmID, note, ev.Time); err != nil {
+			zap.L().Error("记录主机离线恢复失败", zap.Int64("alarmid", firing.AlarmID), zap.Error(err))
+		}
+		e.notify(key, st, alarmtype.AlarmEvent{
+			Status:    medium.StatusResolved,
+			Note:      note,
+			StartTime: st.StartTime,
+			EndTime:   ev.Time,
+			Elapsed:   ev.Time.Sub(st.StartTime).Truncate(time.Second),
+		}, host, registered, alarmSettings)
+		return
+	}
+
+	info := fmt.Sprintf("客户端 %s 心跳超时，在线租约已过期", ev.AgentID)
+	if p := ev.Presence; p != nil && !p.ConnectedAt.IsZero() {
+		info += fmt.Sprintf("，最后一次连接于 %s（%s）", p.ConnectedAt.Format("2006-01-02 15:04:05"), p.Server)
+	}
+	if firing != nil {
+		// 未恢复的报警只再次通知，由分发器去重
+		st.AlarmID, st.StartTime = firing.AlarmID, firing.AlarmStartTime
+	} else {
+		st.AlarmID, st.StartTime = snowflake.GenID(), ev.Time
+		stat := &alarmtype.AlarmStatistic{
+			AlarmID:        st.AlarmID,
+			HostID:         host.HostID,
+			RuleID:         hostRuleID,
+			AlarmStatus:    alarmtype.AlarmFiring,
+			AlarmType:      hostAlarmType,
+			AlarmInfo:      info,
+			AlarmStartTime: st.StartTime,
+		}
+		if err := InsertAlarm(e.db, stat); err != nil {
+			zap.L().Error("记录主机离线报警失败", zap.Int64("hostid", host.HostID), zap.Error(err))
+		}
+	}
+	e.notify(key, st, alarmtype.AlarmEvent{
+		Status:    medium.StatusFiring,
+		Note:      info,
+		StartTime: st.StartTime,
+	}, host, registered, alarmSettings)
+}
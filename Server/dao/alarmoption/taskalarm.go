@@ -0,0 +1,165 @@
+package alarmoption
+
+import (
+	"Server/models/alarmtype"
+	"Server/models/tasktype"
+	"Server/pkg/medium"
+	"Server/pkg/snowflake"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	// 任务执行失败的内置规则，报警标签为任务 ID
+	taskRuleID    int64 = -4
+	taskAlarmType       = 1002 // 任务执行问题
+	taskMetric          = "task"
+)
+
+// TaskRunFinished 按执行结果触发或恢复任务报警：执行失败或超时时报警，
+// 同一任务在同一主机上再次执行成功时恢复。未登记的主机只发送通知
+func (e *Engine) TaskRunFinished(run *tasktype.TaskRun) {
+	failed := run.Status == tasktype.TaskFailed || run.Status == tasktype.TaskTimedOut
+	if !failed && run.Status != tasktype.TaskSucceeded {
+		return
+	}
+	host, registered, err := LoadHostByAgent(e.db, run.AgentID)
+	if err != nil {
+		zap.L().Error("查询任务所在主机失败", zap.String("agent_id", run.AgentID), zap.Error(err))
+		return
+	}
+	key := stateKey{taskRuleID, run.AgentID, run.TaskID}
+	st := &ruleState{Rule: alarmtype.AlarmRule{ID: taskRuleID, Metric: taskMetric, AlarmType: taskAlarmType}}
+
+	var firing *alarmtype.AlarmStatistic
+	if registered {
+		if firing, err = FindFiringAlarm(e.db, taskRuleID, host.HostID, run.TaskID); err != nil {
+			zap.L().Error("查询任务报警失败", zap.String("task_id", run.TaskID), zap.Error(err))
+			return
+		}
+	}
+	alarmSettings, err := LoadAlarmSettings(e.db)
+	if err != nil {
+		zap.L().Error("查询主机报警设置失败", zap.Error(err))
+		return
+	}
+	now := time.Now()
+	if run.EndTime != nil {
+		now = *run.EndTime
+	}
+
+	if !failed {
+		if firing == nil {
+			return
+		}
+		st.AlarmID, st.StartTime = firing.AlarmID, firing.AlarmStartTime
+		note := fmt.Sprintf("执行 %d 成功，已恢复", run.RunID)
+		if err := ResolveAlarm(e.db, firing.AlarmID, note, now); err != nil {
+			zap.L().Error("记录任务报警恢复失败", zap.Int64("alarmid", firing.AlarmID), zap.Error(err))
+		}
+		e.notify(key, st, alarmtype.AlarmEvent{
+			Status:    medium.StatusResolved,
+			Note:      note,
+			StartTime: st.StartTime,
+			EndTime:   now,
+			Elapsed:   now.Sub(st.StartTime).Truncate(time.Second),
+		}, host, registered, alarmSettings)
+		return
+	}
+
+	info := describeRun(run)
+	if firing != nil {
+		// 未恢复的报警只再次通知，由分发器去重
+		st.AlarmID, st.StartTime = firing.AlarmID, firing.AlarmStartTime
+	} else {
+		st.AlarmID, st.StartTime = snowflake.GenID(), run.StartTime
+		if registered {
+			stat := &alarmtype.AlarmStatistic{
+				AlarmID:        st.AlarmID,
+				HostID:         host.HostID,
+				RuleID:         taskRuleID,
+				AlarmLabel:     run.TaskID,
+				AlarmStatus:    alarmtype.AlarmFiring,
+				AlarmType:      taskAlarmType,
+				AlarmInfo:      info,
+				AlarmStartTime: st.StartTime,
+			}
+			if err := InsertAlarm(e.db, stat); err != nil {
+				zap.L().Error("记录任务报警失败", zap.String("task_id", run.TaskID), zap.Error(err))
+			}
+		} else {
+			zap.L().Warn("主机未登记，任务报警不写入统计表", zap.String("agent_id", run.AgentID), zap.String("task_id", run.TaskID))
+		}
+	}
+	event := alarmtype.AlarmEvent{
+		Status:    medium.StatusFiring,
+		Note:      info,
+		StartTime: st.StartTime,
+	}
+	if run.ExitCode != nil {
+		event.Value = float64(*run.ExitCode)
+	}
+	e.notify(key, st, event, host, registered, alarmSettings)
+}
+
+// describeRun 生成执行失败的说明
+func describeRun(run *tasktype.TaskRun) string {
+	parts := []string{fmt.Sprintf("执行 %d %s", run.RunID, run.Status)}
+	if run.ExitCode != nil {
+		parts = append(parts, fmt.Sprintf("退出码 %d", *run.ExitCode))
+	}
+	if run.Signal != "" {
+		parts = append(parts, "信号 "+run.Signal)
+	}
+	if run.TimedOut {
+		parts = append(parts, "执行超时")
+	}
+	if run.Attempts > 1 {
+		parts = append(parts, fmt.Sprintf("共尝试 %d 次", run.Attempts))
+	}
+	if run.DurationMs != nil {
+		parts = append(parts, fmt.Sprintf("耗时 %s", (time.Duration(*run.DurationMs)*time.Millisecond).String()))
+	}
+	parts = append(parts, fmt.Sprintf("输出 %d/%d 字节", run.StdoutBytes, run.StderrBytes))
+	if run.Error != "" {
+		parts = append(parts, run.Error)
+	}
+	return strings.Join(parts, "，")
+}
+
+// LoadHostByAgent 按客户端 ID 查询已登记的主机
+func LoadHostByAgent(db *sqlx.DB, agentID string) (alarmtype.HostRef, bool, error) {
+	var host alarmtype.HostRef
+	err := db.Get(&host, `SELECT hostid, agent_id, hostname, hostip, hostowner, hoststatus FROM hostlist WHERE agent_id = ?`, agentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return host, false, nil
+	}
+	if err != nil {
+		return host, false, fmt.Errorf("查询主机失败: %w", err)
+	}
+	return host, true, nil
+}
+
+// FindFiringAlarm 查询规则在主机指定标签上未恢复的报警，没有时返回 nil
+func FindFiringAlarm(db *sqlx.DB, ruleID, hostID int64, label string) (*alarmtype.AlarmStatistic, error) {
+	var stat alarmtype.AlarmStatistic
+	err := db.Get(&stat, `
+		SELECT id, alarmid, hostid, ruleid, COALESCE(alarmlabel, '') AS alarmlabel, alarmstatus, alarmtype,
+		COALESCE(alarminfo, '') AS alarminfo, COALESCE(alarmnote, '') AS alarmnote, alarmstarttime, alarmstoptime
+		FROM alarmstatistics WHERE ruleid = ? AND hostid = ? AND alarmlabel = ? AND alarmstatus = ?
+		ORDER BY id DESC LIMIT 1
+	`, ruleID, hostID, label, alarmtype.AlarmFiring)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询未恢复报警失败: %w", err)
+	}
+	return &stat, nil
+}
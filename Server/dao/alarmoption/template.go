@@ -0,0 +1,72 @@
+package alarmoption
+
+import (
+	"Server/models/alarmtype"
+	"Server/pkg/medium"
+	"Server/settings"
+)
+
+// 内置通知模板，字段见 alarmtype.AlarmEvent
+const (
+	defaultFiringTemplate = `主机：{{.HostName}}({{.ClientIP}})
+指标：{{.Metric}}{{if .Label}}[{{.Label}}]{{end}} {{.Operator}} {{printf "%.2f" .Threshold}}，持续 {{.Duration}} 分钟
+当前值：{{printf "%.2f" .Value}}
+开始时间：{{.StartTime.Format "2006-01-02 15:04:05"}}{{if .Note}}
+备注：{{.Note}}{{end}}{{if .Owner}}
+负责人：{{.Owner}}{{end}}`
+
+	defaultResolvedTemplate = `主机：{{.HostName}}({{.ClientIP}})
+指标：{{.Metric}}{{if .Label}}[{{.Label}}]{{end}} {{.Operator}} {{printf "%.2f" .Threshold}}
+{{.Note}}
+开始时间：{{.StartTime.Format "2006-01-02 15:04:05"}}
+恢复时间：{{.EndTime.Format "2006-01-02 15:04:05"}}（持续 {{.Elapsed}}）{{if .Owner}}
+负责人：{{.Owner}}{{end}}`
+
+	defaultTaskFiringTemplate = `主机：{{.HostName}}({{.ClientIP}})
+任务：{{.Label}} 执行失败
+{{.Note}}
+开始时间：{{.StartTime.Format "2006-01-02 15:04:05"}}{{if .Owner}}
+负责人：{{.Owner}}{{end}}`
+
+	defaultTaskResolvedTemplate = `主机：{{.HostName}}({{.ClientIP}})
+任务：{{.Label}} {{.Note}}
+开始时间：{{.StartTime.Format "2006-01-02 15:04:05"}}
+恢复时间：{{.EndTime.Format "2006-01-02 15:04:05"}}（持续 {{.Elapsed}}）{{if .Owner}}
+负责人：{{.Owner}}{{end}}`
+)
+
+// renderMessage 按配置的模板生成通知消息，未配置时使用内置模板
+func renderMessage(cfg *settings.NotifyConfig, event *alarmtype.AlarmEvent) (*medium.Message, error) {
+	text, title := defaultFiringTemplate, "【报警】"
+	if event.Status == medium.StatusResolved {
+		text, title = defaultResolvedTemplate, "【恢复】"
+	}
+	// 配置的模板按指标报警编写，任务报警使用内置模板
+	subject := event.Metric
+	if event.Metric == taskMetric {
+		text, subject = defaultTaskFiringTemplate, "任务 "+event.Label
+		if event.Status == medium.StatusResolved {
+			text = defaultTaskResolvedTemplate
+		}
+	} else if cfg != nil {
+		if event.Status == medium.StatusResolved && cfg.ResolvedTemplate != "" {
+			text = cfg.ResolvedTemplate
+		} else if event.Status == medium.StatusFiring && cfg.FiringTemplate != "" {
+			text = cfg.FiringTemplate
+		}
+	}
+
+	content, err := medium.Render(text, event)
+	if err != nil {
+		return nil, err
+	}
+	name := event.HostName
+	if name == "" {
+		name = event.ClientIP
+	}
+	return &medium.Message{
+		Status:  event.Status,
+		Title:   title + name + " " + subject,
+		Content: content,
+	}, nil
+}
@@ -0,0 +1,90 @@
+package auditoption
+
+import (
+	"Server/models/audittype"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"strings"
+)
+
+// 审计记录各字段的最大长度，超出部分截断
+const (
+	maxTargetLen  = 255
+	maxPayloadLen = 4096
+	maxDetailLen  = 255
+)
+
+// Insert 写入一条审计记录
+func Insert(db *sqlx.DB, l *audittype.AuditLog) error {
+	_, err := db.Exec(`
+		INSERT INTO audit_log (user_id, username, client_ip, action, target, payload, result, detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, l.UserID, truncate(l.Username, 64), l.ClientIP, l.Action, truncate(l.Target, maxTargetLen),
+		truncate(l.Payload, maxPayloadLen), l.Result, truncate(l.Detail, maxDetailLen))
+	if err != nil {
+		return fmt.Errorf("写入审计日志失败: %w", err)
+	}
+	return nil
+}
+
+// List 按用户、动作和时间范围查询审计记录，按时间倒序，同时返回符合条件的总数
+func List(db *sqlx.DB, q *audittype.AuditQuery) ([]audittype.AuditLog, int64, error) {
+	where := " WHERE 1 = 1"
+	var args []interface{}
+	if q.UserID != 0 {
+		where += " AND user_id = ?"
+		args = append(args, q.UserID)
+	}
+	if q.Username != "" {
+		where += " AND username = ?"
+		args = append(args, q.Username)
+	}
+	if q.Action != "" {
+		if strings.HasSuffix(q.Action, ".") {
+			where += " AND action LIKE ?"
+			args = append(args, q.Action+"%")
+		} else {
+			where += " AND action = ?"
+			args = append(args, q.Action)
+		}
+	}
+	if !q.Start.IsZero() {
+		where += " AND create_time >= ?"
+		args = append(args, q.Start)
+	}
+	if !q.End.IsZero() {
+		where += " AND create_time <= ?"
+		args = append(args, q.End)
+	}
+
+	var total int64
+	if err := db.Get(&total, "SELECT COUNT(*) FROM audit_log"+where, args...); err != nil {
+		return nil, 0, fmt.Errorf("查询审计日志失败: %w", err)
+	}
+
+	limit := q.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	offset := q.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	query := `SELECT id, user_id, username, client_ip, action, target, payload, result, detail, create_time
+		FROM audit_log` + where + fmt.Sprintf(" ORDER BY create_time DESC, id DESC LIMIT %d OFFSET %d", limit, offset)
+
+	list := []audittype.AuditLog{}
+	if err := db.Select(&list, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("查询审计日志失败: %w", err)
+	}
+	return list, total, nil
+}
+
+// truncate 按字符截断，避免切断多字节字符
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,151 @@
+package clientoption
+
+import (
+	"Server/models/hosttype"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// ErrPresenceExpired 在线租约已过期，需要重新登记
+var ErrPresenceExpired = errors.New("在线租约已过期")
+
+const watchRetryWait = 3 * time.Second
+
+// RegisterPresence 申请 ttl 秒的租约并写入 /agents/online/<agent_id>，同一客户端重连时覆盖旧值，
+// 旧租约过期时不会再删除该 key
+func RegisterPresence(cli *clientv3.Client, p *hosttype.Presence, ttl int64) (clientv3.LeaseID, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return 0, fmt.Errorf("序列化在线信息失败: %w", err)
+	}
+	lease, err := cli.Grant(context.Background(), ttl)
+	if err != nil {
+		return 0, fmt.Errorf("申请在线租约失败: %w", err)
+	}
+	if _, err := cli.Put(context.Background(), hosttype.OnlineDir+p.AgentID, string(data), clientv3.WithLease(lease.ID)); err != nil {
+		cli.Revoke(context.Background(), lease.ID)
+		return 0, fmt.Errorf("写入在线状态失败: %w", err)
+	}
+	return lease.ID, nil
+}
+
+// RefreshPresence 收到心跳时续约一次
+func RefreshPresence(cli *clientv3.Client, leaseID clientv3.LeaseID) error {
+	if _, err := cli.KeepAliveOnce(context.Background(), leaseID); err != nil {
+		if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+			return ErrPresenceExpired
+		}
+		return fmt.Errorf("在线租约续约失败: %w", err)
+	}
+	return nil
+}
+
+// GetPresence 查询客户端的在线信息，不在线时返回 nil
+func GetPresence(kv clientv3.KV, agentID string) (*hosttype.Presence, error) {
+	resp, err := kv.Get(context.Background(), hosttype.OnlineDir+agentID)
+	if err != nil {
+		return nil, fmt.Errorf("查询客户端在线状态失败: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	p := decodePresence(agentID, resp.Kvs[0].Value)
+	return &p, nil
+}
+
+// ListOnline 列出在线客户端，key 为客户端 ID，同时返回读取时的 revision 供 watch 续接
+func ListOnline(kv clientv3.KV) (map[string]hosttype.Presence, int64, error) {
+	resp, err := kv.Get(context.Background(), hosttype.OnlineDir, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询在线客户端失败: %w", err)
+	}
+	online := make(map[string]hosttype.Presence, len(resp.Kvs))
+	for _, kvPair := range resp.Kvs {
+		agentID := strings.TrimPrefix(string(kvPair.Key), hosttype.OnlineDir)
+		online[agentID] = decodePresence(agentID, kvPair.Value)
+	}
+	return online, resp.Header.Revision, nil
+}
+
+// WatchPresence 监听客户端上线和离线并回调 handle，ctx 取消时返回。
+// 启动时对已在线的客户端各回调一次上线；watch 中断后重新全量加载，与上次的快照比对补发期间的变化
+func WatchPresence(ctx context.Context, cli *clientv3.Client, handle func(hosttype.PresenceEvent)) {
+	known := make(map[string]hosttype.Presence)
+	for ctx.Err() == nil {
+		online, rev, err := ListOnline(cli)
+		if err != nil {
+			zap.L().Error("加载在线客户端失败", zap.Error(err))
+			sleepCtx(ctx, watchRetryWait)
+			continue
+		}
+		now := time.Now()
+		for agentID, p := range online {
+			if old, ok := known[agentID]; !ok || old != p {
+				p := p
+				handle(hosttype.PresenceEvent{Type: hosttype.PresenceJoin, AgentID: agentID, Presence: &p, Time: now})
+			}
+		}
+		for agentID, p := range known {
+			if _, ok := online[agentID]; !ok {
+				p := p
+				handle(hosttype.PresenceEvent{Type: hosttype.PresenceLeave, AgentID: agentID, Presence: &p, Time: now})
+			}
+		}
+		known = online
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		for resp := range cli.Watch(watchCtx, hosttype.OnlineDir, clientv3.WithPrefix(), clientv3.WithRev(rev+1)) {
+			if err := resp.Err(); err != nil {
+				zap.L().Warn("监听在线客户端中断，重新加载", zap.Error(err))
+				break
+			}
+			for _, ev := range resp.Events {
+				agentID := strings.TrimPrefix(string(ev.Kv.Key), hosttype.OnlineDir)
+				switch ev.Type {
+				case mvccpb.PUT:
+					p := decodePresence(agentID, ev.Kv.Value)
+					known[agentID] = p
+					handle(hosttype.PresenceEvent{Type: hosttype.PresenceJoin, AgentID: agentID, Presence: &p, Time: time.Now()})
+				case mvccpb.DELETE:
+					p, ok := known[agentID]
+					if !ok {
+						p = hosttype.Presence{AgentID: agentID}
+					}
+					delete(known, agentID)
+					handle(hosttype.PresenceEvent{Type: hosttype.PresenceLeave, AgentID: agentID, Presence: &p, Time: time.Now()})
+				}
+			}
+		}
+		cancel()
+		sleepCtx(ctx, watchRetryWait)
+	}
+}
+
+// sleepCtx 等待 d，ctx 取消时提前返回
+func sleepCtx(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// decodePresence 旧值无法解析时只保留客户端 ID
+func decodePresence(agentID string, value []byte) hosttype.Presence {
+	var p hosttype.Presence
+	if err := json.Unmarshal(value, &p); err != nil {
+		zap.L().Warn("解析在线信息失败", zap.String("agent_id", agentID), zap.Error(err))
+	}
+	p.AgentID = agentID
+	return p
+}
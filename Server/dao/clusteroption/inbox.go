@@ -0,0 +1,92 @@
+package clusteroption
+
+import (
+	"Server/models/clustertype"
+	"Server/pkg/instance"
+	"Server/pkg/snowflake"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// ErrServerOffline 目标实例未登记，消息无法转发
+var ErrServerOffline = errors.New("服务端实例不在线")
+
+// inboxTTL 转发消息的存活秒数，目标实例在此时间内未处理时随租约删除
+const inboxTTL = 60
+
+// SendToInbox 把消息写入目标实例的收件箱 /inbox/<instance>/<id>
+func SendToInbox(cli *clientv3.Client, target string, env *clustertype.Envelope) error {
+	online, err := serverOnline(cli, target)
+	if err != nil {
+		return err
+	}
+	if !online {
+		return fmt.Errorf("%w: %s", ErrServerOffline, target)
+	}
+	env.From, env.Time = instance.ID(), time.Now()
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("序列化转发消息失败: %w", err)
+	}
+	lease, err := cli.Grant(context.Background(), inboxTTL)
+	if err != nil {
+		return fmt.Errorf("申请转发消息租约失败: %w", err)
+	}
+	key := fmt.Sprintf("%s%s/%d", clustertype.InboxDir, target, snowflake.GenID())
+	if _, err := cli.Put(context.Background(), key, string(data), clientv3.WithLease(lease.ID)); err != nil {
+		return fmt.Errorf("写入转发消息失败: %w", err)
+	}
+	return nil
+}
+
+// WatchInbox 处理发给本实例的消息，处理后删除，阻塞运行。watch 中断后先处理积压的消息再继续监听
+func WatchInbox(cli *clientv3.Client, handle func(*clustertype.Envelope)) {
+	prefix := clustertype.InboxDir + instance.ID() + "/"
+	for {
+		resp, err := cli.Get(context.Background(), prefix, clientv3.WithPrefix(),
+			clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend))
+		if err != nil {
+			zap.L().Error("加载收件箱失败", zap.Error(err))
+			time.Sleep(retryWait)
+			continue
+		}
+		for _, kvPair := range resp.Kvs {
+			deliver(cli, kvPair, handle)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		for wresp := range cli.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1)) {
+			if err := wresp.Err(); err != nil {
+				zap.L().Warn("监听收件箱中断，重新加载", zap.Error(err))
+				break
+			}
+			for _, ev := range wresp.Events {
+				if ev.Type == mvccpb.PUT {
+					deliver(cli, ev.Kv, handle)
+				}
+			}
+		}
+		cancel()
+		time.Sleep(retryWait)
+	}
+}
+
+// deliver 处理一条消息并删除，无法解析的消息直接丢弃
+func deliver(cli *clientv3.Client, kvPair *mvccpb.KeyValue, handle func(*clustertype.Envelope)) {
+	var env clustertype.Envelope
+	if err := json.Unmarshal(kvPair.Value, &env); err != nil {
+		zap.L().Warn("解析转发消息失败", zap.String("key", string(kvPair.Key)), zap.Error(err))
+	} else {
+		handle(&env)
+	}
+	if _, err := cli.Delete(context.Background(), string(kvPair.Key)); err != nil {
+		zap.L().Warn("删除已处理的转发消息失败", zap.String("key", string(kvPair.Key)), zap.Error(err))
+	}
+}
@@ -0,0 +1,76 @@
+package clusteroption
+
+import (
+	"Server/models/clustertype"
+	"Server/pkg/instance"
+	"context"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"go.uber.org/zap"
+)
+
+// RunAsLeader 参与 /election/server/ 选举，当选后调用 duties，会话失效失去 leader 时取消 ctx，
+// 等 duties 返回后重新参选，阻塞运行。duties 须在 ctx 取消后尽快返回
+func RunAsLeader(cli *clientv3.Client, duties func(ctx context.Context)) {
+	for {
+		session, err := concurrency.NewSession(cli, concurrency.WithTTL(sessionTTL))
+		if err != nil {
+			zap.L().Error("创建选举会话失败", zap.Error(err))
+			time.Sleep(retryWait)
+			continue
+		}
+		election := concurrency.NewElection(session, clustertype.LeaderElection)
+
+		// 会话在竞选期间失效时放弃本次竞选
+		campaignCtx, stopCampaign := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-session.Done():
+				stopCampaign()
+			case <-campaignCtx.Done():
+			}
+		}()
+		err = election.Campaign(campaignCtx, instance.ID())
+		stopCampaign()
+		if err != nil {
+			zap.L().Warn("参选 leader 失败", zap.Error(err))
+			session.Close()
+			time.Sleep(retryWait)
+			continue
+		}
+
+		zap.L().Info("当选 leader，开始执行单例任务", zap.String("instance", instance.ID()))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			duties(ctx)
+		}()
+		select {
+		case <-session.Done():
+			zap.L().Warn("选举会话失效，停止单例任务", zap.String("instance", instance.ID()))
+		case <-done:
+			// duties 意外退出时让出 leader，由其他实例接替
+			zap.L().Warn("单例任务已退出，让出 leader", zap.String("instance", instance.ID()))
+			election.Resign(context.Background())
+		}
+		cancel()
+		<-done
+		session.Close()
+	}
+}
+
+// CurrentLeader 返回当前 leader 的实例 ID，没有 leader 时为空
+func CurrentLeader(cli *clientv3.Client) (string, error) {
+	resp, err := cli.Get(context.Background(), clustertype.LeaderElection, clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", fmt.Errorf("查询 leader 失败: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return "", nil
+	}
+	return string(resp.Kvs[0].Value), nil
+}
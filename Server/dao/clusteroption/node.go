@@ -0,0 +1,115 @@
+package clusteroption
+
+import (
+	"Server/models/clustertype"
+	"Server/pkg/instance"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"go.uber.org/zap"
+)
+
+// maxNodeID snowflake 节点号上限，与 10 位节点号一致
+const maxNodeID = 1023
+
+// ErrNodeTaken 节点号已被其他实例占用
+var ErrNodeTaken = errors.New("snowflake 节点号已被其他实例占用")
+
+// nodeID 本实例占用的节点号，ClaimNodeID 成功后设置
+var nodeID int64 = -1
+
+// ClaimNodeID 以会话租约占用 /snowflake/nodes/<n>，优先使用配置的 preferred，已被占用时依次尝试其他节点号，
+// 保证各实例生成的 ID 和收件箱键不会冲突。会话失效后重新占用同一节点号，期间已被其他实例占用时退出进程
+func ClaimNodeID(cli *clientv3.Client, preferred int64) (int64, error) {
+	session, err := concurrency.NewSession(cli, concurrency.WithTTL(sessionTTL))
+	if err != nil {
+		return 0, fmt.Errorf("创建节点号会话失败: %w", err)
+	}
+	candidates := make([]int64, 0, maxNodeID+1)
+	if preferred >= 0 && preferred <= maxNodeID {
+		candidates = append(candidates, preferred)
+	}
+	for n := int64(0); n <= maxNodeID; n++ {
+		if n != preferred {
+			candidates = append(candidates, n)
+		}
+	}
+	for _, n := range candidates {
+		err := putNode(cli, session, n)
+		if errors.Is(err, ErrNodeTaken) {
+			continue
+		}
+		if err != nil {
+			session.Close()
+			return 0, err
+		}
+		if n != preferred {
+			zap.L().Warn("配置的 machine_id 已被其他实例占用，改用空闲节点号", zap.Int64("machine_id", preferred), zap.Int64("node_id", n))
+		}
+		nodeID = n
+		go keepNode(cli, session, n)
+		return n, nil
+	}
+	session.Close()
+	return 0, fmt.Errorf("没有空闲的 snowflake 节点号")
+}
+
+// NodeID 返回本实例占用的节点号，未占用时为 -1
+func NodeID() int64 {
+	return nodeID
+}
+
+// putNode 节点号空闲或已由本实例占用时以会话租约写入，被其他实例占用时返回 ErrNodeTaken
+func putNode(cli *clientv3.Client, session *concurrency.Session, n int64) error {
+	key := clustertype.NodeDir + strconv.FormatInt(n, 10)
+	resp, err := cli.Txn(context.Background()).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, instance.ID(), clientv3.WithLease(session.Lease()))).
+		Else(clientv3.OpGet(key)).
+		Commit()
+	if err != nil {
+		return fmt.Errorf("占用 snowflake 节点号失败: %w", err)
+	}
+	if resp.Succeeded {
+		return nil
+	}
+	// 旧会话的租约尚未过期时键仍属于本实例，改绑到新会话的租约
+	if kvs := resp.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 && string(kvs[0].Value) == instance.ID() {
+		if _, err := cli.Put(context.Background(), key, instance.ID(), clientv3.WithLease(session.Lease())); err != nil {
+			return fmt.Errorf("占用 snowflake 节点号失败: %w", err)
+		}
+		return nil
+	}
+	return ErrNodeTaken
+}
+
+// keepNode 会话失效后以新会话重新占用同一节点号，节点号已被其他实例占用时继续生成 ID 会产生重复，直接退出进程
+func keepNode(cli *clientv3.Client, session *concurrency.Session, n int64) {
+	for {
+		<-session.Done()
+		zap.L().Warn("节点号会话已失效，重新占用", zap.Int64("node_id", n))
+		for {
+			var err error
+			if session, err = concurrency.NewSession(cli, concurrency.WithTTL(sessionTTL)); err != nil {
+				zap.L().Error("创建节点号会话失败", zap.Error(err))
+				time.Sleep(retryWait)
+				continue
+			}
+			err = putNode(cli, session, n)
+			if err == nil {
+				break
+			}
+			session.Close()
+			if errors.Is(err, ErrNodeTaken) {
+				zap.L().Fatal("snowflake 节点号已被其他实例占用，停止服务以免生成重复 ID", zap.Int64("node_id", n))
+			}
+			zap.L().Error("重新占用节点号失败", zap.Int64("node_id", n), zap.Error(err))
+			time.Sleep(retryWait)
+		}
+	}
+}
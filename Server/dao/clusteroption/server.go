@@ -0,0 +1,81 @@
+package clusteroption
+
+import (
+	"Server/models/clustertype"
+	"Server/pkg/instance"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"go.uber.org/zap"
+)
+
+const (
+	// sessionTTL 实例会话租约秒数，实例崩溃后登记、leader 和收件箱在此时间内失效
+	sessionTTL = 10
+	retryWait  = 3 * time.Second
+)
+
+// RegisterServer 以会话租约登记 /servers/<instance>，会话失效时重新登记，阻塞运行
+func RegisterServer(cli *clientv3.Client) {
+	info := clustertype.ServerInfo{ID: instance.ID(), NodeID: NodeID(), StartedAt: time.Now()}
+	data, err := json.Marshal(info)
+	if err != nil {
+		zap.L().Error("序列化实例信息失败", zap.Error(err))
+		return
+	}
+	for {
+		session, err := concurrency.NewSession(cli, concurrency.WithTTL(sessionTTL))
+		if err != nil {
+			zap.L().Error("创建实例会话失败", zap.Error(err))
+			time.Sleep(retryWait)
+			continue
+		}
+		if _, err := cli.Put(context.Background(), clustertype.ServerDir+info.ID, string(data), clientv3.WithLease(session.Lease())); err != nil {
+			zap.L().Error("登记服务端实例失败", zap.Error(err))
+			session.Close()
+			time.Sleep(retryWait)
+			continue
+		}
+		zap.L().Info("服务端实例已登记", zap.String("instance", info.ID))
+		<-session.Done()
+		zap.L().Warn("实例会话已失效，重新登记", zap.String("instance", info.ID))
+	}
+}
+
+// ListServers 列出在线的服务端实例并标记当前 leader
+func ListServers(cli *clientv3.Client) ([]clustertype.ServerInfo, error) {
+	resp, err := cli.Get(context.Background(), clustertype.ServerDir, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("查询服务端实例失败: %w", err)
+	}
+	leader, err := CurrentLeader(cli)
+	if err != nil {
+		return nil, err
+	}
+	servers := make([]clustertype.ServerInfo, 0, len(resp.Kvs))
+	for _, kvPair := range resp.Kvs {
+		var info clustertype.ServerInfo
+		if err := json.Unmarshal(kvPair.Value, &info); err != nil {
+			info.ID = strings.TrimPrefix(string(kvPair.Key), clustertype.ServerDir)
+		}
+		info.Leader = info.ID == leader
+		servers = append(servers, info)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+	return servers, nil
+}
+
+// serverOnline 检查实例是否仍在 /servers/ 中登记
+func serverOnline(cli *clientv3.Client, id string) (bool, error) {
+	resp, err := cli.Get(context.Background(), clustertype.ServerDir+id, clientv3.WithCountOnly())
+	if err != nil {
+		return false, fmt.Errorf("查询服务端实例失败: %w", err)
+	}
+	return resp.Count > 0, nil
+}
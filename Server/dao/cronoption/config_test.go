@@ -0,0 +1,41 @@
+package cronoption
+
+import (
+	"Server/settings"
+	"testing"
+	"time"
+)
+
+func TestKillTTL(t *testing.T) {
+	tests := []struct {
+		cfg  *settings.CronConfig
+		want int64
+	}{
+		{nil, defaultKillTTL},
+		{&settings.CronConfig{}, defaultKillTTL},
+		{&settings.CronConfig{KillTTL: 1}, minKillTTL},
+		{&settings.CronConfig{KillTTL: 30}, 30},
+	}
+	for _, tt := range tests {
+		if got := killTTL(tt.cfg); got != tt.want {
+			t.Errorf("killTTL(%+v) = %d, want %d", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestLockHold(t *testing.T) {
+	tests := []struct {
+		cfg  *settings.CronConfig
+		want time.Duration
+	}{
+		{nil, defaultLockHold},
+		{&settings.CronConfig{}, defaultLockHold},
+		{&settings.CronConfig{LockHold: 1}, minLockHold},
+		{&settings.CronConfig{LockHold: 10}, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := lockHold(tt.cfg); got != tt.want {
+			t.Errorf("lockHold(%+v) = %s, want %s", tt.cfg, got, tt.want)
+		}
+	}
+}
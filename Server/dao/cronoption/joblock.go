@@ -0,0 +1,76 @@
+package cronoption
+
+import (
+	"Server/models/tasktype"
+	"context"
+	"fmt"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// lockTTL 锁租约的秒数，持有锁的 worker 异常退出后锁在此时间内自动释放
+const lockTTL = 5
+
+// JobLock 基于租约的 /cron/lock/<name> 分布式锁，同一时刻只有一个 worker 能执行同一个任务
+type JobLock struct {
+	kv      clientv3.KV
+	lease   clientv3.Lease
+	name    string
+	leaseID clientv3.LeaseID
+	cancel  context.CancelFunc
+	locked  bool
+}
+
+// NewJobLock 创建任务锁，调用 TryLock 后才真正占用
+func NewJobLock(name string, kv clientv3.KV, lease clientv3.Lease) *JobLock {
+	return &JobLock{kv: kv, lease: lease, name: name}
+}
+
+// TryLock 尝试抢占锁，已被其他 worker 持有时返回 ERR_LOCK_ALREADY_REQUIRED
+func (l *JobLock) TryLock(value string) error {
+	grant, err := l.lease.Grant(context.Background(), lockTTL)
+	if err != nil {
+		return fmt.Errorf("申请租约失败: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	keepAlive, err := l.lease.KeepAlive(ctx, grant.ID)
+	if err != nil {
+		cancel()
+		l.lease.Revoke(context.Background(), grant.ID)
+		return fmt.Errorf("续约失败: %w", err)
+	}
+	// 消费续约应答，避免 channel 写满
+	go func() {
+		for range keepAlive {
+		}
+	}()
+
+	key := tasktype.JobLock + l.name
+	resp, err := l.kv.Txn(context.Background()).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, value, clientv3.WithLease(grant.ID))).
+		Commit()
+	if err != nil {
+		cancel()
+		l.lease.Revoke(context.Background(), grant.ID)
+		return fmt.Errorf("抢占任务锁失败: %w", err)
+	}
+	if !resp.Succeeded {
+		cancel()
+		l.lease.Revoke(context.Background(), grant.ID)
+		return tasktype.ERR_LOCK_ALREADY_REQUIRED
+	}
+
+	l.leaseID, l.cancel, l.locked = grant.ID, cancel, true
+	return nil
+}
+
+// Unlock 停止续约并撤销租约，锁随租约一起删除
+func (l *JobLock) Unlock() {
+	if !l.locked {
+		return
+	}
+	l.cancel()
+	l.lease.Revoke(context.Background(), l.leaseID)
+	l.locked = false
+}
@@ -0,0 +1,129 @@
+package cronoption
+
+import (
+	"Server/models/tasktype"
+	"Server/pkg/unmarshal"
+	"Server/settings"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gorhill/cronexpr"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	ErrJobNotFound    = errors.New("定时任务不存在")
+	ErrInvalidJobName = errors.New("任务名不能为空或超过 255 字节，且不能包含 / 或空白字符")
+)
+
+const (
+	// kill 标记的存活秒数，只需被正在运行的 worker 看到即可。etcd 会把过短的租约提升到自己的最小 TTL，
+	// 低于 minKillTTL 的配置按 minKillTTL 处理；worker 只响应新写入的标记，存活期间启动的执行不受影响
+	defaultKillTTL = 10
+	minKillTTL     = 5
+	// maxJobNameLen 与 joblist、jobdata 的 jobname 列长度一致
+	maxJobNameLen = 255
+)
+
+// ValidateJob 校验任务名、命令和 cron 表达式
+func ValidateJob(job *tasktype.Job) error {
+	if job.Name == "" || len(job.Name) > maxJobNameLen || strings.ContainsAny(job.Name, "/ \t\r\n") {
+		return ErrInvalidJobName
+	}
+	if strings.TrimSpace(job.Command) == "" {
+		return errors.New("命令不能为空")
+	}
+	if _, err := cronexpr.Parse(job.CronExpr); err != nil {
+		return fmt.Errorf("cron 表达式无效: %w", err)
+	}
+	return nil
+}
+
+// SaveJob 保存定时任务到 /cron/jobs/<name>，返回被覆盖的旧任务，新增时为 nil
+func SaveJob(kv clientv3.KV, job *tasktype.Job) (*tasktype.Job, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("序列化定时任务失败: %w", err)
+	}
+	resp, err := kv.Put(context.Background(), tasktype.JobDir+job.Name, string(data), clientv3.WithPrevKV())
+	if err != nil {
+		return nil, fmt.Errorf("保存定时任务失败: %w", err)
+	}
+	if resp.PrevKv == nil {
+		return nil, nil
+	}
+	old, err := unmarshal.UnPackJob(resp.PrevKv.Value)
+	if err != nil {
+		// 旧值损坏不影响本次保存
+		return nil, nil
+	}
+	return old, nil
+}
+
+// DeleteJob 删除定时任务，返回被删除的任务
+func DeleteJob(kv clientv3.KV, name string) (*tasktype.Job, error) {
+	resp, err := kv.Delete(context.Background(), tasktype.JobDir+name, clientv3.WithPrevKV())
+	if err != nil {
+		return nil, fmt.Errorf("删除定时任务失败: %w", err)
+	}
+	if len(resp.PrevKvs) == 0 {
+		return nil, ErrJobNotFound
+	}
+	old, err := unmarshal.UnPackJob(resp.PrevKvs[0].Value)
+	if err != nil {
+		return &tasktype.Job{Name: name}, nil
+	}
+	return old, nil
+}
+
+// ListJobs 列出 /cron/jobs/ 下的所有定时任务，同时返回读取时的 revision 供 watch 续接
+func ListJobs(kv clientv3.KV) ([]*tasktype.Job, int64, error) {
+	resp, err := kv.Get(context.Background(), tasktype.JobDir, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询定时任务失败: %w", err)
+	}
+	jobs := make([]*tasktype.Job, 0, len(resp.Kvs))
+	for _, kvPair := range resp.Kvs {
+		job, err := unmarshal.UnPackJob(kvPair.Value)
+		if err != nil {
+			// 跳过无法解析的任务，避免一个坏值影响整个列表
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, resp.Header.Revision, nil
+}
+
+// killTTL 返回配置的终止标记存活秒数，未配置时使用默认值，不低于 minKillTTL
+func killTTL(cfg *settings.CronConfig) int64 {
+	ttl := defaultKillTTL
+	if cfg != nil && cfg.KillTTL > 0 {
+		ttl = cfg.KillTTL
+	}
+	if ttl < minKillTTL {
+		ttl = minKillTTL
+	}
+	return int64(ttl)
+}
+
+// KillJob 写入带短租约的 /cron/kill/<name>，正在执行该任务的 worker 收到后终止命令
+func KillJob(cli *clientv3.Client, name string) error {
+	resp, err := cli.Get(context.Background(), tasktype.JobDir+name, clientv3.WithCountOnly())
+	if err != nil {
+		return fmt.Errorf("查询定时任务失败: %w", err)
+	}
+	if resp.Count == 0 {
+		return ErrJobNotFound
+	}
+	lease, err := cli.Grant(context.Background(), killTTL(settings.Conf.CronConfig))
+	if err != nil {
+		return fmt.Errorf("申请租约失败: %w", err)
+	}
+	if _, err := cli.Put(context.Background(), tasktype.JobKill+name, "", clientv3.WithLease(lease.ID)); err != nil {
+		return fmt.Errorf("写入终止标记失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package cronoption
+
+import (
+	"Server/models/tasktype"
+	"Server/pkg/snowflake"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	jobStatusEnabled = 1
+
+	defaultLogLimit = 100
+	maxLogLimit     = 1000
+)
+
+// SaveJobRecord 同步定时任务到 joblist，已存在时只更新命令和表达式
+func SaveJobRecord(db *sqlx.DB, job *tasktype.Job) error {
+	query := `INSERT INTO joblist (jobid, jobname, jobshell, jobstarttime, jobstatus, jobcronexpr)
+		VALUES (?, ?, ?, NOW(), ?, ?)
+		ON DUPLICATE KEY UPDATE jobshell = VALUES(jobshell), jobcronexpr = VALUES(jobcronexpr), jobstatus = VALUES(jobstatus)`
+	if _, err := db.Exec(query, snowflake.GenID(), job.Name, job.Command, jobStatusEnabled, job.CronExpr); err != nil {
+		return fmt.Errorf("保存定时任务记录失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteJobRecord 从 joblist 删除定时任务，执行记录保留
+func DeleteJobRecord(db *sqlx.DB, name string) error {
+	if _, err := db.Exec(`DELETE FROM joblist WHERE jobname = ?`, name); err != nil {
+		return fmt.Errorf("删除定时任务记录失败: %w", err)
+	}
+	return nil
+}
+
+// InsertJobLog 写入一次执行结果
+func InsertJobLog(db *sqlx.DB, log *tasktype.JobLog) error {
+	query := `INSERT INTO jobdata (jobname, jobplantime, jobstarttime, jobstoptime, jobinfo, jobrunning, joberr, jobworker)
+		VALUES (:jobname, :jobplantime, :jobstarttime, :jobstoptime, :jobinfo, :jobrunning, :joberr, :jobworker)`
+	if _, err := db.NamedExec(query, log); err != nil {
+		return fmt.Errorf("写入定时任务执行记录失败: %w", err)
+	}
+	return nil
+}
+
+// ListJobLogs 按开始时间倒序查询定时任务的执行记录
+func ListJobLogs(db *sqlx.DB, name string, q *tasktype.JobLogQuery) ([]tasktype.JobLog, error) {
+	query := `SELECT jobname, COALESCE(jobplantime, jobstarttime) AS jobplantime, jobstarttime, jobstoptime,
+		COALESCE(jobinfo, '') AS jobinfo, COALESCE(jobrunning, 0) AS jobrunning, COALESCE(joberr, '') AS joberr,
+		COALESCE(jobworker, '') AS jobworker
+		FROM jobdata WHERE jobname = ?`
+	args := []interface{}{name}
+	if !q.Start.IsZero() {
+		query += ` AND jobstarttime >= ?`
+		args = append(args, q.Start)
+	}
+	if !q.End.IsZero() {
+		query += ` AND jobstarttime <= ?`
+		args = append(args, q.End)
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLogLimit
+	}
+	if limit > maxLogLimit {
+		limit = maxLogLimit
+	}
+	query += ` ORDER BY jobstarttime DESC LIMIT ?`
+	args = append(args, limit)
+
+	var logs []tasktype.JobLog
+	if err := db.Select(&logs, query, args...); err != nil {
+		return nil, fmt.Errorf("查询定时任务执行记录失败: %w", err)
+	}
+	return logs, nil
+}
@@ -0,0 +1,351 @@
+package cronoption
+
+import (
+	"Server/models/tasktype"
+	"Server/pkg/instance"
+	"Server/pkg/unmarshal"
+	"Server/settings"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultShell = "/bin/bash"
+	// maxOutputBytes jobinfo 为 text 类型，超出部分截断
+	maxOutputBytes = 60000
+	// 锁至少持有到计划时间之后这么久，避免时钟较慢的 worker 在锁释放后重复执行同一轮。
+	// 假设各 worker 通过 NTP 同步，时钟偏差小于该值；偏差可能更大时调大 cron.lock_hold
+	defaultLockHold = 5 * time.Second
+	minLockHold     = 2 * time.Second
+	// killWaitDelay 命令被终止后等待子进程释放输出管道的时间
+	killWaitDelay  = 5 * time.Second
+	watchRetryWait = 3 * time.Second
+	idleInterval   = time.Second
+)
+
+// errNotLocked 因 etcd 错误未能抢锁，与锁已被其他 worker 持有区分
+var errNotLocked = errors.New("未能抢占任务锁")
+
+// Scheduler 监听 /cron/jobs/ 计算下次执行时间，到点后抢占 /cron/lock/<name> 执行命令并把结果写入 jobdata
+type Scheduler struct {
+	mgr      *tasktype.JobMgr
+	db       *sqlx.DB
+	shell    string
+	worker   string
+	lockHold time.Duration
+
+	events  chan *unmarshal.JobEvent
+	resync  chan []*tasktype.Job
+	results chan *tasktype.JobExecuteResult
+
+	// 以下只在 loop 中访问
+	plans     map[string]*tasktype.JobSchedulePlan
+	executing map[string]*tasktype.JobExecutingInfo
+}
+
+// NewScheduler 创建定时任务调度器，cfg 为空时使用默认 shell
+func NewScheduler(mgr *tasktype.JobMgr, db *sqlx.DB, cfg *settings.CronConfig) *Scheduler {
+	shell := defaultShell
+	if cfg != nil && cfg.Shell != "" {
+		shell = cfg.Shell
+	}
+	if mgr.Watcher == nil {
+		mgr.Watcher = clientv3.NewWatcher(mgr.Clinet)
+	}
+	return &Scheduler{
+		mgr:       mgr,
+		db:        db,
+		shell:     shell,
+		worker:    instance.ID(),
+		lockHold:  lockHold(cfg),
+		events:    make(chan *unmarshal.JobEvent, 1000),
+		resync:    make(chan []*tasktype.Job),
+		results:   make(chan *tasktype.JobExecuteResult, 1000),
+		plans:     make(map[string]*tasktype.JobSchedulePlan),
+		executing: make(map[string]*tasktype.JobExecutingInfo),
+	}
+}
+
+// lockHold 返回配置的锁持有时间，未配置时使用默认值，不低于 minLockHold
+func lockHold(cfg *settings.CronConfig) time.Duration {
+	hold := defaultLockHold
+	if cfg != nil && cfg.LockHold > 0 {
+		hold = time.Duration(cfg.LockHold) * time.Second
+	}
+	if hold < minLockHold {
+		hold = minLockHold
+	}
+	return hold
+}
+
+// Start 开始监听任务变更和终止请求并调度执行，阻塞运行
+func (s *Scheduler) Start() {
+	go s.watchJobs()
+	go s.watchKiller()
+	s.loop()
+}
+
+// watchJobs 全量加载任务后从下一个 revision 开始 watch，watch 中断时重新全量加载
+func (s *Scheduler) watchJobs() {
+	for {
+		jobs, rev, err := ListJobs(s.mgr.Kv)
+		if err != nil {
+			zap.L().Error("加载定时任务失败", zap.Error(err))
+			time.Sleep(watchRetryWait)
+			continue
+		}
+		s.resync <- jobs
+
+		ctx, cancel := context.WithCancel(context.Background())
+		for resp := range s.mgr.Watcher.Watch(ctx, tasktype.JobDir, clientv3.WithPrefix(), clientv3.WithRev(rev+1)) {
+			if err := resp.Err(); err != nil {
+				zap.L().Warn("监听定时任务中断，重新加载", zap.Error(err))
+				break
+			}
+			for _, ev := range resp.Events {
+				name := unmarshal.ExtractJobName(string(ev.Kv.Key))
+				switch ev.Type {
+				case mvccpb.PUT:
+					job, err := unmarshal.UnPackJob(ev.Kv.Value)
+					if err != nil {
+						zap.L().Warn("解析定时任务失败", zap.String("job", name), zap.Error(err))
+						continue
+					}
+					job.Name = name
+					s.events <- unmarshal.BUildJobEvent(tasktype.JobEventSave, job)
+				case mvccpb.DELETE:
+					s.events <- unmarshal.BUildJobEvent(tasktype.JobEventDelete, &tasktype.Job{Name: name})
+				}
+			}
+		}
+		cancel()
+		time.Sleep(watchRetryWait)
+	}
+}
+
+// watchKiller 监听 /cron/kill/，只关心新写入的终止标记
+func (s *Scheduler) watchKiller() {
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		for resp := range s.mgr.Watcher.Watch(ctx, tasktype.JobKill, clientv3.WithPrefix()) {
+			if err := resp.Err(); err != nil {
+				zap.L().Warn("监听定时任务终止请求中断", zap.Error(err))
+				break
+			}
+			for _, ev := range resp.Events {
+				if ev.Type != mvccpb.PUT {
+					continue
+				}
+				name := unmarshal.ExtractKillerName(string(ev.Kv.Key))
+				s.events <- unmarshal.BUildJobEvent(tasktype.JobKiller, &tasktype.Job{Name: name})
+			}
+		}
+		cancel()
+		time.Sleep(watchRetryWait)
+	}
+}
+
+func (s *Scheduler) loop() {
+	timer := time.NewTimer(s.trySchedule())
+	defer timer.Stop()
+	for {
+		select {
+		case ev := <-s.events:
+			s.handleEvent(ev)
+		case jobs := <-s.resync:
+			s.reset(jobs)
+		case result := <-s.results:
+			s.handleResult(result)
+		case <-timer.C:
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(s.trySchedule())
+	}
+}
+
+func (s *Scheduler) handleEvent(ev *unmarshal.JobEvent) {
+	switch ev.EventType {
+	case tasktype.JobEventSave:
+		s.savePlan(ev.Job)
+	case tasktype.JobEventDelete:
+		delete(s.plans, ev.Job.Name)
+	case tasktype.JobKiller:
+		if info, ok := s.executing[ev.Job.Name]; ok {
+			zap.L().Info("终止定时任务", zap.String("job", ev.Job.Name))
+			info.CancleFunc()
+		}
+	}
+}
+
+// savePlan 新增或更新执行计划，表达式未变时保留原来的下次执行时间
+func (s *Scheduler) savePlan(job *tasktype.Job) {
+	if old, ok := s.plans[job.Name]; ok && old.Job.CronExpr == job.CronExpr {
+		old.Job = job
+		return
+	}
+	plan, err := unmarshal.BuildJobSchedulePlan(job)
+	if err != nil {
+		zap.L().Warn("定时任务 cron 表达式无效", zap.String("job", job.Name), zap.String("cronexpr", job.CronExpr), zap.Error(err))
+		delete(s.plans, job.Name)
+		return
+	}
+	s.plans[job.Name] = plan
+}
+
+// reset 用全量加载的任务替换执行计划，watch 中断期间删除的任务在这里移除
+func (s *Scheduler) reset(jobs []*tasktype.Job) {
+	keep := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		keep[job.Name] = true
+		s.savePlan(job)
+	}
+	for name := range s.plans {
+		if !keep[name] {
+			delete(s.plans, name)
+		}
+	}
+}
+
+// trySchedule 启动已到期的任务，返回距离最近一次计划执行的时间
+func (s *Scheduler) trySchedule() time.Duration {
+	now := time.Now()
+	var nearest time.Time
+	for _, plan := range s.plans {
+		// 表达式不再有未来的执行时间
+		if plan.NextTime.IsZero() {
+			continue
+		}
+		if !plan.NextTime.After(now) {
+			s.tryStart(plan)
+			plan.NextTime = plan.Expr.Next(now)
+		}
+		if !plan.NextTime.IsZero() && (nearest.IsZero() || plan.NextTime.Before(nearest)) {
+			nearest = plan.NextTime
+		}
+	}
+	if nearest.IsZero() {
+		return idleInterval
+	}
+	return nearest.Sub(now)
+}
+
+// tryStart 本实例上一轮仍在执行时跳过本轮
+func (s *Scheduler) tryStart(plan *tasktype.JobSchedulePlan) {
+	if _, running := s.executing[plan.Job.Name]; running {
+		zap.L().Debug("定时任务仍在执行，跳过本轮", zap.String("job", plan.Job.Name))
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	info := &tasktype.JobExecutingInfo{
+		Job:        plan.Job,
+		PlanTime:   plan.NextTime,
+		RealTime:   time.Now(),
+		CancleCtx:  ctx,
+		CancleFunc: cancel,
+	}
+	s.executing[plan.Job.Name] = info
+	go s.execute(info)
+}
+
+// execute 抢到锁后执行命令，未抢到锁说明本轮已由其他 worker 执行
+func (s *Scheduler) execute(info *tasktype.JobExecutingInfo) {
+	result := &tasktype.JobExecuteResult{ExecuteInfo: info, StartTime: time.Now()}
+	defer func() {
+		if result.EndTime.IsZero() {
+			result.EndTime = time.Now()
+		}
+		s.results <- result
+	}()
+
+	lock := NewJobLock(info.Job.Name, s.mgr.Kv, s.mgr.Lease)
+	if err := lock.TryLock(s.worker); err != nil {
+		if !errors.Is(err, tasktype.ERR_LOCK_ALREADY_REQUIRED) {
+			err = fmt.Errorf("%w: %v", errNotLocked, err)
+		}
+		result.Err = err
+		return
+	}
+	defer func() {
+		if wait := time.Until(info.PlanTime.Add(s.lockHold)); wait > 0 {
+			time.Sleep(wait)
+		}
+		lock.Unlock()
+	}()
+
+	result.StartTime = time.Now()
+	cmd := exec.CommandContext(info.CancleCtx, s.shell, "-c", info.Job.Command)
+	cmd.WaitDelay = killWaitDelay
+	result.Output, result.Err = cmd.CombinedOutput()
+	result.EndTime = time.Now()
+}
+
+func (s *Scheduler) handleResult(result *tasktype.JobExecuteResult) {
+	info := result.ExecuteInfo
+	delete(s.executing, info.Job.Name)
+	killed := info.CancleCtx.Err() != nil
+	info.CancleFunc()
+
+	if errors.Is(result.Err, tasktype.ERR_LOCK_ALREADY_REQUIRED) {
+		return
+	}
+	if errors.Is(result.Err, errNotLocked) {
+		// etcd 不可用时本实例无法确认是否轮到自己执行，其他 worker 可能已执行本轮
+		zap.L().Warn("定时任务抢锁失败，跳过本轮", zap.String("job", info.Job.Name), zap.Error(result.Err))
+		return
+	}
+
+	log := &tasktype.JobLog{
+		JobName:   info.Job.Name,
+		PlanTime:  info.PlanTime,
+		StartTime: result.StartTime,
+		StopTime:  result.EndTime,
+		Output:    truncateOutput(result.Output),
+		ExitCode:  exitCode(result.Err),
+		Worker:    s.worker,
+	}
+	if result.Err != nil {
+		log.Err = result.Err.Error()
+		if killed {
+			log.Err = "已被终止: " + log.Err
+		}
+	}
+	go func() {
+		if err := InsertJobLog(s.db, log); err != nil {
+			zap.L().Error("写入定时任务执行记录失败", zap.String("job", log.JobName), zap.Error(err))
+		}
+	}()
+}
+
+// exitCode 命令未能启动或被信号终止时为 -1
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// truncateOutput 截断过长的输出，截断处可能落在多字节字符中间，需去掉不完整的字符
+func truncateOutput(out []byte) string {
+	if len(out) <= maxOutputBytes {
+		return strings.ToValidUTF8(string(out), "")
+	}
+	return strings.ToValidUTF8(string(out[:maxOutputBytes]), "") + fmt.Sprintf("\n... 已截断 %d 字节", len(out)-maxOutputBytes)
+}
@@ -0,0 +1,76 @@
+package enrolloption
+
+import (
+	"Server/models/enrolltype"
+	"Server/pkg/minica"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrCertificateNotFound 证书不存在
+	ErrCertificateNotFound = errors.New("证书不存在")
+	// ErrCertificateInvalid 证书未登记、已吊销或与客户端 ID 不一致
+	ErrCertificateInvalid = errors.New("客户端证书无效")
+)
+
+// SaveCertificate 登记内置 CA 签发的客户端证书
+func SaveCertificate(db *sqlx.DB, agentID string, cert *x509.Certificate) error {
+	_, err := db.Exec(`
+		INSERT INTO agent_certificates (serial, agent_id, not_before, not_after) VALUES (?, ?, ?, ?)
+	`, minica.Serial(cert), agentID, cert.NotBefore, cert.NotAfter)
+	if err != nil {
+		zap.L().Error("Failed to save agent certificate", zap.String("agent_id", agentID), zap.Error(err))
+		return fmt.Errorf("failed to save agent certificate: %w", err)
+	}
+	return nil
+}
+
+// ListCertificates 查询客户端的全部证书，新签发的在前
+func ListCertificates(db *sqlx.DB, agentID string) ([]enrolltype.AgentCertificate, error) {
+	certs := []enrolltype.AgentCertificate{}
+	err := db.Select(&certs, `
+		SELECT serial, agent_id, not_before, not_after, created_at, revoked_at
+		FROM agent_certificates WHERE agent_id = ? ORDER BY created_at DESC
+	`, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("查询客户端证书失败: %w", err)
+	}
+	return certs, nil
+}
+
+// RevokeCertificate 吊销证书，返回证书所属的客户端 ID
+func RevokeCertificate(db *sqlx.DB, serial string) (string, error) {
+	var agentID string
+	err := db.Get(&agentID, `SELECT agent_id FROM agent_certificates WHERE serial = ?`, serial)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrCertificateNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询客户端证书失败: %w", err)
+	}
+	if _, err := db.Exec(`UPDATE agent_certificates SET revoked_at = NOW() WHERE serial = ? AND revoked_at IS NULL`, serial); err != nil {
+		return "", fmt.Errorf("吊销客户端证书失败: %w", err)
+	}
+	return agentID, nil
+}
+
+// CheckCertificate 校验客户端出示的证书已登记且未吊销，返回证书对应的客户端 ID
+func CheckCertificate(db *sqlx.DB, cert *x509.Certificate) (string, error) {
+	var record enrolltype.AgentCertificate
+	err := db.Get(&record, `SELECT serial, agent_id, revoked_at FROM agent_certificates WHERE serial = ?`, minica.Serial(cert))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrCertificateInvalid
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询客户端证书失败: %w", err)
+	}
+	if record.RevokedAt != nil || record.AgentID != cert.Subject.CommonName {
+		return "", ErrCertificateInvalid
+	}
+	return record.AgentID, nil
+}
@@ -0,0 +1,234 @@
+package enrolloption
+
+import (
+	"Server/models/enrolltype"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"time"
+)
+
+var (
+	// ErrTokenNotFound 注册令牌不存在
+	ErrTokenNotFound = errors.New("注册令牌不存在")
+	// ErrCredentialNotFound 客户端凭证不存在
+	ErrCredentialNotFound = errors.New("客户端凭证不存在")
+	// ErrEnrollDenied 注册令牌无效、已吊销、已过期或次数用尽，不区分原因返回给客户端
+	ErrEnrollDenied = errors.New("注册令牌无效")
+	// ErrCredentialInvalid 凭证不匹配或已吊销
+	ErrCredentialInvalid = errors.New("客户端凭证无效")
+	// ErrAgentEnrolled 客户端已有凭证（含已吊销），需管理员重置后才能重新注册
+	ErrAgentEnrolled = errors.New("客户端已注册，需管理员重置凭证后才能重新注册")
+)
+
+// MySQL 唯一索引冲突的错误码
+const errDupEntry = 1062
+
+const tokenColumns = `id, name, token_hash, max_uses, used_count, expires_at, revoked, created_at`
+
+// CreateToken 生成注册令牌，返回的明文令牌不落库
+func CreateToken(db *sqlx.DB, p *enrolltype.NewEnrollmentToken) (*enrolltype.IssuedEnrollmentToken, error) {
+	plain, err := randomSecret()
+	if err != nil {
+		return nil, err
+	}
+	var expiresAt *time.Time
+	if p.TTLHours > 0 {
+		t := time.Now().Add(time.Duration(p.TTLHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO enrollment_tokens (name, token_hash, max_uses, expires_at) VALUES (?, ?, ?, ?)
+	`, p.Name, hashSecret(plain), p.MaxUses, expiresAt)
+	if err != nil {
+		zap.L().Error("Failed to insert enrollment token", zap.Error(err))
+		return nil, fmt.Errorf("failed to insert enrollment token: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	issued := &enrolltype.IssuedEnrollmentToken{Token: plain}
+	if err := db.Get(&issued.EnrollmentToken, `SELECT `+tokenColumns+` FROM enrollment_tokens WHERE id = ?`, id); err != nil {
+		return nil, fmt.Errorf("查询注册令牌失败: %w", err)
+	}
+	return issued, nil
+}
+
+// ListTokens 查询全部注册令牌
+func ListTokens(db *sqlx.DB) ([]enrolltype.EnrollmentToken, error) {
+	tokens := []enrolltype.EnrollmentToken{}
+	if err := db.Select(&tokens, `SELECT `+tokenColumns+` FROM enrollment_tokens ORDER BY id DESC`); err != nil {
+		return nil, fmt.Errorf("查询注册令牌失败: %w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeToken 吊销注册令牌，已用它注册的客户端不受影响
+func RevokeToken(db *sqlx.DB, id int64) error {
+	result, err := db.Exec(`UPDATE enrollment_tokens SET revoked = 1 WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("吊销注册令牌失败: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		var count int64
+		if err := db.Get(&count, `SELECT COUNT(*) FROM enrollment_tokens WHERE id = ?`, id); err == nil && count == 0 {
+			return ErrTokenNotFound
+		}
+	}
+	return nil
+}
+
+// Enroll 校验并消耗一次注册令牌，为客户端签发新的长期凭证。
+// 客户端已有凭证时不论是否吊销都拒绝注册，防止持有注册令牌者接管已有客户端或解除吊销
+func Enroll(db *sqlx.DB, plainToken, agentID, clientIP string) (string, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var token enrolltype.EnrollmentToken
+	err = tx.Get(&token, `SELECT `+tokenColumns+` FROM enrollment_tokens WHERE token_hash = ? FOR UPDATE`, hashSecret(plainToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrEnrollDenied
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询注册令牌失败: %w", err)
+	}
+	if token.Revoked ||
+		(token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now())) ||
+		(token.MaxUses > 0 && token.UsedCount >= token.MaxUses) {
+		return "", ErrEnrollDenied
+	}
+
+	// 先校验令牌再检查客户端是否已注册，没有有效令牌时无法借此探测已注册的客户端
+	var enrolled int64
+	if err := tx.Get(&enrolled, `SELECT COUNT(*) FROM agent_credentials WHERE agent_id = ? FOR UPDATE`, agentID); err != nil {
+		return "", fmt.Errorf("查询客户端凭证失败: %w", err)
+	}
+	if enrolled > 0 {
+		return "", ErrAgentEnrolled
+	}
+
+	if _, err := tx.Exec(`UPDATE enrollment_tokens SET used_count = used_count + 1 WHERE id = ?`, token.ID); err != nil {
+		return "", fmt.Errorf("更新注册令牌失败: %w", err)
+	}
+
+	credential, err := randomSecret()
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO agent_credentials (agent_id, credential_hash, enrollment_token_id, client_ip)
+		VALUES (?, ?, ?, ?)
+	`, agentID, hashSecret(credential), token.ID, clientIP)
+	// 同一客户端并发注册时只有一个能写入
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == errDupEntry {
+		return "", ErrAgentEnrolled
+	}
+	if err != nil {
+		zap.L().Error("Failed to save agent credential", zap.String("agent_id", agentID), zap.Error(err))
+		return "", fmt.Errorf("failed to save agent credential: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return credential, nil
+}
+
+// VerifyCredential 校验客户端凭证，凭证不存在、不匹配或已吊销时返回 ErrCredentialInvalid
+func VerifyCredential(db *sqlx.DB, agentID, credential string) error {
+	if agentID == "" || credential == "" {
+		return ErrCredentialInvalid
+	}
+	var cred enrolltype.AgentCredential
+	err := db.Get(&cred, `SELECT agent_id, credential_hash, revoked_at FROM agent_credentials WHERE agent_id = ?`, agentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCredentialInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("查询客户端凭证失败: %w", err)
+	}
+	if cred.RevokedAt != nil || subtle.ConstantTimeCompare([]byte(cred.CredentialHash), []byte(hashSecret(credential))) != 1 {
+		return ErrCredentialInvalid
+	}
+	return nil
+}
+
+// ListCredentials 查询全部客户端凭证
+func ListCredentials(db *sqlx.DB) ([]enrolltype.AgentCredential, error) {
+	creds := []enrolltype.AgentCredential{}
+	err := db.Select(&creds, `
+		SELECT agent_id, credential_hash, enrollment_token_id, client_ip, created_at, revoked_at
+		FROM agent_credentials ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询客户端凭证失败: %w", err)
+	}
+	return creds, nil
+}
+
+// RevokeCredential 吊销客户端凭证及其证书，吊销记录保留，客户端需管理员重置后才能重新注册
+func RevokeCredential(db *sqlx.DB, agentID string) error {
+	result, err := db.Exec(`UPDATE agent_credentials SET revoked_at = NOW() WHERE agent_id = ? AND revoked_at IS NULL`, agentID)
+	if err != nil {
+		return fmt.Errorf("吊销客户端凭证失败: %w", err)
+	}
+	if _, err := db.Exec(`UPDATE agent_certificates SET revoked_at = NOW() WHERE agent_id = ? AND revoked_at IS NULL`, agentID); err != nil {
+		return fmt.Errorf("吊销客户端证书失败: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		var count int64
+		if err := db.Get(&count, `SELECT COUNT(*) FROM agent_credentials WHERE agent_id = ?`, agentID); err == nil && count == 0 {
+			return ErrCredentialNotFound
+		}
+	}
+	return nil
+}
+
+// ResetCredential 删除客户端凭证并吊销其证书，之后该客户端可以使用注册令牌重新注册
+func ResetCredential(db *sqlx.DB, agentID string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM agent_credentials WHERE agent_id = ?`, agentID)
+	if err != nil {
+		return fmt.Errorf("删除客户端凭证失败: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrCredentialNotFound
+	}
+	if _, err := tx.Exec(`UPDATE agent_certificates SET revoked_at = NOW() WHERE agent_id = ? AND revoked_at IS NULL`, agentID); err != nil {
+		return fmt.Errorf("吊销客户端证书失败: %w", err)
+	}
+	return tx.Commit()
+}
+
+// randomSecret 生成 32 字节的随机令牌
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成随机令牌失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashSecret 令牌和凭证只以 SHA-256 哈希保存
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -4,7 +4,7 @@ import (
 	"Server/models/clientsoket"
 	"Server/models/tasktype"
 	"Server/settings"
-	"crypto/sha256"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
@@ -69,9 +69,10 @@ func InitCrontab(cfg *settings.EtcdConfig) (*clientv3.Client, error) {
 
 	// 初始化全局 GJobMgr
 	GJobMgr = &tasktype.JobMgr{
-		Clinet: Client,
-		Kv:     Kv,
-		Lease:  Lease,
+		Clinet:  Client,
+		Kv:      Kv,
+		Lease:   Lease,
+		Watcher: clientv3.NewWatcher(Client),
 	}
 	return Client, nil
 }
@@ -103,18 +104,12 @@ func ParseTokenInfo(data string) (tokenInfo clientsoket.TokenInfo, err error) {
 
 	return tokenInfo, nil
 }
-func GenerateToken(clientIP string) string {
-	// 获取当前时间
-	timestamp := time.Now().Unix()
 
-	// 将 IP 和时间戳结合在一起生成一个字符串
-	data := fmt.Sprintf("%s:%d", clientIP, timestamp)
-
-	// 使用 SHA256 生成哈希
-	hash := sha256.New()
-	hash.Write([]byte(data))
-	token := hex.EncodeToString(hash.Sum(nil))
-
-	// 返回生成的 token
-	return token
+// GenerateToken 生成随机的会话 token，不能由客户端 ID 推算
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
 }
@@ -0,0 +1,270 @@
+package hostoption
+
+import (
+	"Server/models/hosttype"
+	"Server/pkg/snowflake"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ErrHostNotFound 主机不存在
+var ErrHostNotFound = errors.New("主机不存在")
+
+const hostColumns = `h.id, h.hostid, h.agent_id, h.hostname, h.systemtype, h.hoststatus, h.hostip, h.hostlocation, h.hostowner,
+	h.hostaddtime, h.hostnote, h.hostsysteminfo, h.hostuptime`
+
+const hostFrom = `FROM hostlist h`
+
+// RegisterHost 客户端注册时按客户端 ID 新增或更新主机清单，返回 hostid。
+// IP 只作为主机属性更新；升级前按 IP 登记、尚无客户端 ID 的主机会被认领。
+// 已下线的主机保持下线状态，需要人工恢复
+func RegisterHost(db *sqlx.DB, facts *hosttype.HostFacts) (int64, error) {
+	info, err := json.Marshal(facts)
+	if err != nil {
+		return 0, err
+	}
+	systemType := strings.TrimSpace(facts.Platform + " " + facts.PlatformVersion)
+	if systemType == "" {
+		systemType = facts.OS
+	}
+	uptime := FormatUptime(time.Duration(facts.Uptime) * time.Second)
+
+	var hostID int64
+	err = db.Get(&hostID, `SELECT hostid FROM hostlist WHERE agent_id = ?`, facts.AgentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = db.Get(&hostID, `SELECT hostid FROM hostlist WHERE hostip = ? AND agent_id IS NULL ORDER BY id LIMIT 1`, facts.ClientIP)
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		hostID = snowflake.GenID()
+		_, err = db.Exec(`
+			INSERT INTO hostlist (hostid, agent_id, hostname, systemtype, hoststatus, hostip, hostsysteminfo, hostuptime)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, hostID, facts.AgentID, facts.HostName, systemType, hosttype.HostActive, facts.ClientIP, string(info), uptime)
+		if err != nil {
+			zap.L().Error("Failed to insert host", zap.String("agent_id", facts.AgentID), zap.Error(err))
+			return 0, fmt.Errorf("failed to insert host: %w", err)
+		}
+	case err != nil:
+		return 0, fmt.Errorf("查询主机失败: %w", err)
+	default:
+		_, err = db.Exec(`
+			UPDATE hostlist SET agent_id = ?, hostip = ?, hostname = ?, systemtype = ?, hostsysteminfo = ?, hostuptime = ?
+			WHERE hostid = ?
+		`, facts.AgentID, facts.ClientIP, facts.HostName, systemType, string(info), uptime, hostID)
+		if err != nil {
+			zap.L().Error("Failed to update host", zap.Int64("hostid", hostID), zap.Error(err))
+			return 0, fmt.Errorf("failed to update host: %w", err)
+		}
+	}
+	return hostID, nil
+}
+
+// ListHosts 按条件查询主机清单，在线状态取自 online（/agents/online/ 下的客户端，key 为客户端 ID）
+func ListHosts(db *sqlx.DB, q *hosttype.HostQuery, online map[string]hosttype.Presence) ([]hosttype.HostView, error) {
+	query := "SELECT " + hostColumns + " " + hostFrom + " WHERE 1 = 1"
+	var args []interface{}
+	if q.Status != nil {
+		query += " AND h.hoststatus = ?"
+		args = append(args, *q.Status)
+	}
+	if q.Keyword != "" {
+		query += " AND (h.hostname LIKE ? OR h.hostip LIKE ?)"
+		args = append(args, "%"+q.Keyword+"%", "%"+q.Keyword+"%")
+	}
+	if q.Tag != "" {
+		query += " AND h.hostid IN (SELECT hostid FROM host_tags WHERE tag = ?)"
+		args = append(args, q.Tag)
+	}
+	if q.ScopeUserID != nil {
+		query += ` AND h.hostid IN (
+			SELECT m.hostid FROM user_host_groups u
+			JOIN host_group_members m ON m.group_id = u.group_id
+			WHERE u.user_id = ?)`
+		args = append(args, *q.ScopeUserID)
+	}
+	query += " ORDER BY h.id"
+
+	var hosts []hosttype.Host
+	if err := db.Select(&hosts, query, args...); err != nil {
+		return nil, fmt.Errorf("查询主机列表失败: %w", err)
+	}
+	tags, err := loadTags(db)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]hosttype.HostView, 0, len(hosts))
+	for i := range hosts {
+		view := toView(&hosts[i], tags[hosts[i].HostID], online)
+		if q.Online != nil && view.Online != *q.Online {
+			continue
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// GetHost 查询单个主机，online 同 ListHosts
+func GetHost(db *sqlx.DB, hostID int64, online map[string]hosttype.Presence) (*hosttype.HostView, error) {
+	var host hosttype.Host
+	query := "SELECT " + hostColumns + " " + hostFrom + " WHERE h.hostid = ?"
+	if err := db.Get(&host, query, hostID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrHostNotFound
+		}
+		return nil, fmt.Errorf("查询主机失败: %w", err)
+	}
+
+	var tags []string
+	if err := db.Select(&tags, `SELECT tag FROM host_tags WHERE hostid = ? ORDER BY tag`, hostID); err != nil {
+		return nil, fmt.Errorf("查询主机标签失败: %w", err)
+	}
+	view := toView(&host, tags, online)
+	return &view, nil
+}
+
+// UpdateHost 修改主机负责人、位置、备注和状态
+func UpdateHost(db *sqlx.DB, hostID int64, u *hosttype.HostUpdate) error {
+	var sets []string
+	var args []interface{}
+	if u.HostLocation != nil {
+		sets = append(sets, "hostlocation = ?")
+		args = append(args, *u.HostLocation)
+	}
+	if u.HostOwner != nil {
+		sets = append(sets, "hostowner = ?")
+		args = append(args, *u.HostOwner)
+	}
+	if u.HostNote != nil {
+		sets = append(sets, "hostnote = ?")
+		args = append(args, *u.HostNote)
+	}
+	if u.HostStatus != nil {
+		sets = append(sets, "hoststatus = ?")
+		args = append(args, *u.HostStatus)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	return execHost(db, "UPDATE hostlist SET "+strings.Join(sets, ", ")+" WHERE hostid = ?", append(args, hostID)...)
+}
+
+// DecommissionHost 将主机标记为下线，保留历史记录
+func DecommissionHost(db *sqlx.DB, hostID int64) error {
+	return execHost(db, `UPDATE hostlist SET hoststatus = ? WHERE hostid = ?`, hosttype.HostDecommissioned, hostID)
+}
+
+// SetHostTags 替换主机的全部标签
+func SetHostTags(db *sqlx.DB, hostID int64, tags []string) error {
+	var exists int64
+	if err := db.Get(&exists, `SELECT COUNT(*) FROM hostlist WHERE hostid = ?`, hostID); err != nil {
+		return fmt.Errorf("查询主机失败: %w", err)
+	}
+	if exists == 0 {
+		return ErrHostNotFound
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM host_tags WHERE hostid = ?`, hostID); err != nil {
+		return fmt.Errorf("删除主机标签失败: %w", err)
+	}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		if _, err := tx.Exec(`INSERT INTO host_tags (hostid, tag) VALUES (?, ?)`, hostID, tag); err != nil {
+			return fmt.Errorf("写入主机标签失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// execHost 执行针对单个主机的更新，主机不存在时返回 ErrHostNotFound
+func execHost(db *sqlx.DB, query string, args ...interface{}) error {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		zap.L().Error("Failed to update host", zap.Error(err))
+		return fmt.Errorf("failed to update host: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		var count int64
+		if err := db.Get(&count, `SELECT COUNT(*) FROM hostlist WHERE hostid = ?`, args[len(args)-1]); err == nil && count == 0 {
+			return ErrHostNotFound
+		}
+	}
+	return nil
+}
+
+// loadTags 查询所有主机标签，key 为 hostid
+func loadTags(db *sqlx.DB) (map[int64][]string, error) {
+	var rows []struct {
+		HostID int64  `db:"hostid"`
+		Tag    string `db:"tag"`
+	}
+	if err := db.Select(&rows, `SELECT hostid, tag FROM host_tags ORDER BY tag`); err != nil {
+		return nil, fmt.Errorf("查询主机标签失败: %w", err)
+	}
+	tags := make(map[int64][]string)
+	for _, r := range rows {
+		tags[r.HostID] = append(tags[r.HostID], r.Tag)
+	}
+	return tags, nil
+}
+
+func toView(h *hosttype.Host, tags []string, online map[string]hosttype.Presence) hosttype.HostView {
+	view := hosttype.HostView{
+		HostID:       h.HostID,
+		AgentID:      h.AgentID.String,
+		HostName:     h.HostName,
+		SystemType:   h.SystemType.String,
+		HostStatus:   h.HostStatus,
+		HostIP:       h.HostIP,
+		HostLocation: h.HostLocation.String,
+		HostOwner:    h.HostOwner.String,
+		HostNote:     h.HostNote.String,
+		HostUptime:   h.HostUptime.String,
+		HostAddTime:  h.HostAddTime,
+		Tags:         tags,
+	}
+	if view.Tags == nil {
+		view.Tags = []string{}
+	}
+	if p, ok := online[h.AgentID.String]; ok && h.AgentID.Valid {
+		view.Online = true
+		view.ConnectedAt = &p.ConnectedAt
+		view.Server = p.Server
+	}
+	// 旧数据的主机信息不是 JSON，解析失败时忽略
+	var facts hosttype.HostFacts
+	if h.HostSystemInfo.Valid && json.Unmarshal([]byte(h.HostSystemInfo.String), &facts) == nil {
+		view.Facts = &facts
+	}
+	return view
+}
+
+// FormatUptime 将运行时长格式化为 "x天x小时x分"
+func FormatUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	if days > 0 {
+		return fmt.Sprintf("%d天%d小时%d分", days, hours, minutes)
+	}
+	return fmt.Sprintf("%d小时%d分", hours, minutes)
+}
@@ -0,0 +1,34 @@
+package metricoption
+
+import (
+	"Server/models/metrictype"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// InsertMetricSamples 批量写入指标样本到 host_metrics 表
+func InsertMetricSamples(db *sqlx.DB, samples []metrictype.MetricSample) (int64, error) {
+	if len(samples) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		INSERT INTO host_metrics (agent_id, client_ip, metric, label, value, collect_time)
+		VALUES (:agent_id, :client_ip, :metric, :label, :value, :collect_time)
+	`
+
+	// sqlx 对切片参数会展开为批量插入
+	result, err := db.NamedExec(query, samples)
+	if err != nil {
+		zap.L().Error("Failed to insert metric samples", zap.Error(err))
+		return 0, fmt.Errorf("failed to insert metric samples: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve affected rows: %w", err)
+	}
+	return rows, nil
+}
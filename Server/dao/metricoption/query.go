@@ -0,0 +1,215 @@
+package metricoption
+
+import (
+	"Server/models/metrictype"
+	"Server/pkg/aggregate"
+	"Server/settings"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	rawQuerySpan    = 6 * time.Hour
+	minuteQuerySpan = 7 * 24 * time.Hour
+)
+
+// ChooseResolution 未指定精度时按查询跨度和保留策略选择数据源
+func ChooseResolution(q *metrictype.MetricQuery, cfg *settings.MetricsConfig) string {
+	if q.Resolution != "" {
+		return q.Resolution
+	}
+
+	rawHours, minuteDays := defaultRawHours, defaultMinuteDay
+	if cfg != nil {
+		if cfg.RawRetention > 0 {
+			rawHours = cfg.RawRetention
+		}
+		if cfg.MinuteRetention > 0 {
+			minuteDays = cfg.MinuteRetention
+		}
+	}
+
+	span := q.End.Sub(q.Start)
+	age := time.Since(q.Start)
+	switch {
+	case span <= rawQuerySpan && age <= time.Duration(rawHours)*time.Hour:
+		return metrictype.ResolutionRaw
+	case span <= minuteQuerySpan && age <= time.Duration(minuteDays)*24*time.Hour:
+		return metrictype.Resolution1m
+	default:
+		return metrictype.Resolution1h
+	}
+}
+
+// QueryRawSamples 查询区间内的原始样本
+func QueryRawSamples(db *sqlx.DB, q *metrictype.MetricQuery) ([]metrictype.MetricSample, error) {
+	query := `SELECT agent_id, client_ip, metric, label, value, collect_time FROM host_metrics
+		WHERE agent_id = ? AND metric = ? AND collect_time >= ? AND collect_time < ?`
+	args := []interface{}{q.AgentID, q.Metric, q.Start, q.End}
+	if q.Label != "" {
+		query += " AND label = ?"
+		args = append(args, q.Label)
+	}
+	query += " ORDER BY collect_time"
+
+	var samples []metrictype.MetricSample
+	if err := db.Select(&samples, query, args...); err != nil {
+		return nil, fmt.Errorf("查询原始指标失败: %w", err)
+	}
+	return samples, nil
+}
+
+// QueryRollups 查询区间内指定精度的汇总数据
+func QueryRollups(db *sqlx.DB, resolution string, q *metrictype.MetricQuery) ([]metrictype.MetricRollup, error) {
+	target, ok := rollupTables[resolution]
+	if !ok {
+		return nil, fmt.Errorf("不支持的汇总精度: %s", resolution)
+	}
+
+	query := fmt.Sprintf(`SELECT agent_id, client_ip, metric, label, bucket_time, avg_value, max_value, min_value, p95_value, sample_count
+		FROM %s WHERE agent_id = ? AND metric = ? AND bucket_time >= ? AND bucket_time < ?`, target.Table)
+	args := []interface{}{q.AgentID, q.Metric, q.Start.Truncate(target.Step), q.End}
+	if q.Label != "" {
+		query += " AND label = ?"
+		args = append(args, q.Label)
+	}
+	query += " ORDER BY bucket_time"
+
+	var rollups []metrictype.MetricRollup
+	if err := db.Select(&rollups, query, args...); err != nil {
+		return nil, fmt.Errorf("查询汇总指标失败: %w", err)
+	}
+	return rollups, nil
+}
+
+// RangeQuery 按标签返回区间内的时间序列
+func RangeQuery(db *sqlx.DB, q *metrictype.MetricQuery, cfg *settings.MetricsConfig) (*metrictype.MetricRangeResult, error) {
+	resolution := ChooseResolution(q, cfg)
+	series := make(map[string][]metrictype.MetricPoint)
+
+	if resolution == metrictype.ResolutionRaw {
+		samples, err := QueryRawSamples(db, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			series[s.Label] = append(series[s.Label], metrictype.MetricPoint{
+				Time: s.CollectTime, Avg: s.Value, Max: s.Value, Min: s.Value, P95: s.Value, Count: 1,
+			})
+		}
+	} else {
+		rollups, err := QueryRollups(db, resolution, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rollups {
+			series[r.Label] = append(series[r.Label], metrictype.MetricPoint{
+				Time: r.BucketTime, Avg: r.AvgValue, Max: r.MaxValue, Min: r.MinValue, P95: r.P95Value, Count: r.SampleCount,
+			})
+		}
+	}
+
+	result := &metrictype.MetricRangeResult{
+		AgentID:    q.AgentID,
+		Metric:     q.Metric,
+		Resolution: resolution,
+		Start:      q.Start,
+		End:        q.End,
+		Series:     make([]metrictype.MetricSeries, 0, len(series)),
+	}
+	for label, points := range series {
+		result.Series = append(result.Series, metrictype.MetricSeries{Label: label, Points: points})
+	}
+	sort.Slice(result.Series, func(i, j int) bool { return result.Series[i].Label < result.Series[j].Label })
+	return result, nil
+}
+
+// AggregateQuery 计算区间内每个标签的平均值、最大值和 95 分位。
+// 原始数据可用时结果精确；使用汇总数据时平均值按样本数加权，95 分位取各时间桶 95 分位的 95 分位，为近似值
+func AggregateQuery(db *sqlx.DB, q *metrictype.MetricQuery, cfg *settings.MetricsConfig) ([]metrictype.MetricAggregate, string, error) {
+	// 聚合不受查询跨度限制，只要原始数据仍在保留期内就使用原始数据
+	resolution := q.Resolution
+	if resolution == "" {
+		rawHours := defaultRawHours
+		if cfg != nil && cfg.RawRetention > 0 {
+			rawHours = cfg.RawRetention
+		}
+		if time.Since(q.Start) <= time.Duration(rawHours)*time.Hour {
+			resolution = metrictype.ResolutionRaw
+		} else {
+			resolution = ChooseResolution(q, cfg)
+		}
+	}
+
+	var aggregates []metrictype.MetricAggregate
+	if resolution == metrictype.ResolutionRaw {
+		samples, err := QueryRawSamples(db, q)
+		if err != nil {
+			return nil, resolution, err
+		}
+		values := make(map[string][]float64)
+		for _, s := range samples {
+			values[s.Label] = append(values[s.Label], s.Value)
+		}
+		for label, v := range values {
+			sum := aggregate.Summarize(v)
+			aggregates = append(aggregates, metrictype.MetricAggregate{
+				Label: label, Avg: sum.Avg, Max: sum.Max, Min: sum.Min, P95: sum.P95, Count: sum.Count,
+			})
+		}
+	} else {
+		rollups, err := QueryRollups(db, resolution, q)
+		if err != nil {
+			return nil, resolution, err
+		}
+		grouped := make(map[string][]metrictype.MetricRollup)
+		for _, r := range rollups {
+			grouped[r.Label] = append(grouped[r.Label], r)
+		}
+		for label, rs := range grouped {
+			agg := metrictype.MetricAggregate{Label: label, Max: rs[0].MaxValue, Min: rs[0].MinValue}
+			var weighted float64
+			p95s := make([]float64, 0, len(rs))
+			for _, r := range rs {
+				weighted += r.AvgValue * float64(r.SampleCount)
+				agg.Count += r.SampleCount
+				if r.MaxValue > agg.Max {
+					agg.Max = r.MaxValue
+				}
+				if r.MinValue < agg.Min {
+					agg.Min = r.MinValue
+				}
+				p95s = append(p95s, r.P95Value)
+			}
+			if agg.Count > 0 {
+				agg.Avg = weighted / float64(agg.Count)
+			}
+			agg.P95 = aggregate.Percentile(p95s, 95)
+			aggregates = append(aggregates, agg)
+		}
+	}
+
+	sort.Slice(aggregates, func(i, j int) bool { return aggregates[i].Label < aggregates[j].Label })
+	return aggregates, resolution, nil
+}
+
+// QuerySamplesSince 查询 (since, until] 区间内多个指标的原始样本，按采集时间排序
+func QuerySamplesSince(db *sqlx.DB, metrics []string, since, until time.Time) ([]metrictype.MetricSample, error) {
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+	query, args, err := sqlx.In(`SELECT agent_id, client_ip, metric, label, value, collect_time FROM host_metrics
+		WHERE metric IN (?) AND collect_time > ? AND collect_time <= ? ORDER BY collect_time`, metrics, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []metrictype.MetricSample
+	if err := db.Select(&samples, db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("查询原始指标失败: %w", err)
+	}
+	return samples, nil
+}
@@ -0,0 +1,190 @@
+package metricoption
+
+import (
+	"Server/models/metrictype"
+	"Server/pkg/aggregate"
+	"Server/settings"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// 各精度对应的存储表和时间桶宽度
+var rollupTables = map[string]struct {
+	Table string
+	Step  time.Duration
+}{
+	metrictype.Resolution1m: {"host_metrics_1m", time.Minute},
+	metrictype.Resolution1h: {"host_metrics_1h", time.Hour},
+}
+
+const (
+	rollupBatchSize  = 500
+	deleteBatchSize  = 10000
+	lateDataWindow   = 5 * time.Minute // 分钟汇总回溯的窗口，容忍客户端延迟上报
+	defaultRawHours  = 48
+	defaultMinuteDay = 14
+	defaultHourDay   = 365
+)
+
+// RollupMetrics 将 [from, to) 区间的原始样本按精度聚合后写入汇总表，重复执行结果一致
+func RollupMetrics(db *sqlx.DB, resolution string, from, to time.Time) (int, error) {
+	target, ok := rollupTables[resolution]
+	if !ok {
+		return 0, fmt.Errorf("不支持的汇总精度: %s", resolution)
+	}
+
+	var samples []metrictype.MetricSample
+	query := `SELECT agent_id, client_ip, metric, label, value, collect_time FROM host_metrics
+		WHERE collect_time >= ? AND collect_time < ? ORDER BY collect_time`
+	if err := db.Select(&samples, query, from, to); err != nil {
+		return 0, fmt.Errorf("查询原始指标失败: %w", err)
+	}
+
+	type bucketKey struct {
+		AgentID, Metric, Label string
+		Bucket                 time.Time
+	}
+	buckets := make(map[bucketKey][]float64)
+	// 样本按时间排序，时间桶记录最近一次上报的 IP
+	clientIPs := make(map[bucketKey]string)
+	for _, s := range samples {
+		key := bucketKey{s.AgentID, s.Metric, s.Label, s.CollectTime.Truncate(target.Step)}
+		buckets[key] = append(buckets[key], s.Value)
+		clientIPs[key] = s.ClientIP
+	}
+
+	rollups := make([]metrictype.MetricRollup, 0, len(buckets))
+	for key, values := range buckets {
+		sum := aggregate.Summarize(values)
+		rollups = append(rollups, metrictype.MetricRollup{
+			AgentID:     key.AgentID,
+			ClientIP:    clientIPs[key],
+			Metric:      key.Metric,
+			Label:       key.Label,
+			BucketTime:  key.Bucket,
+			AvgValue:    sum.Avg,
+			MaxValue:    sum.Max,
+			MinValue:    sum.Min,
+			P95Value:    sum.P95,
+			SampleCount: sum.Count,
+		})
+	}
+
+	for start := 0; start < len(rollups); start += rollupBatchSize {
+		end := start + rollupBatchSize
+		if end > len(rollups) {
+			end = len(rollups)
+		}
+		if err := upsertRollups(db, target.Table, rollups[start:end]); err != nil {
+			return 0, err
+		}
+	}
+	return len(rollups), nil
+}
+
+// upsertRollups 批量写入汇总数据，时间桶已存在时覆盖
+func upsertRollups(db *sqlx.DB, table string, rollups []metrictype.MetricRollup) error {
+	placeholders := make([]string, len(rollups))
+	args := make([]interface{}, 0, len(rollups)*10)
+	for i, r := range rollups {
+		placeholders[i] = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		args = append(args, r.AgentID, r.ClientIP, r.Metric, r.Label, r.BucketTime, r.AvgValue, r.MaxValue, r.MinValue, r.P95Value, r.SampleCount)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (agent_id, client_ip, metric, label, bucket_time, avg_value, max_value, min_value, p95_value, sample_count)
+		VALUES %s
+		ON DUPLICATE KEY UPDATE
+		client_ip = VALUES(client_ip),
+		avg_value = VALUES(avg_value),
+		max_value = VALUES(max_value),
+		min_value = VALUES(min_value),
+		p95_value = VALUES(p95_value),
+		sample_count = VALUES(sample_count)
+	`, table, strings.Join(placeholders, ","))
+
+	if _, err := db.Exec(query, args...); err != nil {
+		zap.L().Error("Failed to upsert metric rollups", zap.String("table", table), zap.Error(err))
+		return fmt.Errorf("写入汇总指标失败: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpired 按保留策略分批删除过期的原始样本和汇总数据
+func PurgeExpired(db *sqlx.DB, cfg *settings.MetricsConfig) error {
+	now := time.Now()
+	rawHours, minuteDays, hourDays := defaultRawHours, defaultMinuteDay, defaultHourDay
+	if cfg != nil {
+		if cfg.RawRetention > 0 {
+			rawHours = cfg.RawRetention
+		}
+		if cfg.MinuteRetention > 0 {
+			minuteDays = cfg.MinuteRetention
+		}
+		if cfg.HourRetention > 0 {
+			hourDays = cfg.HourRetention
+		}
+	}
+
+	targets := []struct {
+		table, column string
+		before        time.Time
+	}{
+		{"host_metrics", "collect_time", now.Add(-time.Duration(rawHours) * time.Hour)},
+		{"host_metrics_1m", "bucket_time", now.AddDate(0, 0, -minuteDays)},
+		{"host_metrics_1h", "bucket_time", now.AddDate(0, 0, -hourDays)},
+	}
+
+	for _, t := range targets {
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s < ? LIMIT %d", t.table, t.column, deleteBatchSize)
+		for {
+			result, err := db.Exec(query, t.before)
+			if err != nil {
+				return fmt.Errorf("清理 %s 过期数据失败: %w", t.table, err)
+			}
+			rows, _ := result.RowsAffected()
+			if rows < deleteBatchSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// StartRollup 每分钟汇总最近的分钟数据，整点汇总上一小时数据并清理过期数据，ctx 取消时返回
+func StartRollup(ctx context.Context, db *sqlx.DB, cfg *settings.MetricsConfig) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	var lastHour time.Time
+	for {
+		now := time.Now()
+		minute := now.Truncate(time.Minute)
+		if _, err := RollupMetrics(db, metrictype.Resolution1m, minute.Add(-lateDataWindow), minute); err != nil {
+			zap.L().Error("分钟指标汇总失败", zap.Error(err))
+		}
+
+		hour := now.Truncate(time.Hour)
+		if !hour.Equal(lastHour) {
+			if _, err := RollupMetrics(db, metrictype.Resolution1h, hour.Add(-time.Hour), hour); err != nil {
+				zap.L().Error("小时指标汇总失败", zap.Error(err))
+			} else {
+				lastHour = hour
+			}
+			if err := PurgeExpired(db, cfg); err != nil {
+				zap.L().Error("清理过期指标失败", zap.Error(err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -35,15 +35,15 @@ func CheckUserByUsername(username string) (err error) {
 
 //func InsertUser(user *models.User) (err error) {
 //	//对密码进行加密
-//	user.Password = encryptPassword(user.Password)
+//	user.Password = EncryptPassword(user.Password)
 //	//执行SQL语句入库
 //	sqlStr := `insert into user(user_id,username,password) values (?,?,?)`
 //	_, err = db.Exec(sqlStr, user.UserId, user.Username, user.Password)
 //	return err
 //}
 
-// 加密明文
-func encryptPassword(oPassword string) string {
+// EncryptPassword 旧版密码编码，新密码改用 bcrypt，仅用于校验旧数据
+func EncryptPassword(oPassword string) string {
 	h := md5.New()
 	h.Write([]byte(secret))
 	h.Sum([]byte(oPassword))
@@ -62,7 +62,7 @@ func encryptPassword(oPassword string) string {
 //			return err
 //		}
 //		//判断密码是否正确
-//		password := encryptPassword(oPassword)
+//		password := EncryptPassword(oPassword)
 //		if password != user.Password {
 //			return ErrorUserPassword
 //		}
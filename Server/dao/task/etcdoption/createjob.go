@@ -2,53 +2,86 @@ package etcdoption
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"log"
+	"sort"
+	"strings"
 )
 
-// 批量新增，不覆盖现有键
+// ErrKeyExists 创建的键已存在
+var ErrKeyExists = errors.New("键已存在")
 
-func BatchCreateIfNotExist(kv clientv3.KV, keysValues map[string]string) error {
-	// 创建一个 etcd 事务，确保操作的原子性
-	txn := kv.Txn(context.Background())
-
-	// 构建 etcd 操作的条件和操作
-	ops := make([]clientv3.Op, 0)
-
-	// 循环处理每一个键值对
-	for key, value := range keysValues {
-		// 检查键是否已经存在
-		getResp, err := kv.Get(context.Background(), key)
-		if err != nil {
-			return fmt.Errorf("获取键 %s 失败: %v", key, err)
-		}
-
-		// 如果键不存在，则将其加入事务操作中
-		if getResp.Count == 0 {
-			ops = append(ops, clientv3.OpPut(key, value))
-		}
+// CreateAll 在一个事务中创建全部键，任一键已存在时不写入并返回 ErrKeyExists 及已存在的键
+func CreateAll(kv clientv3.KV, keysValues map[string]string) error {
+	keys := make([]string, 0, len(keysValues))
+	for key := range keysValues {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	// 如果没有要创建的键，则直接返回
-	if len(ops) == 0 {
-		log.Println("没有需要创建的键")
-		return nil
+	cmps := make([]clientv3.Cmp, 0, len(keys))
+	ops := make([]clientv3.Op, 0, len(keys))
+	gets := make([]clientv3.Op, 0, len(keys))
+	for _, key := range keys {
+		cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(key), "=", 0))
+		ops = append(ops, clientv3.OpPut(key, keysValues[key]))
+		gets = append(gets, clientv3.OpGet(key, clientv3.WithCountOnly()))
 	}
-
-	// 在事务中添加操作
-	txn = txn.Then(ops...)
-
-	// 提交事务
-	txnResp, err := txn.Commit()
+	resp, err := kv.Txn(context.Background()).If(cmps...).Then(ops...).Else(gets...).Commit()
 	if err != nil {
-		return fmt.Errorf("批量创建任务失败: %v", err)
+		return fmt.Errorf("创建键失败: %v", err)
+	}
+	if resp.Succeeded {
+		return nil
 	}
+	var existing []string
+	for i, r := range resp.Responses {
+		if r.GetResponseRange().Count > 0 {
+			existing = append(existing, keys[i])
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrKeyExists, strings.Join(existing, ", "))
+}
 
-	if !txnResp.Succeeded {
-		return fmt.Errorf("批量创建任务时失败")
+// BatchCreateIfNotExist 在一个事务中创建不存在的键，已存在的键保持不变。
+// 读取后键被其他服务端创建或修改时重试，仍冲突时返回 ConflictError
+func BatchCreateIfNotExist(kv clientv3.KV, keysValues map[string]string) error {
+	keys := make([]string, 0, len(keysValues))
+	for key := range keysValues {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	log.Println("批量任务创建成功")
-	return nil
+	var conflicted []string
+	for attempt := 0; attempt < MaxTxnAttempts; attempt++ {
+		revs, err := readRevisions(kv, keys)
+		if err != nil {
+			return err
+		}
+		var missing []string
+		ops := make([]clientv3.Op, 0, len(keys))
+		for _, key := range keys {
+			if revs[key] == 0 {
+				missing = append(missing, key)
+				ops = append(ops, clientv3.OpPut(key, keysValues[key]))
+			}
+		}
+		// 如果没有要创建的键，则直接返回
+		if len(ops) == 0 {
+			log.Println("没有需要创建的键")
+			return nil
+		}
+		resp, err := kv.Txn(context.Background()).If(revisionGuards(revs, missing)...).Then(ops...).Commit()
+		if err != nil {
+			return fmt.Errorf("批量创建任务失败: %v", err)
+		}
+		if resp.Succeeded {
+			log.Println("批量任务创建成功")
+			return nil
+		}
+		conflicted = changedKeys(kv, revs, missing)
+	}
+	return &ConflictError{Op: "批量创建", Keys: conflicted}
 }
@@ -2,25 +2,42 @@ package etcdoption
 
 import (
 	"context"
+	"fmt"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"sort"
 )
 
-// 批量删除，只删除存在的键
-
+// BatchDeleteIfExist 在一个事务中删除存在的键，读取后键被其他服务端修改时重试，
+// 仍冲突时返回 ConflictError
 func BatchDeleteIfExist(kv clientv3.KV, keys []string) error {
-	for _, key := range keys {
-		// 检查键是否存在
-		resp, err := kv.Get(context.Background(), key)
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+
+	var conflicted []string
+	for attempt := 0; attempt < MaxTxnAttempts; attempt++ {
+		revs, err := readRevisions(kv, keys)
 		if err != nil {
 			return err
 		}
-		// 如果键存在，则删除
-		if resp.Count > 0 {
-			_, err := kv.Delete(context.Background(), key)
-			if err != nil {
-				return err
+		var existing []string
+		ops := make([]clientv3.Op, 0, len(keys))
+		for _, key := range keys {
+			if revs[key] != 0 {
+				existing = append(existing, key)
+				ops = append(ops, clientv3.OpDelete(key))
 			}
 		}
+		if len(ops) == 0 {
+			return nil
+		}
+		resp, err := kv.Txn(context.Background()).If(revisionGuards(revs, existing)...).Then(ops...).Commit()
+		if err != nil {
+			return fmt.Errorf("批量删除任务失败: %v", err)
+		}
+		if resp.Succeeded {
+			return nil
+		}
+		conflicted = changedKeys(kv, revs, existing)
 	}
-	return nil
+	return &ConflictError{Op: "批量删除", Keys: conflicted}
 }
@@ -0,0 +1,75 @@
+package etcdoption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"strings"
+)
+
+// MaxTxnAttempts 事务因并发修改失败时的最大尝试次数
+const MaxTxnAttempts = 3
+
+// ErrConflict 事务因键被并发修改而失败
+var ErrConflict = errors.New("etcd 事务冲突")
+
+// ConflictError 重试后仍冲突的事务，Keys 为被并发修改的键
+type ConflictError struct {
+	Op   string
+	Keys []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s失败，以下键被并发修改: %s", e.Op, strings.Join(e.Keys, ", "))
+}
+
+func (e *ConflictError) Unwrap() error { return ErrConflict }
+
+// readRevisions 在同一版本下读取多个键，返回每个键的 ModRevision，不存在的键为 0
+func readRevisions(kv clientv3.KV, keys []string) (map[string]int64, error) {
+	gets := make([]clientv3.Op, 0, len(keys))
+	for _, key := range keys {
+		gets = append(gets, clientv3.OpGet(key))
+	}
+	resp, err := kv.Txn(context.Background()).Then(gets...).Commit()
+	if err != nil {
+		return nil, fmt.Errorf("读取键失败: %v", err)
+	}
+	revs := make(map[string]int64, len(keys))
+	for i, r := range resp.Responses {
+		revs[keys[i]] = 0
+		if kvs := r.GetResponseRange().Kvs; len(kvs) > 0 {
+			revs[keys[i]] = kvs[0].ModRevision
+		}
+	}
+	return revs, nil
+}
+
+// revisionGuards 构造要求键的 ModRevision 与读取时一致的比较条件，不存在的键要求仍不存在
+func revisionGuards(revs map[string]int64, keys []string) []clientv3.Cmp {
+	cmps := make([]clientv3.Cmp, 0, len(keys))
+	for _, key := range keys {
+		if revs[key] == 0 {
+			cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(key), "=", 0))
+		} else {
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", revs[key]))
+		}
+	}
+	return cmps
+}
+
+// changedKeys 重新读取键，返回与 revs 中记录的版本不一致的键
+func changedKeys(kv clientv3.KV, revs map[string]int64, keys []string) []string {
+	now, err := readRevisions(kv, keys)
+	if err != nil {
+		return keys
+	}
+	var changed []string
+	for _, key := range keys {
+		if now[key] != revs[key] {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,207 @@
+package etcdoption
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// memKV 内存中的 KV，只实现本包用到的事务。beforeGuarded 在每个带条件的事务提交前调用，
+// 用来模拟其他服务端在读取和提交之间修改键
+type memKV struct {
+	clientv3.KV
+	rev           int64
+	data          map[string]*mvccpb.KeyValue
+	guarded       int
+	beforeGuarded func(kv *memKV, n int)
+}
+
+func newMemKV() *memKV {
+	return &memKV{data: make(map[string]*mvccpb.KeyValue)}
+}
+
+func (m *memKV) put(key, value string) {
+	m.rev++
+	if kv, ok := m.data[key]; ok {
+		kv.Value, kv.ModRevision = []byte(value), m.rev
+		return
+	}
+	m.data[key] = &mvccpb.KeyValue{Key: []byte(key), Value: []byte(value), CreateRevision: m.rev, ModRevision: m.rev}
+}
+
+func (m *memKV) value(key string) (string, bool) {
+	kv, ok := m.data[key]
+	if !ok {
+		return "", false
+	}
+	return string(kv.Value), true
+}
+
+func (m *memKV) Txn(ctx context.Context) clientv3.Txn { return &memTxn{kv: m} }
+
+type memTxn struct {
+	kv        *memKV
+	cmps      []clientv3.Cmp
+	then, els []clientv3.Op
+}
+
+func (t *memTxn) If(cs ...clientv3.Cmp) clientv3.Txn   { t.cmps = cs; return t }
+func (t *memTxn) Then(ops ...clientv3.Op) clientv3.Txn { t.then = ops; return t }
+func (t *memTxn) Else(ops ...clientv3.Op) clientv3.Txn { t.els = ops; return t }
+
+func (t *memTxn) Commit() (*clientv3.TxnResponse, error) {
+	m := t.kv
+	if len(t.cmps) > 0 {
+		m.guarded++
+		if m.beforeGuarded != nil {
+			m.beforeGuarded(m, m.guarded)
+		}
+	}
+	ok := true
+	for _, c := range t.cmps {
+		ok = ok && m.compare(c)
+	}
+	ops := t.then
+	if !ok {
+		ops = t.els
+	}
+	resp := &clientv3.TxnResponse{Succeeded: ok}
+	for _, op := range ops {
+		key := string(op.KeyBytes())
+		switch {
+		case op.IsPut():
+			m.put(key, string(op.ValueBytes()))
+			resp.Responses = append(resp.Responses, &pb.ResponseOp{Response: &pb.ResponseOp_ResponsePut{ResponsePut: &pb.PutResponse{}}})
+		case op.IsDelete():
+			delete(m.data, key)
+			resp.Responses = append(resp.Responses, &pb.ResponseOp{Response: &pb.ResponseOp_ResponseDeleteRange{ResponseDeleteRange: &pb.DeleteRangeResponse{}}})
+		case op.IsGet():
+			rr := &pb.RangeResponse{}
+			if kv, ok := m.data[key]; ok {
+				rr.Count = 1
+				if !op.IsCountOnly() {
+					rr.Kvs = []*mvccpb.KeyValue{kv}
+				}
+			}
+			resp.Responses = append(resp.Responses, &pb.ResponseOp{Response: &pb.ResponseOp_ResponseRange{ResponseRange: rr}})
+		}
+	}
+	return resp, nil
+}
+
+// compare 只支持本包使用的 CreateRevision 和 ModRevision 相等比较
+func (m *memKV) compare(c clientv3.Cmp) bool {
+	pc := (*pb.Compare)(&c)
+	kv := m.data[string(pc.Key)]
+	var create, mod int64
+	if kv != nil {
+		create, mod = kv.CreateRevision, kv.ModRevision
+	}
+	switch u := pc.TargetUnion.(type) {
+	case *pb.Compare_CreateRevision:
+		return create == u.CreateRevision
+	case *pb.Compare_ModRevision:
+		return mod == u.ModRevision
+	}
+	panic("unsupported compare")
+}
+
+func TestCreateAllReportsExistingKey(t *testing.T) {
+	kv := newMemKV()
+	kv.put("/b", "other")
+
+	err := CreateAll(kv, map[string]string{"/a": "1", "/b": "2", "/c": "3"})
+	if !errors.Is(err, ErrKeyExists) || !strings.Contains(err.Error(), "/b") {
+		t.Fatalf("error = %v, want ErrKeyExists for /b", err)
+	}
+	// 任一键已存在时不写入任何键
+	if _, ok := kv.value("/a"); ok {
+		t.Error("/a was written although /b existed")
+	}
+	if v, _ := kv.value("/b"); v != "other" {
+		t.Errorf("/b = %q, want it untouched", v)
+	}
+}
+
+func TestCreateAllConcurrentCreate(t *testing.T) {
+	kv := newMemKV()
+	// 另一个服务端在提交前创建了同一个键
+	kv.beforeGuarded = func(kv *memKV, n int) { kv.put("/a", "other") }
+
+	if err := CreateAll(kv, map[string]string{"/a": "1"}); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("error = %v, want ErrKeyExists", err)
+	}
+	if v, _ := kv.value("/a"); v != "other" {
+		t.Errorf("/a = %q, want the concurrent value kept", v)
+	}
+}
+
+func TestBatchCreateIfNotExistRetriesOnce(t *testing.T) {
+	kv := newMemKV()
+	kv.beforeGuarded = func(kv *memKV, n int) {
+		if n == 1 {
+			kv.put("/a", "other")
+		}
+	}
+	if err := BatchCreateIfNotExist(kv, map[string]string{"/a": "1", "/b": "2"}); err != nil {
+		t.Fatalf("error = %v, want nil after retry", err)
+	}
+	if v, _ := kv.value("/a"); v != "other" {
+		t.Errorf("/a = %q, want the concurrent value kept", v)
+	}
+	if v, _ := kv.value("/b"); v != "2" {
+		t.Errorf("/b = %q, want 2", v)
+	}
+}
+
+func TestBatchCreateIfNotExistReportsConflict(t *testing.T) {
+	kv := newMemKV()
+	keys := []string{"/a", "/b", "/c", "/d"}
+	// 每次提交前都有另一个服务端创建其中一个键
+	kv.beforeGuarded = func(kv *memKV, n int) { kv.put(keys[n-1], "other") }
+
+	err := BatchCreateIfNotExist(kv, map[string]string{"/a": "1", "/b": "2", "/c": "3", "/d": "4"})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) {
+		t.Fatalf("error = %v, want ConflictError", err)
+	}
+	if !reflect.DeepEqual(conflict.Keys, []string{"/c"}) {
+		t.Errorf("conflict keys = %v, want [/c]", conflict.Keys)
+	}
+	if _, ok := kv.value("/d"); ok {
+		t.Error("/d was written although the transaction conflicted")
+	}
+}
+
+func TestBatchDeleteIfExistReportsConflict(t *testing.T) {
+	kv := newMemKV()
+	kv.put("/a", "1")
+	kv.put("/b", "2")
+	kv.beforeGuarded = func(kv *memKV, n int) { kv.put("/b", "changed") }
+
+	err := BatchDeleteIfExist(kv, []string{"/a", "/b", "/missing"})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !reflect.DeepEqual(conflict.Keys, []string{"/b"}) {
+		t.Fatalf("error = %v, want ConflictError for /b", err)
+	}
+	if _, ok := kv.value("/a"); !ok {
+		t.Error("/a was deleted although the transaction conflicted")
+	}
+}
+
+func TestBatchDeleteIfExist(t *testing.T) {
+	kv := newMemKV()
+	kv.put("/a", "1")
+	if err := BatchDeleteIfExist(kv, []string{"/a", "/missing"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kv.value("/a"); ok {
+		t.Error("/a was not deleted")
+	}
+}
@@ -0,0 +1,141 @@
+package mysqloption
+
+import (
+	"Server/models/tasktype"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxCommandError 与 agent_commands.error 列长度一致
+const maxCommandError = 1024
+
+// EnqueueCommand 为指令分配客户端内递增的序号并保存，返回带 seq 字段的消息。
+// 序号在同一事务中分配，事务回滚时不会留下空缺，客户端据此判断是否漏收
+func EnqueueCommand(db *sqlx.DB, agentID string, msg map[string]interface{}) (int64, []byte, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	// 行锁保证同一客户端的指令按分配顺序提交
+	if _, err := tx.Exec(`
+		INSERT INTO agent_command_seqs (agent_id, seq) VALUES (?, 1)
+		ON DUPLICATE KEY UPDATE seq = seq + 1
+	`, agentID); err != nil {
+		return 0, nil, fmt.Errorf("分配指令序号失败: %w", err)
+	}
+	var seq int64
+	if err := tx.Get(&seq, "SELECT seq FROM agent_command_seqs WHERE agent_id = ?", agentID); err != nil {
+		return 0, nil, fmt.Errorf("分配指令序号失败: %w", err)
+	}
+
+	msg["seq"] = seq
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0, nil, fmt.Errorf("序列化指令失败: %w", err)
+	}
+	taskID, _ := msg["task_id"].(string)
+	action, _ := msg["action"].(string)
+	if _, err := tx.Exec(`
+		INSERT INTO agent_commands (agent_id, seq, task_id, action, payload, status)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, agentID, seq, taskID, action, string(data), tasktype.CommandPending); err != nil {
+		return 0, nil, fmt.Errorf("保存指令失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, fmt.Errorf("保存指令失败: %w", err)
+	}
+	return seq, data, nil
+}
+
+// ListPendingCommands 按序号列出客户端未确认的指令，olderThan 大于 0 时只列出下发超过该时间的指令
+func ListPendingCommands(db *sqlx.DB, agentID string, olderThan time.Duration) ([]tasktype.AgentCommand, error) {
+	query := `
+		SELECT agent_id, seq, task_id, action, payload, status, error, created_at, acked_at
+		FROM agent_commands WHERE agent_id = ? AND status = ?`
+	args := []interface{}{agentID, tasktype.CommandPending}
+	if olderThan > 0 {
+		// 按数据库时间比较，避免与应用服务器的时区或时钟不一致
+		query += " AND created_at < NOW() - INTERVAL ? SECOND"
+		args = append(args, int64(olderThan/time.Second))
+	}
+	query += " ORDER BY seq"
+
+	commands := make([]tasktype.AgentCommand, 0)
+	if err := db.Select(&commands, query, args...); err != nil {
+		return nil, fmt.Errorf("查询未确认指令失败: %w", err)
+	}
+	return commands, nil
+}
+
+// AckCommand 记录客户端对指令的确认，重复确认时保持第一次的结果
+func AckCommand(db *sqlx.DB, agentID string, ack *tasktype.CommandAck) error {
+	status := tasktype.CommandApplied
+	if !ack.OK {
+		status = tasktype.CommandFailed
+	}
+	errMsg := ack.Error
+	if len(errMsg) > maxCommandError {
+		errMsg = strings.ToValidUTF8(errMsg[:maxCommandError], "")
+	}
+	if _, err := db.Exec(`
+		UPDATE agent_commands SET status = ?, error = ?, acked_at = NOW()
+		WHERE agent_id = ? AND seq = ? AND status = ?
+	`, status, errMsg, agentID, ack.Seq, tasktype.CommandPending); err != nil {
+		return fmt.Errorf("记录指令确认失败: %w", err)
+	}
+	return nil
+}
+
+// AppliedSeq 返回客户端已确认的最大指令序号，客户端按序应用，之前的指令均已确认
+func AppliedSeq(db *sqlx.DB, agentID string) (int64, error) {
+	var seq int64
+	if err := db.Get(&seq, `
+		SELECT COALESCE(MAX(seq), 0) FROM agent_commands WHERE agent_id = ? AND status <> ?
+	`, agentID, tasktype.CommandPending); err != nil {
+		return 0, fmt.Errorf("查询已确认的指令序号失败: %w", err)
+	}
+	return seq, nil
+}
+
+// GetAgentSync 对比客户端每个任务最近下发的指令与最近确认的指令
+func GetAgentSync(db *sqlx.DB, agentID string) (*tasktype.AgentSync, error) {
+	result := &tasktype.AgentSync{AgentID: agentID}
+	if err := db.Get(&result.DesiredSeq, "SELECT COALESCE(MAX(seq), 0) FROM agent_commands WHERE agent_id = ?", agentID); err != nil {
+		return nil, fmt.Errorf("查询最近下发的指令失败: %w", err)
+	}
+	applied, err := AppliedSeq(db, agentID)
+	if err != nil {
+		return nil, err
+	}
+	result.AppliedSeq = applied
+	if result.Pending, err = ListPendingCommands(db, agentID, 0); err != nil {
+		return nil, err
+	}
+
+	result.Tasks = make([]tasktype.TaskSync, 0)
+	if err := db.Select(&result.Tasks, `
+		SELECT d.task_id, d.seq AS desired_seq, d.action AS desired_action,
+			COALESCE(a.seq, 0) AS applied_seq, COALESCE(a.action, '') AS applied_action, COALESCE(a.status, '') AS applied_status
+		FROM agent_commands d
+		JOIN (SELECT task_id, MAX(seq) AS seq FROM agent_commands WHERE agent_id = ? GROUP BY task_id) dm
+			ON d.task_id = dm.task_id AND d.seq = dm.seq
+		LEFT JOIN (SELECT task_id, MAX(seq) AS seq FROM agent_commands WHERE agent_id = ? AND status <> ? GROUP BY task_id) am
+			ON d.task_id = am.task_id
+		LEFT JOIN agent_commands a ON a.agent_id = d.agent_id AND a.seq = am.seq
+		WHERE d.agent_id = ? AND d.task_id <> ''
+		ORDER BY d.task_id
+	`, agentID, agentID, tasktype.CommandPending, agentID); err != nil {
+		return nil, fmt.Errorf("查询任务指令状态失败: %w", err)
+	}
+	for i := range result.Tasks {
+		t := &result.Tasks[i]
+		t.InSync = t.AppliedSeq == t.DesiredSeq && t.AppliedStatus == tasktype.CommandApplied
+	}
+	return result, nil
+}
@@ -1,79 +1,154 @@
 package mysqloption
 
 import (
-	"Server/common"
 	"Server/dao/task/etcdoption"
 	"Server/models/tasktype"
-	"Server/pkg/crondoption"
+	"Server/pkg/snowflake"
+	"errors"
 	"fmt"
-	"github.com/gorilla/websocket"
 	"github.com/jmoiron/sqlx"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
 	"strconv"
 )
 
-func TaskOptionCore(p *tasktype.TaskRequestOption, db *sqlx.DB, cli *clientv3.Client, clients map[*websocket.Conn]*common.WebSocketClient) (interface{}, error) {
-	taskid, _ := crondoption.GenerateFourDigitCodeFromIP(p.Record.ClientIP)
-	// 构建要插入 etcd 的键值对
+// createTask 生成任务 ID，在同一事务中写入任务记录和 etcd 中的任务键，任一侧 ID 冲突时创建失败
+func createTask(p *tasktype.TaskRequestOption, db *sqlx.DB, cli *clientv3.Client) (string, int64, error) {
+	taskid := strconv.FormatInt(snowflake.GenID(), 10)
+	// 构建要插入 etcd 的键值对，任务按客户端 ID 归属
 	keysValues := map[string]string{
-		fmt.Sprintf("/tasks/%s/%s", p.Record.ClientIP, taskid): p.Record.Status,                 // 可以设置为任务状态
-		fmt.Sprintf("/tasksfile/%s", taskid):                   strconv.FormatInt(p.FileId, 10), // 可以设置为任务状态
+		fmt.Sprintf("/tasks/%s/%s", p.Record.AgentID, taskid): p.Record.Status,
+		fmt.Sprintf("/tasksfile/%s", taskid):                  strconv.FormatInt(p.FileId, 10),
 	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", 0, err
+	}
+	defer tx.Rollback()
+	record, err := InsertTaskRecord(tx, p.Record.AgentID, p.Record.ClientIP, p.Record.ScriptPath, p.Record.Remarks, taskid, p.Record.CrondExpression, p.FileId, p.Record.Timeout, p.Record.ConcurrencyPolicy,
+		p.Record.MaxRetries, p.Record.RetryBackoff, p.Record.RetryOn)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := etcdoption.CreateAll(cli.KV, keysValues); err != nil {
+		if errors.Is(err, etcdoption.ErrKeyExists) {
+			return "", 0, fmt.Errorf("%w: %v", ErrTaskExists, err)
+		}
+		return "", 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		// 任务记录未写入，撤销已创建的 etcd 键
+		keys := make([]string, 0, len(keysValues))
+		for key := range keysValues {
+			keys = append(keys, key)
+		}
+		if delErr := etcdoption.BatchDeleteIfExist(cli.KV, keys); delErr != nil {
+			return "", 0, fmt.Errorf("写入任务记录失败: %v，撤销 etcd 任务键失败: %v", err, delErr)
+		}
+		return "", 0, fmt.Errorf("写入任务记录失败: %w", err)
+	}
+	return taskid, record, nil
+}
+
+// ErrInvalidAction 停止接口只接受 stop 和 kill
+var ErrInvalidAction = errors.New("无效的任务控制操作")
+
+// DeliverFunc 保存指令并发送给指定客户端，返回指令序号和是否已送达，未送达的指令等待重发
+type DeliverFunc func(agentID string, msg map[string]interface{}) (int64, bool, error)
+
+// TaskOptionCore 处理任务的创建、停止、更新和删除，并把对应指令只发送给任务所属的客户端
+func TaskOptionCore(p *tasktype.TaskRequestOption, db *sqlx.DB, cli *clientv3.Client, deliver DeliverFunc) (interface{}, error) {
 	// 基于 Option 的值使用 switch 语句处理不同的逻辑
 	switch p.Option {
 	case "create":
+		if p.Record.AgentID == "" {
+			return nil, fmt.Errorf("agent_id 不能为空")
+		}
+		if _, err := tasktype.ParseExitCodes(p.Record.RetryOn); err != nil {
+			return nil, err
+		}
 		// 处理创建任务的逻辑
-		etcdoption.BatchCreateIfNotExist(cli.KV, keysValues)
-		record, err := InsertTaskRecord(db, p.Record.ClientIP, p.Record.ScriptPath, p.Record.Remarks, taskid, p.Record.CrondExpression, p.FileId)
+		taskid, record, err := createTask(p, db, cli)
 		if err != nil {
 			return nil, err
 		}
-		// 构建广播的任务消息，包含客户端 IP
+		// 构建任务消息，包含目标客户端 ID
 		taskMessage := map[string]interface{}{
-			"action":    "add",
-			"task_id":   taskid,
-			"status":    p.Record.Status,
-			"message":   "任务创建",
-			"file_id":   p.FileId,
-			"client_ip": p.Record.ClientIP, // 添加客户端IP
-		}
-		// 调用广播函数
-		common.BroadcastTaskMessage(clients, taskMessage)
-		return record, nil
+			"action":             "add",
+			"task_id":            taskid,
+			"status":             p.Record.Status,
+			"message":            "任务创建",
+			"file_id":            p.FileId,
+			"timeout":            p.Record.Timeout,
+			"concurrency_policy": p.Record.ConcurrencyPolicy,
+			"max_retries":        p.Record.MaxRetries,
+			"retry_backoff":      p.Record.RetryBackoff,
+			"retry_on":           p.Record.RetryOn,
+			"agent_id":           p.Record.AgentID, // 目标客户端 ID
+		}
+		d := deliverCommand(deliver, p.Record.AgentID, taskMessage)
+		d.Record = record
+		return d, nil
 	case "stop":
-		// 处理更新任务的逻辑
+		// 停止调度后任务进入 cancelled，客户端重新启用时再上报 scheduled；
+		// kill 只终止执行中的实例，由客户端上报该次执行为 cancelled
+		if p.TaskControl.AgentID == "" {
+			return nil, fmt.Errorf("agent_id 不能为空")
+		}
+		// 只转发 stop 和 kill，避免借停止接口向客户端下发其他指令
+		if p.TaskControl.Action != "stop" && p.TaskControl.Action != "kill" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAction, p.TaskControl.Action)
+		}
+		// 任务必须属于指定的客户端，否则可借自己有权限的客户端操作其他任务
+		if p.TaskControl.Action == "stop" {
+			if _, err := TransitionTask(db, p.TaskControl.AgentID, p.TaskControl.TaskID, tasktype.TaskCancelled); err != nil {
+				return nil, err
+			}
+		} else if err := CheckTaskAgent(db, p.TaskControl.AgentID, p.TaskControl.TaskID); err != nil {
+			return nil, err
+		}
 		taskMessage := map[string]interface{}{
-			"action":    p.TaskControl.Action,
-			"task_id":   p.TaskControl.TaskID,
-			"message":   "任务更新",
-			"client_ip": p.TaskControl.ClientIP, // 添加客户端IP
+			"action":   p.TaskControl.Action,
+			"task_id":  p.TaskControl.TaskID,
+			"message":  "任务更新",
+			"agent_id": p.TaskControl.AgentID, // 目标客户端 ID
 		}
-		// 调用广播函数
-		common.BroadcastTaskMessage(clients, taskMessage)
-		return nil, nil
+		return deliverCommand(deliver, p.TaskControl.AgentID, taskMessage), nil
 	case "update":
 		// 处理更新任务的逻辑
+		if p.Record.AgentID == "" {
+			return nil, fmt.Errorf("agent_id 不能为空")
+		}
+		if err := CheckTaskAgent(db, p.Record.AgentID, p.Record.TaskID); err != nil {
+			return nil, err
+		}
 		taskMessage := map[string]interface{}{
-			"action":    "update",
-			"task_id":   taskid,
-			"status":    p.Record.Status,
-			"message":   "任务更新",
-			"client_ip": p.Record.ClientIP, // 添加客户端IP
-		}
-		// 调用广播函数
-		common.BroadcastTaskMessage(clients, taskMessage)
-		return nil, nil
+			"action":   "update",
+			"task_id":  p.Record.TaskID,
+			"status":   p.Record.Status,
+			"message":  "任务更新",
+			"agent_id": p.Record.AgentID, // 目标客户端 ID
+		}
+		return deliverCommand(deliver, p.Record.AgentID, taskMessage), nil
 	case "delete":
-		// 处理删除任务的逻辑
+		// 处理删除任务的逻辑，删除的任务不再执行
+		if p.Record.TaskID == "" {
+			return nil, fmt.Errorf("task_id 不能为空")
+		}
+		if p.Record.AgentID == "" {
+			return nil, fmt.Errorf("agent_id 不能为空")
+		}
+		if _, err := TransitionTask(db, p.Record.AgentID, p.Record.TaskID, tasktype.TaskCancelled); err != nil {
+			return nil, err
+		}
 		taskMessage := map[string]interface{}{
-			"action":    "delete",
-			"task_id":   taskid,
-			"message":   "任务删除",
-			"client_ip": p.Record.ClientIP, // 添加客户端IP
+			"action":   "delete",
+			"task_id":  p.Record.TaskID,
+			"message":  "任务删除",
+			"agent_id": p.Record.AgentID, // 目标客户端 ID
 		}
-		// 调用广播函数
-		common.BroadcastTaskMessage(clients, taskMessage)
-		return nil, nil
+		return deliverCommand(deliver, p.Record.AgentID, taskMessage), nil
 
 	case "query":
 		// 处理查询任务的逻辑
@@ -85,3 +160,18 @@ func TaskOptionCore(p *tasktype.TaskRequestOption, db *sqlx.DB, cli *clientv3.Cl
 		return nil, fmt.Errorf("未知的任务操作: %s", p.Option)
 	}
 }
+
+// deliverCommand 发送指令并记录投递结果。任务记录此时已经写入，指令保存失败不回滚，只在结果中说明
+func deliverCommand(deliver DeliverFunc, agentID string, msg map[string]interface{}) *tasktype.CommandDelivery {
+	d := &tasktype.CommandDelivery{AgentID: agentID}
+	d.TaskID, _ = msg["task_id"].(string)
+	d.Action, _ = msg["action"].(string)
+	seq, delivered, err := deliver(agentID, msg)
+	if err != nil {
+		zap.L().Error("发送任务指令失败", zap.String("agent_id", agentID), zap.String("task_id", d.TaskID), zap.Error(err))
+		d.Error = err.Error()
+		return d
+	}
+	d.Seq, d.Delivered, d.Queued = seq, delivered, !delivered
+	return d
+}
@@ -0,0 +1,24 @@
+package mysqloption
+
+import (
+	"Server/models/tasktype"
+	"errors"
+	"testing"
+)
+
+// 停止接口拒绝 stop 和 kill 以外的操作，且不会访问数据库或下发指令
+func TestTaskOptionCoreRejectsInvalidStopAction(t *testing.T) {
+	deliver := func(agentID string, msg map[string]interface{}) (int64, bool, error) {
+		t.Fatalf("command delivered for invalid action: %v", msg)
+		return 0, false, nil
+	}
+	for _, action := range []string{"", "delete", "add", "update", "STOP"} {
+		p := &tasktype.TaskRequestOption{
+			Option:      "stop",
+			TaskControl: tasktype.TaskRequest{AgentID: "agent-1", TaskID: "1", Action: action},
+		}
+		if _, err := TaskOptionCore(p, nil, nil, deliver); !errors.Is(err, ErrInvalidAction) {
+			t.Errorf("action %q: error = %v, want ErrInvalidAction", action, err)
+		}
+	}
+}
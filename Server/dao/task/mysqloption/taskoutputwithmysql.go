@@ -0,0 +1,285 @@
+package mysqloption
+
+import (
+	"Server/models/tasktype"
+	"Server/pkg/outputstore"
+	"Server/settings"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ErrOutputNotFound 执行没有上传输出
+var ErrOutputNotFound = errors.New("执行输出不存在")
+
+const (
+	// 单行输出的最大字节数，超出部分截断
+	maxOutputLineLen = 4096
+	// 一次上报的最大行数
+	maxOutputBatch = 500
+)
+
+// GetRun 查询任务的一次执行
+func GetRun(db *sqlx.DB, taskID string, runID int64) (*tasktype.TaskRun, error) {
+	var run tasktype.TaskRun
+	err := db.Get(&run, `
+		SELECT run_id, task_id, agent_id, status, start_time, end_time, exit_code, term_signal,
+		stdout_bytes, stderr_bytes, timed_out, duration_ms, attempts, error, output_id
+		FROM task_runs WHERE run_id = ? AND task_id = ?
+	`, runID, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRunNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	return &run, nil
+}
+
+// CheckRunAgent 校验执行属于该客户端
+func CheckRunAgent(db *sqlx.DB, taskID string, runID int64, agentID string) error {
+	var count int64
+	err := db.Get(&count, `SELECT COUNT(*) FROM task_runs WHERE run_id = ? AND task_id = ? AND agent_id = ?`, runID, taskID, agentID)
+	if err != nil {
+		return fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	if count == 0 {
+		return ErrRunNotFound
+	}
+	return nil
+}
+
+// AppendOutput 保存客户端上报的一批输出。按 run_id+seq 去重，客户端重发的行不会重复保存
+func AppendOutput(db *sqlx.DB, agentID string, p *tasktype.TaskOutput) error {
+	if len(p.Lines) == 0 {
+		return nil
+	}
+	if len(p.Lines) > maxOutputBatch {
+		return fmt.Errorf("一次上报的输出超过 %d 行", maxOutputBatch)
+	}
+	if err := CheckRunAgent(db, p.TaskID, p.RunID, agentID); err != nil {
+		return err
+	}
+
+	placeholders := make([]string, 0, len(p.Lines))
+	args := make([]interface{}, 0, len(p.Lines)*6)
+	for i := range p.Lines {
+		l := &p.Lines[i]
+		l.RunID = p.RunID
+		if l.Stream != tasktype.StreamStderr {
+			l.Stream = tasktype.StreamStdout
+		}
+		if len(l.Line) > maxOutputLineLen {
+			l.Line = strings.ToValidUTF8(l.Line[:maxOutputLineLen], "")
+		}
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?)")
+		args = append(args, l.RunID, l.Seq, l.Attempt, l.Stream, l.Line, l.Time)
+	}
+	query := `INSERT IGNORE INTO task_output_lines (run_id, seq, attempt, stream, line, output_time) VALUES ` +
+		strings.Join(placeholders, ", ")
+	if _, err := db.Exec(query, args...); err != nil {
+		return fmt.Errorf("保存任务输出失败: %w", err)
+	}
+	return nil
+}
+
+// ListOutput 按序号查询执行中 afterSeq 之后的输出
+func ListOutput(db *sqlx.DB, runID, afterSeq int64, limit int) ([]tasktype.OutputLine, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+	lines := []tasktype.OutputLine{}
+	err := db.Select(&lines, `
+		SELECT run_id, seq, attempt, stream, line, output_time FROM task_output_lines
+		WHERE run_id = ? AND seq > ? ORDER BY seq LIMIT ?
+	`, runID, afterSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询任务输出失败: %w", err)
+	}
+	return lines, nil
+}
+
+// SaveRunOutput 记录上传的执行输出并关联到执行记录，重复上传时覆盖之前的记录
+func SaveRunOutput(db *sqlx.DB, out *tasktype.RunOutput) (int64, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.NamedExec(`
+		INSERT INTO task_outputs (run_id, task_id, agent_id, file_path, size, stored_size, truncated)
+		VALUES (:run_id, :task_id, :agent_id, :file_path, :size, :stored_size, :truncated)
+		ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id), file_path = VALUES(file_path), size = VALUES(size),
+		stored_size = VALUES(stored_size), truncated = VALUES(truncated), create_time = CURRENT_TIMESTAMP
+	`, out)
+	if err != nil {
+		return 0, fmt.Errorf("保存执行输出记录失败: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE task_runs SET output_id = ? WHERE run_id = ?`, id, out.RunID); err != nil {
+		return 0, fmt.Errorf("关联执行输出失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetRunOutput 查询执行上传的输出
+func GetRunOutput(db *sqlx.DB, taskID string, runID int64) (*tasktype.RunOutput, error) {
+	var out tasktype.RunOutput
+	err := db.Get(&out, `
+		SELECT id, run_id, task_id, agent_id, file_path, size, stored_size, truncated, create_time
+		FROM task_outputs WHERE run_id = ? AND task_id = ?
+	`, runID, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOutputNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询执行输出失败: %w", err)
+	}
+	return &out, nil
+}
+
+const (
+	// 一次搜索最多扫描的执行输出数
+	maxSearchRuns = 200
+	// 每批清理的输出数
+	outputPurgeBatch = 500
+	// 未配置时执行输出的保留天数
+	defaultOutputRetention = 30
+)
+
+// SearchCandidates 按条件查询需要搜索的执行输出，按执行开始时间倒序，超过扫描上限时 more 为 true
+func SearchCandidates(db *sqlx.DB, q *tasktype.OutputSearch) ([]tasktype.OutputCandidate, bool, error) {
+	query := `
+		SELECT r.run_id, r.task_id, r.agent_id, h.hostid, r.status, r.start_time, o.file_path
+		FROM task_outputs o JOIN task_runs r ON r.run_id = o.run_id
+		LEFT JOIN hostlist h ON h.agent_id = r.agent_id
+		WHERE 1 = 1
+	`
+	var args []interface{}
+	if q.TaskID != "" {
+		query += " AND r.task_id = ?"
+		args = append(args, q.TaskID)
+	}
+	if q.HostID != 0 {
+		query += " AND h.hostid = ?"
+		args = append(args, q.HostID)
+	}
+	if q.Status != "" {
+		query += " AND r.status = ?"
+		args = append(args, q.Status)
+	}
+	if !q.Start.IsZero() {
+		query += " AND r.start_time >= ?"
+		args = append(args, q.Start)
+	}
+	if !q.End.IsZero() {
+		query += " AND r.start_time <= ?"
+		args = append(args, q.End)
+	}
+	if q.HostIDs != nil {
+		if len(q.HostIDs) == 0 {
+			return []tasktype.OutputCandidate{}, false, nil
+		}
+		in, inArgs, err := sqlx.In(" AND h.hostid IN (?)", q.HostIDs)
+		if err != nil {
+			return nil, false, err
+		}
+		query += in
+		args = append(args, inArgs...)
+	}
+	query += fmt.Sprintf(" ORDER BY r.start_time DESC, r.run_id DESC LIMIT %d", maxSearchRuns+1)
+
+	list := []tasktype.OutputCandidate{}
+	if err := db.Select(&list, query, args...); err != nil {
+		return nil, false, fmt.Errorf("查询执行输出失败: %w", err)
+	}
+	if len(list) > maxSearchRuns {
+		return list[:maxSearchRuns], true, nil
+	}
+	return list, false, nil
+}
+
+// PurgeOutputs 删除超过保留天数的执行输出文件、记录和实时输出行
+func PurgeOutputs(db *sqlx.DB, dir string, retentionDays int) error {
+	before := time.Now().AddDate(0, 0, -retentionDays)
+	for {
+		var expired []tasktype.RunOutput
+		err := db.Select(&expired, `SELECT id, file_path FROM task_outputs WHERE create_time < ? ORDER BY id LIMIT ?`,
+			before, outputPurgeBatch)
+		if err != nil {
+			return fmt.Errorf("查询过期执行输出失败: %w", err)
+		}
+		if len(expired) == 0 {
+			break
+		}
+		ids := make([]int64, 0, len(expired))
+		for _, out := range expired {
+			if err := outputstore.Remove(dir, out.FilePath); err != nil {
+				zap.L().Warn("删除执行输出文件失败", zap.String("path", out.FilePath), zap.Error(err))
+			}
+			ids = append(ids, out.ID)
+		}
+		query, args, err := sqlx.In(`UPDATE task_runs SET output_id = NULL WHERE output_id IN (?)`, ids)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(query, args...); err != nil {
+			return fmt.Errorf("清理执行输出关联失败: %w", err)
+		}
+		query, args, err = sqlx.In(`DELETE FROM task_outputs WHERE id IN (?)`, ids)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(query, args...); err != nil {
+			return fmt.Errorf("删除过期执行输出失败: %w", err)
+		}
+	}
+
+	for {
+		result, err := db.Exec(`DELETE FROM task_output_lines WHERE output_time < ? LIMIT ?`, before, outputPurgeBatch*10)
+		if err != nil {
+			return fmt.Errorf("删除过期实时输出失败: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows < outputPurgeBatch*10 {
+			return nil
+		}
+	}
+}
+
+// StartOutputPurge 每小时清理一次过期的执行输出，ctx 取消时返回
+func StartOutputPurge(ctx context.Context, db *sqlx.DB, cfg *settings.OutputConfig) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		dir, days := outputstore.DefaultDir, defaultOutputRetention
+		if cfg != nil {
+			if cfg.Dir != "" {
+				dir = cfg.Dir
+			}
+			if cfg.Retention > 0 {
+				days = cfg.Retention
+			}
+		}
+		if err := PurgeOutputs(db, dir, days); err != nil {
+			zap.L().Error("清理过期执行输出失败", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
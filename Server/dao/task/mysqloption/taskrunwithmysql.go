@@ -0,0 +1,353 @@
+package mysqloption
+
+import (
+	"Server/models/tasktype"
+	"Server/pkg/snowflake"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+var (
+	// ErrTaskNotFound 任务不存在
+	ErrTaskNotFound = errors.New("任务不存在")
+	// ErrRunNotFound 执行记录不存在
+	ErrRunNotFound = errors.New("执行记录不存在")
+	// ErrInvalidTransition 任务状态不允许迁移到目标状态
+	ErrInvalidTransition = errors.New("任务状态迁移无效")
+)
+
+const (
+	// 客户端重新调度时未结束的执行记录的失败原因
+	runInterrupted = "客户端重新调度，执行中断"
+	// 失败原因的最大长度
+	maxRunErrorLen = 255
+	// 一次执行最多记录的尝试次数
+	maxRunAttempts = 32
+)
+
+// lockTask 在事务中锁定属于该客户端的任务记录，返回记录 ID 和当前状态。
+// 任务不属于该客户端时与不存在同样返回 ErrTaskNotFound。旧数据的空状态视为 pending
+func lockTask(tx *sqlx.Tx, agentID, taskID string) (int64, string, error) {
+	var row struct {
+		ID     int64          `db:"id"`
+		Status sql.NullString `db:"status"`
+	}
+	err := tx.Get(&row, `SELECT id, status FROM task_records WHERE task_id = ? AND agent_id = ? ORDER BY id DESC LIMIT 1 FOR UPDATE`, taskID, agentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", ErrTaskNotFound
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("查询任务状态失败: %w", err)
+	}
+	status := tasktype.NormalizeTaskState(row.Status.String)
+	if status == "" {
+		status = tasktype.TaskPending
+	}
+	return row.ID, status, nil
+}
+
+// setTaskState 校验迁移并更新任务状态
+func setTaskState(tx *sqlx.Tx, id int64, from, to string) error {
+	if !tasktype.CanTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+	if from == to {
+		return nil
+	}
+	if _, err := tx.Exec(`UPDATE task_records SET status = ?, update_time = NOW() WHERE id = ?`, to, id); err != nil {
+		return fmt.Errorf("更新任务状态失败: %w", err)
+	}
+	return nil
+}
+
+// TransitionTask 将客户端的任务迁移到新状态，任务不属于该客户端时返回 ErrTaskNotFound，迁移不合法时返回 ErrInvalidTransition。
+// 从 running 回到 scheduled 时，未结束的执行记为失败
+func TransitionTask(db *sqlx.DB, agentID, taskID, to string) (string, error) {
+	if !tasktype.IsTaskState(to) {
+		return "", fmt.Errorf("%w: 未知状态 %s", ErrInvalidTransition, to)
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	id, from, err := lockTask(tx, agentID, taskID)
+	if err != nil {
+		return "", err
+	}
+	if err := setTaskState(tx, id, from, to); err != nil {
+		return from, err
+	}
+	if from == tasktype.TaskRunning && to != tasktype.TaskRunning {
+		status := tasktype.TaskFailed
+		if to == tasktype.TaskCancelled {
+			status = tasktype.TaskCancelled
+		}
+		if _, err := tx.Exec(`
+			UPDATE task_runs SET status = ?, end_time = NOW(3),
+			duration_ms = TIMESTAMPDIFF(MICROSECOND, start_time, NOW(3)) DIV 1000, error = ?
+			WHERE task_id = ? AND status = ?
+		`, status, runInterrupted, taskID, tasktype.TaskRunning); err != nil {
+			return from, fmt.Errorf("结束执行记录失败: %w", err)
+		}
+	}
+	return from, tx.Commit()
+}
+
+// GetTaskState 查询任务当前状态
+func GetTaskState(db *sqlx.DB, taskID string) (*tasktype.TaskState, error) {
+	var row struct {
+		TaskID     string         `db:"task_id"`
+		AgentID    string         `db:"agent_id"`
+		Status     sql.NullString `db:"status"`
+		UpdateTime time.Time      `db:"update_time"`
+	}
+	err := db.Get(&row, `SELECT task_id, agent_id, status, update_time FROM task_records WHERE task_id = ? ORDER BY id DESC LIMIT 1`, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询任务状态失败: %w", err)
+	}
+	state := &tasktype.TaskState{TaskID: row.TaskID, AgentID: row.AgentID, UpdateTime: row.UpdateTime}
+	if state.Status = tasktype.NormalizeTaskState(row.Status.String); state.Status == "" {
+		state.Status = tasktype.TaskPending
+	}
+	return state, nil
+}
+
+// CheckTaskAgent 校验任务属于该客户端，不属于时与不存在同样返回 ErrTaskNotFound
+func CheckTaskAgent(db *sqlx.DB, agentID, taskID string) error {
+	var count int64
+	if err := db.Get(&count, `SELECT COUNT(*) FROM task_records WHERE task_id = ? AND agent_id = ?`, taskID, agentID); err != nil {
+		return fmt.Errorf("查询任务失败: %w", err)
+	}
+	if count == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+// StartRun 登记客户端任务的一次执行并将任务迁移到 running，返回执行 ID
+func StartRun(db *sqlx.DB, agentID string, p *tasktype.RunStart) (int64, error) {
+	if p.StartTime.IsZero() {
+		p.StartTime = time.Now()
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, from, err := lockTask(tx, agentID, p.TaskID)
+	if err != nil {
+		return 0, err
+	}
+	if err := setTaskState(tx, id, from, tasktype.TaskRunning); err != nil {
+		return 0, err
+	}
+	runID := snowflake.GenID()
+	_, err = tx.Exec(`
+		INSERT INTO task_runs (run_id, task_id, agent_id, status, start_time) VALUES (?, ?, ?, ?, ?)
+	`, runID, p.TaskID, agentID, tasktype.TaskRunning, p.StartTime)
+	if err != nil {
+		return 0, fmt.Errorf("写入执行记录失败: %w", err)
+	}
+	return runID, tx.Commit()
+}
+
+// SkipRun 记录客户端任务一次因并发策略跳过的触发，任务状态不变
+func SkipRun(db *sqlx.DB, agentID string, p *tasktype.RunSkip) (int64, error) {
+	if p.SkipTime.IsZero() {
+		p.SkipTime = time.Now()
+	}
+	if r := []rune(p.Reason); len(r) > maxRunErrorLen {
+		p.Reason = string(r[:maxRunErrorLen])
+	}
+	var count int64
+	if err := db.Get(&count, `SELECT COUNT(*) FROM task_records WHERE task_id = ? AND agent_id = ?`, p.TaskID, agentID); err != nil {
+		return 0, fmt.Errorf("查询任务失败: %w", err)
+	}
+	if count == 0 {
+		return 0, ErrTaskNotFound
+	}
+	runID := snowflake.GenID()
+	_, err := db.Exec(`
+		INSERT INTO task_runs (run_id, task_id, agent_id, status, start_time, end_time, duration_ms, error)
+		VALUES (?, ?, ?, ?, ?, ?, 0, ?)
+	`, runID, p.TaskID, agentID, tasktype.RunSkipped, p.SkipTime, p.SkipTime, p.Reason)
+	if err != nil {
+		return 0, fmt.Errorf("写入执行记录失败: %w", err)
+	}
+	return runID, nil
+}
+
+// FinishRun 记录执行结果并返回更新后的执行记录。任务没有其他执行中的记录时，任务状态迁移为本次执行的结果，
+// 被终止的执行使任务回到 scheduled；任务已被取消时只记录执行结果
+func FinishRun(db *sqlx.DB, agentID string, p *tasktype.RunFinish) (*tasktype.TaskRun, error) {
+	if !tasktype.IsRunFinished(p.Status) {
+		return nil, fmt.Errorf("%w: 执行结束状态不能为 %s", ErrInvalidTransition, p.Status)
+	}
+	p.Status = p.Outcome()
+	if p.EndTime.IsZero() {
+		p.EndTime = time.Now()
+	}
+	if r := []rune(p.Error); len(r) > maxRunErrorLen {
+		p.Error = string(r[:maxRunErrorLen])
+	}
+	if len(p.Attempts) > maxRunAttempts {
+		return nil, fmt.Errorf("%w: 尝试次数超过 %d", ErrInvalidTransition, maxRunAttempts)
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 先锁任务再锁执行记录，与 StartRun、TransitionTask 的加锁顺序一致
+	var taskID string
+	err = tx.Get(&taskID, `SELECT task_id FROM task_runs WHERE run_id = ? AND agent_id = ?`, p.RunID, agentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRunNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	id, from, err := lockTask(tx, agentID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	var run tasktype.TaskRun
+	err = tx.Get(&run, `
+		SELECT run_id, task_id, agent_id, status, start_time FROM task_runs WHERE run_id = ? FOR UPDATE
+	`, p.RunID)
+	if err != nil {
+		return nil, fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	if run.Status != tasktype.TaskRunning {
+		return nil, fmt.Errorf("%w: 执行 %d 已结束，状态为 %s", ErrInvalidTransition, run.RunID, run.Status)
+	}
+
+	// 以客户端上报的进程启动时间为准，早于登记时间或晚于结束时间的视为无效
+	if !p.StartTime.IsZero() && !p.StartTime.Before(run.StartTime) && !p.StartTime.After(p.EndTime) {
+		run.StartTime = p.StartTime
+	}
+	duration := p.EndTime.Sub(run.StartTime).Milliseconds()
+	if duration < 0 {
+		duration = 0
+	}
+	attempts := p.NumberAttempts(run.RunID)
+	_, err = tx.Exec(`
+		UPDATE task_runs SET status = ?, start_time = ?, end_time = ?, exit_code = ?, term_signal = ?,
+		stdout_bytes = ?, stderr_bytes = ?, timed_out = ?, duration_ms = ?, attempts = ?, error = ? WHERE run_id = ?
+	`, p.Status, run.StartTime, p.EndTime, p.ExitCode, p.Signal, p.StdoutBytes, p.StderrBytes, p.TimedOut, duration, attempts, p.Error, run.RunID)
+	if err != nil {
+		return nil, fmt.Errorf("更新执行记录失败: %w", err)
+	}
+	if err := insertAttempts(tx, p.Attempts); err != nil {
+		return nil, err
+	}
+
+	var others int64
+	if err := tx.Get(&others, `SELECT COUNT(*) FROM task_runs WHERE task_id = ? AND status = ?`, run.TaskID, tasktype.TaskRunning); err != nil {
+		return nil, fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	if from == tasktype.TaskRunning && others == 0 {
+		// 单次执行被 kill 终止时任务仍在调度中
+		to := p.Status
+		if to == tasktype.TaskCancelled {
+			to = tasktype.TaskScheduled
+		}
+		if err := setTaskState(tx, id, from, to); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	run.Status = p.Status
+	run.EndTime = &p.EndTime
+	run.ExitCode = p.ExitCode
+	run.Signal = p.Signal
+	run.StdoutBytes, run.StderrBytes = p.StdoutBytes, p.StderrBytes
+	run.TimedOut = p.TimedOut
+	run.DurationMs = &duration
+	run.Attempts = attempts
+	run.Error = p.Error
+	return &run, nil
+}
+
+// insertAttempts 记录执行中已由 NumberAttempts 编号的各次尝试
+func insertAttempts(tx *sqlx.Tx, attempts []tasktype.RunAttempt) error {
+	for i := range attempts {
+		a := &attempts[i]
+		if r := []rune(a.Error); len(r) > maxRunErrorLen {
+			a.Error = string(r[:maxRunErrorLen])
+		}
+		_, err := tx.NamedExec(`
+			INSERT INTO task_run_attempts (run_id, attempt, status, start_time, end_time, exit_code, term_signal,
+			stdout_bytes, stderr_bytes, timed_out, duration_ms, error)
+			VALUES (:run_id, :attempt, :status, :start_time, :end_time, :exit_code, :term_signal,
+			:stdout_bytes, :stderr_bytes, :timed_out, :duration_ms, :error)
+		`, a)
+		if err != nil {
+			return fmt.Errorf("写入执行尝试失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListAttempts 按尝试序号查询执行的各次尝试
+func ListAttempts(db *sqlx.DB, taskID string, runID int64) ([]tasktype.RunAttempt, error) {
+	var count int64
+	if err := db.Get(&count, `SELECT COUNT(*) FROM task_runs WHERE run_id = ? AND task_id = ?`, runID, taskID); err != nil {
+		return nil, fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrRunNotFound
+	}
+	attempts := []tasktype.RunAttempt{}
+	err := db.Select(&attempts, `
+		SELECT run_id, attempt, status, start_time, end_time, exit_code, term_signal,
+		stdout_bytes, stderr_bytes, timed_out, duration_ms, error
+		FROM task_run_attempts WHERE run_id = ? ORDER BY attempt
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("查询执行尝试失败: %w", err)
+	}
+	return attempts, nil
+}
+
+// ListRuns 按开始时间倒序查询任务的执行记录
+func ListRuns(db *sqlx.DB, taskID string, q *tasktype.RunQuery) ([]tasktype.TaskRun, error) {
+	query := `
+		SELECT run_id, task_id, agent_id, status, start_time, end_time, exit_code, term_signal,
+		stdout_bytes, stderr_bytes, timed_out, duration_ms, attempts, error, output_id
+		FROM task_runs WHERE task_id = ?
+	`
+	args := []interface{}{taskID}
+	if q.Status != "" {
+		query += " AND status = ?"
+		args = append(args, q.Status)
+	}
+	limit := q.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	offset := q.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	query += fmt.Sprintf(" ORDER BY start_time DESC, run_id DESC LIMIT %d OFFSET %d", limit, offset)
+
+	runs := []tasktype.TaskRun{}
+	if err := db.Select(&runs, query, args...); err != nil {
+		return nil, fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	return runs, nil
+}
@@ -10,14 +10,15 @@ import (
 
 func GetTaskRecordByTaskID(db *sqlx.DB, taskID string) (*tasktype.TaskRecord, error) {
 	// 构建查询语句
-	query := "SELECT script_path, task_id,crond_expression,remarks FROM task_records WHERE task_id = ?"
+	query := "SELECT script_path, task_id,crond_expression,remarks,timeout,concurrency_policy,max_retries,retry_backoff,retry_on FROM task_records WHERE task_id = ?"
 
 	// 执行查询
 	row := db.QueryRow(query, taskID)
 
 	// 解析查询结果
 	var record tasktype.TaskRecord
-	if err := row.Scan(&record.ScriptPath, &record.TaskID, &record.CrondExpression, &record.Remarks); err != nil {
+	if err := row.Scan(&record.ScriptPath, &record.TaskID, &record.CrondExpression, &record.Remarks, &record.Timeout, &record.ConcurrencyPolicy,
+		&record.MaxRetries, &record.RetryBackoff, &record.RetryOn); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("没有找到任务 ID 为 %s 的记录", taskID)
 		}
@@ -38,7 +39,7 @@ func GetTaskRecordsByTaskIDs(db *sqlx.DB, taskIDs []string) ([]tasktype.TaskReco
 	}
 
 	// 使用 IN 子句进行批量查询
-	query := fmt.Sprintf("SELECT script_path, task_id, crond_expression, remarks,file_id FROM task_records WHERE task_id IN (%s)", strings.Join(placeholders, ","))
+	query := fmt.Sprintf("SELECT script_path, task_id, crond_expression, remarks,file_id,timeout,concurrency_policy,max_retries,retry_backoff,retry_on FROM task_records WHERE task_id IN (%s)", strings.Join(placeholders, ","))
 
 	// 执行查询
 	rows, err := db.Query(query, args...)
@@ -51,7 +52,8 @@ func GetTaskRecordsByTaskIDs(db *sqlx.DB, taskIDs []string) ([]tasktype.TaskReco
 	var records []tasktype.TaskRecord
 	for rows.Next() {
 		var record tasktype.TaskRecord
-		if err := rows.Scan(&record.ScriptPath, &record.TaskID, &record.CrondExpression, &record.Remarks, &record.FileId); err != nil {
+		if err := rows.Scan(&record.ScriptPath, &record.TaskID, &record.CrondExpression, &record.Remarks, &record.FileId, &record.Timeout, &record.ConcurrencyPolicy,
+			&record.MaxRetries, &record.RetryBackoff, &record.RetryOn); err != nil {
 			return nil, err
 		}
 		records = append(records, record)
@@ -2,32 +2,55 @@ package mysqloption
 
 import (
 	"Server/models/tasktype"
+	"errors"
 	"fmt"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
-// InsertTaskRecord 插入一条新的任务记录到 task_records 表
-func InsertTaskRecord(db *sqlx.DB, clientIP, scriptPath, remarks string, taskID string, crond string, fileId int64) (int64, error) {
+// ErrTaskExists 任务 ID 已存在
+var ErrTaskExists = errors.New("任务已存在")
+
+// MySQL 唯一索引冲突的错误码
+const errDupEntry = 1062
+
+// InsertTaskRecord 插入一条新的任务记录到 task_records 表，任务 ID 已存在时返回 ErrTaskExists
+func InsertTaskRecord(db sqlx.Ext, agentID, clientIP, scriptPath, remarks string, taskID string, crond string, fileId int64, timeout int, policy string,
+	maxRetries, retryBackoff int, retryOn string) (int64, error) {
 	// 定义插入的 SQL 语句，使用命名参数
 	query := `
-		INSERT INTO task_records (client_ip, script_path, remarks, task_id,crond_expression,file_id)
-		VALUES (:client_ip, :script_path, :remarks, :task_id,:crond_expression,:file_id)
+		INSERT INTO task_records (agent_id, client_ip, script_path, remarks, task_id,crond_expression,file_id,status,timeout,concurrency_policy,max_retries,retry_backoff,retry_on)
+		VALUES (:agent_id, :client_ip, :script_path, :remarks, :task_id,:crond_expression,:file_id,:status,:timeout,:concurrency_policy,:max_retries,:retry_backoff,:retry_on)
 	`
 
 	// 创建一个 TaskRecord 实例，不包含 ID
 	task := tasktype.TaskRecord{
-		ClientIP:        clientIP,
-		ScriptPath:      scriptPath,
-		Remarks:         remarks,
-		TaskID:          taskID,
-		CrondExpression: crond,
-		FileId:          fileId,
+		AgentID:           agentID,
+		ClientIP:          clientIP,
+		ScriptPath:        scriptPath,
+		Remarks:           remarks,
+		TaskID:            taskID,
+		CrondExpression:   crond,
+		FileId:            fileId,
+		Status:            tasktype.TaskPending, // 客户端确认调度后迁移为 scheduled
+		Timeout:           timeout,
+		ConcurrencyPolicy: policy,
+		MaxRetries:        maxRetries,
+		RetryBackoff:      retryBackoff,
+		RetryOn:           retryOn,
+	}
+	if task.ConcurrencyPolicy == "" {
+		task.ConcurrencyPolicy = tasktype.ConcurrencyAllow
 	}
 
 	// 使用 NamedExec 进行命名参数的插入
-	result, err := db.NamedExec(query, &task)
+	result, err := sqlx.NamedExec(db, query, &task)
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == errDupEntry {
+		return 0, fmt.Errorf("%w: %s", ErrTaskExists, taskID)
+	}
 	if err != nil {
 		zap.L().Error("Failed to insert task record", zap.Error(err))
 		return 0, fmt.Errorf("failed to insert task record: %w", err)
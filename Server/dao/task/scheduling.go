@@ -41,9 +41,9 @@ func (tm *Manager) AddTasks(tasks map[string]string) error {
 }
 
 // AddClientTask 为指定客户端添加任务
-func (tm *Manager) AddClientTask(clientIP, taskID, taskStatus string) error {
+func (tm *Manager) AddClientTask(agentID, taskID, taskStatus string) error {
 	// 构建 etcd 中存储任务的键
-	taskKey := fmt.Sprintf("/tasks/%s/%s", clientIP, taskID)
+	taskKey := fmt.Sprintf("/tasks/%s/%s", agentID, taskID)
 
 	// 启动一个 etcd 事务，确保操作的原子性
 	txn := tm.kv.Txn(context.Background())
@@ -61,7 +61,7 @@ func (tm *Manager) AddClientTask(clientIP, taskID, taskStatus string) error {
 		return fmt.Errorf("任务 %s 已经存在", taskID)
 	}
 
-	log.Printf("成功为客户端 %s 添加任务 %s", clientIP, taskID)
+	log.Printf("成功为客户端 %s 添加任务 %s", agentID, taskID)
 	return nil
 }
 
@@ -114,11 +114,12 @@ func (tm *Manager) ManageTasks(tasksToCreate map[string]string, tasksToDelete []
 	})
 }
 
-func (tm *Manager) GetTasksByClientIP(clientIP string, taskStatus string) ([]string, error) {
-	// 使用客户端 IP 生成任务键的前缀
-	keyPrefix := fmt.Sprintf("/tasks/%s", clientIP)
+// GetTasksByAgentID 获取指定客户端处于某状态的任务 ID
+func (tm *Manager) GetTasksByAgentID(agentID string, taskStatus string) ([]string, error) {
+	// 使用客户端 ID 生成任务键的前缀，末尾的 / 避免匹配到以相同前缀开头的其他 ID
+	keyPrefix := fmt.Sprintf("/tasks/%s/", agentID)
 
-	// 从 etcd 获取所有与该客户端 IP 相关的任务
+	// 从 etcd 获取所有与该客户端相关的任务
 	taskInfos, err := tm.GetTasks(keyPrefix)
 	if err != nil {
 		log.Printf("从 etcd 获取任务失败: %v", err)
@@ -127,7 +128,7 @@ func (tm *Manager) GetTasksByClientIP(clientIP string, taskStatus string) ([]str
 
 	// 如果没有找到任务，返回空列表
 	if len(taskInfos) == 0 {
-		log.Printf("没有为客户端 IP %s 找到任务", clientIP)
+		log.Printf("没有为客户端 %s 找到任务", agentID)
 		return []string{}, nil // 返回空列表而不是错误
 	}
 
@@ -144,7 +145,7 @@ func (tm *Manager) GetTasksByClientIP(clientIP string, taskStatus string) ([]str
 			// 提取任务 ID（去掉路径前缀）
 			taskIDParts := strings.Split(taskKey, "/")
 
-			// 确保任务 ID 在路径的最后一部分，例如：/tasks/127.0.0.1/4810
+			// 确保任务 ID 在路径的最后一部分，例如：/tasks/<agent_id>/4810
 			if len(taskIDParts) >= 3 {
 				taskID := taskIDParts[len(taskIDParts)-1] // 提取最后一部分即为任务ID
 				matchingTaskIDs = append(matchingTaskIDs, taskID)
@@ -162,7 +163,7 @@ func (tm *Manager) extractTaskIDs(taskInfos []*mvccpb.KeyValue) ([]string, error
 
 	// 遍历从 etcd 获取的任务信息
 	for _, taskInfo := range taskInfos {
-		// 提取完整的任务键，例如 /tasks/192.168.1.100/8529
+		// 提取完整的任务键，例如 /tasks/<agent_id>/8529
 		taskKey := string(taskInfo.Key)
 
 		// 提取任务 ID，也就是去掉路径前缀，保留最后一部分
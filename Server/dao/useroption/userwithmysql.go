@@ -0,0 +1,361 @@
+package useroption
+
+import (
+	"Server/dao/mysql"
+	"Server/models/authtype"
+	"Server/pkg/snowflake"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+var (
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("用户不存在")
+	// ErrInvalidPassword 用户名或密码错误，登录时不区分两种情况
+	ErrInvalidPassword = errors.New("用户名或密码错误")
+	// ErrUserDisabled 用户已停用
+	ErrUserDisabled = errors.New("用户已停用")
+	// ErrUserExist 用户名已存在
+	ErrUserExist = errors.New("用户已存在")
+	// ErrGroupNotFound 主机组不存在
+	ErrGroupNotFound = errors.New("主机组不存在")
+)
+
+const userColumns = `id, user_id, username, password, email, role, status, create_time`
+
+// Login 校验用户名和密码，旧格式的密码校验通过后升级为 bcrypt
+func Login(db *sqlx.DB, username, password string) (*authtype.UserAccount, error) {
+	var user authtype.UserAccount
+	err := db.Get(&user, `SELECT `+userColumns+` FROM user WHERE username = ?`, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidPassword
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	if strings.HasPrefix(user.Password, "$2") {
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+			return nil, ErrInvalidPassword
+		}
+	} else {
+		if subtle.ConstantTimeCompare([]byte(user.Password), []byte(mysql.EncryptPassword(password))) != 1 {
+			return nil, ErrInvalidPassword
+		}
+		if hash, err := hashPassword(password); err == nil {
+			if _, err := db.Exec(`UPDATE user SET password = ? WHERE id = ?`, hash, user.ID); err != nil {
+				zap.L().Warn("Failed to upgrade password hash", zap.String("username", username), zap.Error(err))
+			}
+		}
+	}
+	if user.Status != authtype.UserActive {
+		return nil, ErrUserDisabled
+	}
+	return &user, nil
+}
+
+// GetUser 按 user_id 查询用户
+func GetUser(db *sqlx.DB, userID int64) (*authtype.UserAccount, error) {
+	var user authtype.UserAccount
+	err := db.Get(&user, `SELECT `+userColumns+` FROM user WHERE user_id = ?`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if user.GroupIDs, err = userGroupIDs(db, userID); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers 查询全部用户及其所属主机组
+func ListUsers(db *sqlx.DB) ([]authtype.UserAccount, error) {
+	users := []authtype.UserAccount{}
+	if err := db.Select(&users, `SELECT `+userColumns+` FROM user ORDER BY id`); err != nil {
+		return nil, fmt.Errorf("查询用户列表失败: %w", err)
+	}
+	var rows []struct {
+		UserID  int64 `db:"user_id"`
+		GroupID int64 `db:"group_id"`
+	}
+	if err := db.Select(&rows, `SELECT user_id, group_id FROM user_host_groups`); err != nil {
+		return nil, fmt.Errorf("查询用户主机组失败: %w", err)
+	}
+	groups := make(map[int64][]int64)
+	for _, r := range rows {
+		groups[r.UserID] = append(groups[r.UserID], r.GroupID)
+	}
+	for i := range users {
+		users[i].GroupIDs = groups[users[i].UserID]
+		if users[i].GroupIDs == nil {
+			users[i].GroupIDs = []int64{}
+		}
+	}
+	return users, nil
+}
+
+// CreateUser 创建用户，返回 user_id
+func CreateUser(db *sqlx.DB, p *authtype.NewUser) (int64, error) {
+	var count int64
+	if err := db.Get(&count, `SELECT COUNT(*) FROM user WHERE username = ?`, p.Username); err != nil {
+		return 0, fmt.Errorf("查询用户失败: %w", err)
+	}
+	if count > 0 {
+		return 0, ErrUserExist
+	}
+	hash, err := hashPassword(p.Password)
+	if err != nil {
+		return 0, err
+	}
+	var email *string
+	if p.Email != "" {
+		email = &p.Email
+	}
+
+	userID := snowflake.GenID()
+	_, err = db.Exec(`
+		INSERT INTO user (user_id, username, password, email, role, status) VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, p.Username, hash, email, p.Role, authtype.UserActive)
+	if err != nil {
+		zap.L().Error("Failed to insert user", zap.String("username", p.Username), zap.Error(err))
+		return 0, fmt.Errorf("failed to insert user: %w", err)
+	}
+	return userID, nil
+}
+
+// UpdateUser 修改用户的密码、角色或状态
+func UpdateUser(db *sqlx.DB, userID int64, u *authtype.UserUpdate) error {
+	var sets []string
+	var args []interface{}
+	if u.Password != nil {
+		hash, err := hashPassword(*u.Password)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, "password = ?")
+		args = append(args, hash)
+	}
+	if u.Role != nil {
+		sets = append(sets, "role = ?")
+		args = append(args, *u.Role)
+	}
+	if u.Status != nil {
+		sets = append(sets, "status = ?")
+		args = append(args, *u.Status)
+	}
+	if err := ensureUser(db, userID); err != nil {
+		return err
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	if _, err := db.Exec("UPDATE user SET "+strings.Join(sets, ", ")+" WHERE user_id = ?", append(args, userID)...); err != nil {
+		return fmt.Errorf("修改用户失败: %w", err)
+	}
+	return nil
+}
+
+// SetUserGroups 替换用户所属的主机组
+func SetUserGroups(db *sqlx.DB, userID int64, groupIDs []int64) error {
+	if err := ensureUser(db, userID); err != nil {
+		return err
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_host_groups WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("删除用户主机组失败: %w", err)
+	}
+	for _, id := range uniqueIDs(groupIDs) {
+		if _, err := tx.Exec(`INSERT INTO user_host_groups (user_id, group_id) VALUES (?, ?)`, userID, id); err != nil {
+			return fmt.Errorf("写入用户主机组失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListGroups 查询全部主机组及其成员
+func ListGroups(db *sqlx.DB) ([]authtype.HostGroup, error) {
+	groups := []authtype.HostGroup{}
+	if err := db.Select(&groups, `SELECT id, name, description, create_time FROM host_groups ORDER BY id`); err != nil {
+		return nil, fmt.Errorf("查询主机组失败: %w", err)
+	}
+	var rows []struct {
+		GroupID int64 `db:"group_id"`
+		HostID  int64 `db:"hostid"`
+	}
+	if err := db.Select(&rows, `SELECT group_id, hostid FROM host_group_members`); err != nil {
+		return nil, fmt.Errorf("查询主机组成员失败: %w", err)
+	}
+	members := make(map[int64][]int64)
+	for _, r := range rows {
+		members[r.GroupID] = append(members[r.GroupID], r.HostID)
+	}
+	for i := range groups {
+		groups[i].HostIDs = members[groups[i].ID]
+		if groups[i].HostIDs == nil {
+			groups[i].HostIDs = []int64{}
+		}
+	}
+	return groups, nil
+}
+
+// CreateGroup 创建主机组，返回组 ID
+func CreateGroup(db *sqlx.DB, p *authtype.NewHostGroup) (int64, error) {
+	result, err := db.Exec(`INSERT INTO host_groups (name, description) VALUES (?, ?)`, p.Name, p.Description)
+	if err != nil {
+		zap.L().Error("Failed to insert host group", zap.String("name", p.Name), zap.Error(err))
+		return 0, fmt.Errorf("failed to insert host group: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// DeleteGroup 删除主机组，成员和用户关联一并删除
+func DeleteGroup(db *sqlx.DB, groupID int64) error {
+	if err := ensureGroup(db, groupID); err != nil {
+		return err
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM host_group_members WHERE group_id = ?`,
+		`DELETE FROM user_host_groups WHERE group_id = ?`,
+		`DELETE FROM host_groups WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(query, groupID); err != nil {
+			return fmt.Errorf("删除主机组失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SetGroupHosts 替换主机组的成员
+func SetGroupHosts(db *sqlx.DB, groupID int64, hostIDs []int64) error {
+	if err := ensureGroup(db, groupID); err != nil {
+		return err
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM host_group_members WHERE group_id = ?`, groupID); err != nil {
+		return fmt.Errorf("删除主机组成员失败: %w", err)
+	}
+	for _, id := range uniqueIDs(hostIDs) {
+		if _, err := tx.Exec(`INSERT INTO host_group_members (group_id, hostid) VALUES (?, ?)`, groupID, id); err != nil {
+			return fmt.Errorf("写入主机组成员失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ScopedHostIDs 返回用户所属主机组内的全部主机
+func ScopedHostIDs(db *sqlx.DB, userID int64) ([]int64, error) {
+	ids := []int64{}
+	err := db.Select(&ids, `
+		SELECT DISTINCT m.hostid FROM user_host_groups u
+		JOIN host_group_members m ON m.group_id = u.group_id
+		WHERE u.user_id = ?
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户主机范围失败: %w", err)
+	}
+	return ids, nil
+}
+
+// AgentInScope 判断客户端对应的主机是否在用户的主机组范围内
+func AgentInScope(db *sqlx.DB, userID int64, agentID string) (bool, error) {
+	var count int64
+	err := db.Get(&count, `
+		SELECT COUNT(*) FROM user_host_groups u
+		JOIN host_group_members m ON m.group_id = u.group_id
+		JOIN hostlist h ON h.hostid = m.hostid
+		WHERE u.user_id = ? AND h.agent_id = ?
+	`, userID, agentID)
+	if err != nil {
+		return false, fmt.Errorf("查询用户主机范围失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// HostInScope 判断主机是否在用户的主机组范围内
+func HostInScope(db *sqlx.DB, userID, hostID int64) (bool, error) {
+	var count int64
+	err := db.Get(&count, `
+		SELECT COUNT(*) FROM user_host_groups u
+		JOIN host_group_members m ON m.group_id = u.group_id
+		WHERE u.user_id = ? AND m.hostid = ?
+	`, userID, hostID)
+	if err != nil {
+		return false, fmt.Errorf("查询用户主机范围失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+func userGroupIDs(db *sqlx.DB, userID int64) ([]int64, error) {
+	ids := []int64{}
+	if err := db.Select(&ids, `SELECT group_id FROM user_host_groups WHERE user_id = ? ORDER BY group_id`, userID); err != nil {
+		return nil, fmt.Errorf("查询用户主机组失败: %w", err)
+	}
+	return ids, nil
+}
+
+func ensureUser(db *sqlx.DB, userID int64) error {
+	var count int64
+	if err := db.Get(&count, `SELECT COUNT(*) FROM user WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+	if count == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func ensureGroup(db *sqlx.DB, groupID int64) error {
+	var count int64
+	if err := db.Get(&count, `SELECT COUNT(*) FROM host_groups WHERE id = ?`, groupID); err != nil {
+		return fmt.Errorf("查询主机组失败: %w", err)
+	}
+	if count == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("加密密码失败: %w", err)
+	}
+	return string(hash), nil
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	var out []int64
+	for _, id := range ids {
+		if id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
@@ -1,16 +1,26 @@
 package main
 
 import (
-	"Server/common"
 	"Server/controller"
+	"Server/dao/alarmoption"
+	"Server/dao/clientoption"
+	"Server/dao/clusteroption"
+	"Server/dao/cronoption"
 	"Server/dao/etcd"
+	"Server/dao/metricoption"
 	"Server/dao/mysql"
 	"Server/dao/task"
+	"Server/dao/task/mysqloption"
 	"Server/logger"
+	"Server/pkg/jwt"
+	"Server/pkg/minica"
+	"Server/pkg/snowflake"
 	"Server/router"
 	"Server/settings"
 	"Server/ws"
+	"Server/wshandler"
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -18,6 +28,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -46,24 +57,73 @@ func main() {
 	}
 	defer zap.L().Sync()
 
+	// 管理后台登录令牌的签名密钥和有效期，未配置密钥时拒绝启动
+	auth := settings.Conf.AuthConfig
+	if auth == nil {
+		zap.L().Error("init jwt failed: 缺少 auth 配置")
+		return
+	}
+	if err := jwt.Init(auth.JWTSecret, time.Duration(auth.TokenExpire)*time.Hour); err != nil {
+		zap.L().Error("init jwt failed: 请配置 auth.jwt_secret", zap.Error(err))
+		return
+	}
+
 	// 初始化 etcd 和 mysql
 	cli, err := initEtcd()
 	if err != nil {
 		zap.L().Error("init etcd failed", zap.Error(err))
 		return
 	}
+	// 多实例共用同一 machine_id 会生成重复 ID，节点号通过 etcd 租约占用，配置值只作为首选
+	machineID, err := clusteroption.ClaimNodeID(cli, settings.Conf.MachineId)
+	if err != nil {
+		zap.L().Error("claim snowflake node id failed", zap.Error(err))
+		return
+	}
+	if err := snowflake.Init(settings.Conf.StartTime, machineID); err != nil {
+		zap.L().Error("init snowflake failed", zap.Error(err))
+		return
+	}
 	db, err := mysql.Init(settings.Conf.MySQLConfig)
 	if err != nil {
 		zap.L().Error("init database failed", zap.Error(err))
 		return
 	}
 	defer mysql.Close()
+	// 报警引擎由 leader 运行，任务执行失败的报警由收到结果的实例直接发送
+	alarmEngine := alarmoption.NewEngine(db, settings.Conf.AlarmConfig, settings.Conf.NotifyConfig)
+	// 登记本实例并处理其他实例转发来的客户端消息
+	go clusteroption.RegisterServer(cli)
+	go clusteroption.WatchInbox(cli, ws.HandleEnvelope)
+	// 指标汇总、输出清理、报警评估和离线检测只需一个实例执行，由选出的 leader 负责
+	go clusteroption.RunAsLeader(cli, func(ctx context.Context) {
+		runDuties(ctx,
+			func(ctx context.Context) { metricoption.StartRollup(ctx, db, settings.Conf.MetricsConfig) },
+			func(ctx context.Context) { mysqloption.StartOutputPurge(ctx, db, settings.Conf.OutputConfig) },
+			alarmEngine.Start,
+			func(ctx context.Context) { clientoption.WatchPresence(ctx, cli, alarmEngine.PresenceChanged) },
+		)
+	})
+	// 参与执行 /cron/jobs/ 下的分布式定时任务
+	if cfg := settings.Conf.CronConfig; cfg != nil && cfg.Worker {
+		go cronoption.NewScheduler(etcd.GJobMgr, db, cfg).Start()
+	}
+
 	// 初始化 TaskManager
 	taskManager := task.NewTaskManager(cli)
 
+	// 初始化 TLS 和内置 CA
+	tlsConfig, issuer, err := initTLS(settings.Conf.TLS)
+	if err != nil {
+		zap.L().Error("init tls failed", zap.Error(err))
+		return
+	}
+	requireAgentCert := tlsConfig != nil && settings.Conf.TLS.RequireAgentCert
+
 	// 初始化处理器
-	ws.InitHandlers(taskManager, db, cli)
-	wsManager := common.NewWebSocketManager()
+	ws.InitHandlers(taskManager, db, cli, issuer, requireAgentCert, alarmEngine)
+	// 向连接在本实例的客户端重发超时未确认的指令
+	go wshandler.StartRedelivery(db)
 	// 初始化 Gin 的翻译器
 	if err := controller.InitTrans("zh"); err != nil {
 		zap.L().Error("init validator failed", zap.Error(err))
@@ -71,12 +131,13 @@ func main() {
 	}
 
 	// 注册路由
-	r := router.Setup(settings.Conf.Mode, settings.Conf.ClientUrl, settings.Conf.Filemaxsize, settings.Conf.Savedir, db, cli, wsManager)
+	r := router.Setup(settings.Conf.Mode, settings.Conf.ClientUrl, settings.Conf.Filemaxsize, settings.Conf.Savedir, db, cli, requireAgentCert)
 
 	// 启动 HTTP 服务器
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", settings.Conf.Port),
-		Handler: r,
+		Addr:      fmt.Sprintf(":%d", settings.Conf.Port),
+		Handler:   r,
+		TLSConfig: tlsConfig,
 	}
 	startServer(srv)
 
@@ -85,9 +146,30 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	gracefulShutdown(srv, quit)
 }
+
+// runDuties 并发运行 leader 的单例任务，全部返回后才返回
+func runDuties(ctx context.Context, duties ...func(ctx context.Context)) {
+	var wg sync.WaitGroup
+	for _, duty := range duties {
+		wg.Add(1)
+		go func(duty func(ctx context.Context)) {
+			defer wg.Done()
+			duty(ctx)
+		}(duty)
+	}
+	wg.Wait()
+}
+
 func startServer(srv *http.Server) {
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			// 证书已加载到 TLSConfig 中
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			zap.L().Fatal("listen: %v", zap.Error(err))
 		}
 	}()
@@ -126,3 +208,38 @@ func initEtcd() (*clientv3.Client, error) {
 	}
 	return client, nil
 }
+
+// initTLS 加载服务端证书和内置 CA，未启用时返回 nil。
+// 客户端证书为可选，浏览器访问管理接口时不需要证书
+func initTLS(cfg *settings.TLSConfig) (*tls.Config, *wshandler.CertIssuer, error) {
+	if cfg == nil || !cfg.Enable {
+		return nil, nil, nil
+	}
+	ca, err := minica.LoadOrCreate(cfg.CACert, cfg.CAKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load ca failed: %v", err)
+	}
+	validity := time.Duration(cfg.CertValidity) * 24 * time.Hour
+	if validity <= 0 {
+		validity = 365 * 24 * time.Hour
+	}
+
+	if _, err := os.Stat(cfg.CertFile); os.IsNotExist(err) {
+		zap.L().Info("server certificate not found, issuing with built-in ca", zap.Strings("hosts", cfg.Hosts))
+		if err := ca.IssueServerCert(cfg.Hosts, cfg.CertFile, cfg.KeyFile, validity); err != nil {
+			return nil, nil, err
+		}
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load server certificate failed: %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    ca.Pool(),
+	}
+	return tlsConfig, &wshandler.CertIssuer{CA: ca, Validity: validity}, nil
+}
@@ -0,0 +1,158 @@
+package middlewares
+
+import (
+	"Server/controller"
+	"Server/dao/auditoption"
+	"Server/models/audittype"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// 请求体超过该大小时只记录大小，不读取内容
+const maxAuditBody = 64 << 10
+
+// 请求体中不写入审计日志的字段，按字段名包含匹配，不区分大小写
+var sensitiveKeys = []string{"password", "secret", "credential", "token", "csr"}
+
+// Audit 记录管理操作的审计日志：操作人、来源 IP、动作、对象、请求摘要和结果。
+// 处理函数可通过 controller.SetAuditAction/SetAuditTarget 细化动作和对象，
+// 未指定对象时取路径参数
+func Audit(db *sqlx.DB, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payload := summarizeRequest(c)
+		c.Next()
+
+		entry := &audittype.AuditLog{
+			Username: controller.GetCurrentUsername(c),
+			ClientIP: c.ClientIP(),
+			Action:   action,
+			Payload:  payload,
+		}
+		if userId, err := controller.GetCurrentUser(c); err == nil {
+			entry.UserID = &userId
+		}
+		if entry.UserID == nil {
+			if agentID := c.GetString("agent_id"); agentID != "" {
+				entry.Username = "agent:" + agentID
+			}
+		}
+		if a := c.GetString(controller.ContextAuditActionKey); a != "" {
+			entry.Action = a
+		}
+		entry.Target = c.GetString(controller.ContextAuditTargetKey)
+		if entry.Target == "" {
+			entry.Target = pathTarget(c)
+		}
+		entry.Result, entry.Detail = auditResult(c)
+
+		if err := auditoption.Insert(db, entry); err != nil {
+			zap.L().Error("写入审计日志失败", zap.String("action", entry.Action), zap.String("target", entry.Target), zap.Error(err))
+		}
+	}
+}
+
+// auditResult 按业务码判断操作结果，未使用统一响应的接口按 HTTP 状态判断
+func auditResult(c *gin.Context) (string, string) {
+	if code, ok := c.Get(controller.ContextResCodeKey); ok {
+		if code == controller.CodeSuccess {
+			return audittype.ResultSuccess, ""
+		}
+		return audittype.ResultFailed, c.GetString(controller.ContextResMsgKey)
+	}
+	if status := c.Writer.Status(); status >= http.StatusBadRequest {
+		return audittype.ResultFailed, http.StatusText(status)
+	}
+	return audittype.ResultSuccess, ""
+}
+
+// pathTarget 以路径参数作为操作对象，如 id=12
+func pathTarget(c *gin.Context) string {
+	parts := make([]string, 0, len(c.Params))
+	for _, p := range c.Params {
+		parts = append(parts, p.Key+"="+p.Value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// summarizeRequest 读取请求体生成摘要后放回，上传文件只记录文件名和大小，JSON 中的敏感字段隐去
+func summarizeRequest(c *gin.Context) string {
+	if c.Request.Body == nil || c.Request.Method == http.MethodGet {
+		return c.Request.URL.RawQuery
+	}
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		return summarizeMultipart(c)
+	}
+	if c.Request.ContentLength > maxAuditBody {
+		return fmt.Sprintf("<%d bytes>", c.Request.ContentLength)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBody+1))
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	if len(body) == 0 {
+		return c.Request.URL.RawQuery
+	}
+	if len(body) > maxAuditBody {
+		return fmt.Sprintf("<more than %d bytes>", maxAuditBody)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	redact(v)
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+// summarizeMultipart 解析表单后记录上传的文件名和大小，解析结果保留给后续处理函数使用
+func summarizeMultipart(c *gin.Context) string {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return ""
+	}
+	var files []string
+	for field, headers := range form.File {
+		for _, h := range headers {
+			files = append(files, fmt.Sprintf("%s:%s(%d bytes)", field, h.Filename, h.Size))
+		}
+	}
+	return strings.Join(files, ",")
+}
+
+// redact 隐去 JSON 中的敏感字段
+func redact(v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if isSensitive(k) {
+				t[k] = "***"
+				continue
+			}
+			redact(val)
+		}
+	case []interface{}:
+		for _, val := range t {
+			redact(val)
+		}
+	}
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
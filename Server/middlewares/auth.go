@@ -2,37 +2,96 @@ package middlewares
 
 import (
 	"Server/controller"
+	"Server/dao/useroption"
+	"Server/models/authtype"
 	"Server/pkg/jwt"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
 	"strings"
 )
 
 func JWTAuthMiddleware() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		// 客户端携带Token有三种方式 1.放在请求头 2.放在请求体 3.放在URI
-		// 这里假设Token放在Header的Authorization中，并使用Bearer开头
-		// 这里的具体实现方式要依据你的实际业务情况决定
-		authHeader := c.Request.Header.Get("Authorization")
-		if authHeader == "" {
-			controller.ResopnseError(c, controller.CodeNeedLogin)
+		if !authenticate(c) {
 			c.Abort()
 			return
 		}
-		// 按空格分割
-		parts := strings.SplitN(authHeader, " ", 2)
-		if !(len(parts) == 2 && parts[0] == "Bearer") {
-			controller.ResopnseError(c, controller.CodeInvalidAuth)
+		c.Next() // 后续的处理函数可以用过c.Get(controller.ContextUserIdKey)来获取当前请求的用户信息
+	}
+}
+
+// RequireRole 校验当前用户的角色不低于 role。角色每次从数据库读取，修改或停用后立即生效
+func RequireRole(db *sqlx.DB, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorize(c, db, role) {
 			c.Abort()
 			return
 		}
-		// parts[1]是获取到的tokenString，我们使用之前定义好的解析JWT的函数来解析它
-		mc, err := jwt.ParseToken(parts[1])
-		if err != nil {
+		c.Next()
+	}
+}
+
+// CheckUser 依次校验登录令牌和角色，失败时已写入响应，供需要自行组合认证方式的中间件使用
+func CheckUser(c *gin.Context, db *sqlx.DB, role string) bool {
+	return authenticate(c) && authorize(c, db, role)
+}
+
+// authenticate 解析请求头中的 JWT，将用户 ID 保存到请求上下文
+func authenticate(c *gin.Context) bool {
+	// 客户端携带Token有三种方式 1.放在请求头 2.放在请求体 3.放在URI
+	// 这里假设Token放在Header的Authorization中，并使用Bearer开头
+	authHeader := c.Request.Header.Get("Authorization")
+	// 浏览器的 EventSource 无法设置请求头，实时输出接口允许通过 access_token 参数携带
+	if authHeader == "" && c.GetHeader("Accept") == "text/event-stream" {
+		if token := c.Query("access_token"); token != "" {
+			authHeader = "Bearer " + token
+		}
+	}
+	if authHeader == "" {
+		controller.ResopnseError(c, controller.CodeNeedLogin)
+		return false
+	}
+	// 按空格分割
+	parts := strings.SplitN(authHeader, " ", 2)
+	if !(len(parts) == 2 && parts[0] == "Bearer") {
+		controller.ResopnseError(c, controller.CodeInvalidAuth)
+		return false
+	}
+	// parts[1]是获取到的tokenString，我们使用之前定义好的解析JWT的函数来解析它
+	mc, err := jwt.ParseToken(parts[1])
+	if err != nil {
+		controller.ResopnseError(c, controller.CodeInvalidAuth)
+		return false
+	}
+	// 将当前请求的用户信息保存到请求的上下文c上
+	c.Set(controller.ContextUserIdKey, mc.UserId)
+	c.Set(controller.ContextUserNameKey, mc.Username)
+	return true
+}
+
+// authorize 从数据库读取当前用户，校验状态和角色
+func authorize(c *gin.Context, db *sqlx.DB, role string) bool {
+	userId, err := controller.GetCurrentUser(c)
+	if err != nil {
+		controller.ResopnseError(c, controller.CodeNeedLogin)
+		return false
+	}
+	user, err := useroption.GetUser(db, userId)
+	if err != nil {
+		if errors.Is(err, useroption.ErrUserNotFound) {
 			controller.ResopnseError(c, controller.CodeInvalidAuth)
-			return
+		} else {
+			zap.L().Error("查询当前用户失败", zap.Int64("user_id", userId), zap.Error(err))
+			controller.ResopnseError(c, controller.CodeServerBusy)
 		}
-		// 将当前请求的username信息保存到请求的上下文c上
-		c.Set(controller.ContextUserIdKey, mc.UserId)
-		c.Next() // 后续的处理函数可以用过c.Get("username")来获取当前请求的用户信息
+		return false
+	}
+	if user.Status != authtype.UserActive || !authtype.RoleAllows(user.Role, role) {
+		controller.ResopnseError(c, controller.CodeForbidden)
+		return false
 	}
+	c.Set(controller.ContextUserRoleKey, user.Role)
+	return true
 }
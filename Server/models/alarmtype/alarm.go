@@ -0,0 +1,115 @@
+package alarmtype
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 报警状态
+const (
+	AlarmResolved = 0
+	AlarmFiring   = 1
+)
+
+// AlarmRule 阈值规则，对应 alarm_rules 表；HostID 为 0 时对所有主机生效
+type AlarmRule struct {
+	ID        int64   `json:"id" db:"id"`
+	HostID    int64   `json:"hostid" db:"hostid"`
+	Metric    string  `json:"metric" db:"metric" binding:"required"`
+	Label     string  `json:"label" db:"label"` // 为空时对该指标的每个标签分别评估
+	Operator  string  `json:"operator" db:"operator" binding:"required,oneof=> >= < <="`
+	Threshold float64 `json:"threshold" db:"threshold"`
+	Duration  int     `json:"duration" db:"duration" binding:"gte=0"` // 持续分钟数
+	AlarmType int     `json:"alarmtype" db:"alarmtype"`
+	Enabled   bool    `json:"enabled" db:"enabled"`
+	Note      string  `json:"note" db:"note"`
+}
+
+// Breached 判断指标值是否触发阈值
+func (r *AlarmRule) Breached(value float64) bool {
+	switch r.Operator {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	}
+	return false
+}
+
+// Notification 报警阈值和通知渠道配置，对应 notification 表
+type Notification struct {
+	ID               int64          `db:"id"`
+	CpuOption        sql.NullString `db:"cpuoption"`
+	MemoryOption     sql.NullString `db:"memoryoption"`
+	SystemDiskOption sql.NullString `db:"systemdiskoption"`
+	ThresholdStatus  sql.NullString `db:"thresholdstatus"`
+	WorkApiUrl       sql.NullString `db:"workapiurl"`
+	WorkAtuser       sql.NullString `db:"workatuser"`
+	DingApiUrl       sql.NullString `db:"dingapiurl"`
+	DingAtuser       sql.NullString `db:"dingatuser"`
+}
+
+// AlarmSetting 主机报警开关，对应 alarmsetting 表
+type AlarmSetting struct {
+	HostID      int64          `db:"hostid"`
+	AlarmType   int            `db:"alarmtype"`
+	AlarmStatus int            `db:"alarmstatus"`
+	HostOwner   sql.NullString `db:"alarmhostonwer"`
+}
+
+// HostRef 报警关联的主机信息，来自 hostlist 表
+type HostRef struct {
+	HostID   int64          `db:"hostid"`
+	AgentID  sql.NullString `db:"agent_id"`
+	HostName string         `db:"hostname"`
+	HostIP   string         `db:"hostip"`
+	Owner    sql.NullString `db:"hostowner"`
+	Status   int            `db:"hoststatus"`
+}
+
+// AlarmStatistic 报警记录，对应 alarmstatistics 表
+type AlarmStatistic struct {
+	ID             int64      `json:"id" db:"id"`
+	AlarmID        int64      `json:"alarmid" db:"alarmid"`
+	HostID         int64      `json:"hostid" db:"hostid"`
+	RuleID         int64      `json:"ruleid" db:"ruleid"`
+	AlarmLabel     string     `json:"alarmlabel" db:"alarmlabel"`
+	AlarmStatus    int        `json:"alarmstatus" db:"alarmstatus"`
+	AlarmType      int        `json:"alarmtype" db:"alarmtype"`
+	AlarmInfo      string     `json:"alarminfo" db:"alarminfo"`
+	AlarmNote      string     `json:"alarmnote" db:"alarmnote"`
+	AlarmStartTime time.Time  `json:"alarmstarttime" db:"alarmstarttime"`
+	AlarmStopTime  *time.Time `json:"alarmstoptime" db:"alarmstoptime"`
+}
+
+// AlarmEventQuery 报警记录查询参数
+type AlarmEventQuery struct {
+	HostID  int64   `form:"hostid"`
+	Status  *int    `form:"status"`
+	Limit   int     `form:"limit"`
+	HostIDs []int64 `form:"-"` // 非管理员可访问的主机，为 nil 时不限制
+}
+
+// AlarmEvent 报警触发或恢复时用于渲染通知模板的数据
+type AlarmEvent struct {
+	Status    string // firing 或 resolved
+	RuleID    int64
+	HostName  string
+	AgentID   string
+	ClientIP  string
+	Owner     string
+	Metric    string
+	Label     string
+	Operator  string
+	Threshold float64
+	Value     float64
+	Duration  int // 规则要求的持续分钟数
+	Note      string
+	StartTime time.Time
+	EndTime   time.Time
+	Elapsed   time.Duration // 报警持续时间，恢复时有效
+}
@@ -0,0 +1,62 @@
+package audittype
+
+import "time"
+
+// 审计动作，按 对象.操作 命名
+const (
+	ActionLogin            = "login"
+	ActionTask             = "task" // 任务管理接口，记录时按请求的 option 细化为 task.create、task.stop 等
+	ActionTaskControl      = "task.control"
+	ActionFileUpload       = "file.upload"
+	ActionHostUpdate       = "host.update"
+	ActionHostTags         = "host.tags"
+	ActionHostDecommission = "host.decommission"
+	ActionAlarmRuleSave    = "alarm_rule.save"
+	ActionAlarmRuleDelete  = "alarm_rule.delete"
+	ActionCronJobSave      = "cron_job.save"
+	ActionCronJobDelete    = "cron_job.delete"
+	ActionCronJobKill      = "cron_job.kill"
+	ActionUserCreate       = "user.create"
+	ActionUserUpdate       = "user.update"
+	ActionUserGroups       = "user.groups"
+	ActionGroupCreate      = "group.create"
+	ActionGroupDelete      = "group.delete"
+	ActionGroupHosts       = "group.hosts"
+	ActionEnrollTokenAdd   = "enroll_token.create"
+	ActionEnrollTokenDel   = "enroll_token.revoke"
+	ActionCredentialRevoke = "credential.revoke"
+	ActionCredentialReset  = "credential.reset"
+	ActionCertRotate       = "certificate.rotate"
+	ActionCertRevoke       = "certificate.revoke"
+)
+
+// 操作结果
+const (
+	ResultSuccess = "success"
+	ResultFailed  = "failed"
+)
+
+// AuditLog 一条管理操作记录，对应 audit_log 表
+type AuditLog struct {
+	ID         int64     `json:"id" db:"id"`
+	UserID     *int64    `json:"user_id" db:"user_id"` // 客户端上传等非用户操作为空
+	Username   string    `json:"username" db:"username"`
+	ClientIP   string    `json:"client_ip" db:"client_ip"`
+	Action     string    `json:"action" db:"action"`
+	Target     string    `json:"target" db:"target"`
+	Payload    string    `json:"payload" db:"payload"` // 请求内容摘要，敏感字段已隐去
+	Result     string    `json:"result" db:"result"`
+	Detail     string    `json:"detail" db:"detail"` // 失败原因
+	CreateTime time.Time `json:"create_time" db:"create_time"`
+}
+
+// AuditQuery 审计日志查询参数
+type AuditQuery struct {
+	UserID   int64     `form:"user_id"`
+	Username string    `form:"username"`
+	Action   string    `form:"action"` // 以 . 结尾时按前缀匹配，如 task.
+	Start    time.Time `form:"start" time_format:"2006-01-02 15:04:05"`
+	End      time.Time `form:"end" time_format:"2006-01-02 15:04:05"`
+	Limit    int       `form:"limit"`
+	Offset   int       `form:"offset"`
+}
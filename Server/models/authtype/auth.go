@@ -0,0 +1,89 @@
+package authtype
+
+import "time"
+
+// 角色，权限依次递增
+const (
+	RoleViewer   = "viewer"   // 只读
+	RoleOperator = "operator" // 可下发任务、修改主机
+	RoleAdmin    = "admin"    // 可管理用户、主机组和客户端注册，不受主机组范围限制
+)
+
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// RoleAllows 判断角色 role 是否具备 required 角色的权限，未知角色没有任何权限
+func RoleAllows(role, required string) bool {
+	level, ok := roleLevels[role]
+	return ok && level >= roleLevels[required]
+}
+
+// 用户状态
+const (
+	UserDisabled = 0
+	UserActive   = 1
+)
+
+// UserAccount 管理后台用户，对应 user 表
+type UserAccount struct {
+	ID         int64     `json:"-" db:"id"`
+	UserID     int64     `json:"user_id" db:"user_id"`
+	Username   string    `json:"username" db:"username"`
+	Password   string    `json:"-" db:"password"`
+	Email      *string   `json:"email" db:"email"`
+	Role       string    `json:"role" db:"role"`
+	Status     int       `json:"status" db:"status"`
+	CreateTime time.Time `json:"create_time" db:"create_time"`
+	GroupIDs   []int64   `json:"group_ids" db:"-"`
+}
+
+// NewUser 创建用户的参数
+type NewUser struct {
+	Username string `json:"username" binding:"required,max=64"`
+	Password string `json:"password" binding:"required,min=8"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	Role     string `json:"role" binding:"required,oneof=viewer operator admin"`
+}
+
+// UserUpdate 可修改的用户字段，未传的字段保持不变
+type UserUpdate struct {
+	Password *string `json:"password" binding:"omitempty,min=8"`
+	Role     *string `json:"role" binding:"omitempty,oneof=viewer operator admin"`
+	Status   *int    `json:"status" binding:"omitempty,oneof=0 1"`
+}
+
+// LoginResult 登录成功后返回的令牌
+type LoginResult struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+}
+
+// HostGroup 主机组，对应 host_groups 表，operator 和 viewer 只能访问所属主机组内的主机
+type HostGroup struct {
+	ID          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	CreateTime  time.Time `json:"create_time" db:"create_time"`
+	HostIDs     []int64   `json:"hostids" db:"-"`
+}
+
+// NewHostGroup 创建主机组的参数
+type NewHostGroup struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description" binding:"max=255"`
+}
+
+// GroupHosts 替换主机组成员
+type GroupHosts struct {
+	HostIDs []int64 `json:"hostids"`
+}
+
+// UserGroups 替换用户所属主机组
+type UserGroups struct {
+	GroupIDs []int64 `json:"group_ids"`
+}
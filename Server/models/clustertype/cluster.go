@@ -0,0 +1,36 @@
+package clustertype
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ServerDir      = "/servers/"         // /servers/<instance> 在线的服务端实例，绑定实例会话租约
+	InboxDir       = "/inbox/"           // /inbox/<instance>/<id> 转发给该实例处理的消息
+	LeaderElection = "/election/server/" // 单例任务的 leader 选举前缀
+	NodeDir        = "/snowflake/nodes/" // /snowflake/nodes/<n> 实例占用的 snowflake 节点号，绑定实例会话租约
+)
+
+const (
+	EnvelopeSend       = "send"       // 发送给指定客户端
+	EnvelopeDisconnect = "disconnect" // 断开指定客户端
+)
+
+// ServerInfo 服务端实例信息，以 JSON 存储在 /servers/<instance>
+type ServerInfo struct {
+	ID        string    `json:"id"`
+	NodeID    int64     `json:"node_id"` // 生成任务、执行和消息 ID 使用的 snowflake 节点号
+	StartedAt time.Time `json:"started_at"`
+	Leader    bool      `json:"leader"`
+}
+
+// Envelope 转发给客户端所在实例的消息
+type Envelope struct {
+	Kind    string          `json:"kind"`
+	AgentID string          `json:"agent_id,omitempty"`
+	Message json.RawMessage `json:"message,omitempty"` // 原样转发给客户端，避免数字经 float64 往返丢失精度
+	Reason  string          `json:"reason,omitempty"`  // 断开连接的原因
+	From    string          `json:"from"`
+	Time    time.Time       `json:"time"`
+}
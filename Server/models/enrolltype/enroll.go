@@ -0,0 +1,48 @@
+package enrolltype
+
+import "time"
+
+// EnrollmentToken 客户端注册令牌，对应 enrollment_tokens 表，只保存令牌的哈希
+type EnrollmentToken struct {
+	ID        int64      `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	MaxUses   int        `json:"max_uses" db:"max_uses"` // 0 表示不限次数
+	UsedCount int        `json:"used_count" db:"used_count"`
+	ExpiresAt *time.Time `json:"expires_at" db:"expires_at"`
+	Revoked   bool       `json:"revoked" db:"revoked"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// NewEnrollmentToken 创建注册令牌的参数
+type NewEnrollmentToken struct {
+	Name     string `json:"name" binding:"required,max=255"`
+	MaxUses  int    `json:"max_uses" binding:"gte=0"`  // 1 为一次性令牌，0 不限次数
+	TTLHours int    `json:"ttl_hours" binding:"gte=0"` // 有效小时数，0 表示不过期
+}
+
+// IssuedEnrollmentToken 创建成功后返回的令牌，明文只在此时返回一次
+type IssuedEnrollmentToken struct {
+	EnrollmentToken
+	Token string `json:"token"`
+}
+
+// AgentCredential 客户端注册后获得的长期凭证，对应 agent_credentials 表
+type AgentCredential struct {
+	AgentID        string     `json:"agent_id" db:"agent_id"`
+	CredentialHash string     `json:"-" db:"credential_hash"`
+	TokenID        int64      `json:"enrollment_token_id" db:"enrollment_token_id"`
+	ClientIP       string     `json:"client_ip" db:"client_ip"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt      *time.Time `json:"revoked_at" db:"revoked_at"`
+}
+
+// AgentCertificate 内置 CA 为客户端签发的证书，对应 agent_certificates 表
+type AgentCertificate struct {
+	Serial    string     `json:"serial" db:"serial"`
+	AgentID   string     `json:"agent_id" db:"agent_id"`
+	NotBefore time.Time  `json:"not_before" db:"not_before"`
+	NotAfter  time.Time  `json:"not_after" db:"not_after"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at" db:"revoked_at"`
+}
@@ -0,0 +1,90 @@
+package hosttype
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 主机状态
+const (
+	HostDecommissioned = 0
+	HostActive         = 1
+)
+
+// HostFacts 客户端注册时上报的主机信息
+type HostFacts struct {
+	AgentID         string    `json:"agent_id"`
+	ClientIP        string    `json:"client_ip"`
+	HostName        string    `json:"hostname"`
+	OS              string    `json:"os"`
+	Platform        string    `json:"platform"`
+	PlatformVersion string    `json:"platform_version"`
+	KernelVersion   string    `json:"kernel_version"`
+	KernelArch      string    `json:"kernel_arch"`
+	CPUModel        string    `json:"cpu_model"`
+	CPUCores        int       `json:"cpu_cores"`
+	MemTotal        uint64    `json:"mem_total"`
+	BootTime        time.Time `json:"boot_time"`
+	Uptime          uint64    `json:"uptime"` // 秒
+	AgentVersion    string    `json:"agent_version"`
+}
+
+// Host 主机清单记录，对应 hostlist 表；在线状态来自 etcd 中的 /agents/online/，不写入清单
+type Host struct {
+	ID             int64          `json:"-" db:"id"`
+	HostID         int64          `json:"hostid" db:"hostid"`
+	AgentID        sql.NullString `json:"-" db:"agent_id"`
+	HostName       string         `json:"hostname" db:"hostname"`
+	SystemType     sql.NullString `json:"-" db:"systemtype"`
+	HostStatus     int            `json:"hoststatus" db:"hoststatus"`
+	HostIP         string         `json:"hostip" db:"hostip"`
+	HostLocation   sql.NullString `json:"-" db:"hostlocation"`
+	HostOwner      sql.NullString `json:"-" db:"hostowner"`
+	HostAddTime    time.Time      `json:"hostaddtime" db:"hostaddtime"`
+	HostNote       sql.NullString `json:"-" db:"hostnote"`
+	HostSystemInfo sql.NullString `json:"-" db:"hostsysteminfo"`
+	HostUptime     sql.NullString `json:"-" db:"hostuptime"`
+}
+
+// HostView 返回给前端的主机信息
+type HostView struct {
+	HostID       int64      `json:"hostid"`
+	AgentID      string     `json:"agent_id"`
+	HostName     string     `json:"hostname"`
+	SystemType   string     `json:"systemtype"`
+	HostStatus   int        `json:"hoststatus"`
+	HostIP       string     `json:"hostip"`
+	HostLocation string     `json:"hostlocation"`
+	HostOwner    string     `json:"hostowner"`
+	HostNote     string     `json:"hostnote"`
+	HostUptime   string     `json:"hostuptime"`
+	HostAddTime  time.Time  `json:"hostaddtime"`
+	Facts        *HostFacts `json:"facts,omitempty"`
+	Tags         []string   `json:"tags"`
+	Online       bool       `json:"online"`
+	ConnectedAt  *time.Time `json:"connected_at,omitempty"`
+	Server       string     `json:"server,omitempty"` // 客户端当前连接的服务端实例
+}
+
+// HostQuery 主机列表查询参数
+type HostQuery struct {
+	Status  *int   `form:"status"`
+	Tag     string `form:"tag"`
+	Keyword string `form:"keyword"` // 匹配主机名或 IP
+	Online  *bool  `form:"online"`
+	// ScopeUserID 非空时只返回该用户所属主机组内的主机，由服务端按登录用户填写
+	ScopeUserID *int64 `form:"-"`
+}
+
+// HostUpdate 可编辑的主机字段，未传的字段保持不变
+type HostUpdate struct {
+	HostLocation *string `json:"hostlocation"`
+	HostOwner    *string `json:"hostowner"`
+	HostNote     *string `json:"hostnote"`
+	HostStatus   *int    `json:"hoststatus" binding:"omitempty,oneof=0 1"`
+}
+
+// HostTags 替换主机标签
+type HostTags struct {
+	Tags []string `json:"tags" binding:"dive,required,max=64"`
+}
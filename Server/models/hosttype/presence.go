@@ -0,0 +1,28 @@
+package hosttype
+
+import "time"
+
+// OnlineDir 在线客户端的 key 前缀，/agents/online/<agent_id> 绑定在心跳续约的租约上，
+// 客户端停止心跳后随租约过期被 etcd 删除
+const OnlineDir = "/agents/online/"
+
+const (
+	PresenceJoin  = 1 // 客户端上线或连接到了新的服务端实例
+	PresenceLeave = 2 // 租约过期，客户端离线
+)
+
+// Presence 在线客户端信息，以 JSON 存储在 /agents/online/<agent_id>
+type Presence struct {
+	AgentID     string    `json:"agent_id"`
+	ClientIP    string    `json:"client_ip"`
+	Server      string    `json:"server"` // 客户端连接的服务端实例
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// PresenceEvent 在线状态变化，离线事件的 Presence 为离线前最后一次登记的信息
+type PresenceEvent struct {
+	Type     int
+	AgentID  string
+	Presence *Presence
+	Time     time.Time
+}
@@ -0,0 +1,118 @@
+package metrictype
+
+import (
+	"fmt"
+	"time"
+)
+
+type MemoryData struct {
+	Total       float64 `json:"total"`
+	Used        float64 `json:"used"`
+	Free        float64 `json:"free"`
+	UsedPercent float64 `json:"usedPercent"`
+}
+type CPUData struct {
+	PhysicalID string  `json:"physical_id"`
+	Cores      int32   `json:"cores"`
+	Mhz        float64 `json:"mhz"`
+	Usage      float64 `json:"usage"`
+}
+type DiskData struct {
+	Mountpoint  string  `json:"mountpoint"`
+	Total       float64 `json:"total"`        // 总大小，单位字节
+	Used        float64 `json:"used"`         // 已使用，单位字节
+	Free        float64 `json:"free"`         // 可用，单位字节
+	UsedPercent float64 `json:"used_percent"` // 使用率，百分比
+}
+type NetworkData struct {
+	Name        string  `json:"name"`
+	BytesSent   float64 `json:"bytesSent"`
+	BytesRecv   float64 `json:"bytesRecv"`
+	PacketsSent float64 `json:"packetsSent"`
+	PacketsRecv float64 `json:"packetsRecv"`
+}
+type MonitorData struct {
+	Memory  MemoryData    `json:"memory"`
+	Network []NetworkData `json:"network"`
+	CPU     []CPUData     `json:"cpu"`
+	Disk    []DiskData    `json:"disk"`
+}
+
+// MetricsReport 客户端定时上报的主机指标
+type MetricsReport struct {
+	AgentID   string      `json:"agent_id"`
+	ClientIP  string      `json:"client_ip"`
+	HostName  string      `json:"hostname"`
+	Timestamp time.Time   `json:"timestamp"`
+	Metrics   MonitorData `json:"metrics"`
+}
+
+// MetricSample 单条指标样本，对应 host_metrics 表，按客户端 ID 区分主机
+type MetricSample struct {
+	AgentID     string    `json:"agent_id" db:"agent_id"`
+	ClientIP    string    `json:"client_ip" db:"client_ip"`
+	Metric      string    `json:"metric" db:"metric"`
+	Label       string    `json:"label" db:"label"`
+	Value       float64   `json:"value" db:"value"`
+	CollectTime time.Time `json:"collect_time" db:"collect_time"`
+}
+
+// 指标名称
+const (
+	MetricCPUUsage        = "cpu.usage"
+	MetricMemUsedPercent  = "mem.used_percent"
+	MetricMemUsed         = "mem.used"
+	MetricMemTotal        = "mem.total"
+	MetricDiskUsedPercent = "disk.used_percent"
+	MetricDiskUsed        = "disk.used"
+	MetricDiskFree        = "disk.free"
+	MetricNetBytesSent    = "net.bytes_sent"
+	MetricNetBytesRecv    = "net.bytes_recv"
+	MetricNetPacketsSent  = "net.packets_sent"
+	MetricNetPacketsRecv  = "net.packets_recv"
+)
+
+// Samples 将上报数据展开为按 指标名+标签 区分的样本
+func (r *MetricsReport) Samples() []MetricSample {
+	var samples []MetricSample
+	add := func(metric, label string, value float64) {
+		samples = append(samples, MetricSample{
+			AgentID:     r.AgentID,
+			ClientIP:    r.ClientIP,
+			Metric:      metric,
+			Label:       label,
+			Value:       value,
+			CollectTime: r.Timestamp,
+		})
+	}
+
+	if len(r.Metrics.CPU) > 0 {
+		var total float64
+		for i, c := range r.Metrics.CPU {
+			add(MetricCPUUsage, fmt.Sprintf("cpu%d", i), c.Usage)
+			total += c.Usage
+		}
+		add(MetricCPUUsage, "total", total/float64(len(r.Metrics.CPU)))
+	}
+
+	if r.Metrics.Memory.Total > 0 {
+		add(MetricMemUsedPercent, "", r.Metrics.Memory.UsedPercent)
+		add(MetricMemUsed, "", r.Metrics.Memory.Used)
+		add(MetricMemTotal, "", r.Metrics.Memory.Total)
+	}
+
+	for _, d := range r.Metrics.Disk {
+		add(MetricDiskUsedPercent, d.Mountpoint, d.UsedPercent)
+		add(MetricDiskUsed, d.Mountpoint, d.Used)
+		add(MetricDiskFree, d.Mountpoint, d.Free)
+	}
+
+	for _, n := range r.Metrics.Network {
+		add(MetricNetBytesSent, n.Name, n.BytesSent)
+		add(MetricNetBytesRecv, n.Name, n.BytesRecv)
+		add(MetricNetPacketsSent, n.Name, n.PacketsSent)
+		add(MetricNetPacketsRecv, n.Name, n.PacketsRecv)
+	}
+
+	return samples
+}
@@ -0,0 +1,70 @@
+package metrictype
+
+import "time"
+
+// 查询精度
+const (
+	ResolutionRaw = "raw"
+	Resolution1m  = "1m"
+	Resolution1h  = "1h"
+)
+
+// MetricRollup 汇总表中的一个时间桶，对应 host_metrics_1m / host_metrics_1h 表
+type MetricRollup struct {
+	AgentID     string    `json:"agent_id" db:"agent_id"`
+	ClientIP    string    `json:"client_ip" db:"client_ip"`
+	Metric      string    `json:"metric" db:"metric"`
+	Label       string    `json:"label" db:"label"`
+	BucketTime  time.Time `json:"bucket_time" db:"bucket_time"`
+	AvgValue    float64   `json:"avg_value" db:"avg_value"`
+	MaxValue    float64   `json:"max_value" db:"max_value"`
+	MinValue    float64   `json:"min_value" db:"min_value"`
+	P95Value    float64   `json:"p95_value" db:"p95_value"`
+	SampleCount int64     `json:"sample_count" db:"sample_count"`
+}
+
+// MetricQuery 指标查询参数，时间格式为 2006-01-02 15:04:05
+type MetricQuery struct {
+	AgentID    string    `form:"agent_id" binding:"required"`
+	Metric     string    `form:"metric" binding:"required"`
+	Label      string    `form:"label"`
+	Start      time.Time `form:"start" time_format:"2006-01-02 15:04:05"`
+	End        time.Time `form:"end" time_format:"2006-01-02 15:04:05"`
+	Resolution string    `form:"resolution" binding:"omitempty,oneof=raw 1m 1h"`
+}
+
+// MetricPoint 时间序列上的一个点，原始数据时 Avg/Max/Min 相同
+type MetricPoint struct {
+	Time  time.Time `json:"time"`
+	Avg   float64   `json:"avg"`
+	Max   float64   `json:"max"`
+	Min   float64   `json:"min"`
+	P95   float64   `json:"p95"`
+	Count int64     `json:"count"`
+}
+
+// MetricSeries 同一指标同一标签的时间序列
+type MetricSeries struct {
+	Label  string        `json:"label"`
+	Points []MetricPoint `json:"points"`
+}
+
+// MetricRangeResult 区间查询结果
+type MetricRangeResult struct {
+	AgentID    string         `json:"agent_id"`
+	Metric     string         `json:"metric"`
+	Resolution string         `json:"resolution"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	Series     []MetricSeries `json:"series"`
+}
+
+// MetricAggregate 某一标签在查询区间内的聚合值
+type MetricAggregate struct {
+	Label string  `json:"label"`
+	Avg   float64 `json:"avg"`
+	Max   float64 `json:"max"`
+	Min   float64 `json:"min"`
+	P95   float64 `json:"p95"`
+	Count int64   `json:"count"`
+}
@@ -34,7 +34,7 @@ type ClientParame struct {
 
 type LoginUserinfo struct {
 	UserName string `json:"userName" binding:"required"`
-	UserCode int    `json:"UserCode"`
+	Password string `json:"password" binding:"required"`
 }
 
 type NotiAPI struct {
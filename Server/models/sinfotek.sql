@@ -17,6 +17,85 @@
 SET NAMES utf8mb4;
 SET FOREIGN_KEY_CHECKS = 0;
 
+-- ----------------------------
+-- Table structure for agent_certificates
+-- ----------------------------
+DROP TABLE IF EXISTS `agent_certificates`;
+CREATE TABLE `agent_certificates`  (
+  `serial` varchar(40) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '证书序列号(十六进制)',
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '客户端ID',
+  `not_before` timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '生效时间',
+  `not_after` timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '到期时间',
+  `created_at` timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '签发时间',
+  `revoked_at` timestamp(0) NULL DEFAULT NULL COMMENT '吊销时间',
+  PRIMARY KEY (`serial`) USING BTREE,
+  INDEX `idx_agent_id`(`agent_id`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for agent_command_seqs
+-- ----------------------------
+DROP TABLE IF EXISTS `agent_command_seqs`;
+CREATE TABLE `agent_command_seqs`  (
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '客户端ID',
+  `seq` bigint(20) NOT NULL DEFAULT 0 COMMENT '最近分配的指令序号',
+  PRIMARY KEY (`agent_id`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for agent_commands
+-- ----------------------------
+DROP TABLE IF EXISTS `agent_commands`;
+CREATE TABLE `agent_commands`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '客户端ID',
+  `seq` bigint(20) NOT NULL COMMENT '客户端内从 1 开始递增的指令序号',
+  `task_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '任务ID',
+  `action` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT 'add/stop/kill/update/delete',
+  `payload` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '发送给客户端的完整消息',
+  `status` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT 'pending' COMMENT 'pending/applied/failed',
+  `error` varchar(1024) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '客户端应用失败的原因',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '下发时间',
+  `acked_at` datetime NULL DEFAULT NULL COMMENT '客户端确认时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_agent_seq`(`agent_id`, `seq`) USING BTREE,
+  INDEX `idx_agent_status`(`agent_id`, `status`, `seq`) USING BTREE,
+  INDEX `idx_agent_task`(`agent_id`, `task_id`, `seq`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for agent_credentials
+-- ----------------------------
+DROP TABLE IF EXISTS `agent_credentials`;
+CREATE TABLE `agent_credentials`  (
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '客户端ID',
+  `credential_hash` char(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '长期凭证SHA-256',
+  `enrollment_token_id` bigint(20) NOT NULL COMMENT '注册时使用的令牌',
+  `client_ip` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '注册时的客户端IP',
+  `created_at` timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '签发时间',
+  `revoked_at` timestamp(0) NULL DEFAULT NULL COMMENT '吊销时间',
+  PRIMARY KEY (`agent_id`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for alarm_rules
+-- ----------------------------
+DROP TABLE IF EXISTS `alarm_rules`;
+CREATE TABLE `alarm_rules`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `hostid` bigint(20) NOT NULL DEFAULT 0 COMMENT '主机id，0 表示所有主机',
+  `metric` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '指标名称',
+  `label` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT '' COMMENT '指标标签，为空时匹配所有标签',
+  `operator` varchar(4) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '比较符',
+  `threshold` double NOT NULL COMMENT '阈值',
+  `duration` int(11) NOT NULL DEFAULT 0 COMMENT '持续分钟数',
+  `alarmtype` int(11) NOT NULL DEFAULT 1001 COMMENT '报警类型',
+  `enabled` tinyint(1) NOT NULL DEFAULT 1 COMMENT '是否启用',
+  `note` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT '' COMMENT '备注',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_metric`(`metric`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for alarmsetting
 -- ----------------------------
@@ -45,6 +124,8 @@ CREATE TABLE `alarmstatistics`  (
   `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '序号',
   `alarmid` bigint(20) NULL DEFAULT NULL COMMENT '报警id',
   `hostid` bigint(20) NOT NULL COMMENT '主机id',
+  `ruleid` bigint(20) NULL DEFAULT NULL COMMENT '触发的报警规则id',
+  `alarmlabel` varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL DEFAULT NULL COMMENT '指标标签',
   `alarmstatus` int(10) NULL DEFAULT NULL COMMENT '报警状态',
   `alarmtype` int(20) NULL DEFAULT NULL COMMENT '报警类型',
   `alarminfo` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '报警信息',
@@ -55,6 +136,7 @@ CREATE TABLE `alarmstatistics`  (
   UNIQUE INDEX `idx_id`(`id`) USING BTREE,
   UNIQUE INDEX `idx_alarmid`(`alarmid`) USING BTREE,
   INDEX `fx_alarmstatistics`(`hostid`) USING BTREE,
+  INDEX `idx_alarmstatus`(`alarmstatus`) USING BTREE,
   CONSTRAINT `fx_alarmstatistics` FOREIGN KEY (`hostid`) REFERENCES `hostlist` (`hostid`) ON DELETE CASCADE ON UPDATE NO ACTION
 ) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin ROW_FORMAT = Dynamic;
 
@@ -74,9 +156,49 @@ CREATE TABLE `alarmtype`  (
 -- ----------------------------
 INSERT INTO `alarmtype` VALUES (1000, '应用服务问题', '警告');
 INSERT INTO `alarmtype` VALUES (1001, '系统问题', '一般');
+INSERT INTO `alarmtype` VALUES (1002, '任务执行问题', '一般');
+INSERT INTO `alarmtype` VALUES (1003, '主机离线', '严重');
 INSERT INTO `alarmtype` VALUES (1004, '网络问题', '严重');
 INSERT INTO `alarmtype` VALUES (1006, '硬件问题', '故障');
 
+-- ----------------------------
+-- Table structure for audit_log
+-- ----------------------------
+DROP TABLE IF EXISTS `audit_log`;
+CREATE TABLE `audit_log`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `user_id` bigint(20) NULL DEFAULT NULL COMMENT '操作用户，客户端操作为空',
+  `username` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `client_ip` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `action` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `target` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `payload` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '请求摘要，敏感字段已隐去',
+  `result` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `detail` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `create_time` timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_create_time`(`create_time`) USING BTREE,
+  INDEX `idx_user_time`(`user_id`, `create_time`) USING BTREE,
+  INDEX `idx_action_time`(`action`, `create_time`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for enrollment_tokens
+-- ----------------------------
+DROP TABLE IF EXISTS `enrollment_tokens`;
+CREATE TABLE `enrollment_tokens`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '令牌序号',
+  `name` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '令牌名称',
+  `token_hash` char(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '令牌SHA-256',
+  `max_uses` int(10) NOT NULL DEFAULT 1 COMMENT '最大使用次数，0不限',
+  `used_count` int(10) NOT NULL DEFAULT 0 COMMENT '已使用次数',
+  `expires_at` timestamp(0) NULL DEFAULT NULL COMMENT '过期时间',
+  `revoked` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否吊销',
+  `created_at` timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_token_hash`(`token_hash`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for filedata
 -- ----------------------------
@@ -105,6 +227,102 @@ CREATE TABLE `filelog`  (
   PRIMARY KEY (`id`) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 28 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin ROW_FORMAT = Dynamic;
 
+-- ----------------------------
+-- Table structure for host_group_members
+-- ----------------------------
+DROP TABLE IF EXISTS `host_group_members`;
+CREATE TABLE `host_group_members`  (
+  `group_id` bigint(20) NOT NULL,
+  `hostid` bigint(20) NOT NULL,
+  PRIMARY KEY (`group_id`, `hostid`) USING BTREE,
+  INDEX `idx_hostid`(`hostid`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for host_groups
+-- ----------------------------
+DROP TABLE IF EXISTS `host_groups`;
+CREATE TABLE `host_groups`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `description` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `create_time` timestamp(0) NULL DEFAULT CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for host_metrics
+-- ----------------------------
+DROP TABLE IF EXISTS `host_metrics`;
+CREATE TABLE `host_metrics`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '客户端ID',
+  `client_ip` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '上报时的客户端IP',
+  `metric` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '指标名称',
+  `label` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT '' COMMENT '指标标签(挂载点/网卡等)',
+  `value` double NOT NULL COMMENT '指标值',
+  `collect_time` timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) COMMENT '采集时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_host_metric_time`(`agent_id`, `metric`, `collect_time`) USING BTREE,
+  INDEX `idx_collect_time`(`collect_time`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for host_metrics_1h
+-- ----------------------------
+DROP TABLE IF EXISTS `host_metrics_1h`;
+CREATE TABLE `host_metrics_1h`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '客户端ID',
+  `client_ip` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '时间桶内最近上报的客户端IP',
+  `metric` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '指标名称',
+  `label` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT '' COMMENT '指标标签',
+  `bucket_time` timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) COMMENT '时间桶起点',
+  `avg_value` double NOT NULL COMMENT '平均值',
+  `max_value` double NOT NULL COMMENT '最大值',
+  `min_value` double NOT NULL COMMENT '最小值',
+  `p95_value` double NOT NULL COMMENT '95分位',
+  `sample_count` bigint(20) NOT NULL COMMENT '样本数',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_bucket`(`agent_id`, `metric`, `label`, `bucket_time`) USING BTREE,
+  INDEX `idx_bucket_time`(`bucket_time`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for host_metrics_1m
+-- ----------------------------
+DROP TABLE IF EXISTS `host_metrics_1m`;
+CREATE TABLE `host_metrics_1m`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '客户端ID',
+  `client_ip` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '时间桶内最近上报的客户端IP',
+  `metric` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '指标名称',
+  `label` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT '' COMMENT '指标标签',
+  `bucket_time` timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) COMMENT '时间桶起点',
+  `avg_value` double NOT NULL COMMENT '平均值',
+  `max_value` double NOT NULL COMMENT '最大值',
+  `min_value` double NOT NULL COMMENT '最小值',
+  `p95_value` double NOT NULL COMMENT '95分位',
+  `sample_count` bigint(20) NOT NULL COMMENT '样本数',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_bucket`(`agent_id`, `metric`, `label`, `bucket_time`) USING BTREE,
+  INDEX `idx_bucket_time`(`bucket_time`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for host_tags
+-- ----------------------------
+DROP TABLE IF EXISTS `host_tags`;
+CREATE TABLE `host_tags`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `hostid` bigint(20) NOT NULL COMMENT '主机id',
+  `tag` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL COMMENT '标签',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_host_tag`(`hostid`, `tag`) USING BTREE,
+  INDEX `idx_tag`(`tag`) USING BTREE,
+  CONSTRAINT `fk_host_tags` FOREIGN KEY (`hostid`) REFERENCES `hostlist` (`hostid`) ON DELETE CASCADE ON UPDATE NO ACTION
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for hostdata
 -- ----------------------------
@@ -137,57 +355,62 @@ CREATE TABLE `hostlist`  (
   `hostnote` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '主机备注',
   `hostsysteminfo` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '主机信息',
   `hostuptime` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL DEFAULT NULL COMMENT '主机运行时间',
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '客户端ID',
   PRIMARY KEY (`id`) USING BTREE,
-  UNIQUE INDEX `idx_hostip`(`hostip`) USING BTREE,
-  UNIQUE INDEX `idx_hostname`(`hostname`) USING BTREE,
-  INDEX `hostid`(`hostid`) USING BTREE
+  UNIQUE INDEX `idx_agent_id`(`agent_id`) USING BTREE,
+  INDEX `idx_hostip`(`hostip`) USING BTREE,
+  INDEX `idx_hostname`(`hostname`) USING BTREE,
+  UNIQUE INDEX `hostid`(`hostid`) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 64 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin ROW_FORMAT = Dynamic;
 
 -- ----------------------------
 -- Records of hostlist
 -- ----------------------------
-INSERT INTO `hostlist` VALUES (1, 10086, 'sinfotek', 'windows', 1, '192.168.0.117', '北京', 'op1', '2023-05-05 14:19:21.000000', '测试12', '\r\n\r\nCaption           : Intel64 Family 6 Model 94 Stepping 3\r\nDeviceID          : CPU0\r\nManufacturer      : GenuineIntel\r\nMaxClockSpeed     : 3501\r\nName              : Intel(R) Core(TM) i5-6600K CPU @ 3.50GHz\r\nSocketDesignation : U3E1\r\n\r\n\r\n\r\n\r\n\r\nSMBIOSBIOSVersion : F2\r\nManufacturer      : American Megatrends Inc.\r\nName              : BIOS Date: 11/22/16 14:48:07 Ver: 05.0000C\r\nSerialNumber      : Default string\r\nVersion           : ALASKA - 1072009\r\n\r\n\r\n\r\n\r\n\r\n__GENUS              : 2\r\n__CLASS              : Win32_PhysicalMemory\r\n__SUPERCLASS         : CIM_PhysicalMemory\r\n__DYNASTY            : CIM_ManagedSystemElement\r\n__RELPATH            : Win32_PhysicalMemory.Tag=\"Physical Memory 1\"\r\n__PROPERTY_COUNT     : 36\r\n__DERIVATION         : {CIM_PhysicalMemory, CIM_Chip, CIM_PhysicalComponent, CIM_PhysicalElement...}\r\n__SERVER             : DESKTOP-48RD9C2\r\n__NAMESPACE          : root\\cimv2\r\n__PATH               : \\\\DESKTOP-48RD9C2\\root\\cimv2:Win32_PhysicalMemory.Tag=\"Physical Memory 1\"\r\nAttributes           : 1\r\nBankLabel            : BANK 1\r\nCapacity             : 8589934592\r\nCaption              : 物理内存\r\nConfiguredClockSpeed : 2133\r\nConfiguredVoltage    : 1200\r\nCreationClassName    : Win32_PhysicalMemory\r\nDataWidth            : 64\r\nDescription          : 物理内存\r\nDeviceLocator        : ChannelA-DIMM1\r\nFormFactor           : 8\r\nHotSwappable         : \r\nInstallDate          : \r\nInterleaveDataDepth  : 2\r\nInterleavePosition   : 1\r\nManufacturer         : Kingston\r\nMaxVoltage           : 1200\r\nMemoryType           : 0\r\nMinVoltage           : 1200\r\nModel                : \r\nName                 : 物理内存\r\nOtherIdentifyingInfo : \r\nPartNumber           : 9905678-012.A00G    \r\nPositionInRow        : \r\nPoweredOn            : \r\nRemovable            : \r\nReplaceable          : \r\nSerialNumber         : 04142A56\r\nSKU                  : \r\nSMBIOSMemoryType     : 26\r\nSpeed                : 2400\r\nStatus               : \r\nTag                  : Physical Memory 1\r\nTotalWidth           : 64\r\nTypeDetail           : 128\r\nVersion              : \r\nPSComputerName       : DESKTOP-48RD9C2\r\n\r\n__GENUS              : 2\r\n__CLASS              : Win32_PhysicalMemory\r\n__SUPERCLASS         : CIM_PhysicalMemory\r\n__DYNASTY            : CIM_ManagedSystemElement\r\n__RELPATH            : Win32_PhysicalMemory.Tag=\"Physical Memory 3\"\r\n__PROPERTY_COUNT     : 36\r\n__DERIVATION         : {CIM_PhysicalMemory, CIM_Chip, CIM_PhysicalComponent, CIM_PhysicalElement...}\r\n__SERVER             : DESKTOP-48RD9C2\r\n__NAMESPACE          : root\\cimv2\r\n__PATH               : \\\\DESKTOP-48RD9C2\\root\\cimv2:Win32_PhysicalMemory.Tag=\"Physical Memory 3\"\r\nAttributes           : 1\r\nBankLabel            : BANK 3\r\nCapacity             : 8589934592\r\nCaption              : 物理内存\r\nConfiguredClockSpeed : 2133\r\nConfiguredVoltage    : 1200\r\nCreationClassName    : Win32_PhysicalMemory\r\nDataWidth            : 64\r\nDescription          : 物理内存\r\nDeviceLocator        : ChannelB-DIMM1\r\nFormFactor           : 8\r\nHotSwappable         : \r\nInstallDate          : \r\nInterleaveDataDepth  : 2\r\nInterleavePosition   : 2\r\nManufacturer         : Kingston\r\nMaxVoltage           : 1200\r\nMemoryType           : 0\r\nMinVoltage           : 1200\r\nModel                : \r\nName                 : 物理内存\r\nOtherIdentifyingInfo : \r\nPartNumber           : 9905678-012.A00G    \r\nPositionInRow        : \r\nPoweredOn            : \r\nRemovable            : \r\nReplaceable          : \r\nSerialNumber         : 1E147F37\r\nSKU                  : \r\nSMBIOSMemoryType     : 26\r\nSpeed                : 2400\r\nStatus               : \r\nTag                  : Physical Memory 3\r\nTotalWidth           : 64\r\nTypeDetail           : 128\r\nVersion              : \r\nPSComputerName       : DESKTOP-48RD9C2\r\n\r\n\r\n\r\n\r\n\r\nManufacturer : Gigabyte Technology Co., Ltd.\r\nModel        : \r\nName         : 基板\r\nSerialNumber : Default string\r\nSKU          : \r\nProduct      : B250-HD3-CF\r\n\r\n\r\n\r\n\r\n\r\nSystemDirectory : C:\\WINDOWS\\system32\r\nOrganization    : P R C\r\nBuildNumber     : 19045\r\nRegisteredUser  : China\r\nSerialNumber    : 00391-80000-00001-AA710\r\nVersion         : 10.0.19045\r\n\r\n\r\n\r\n\r\n\r\nDomain              : WORKGROUP\r\nManufacturer        : Gigabyte Technology Co., Ltd.\r\nModel               : B250-HD3\r\nName                : DESKTOP-48RD9C2\r\nPrimaryOwnerName    : China\r\nTotalPhysicalMemory : 17135796224\r\n\r\n\r\n\r\n', '3天3夜');
+INSERT INTO `hostlist` VALUES (1, 10086, 'sinfotek', 'windows', 1, '192.168.0.117', '北京', 'op1', '2023-05-05 14:19:21.000000', '测试12', '\r\n\r\nCaption           : Intel64 Family 6 Model 94 Stepping 3\r\nDeviceID          : CPU0\r\nManufacturer      : GenuineIntel\r\nMaxClockSpeed     : 3501\r\nName              : Intel(R) Core(TM) i5-6600K CPU @ 3.50GHz\r\nSocketDesignation : U3E1\r\n\r\n\r\n\r\n\r\n\r\nSMBIOSBIOSVersion : F2\r\nManufacturer      : American Megatrends Inc.\r\nName              : BIOS Date: 11/22/16 14:48:07 Ver: 05.0000C\r\nSerialNumber      : Default string\r\nVersion           : ALASKA - 1072009\r\n\r\n\r\n\r\n\r\n\r\n__GENUS              : 2\r\n__CLASS              : Win32_PhysicalMemory\r\n__SUPERCLASS         : CIM_PhysicalMemory\r\n__DYNASTY            : CIM_ManagedSystemElement\r\n__RELPATH            : Win32_PhysicalMemory.Tag=\"Physical Memory 1\"\r\n__PROPERTY_COUNT     : 36\r\n__DERIVATION         : {CIM_PhysicalMemory, CIM_Chip, CIM_PhysicalComponent, CIM_PhysicalElement...}\r\n__SERVER             : DESKTOP-48RD9C2\r\n__NAMESPACE          : root\\cimv2\r\n__PATH               : \\\\DESKTOP-48RD9C2\\root\\cimv2:Win32_PhysicalMemory.Tag=\"Physical Memory 1\"\r\nAttributes           : 1\r\nBankLabel            : BANK 1\r\nCapacity             : 8589934592\r\nCaption              : 物理内存\r\nConfiguredClockSpeed : 2133\r\nConfiguredVoltage    : 1200\r\nCreationClassName    : Win32_PhysicalMemory\r\nDataWidth            : 64\r\nDescription          : 物理内存\r\nDeviceLocator        : ChannelA-DIMM1\r\nFormFactor           : 8\r\nHotSwappable         : \r\nInstallDate          : \r\nInterleaveDataDepth  : 2\r\nInterleavePosition   : 1\r\nManufacturer         : Kingston\r\nMaxVoltage           : 1200\r\nMemoryType           : 0\r\nMinVoltage           : 1200\r\nModel                : \r\nName                 : 物理内存\r\nOtherIdentifyingInfo : \r\nPartNumber           : 9905678-012.A00G    \r\nPositionInRow        : \r\nPoweredOn            : \r\nRemovable            : \r\nReplaceable          : \r\nSerialNumber         : 04142A56\r\nSKU                  : \r\nSMBIOSMemoryType     : 26\r\nSpeed                : 2400\r\nStatus               : \r\nTag                  : Physical Memory 1\r\nTotalWidth           : 64\r\nTypeDetail           : 128\r\nVersion              : \r\nPSComputerName       : DESKTOP-48RD9C2\r\n\r\n__GENUS              : 2\r\n__CLASS              : Win32_PhysicalMemory\r\n__SUPERCLASS         : CIM_PhysicalMemory\r\n__DYNASTY            : CIM_ManagedSystemElement\r\n__RELPATH            : Win32_PhysicalMemory.Tag=\"Physical Memory 3\"\r\n__PROPERTY_COUNT     : 36\r\n__DERIVATION         : {CIM_PhysicalMemory, CIM_Chip, CIM_PhysicalComponent, CIM_PhysicalElement...}\r\n__SERVER             : DESKTOP-48RD9C2\r\n__NAMESPACE          : root\\cimv2\r\n__PATH               : \\\\DESKTOP-48RD9C2\\root\\cimv2:Win32_PhysicalMemory.Tag=\"Physical Memory 3\"\r\nAttributes           : 1\r\nBankLabel            : BANK 3\r\nCapacity             : 8589934592\r\nCaption              : 物理内存\r\nConfiguredClockSpeed : 2133\r\nConfiguredVoltage    : 1200\r\nCreationClassName    : Win32_PhysicalMemory\r\nDataWidth            : 64\r\nDescription          : 物理内存\r\nDeviceLocator        : ChannelB-DIMM1\r\nFormFactor           : 8\r\nHotSwappable         : \r\nInstallDate          : \r\nInterleaveDataDepth  : 2\r\nInterleavePosition   : 2\r\nManufacturer         : Kingston\r\nMaxVoltage           : 1200\r\nMemoryType           : 0\r\nMinVoltage           : 1200\r\nModel                : \r\nName                 : 物理内存\r\nOtherIdentifyingInfo : \r\nPartNumber           : 9905678-012.A00G    \r\nPositionInRow        : \r\nPoweredOn            : \r\nRemovable            : \r\nReplaceable          : \r\nSerialNumber         : 1E147F37\r\nSKU                  : \r\nSMBIOSMemoryType     : 26\r\nSpeed                : 2400\r\nStatus               : \r\nTag                  : Physical Memory 3\r\nTotalWidth           : 64\r\nTypeDetail           : 128\r\nVersion              : \r\nPSComputerName       : DESKTOP-48RD9C2\r\n\r\n\r\n\r\n\r\n\r\nManufacturer : Gigabyte Technology Co., Ltd.\r\nModel        : \r\nName         : 基板\r\nSerialNumber : Default string\r\nSKU          : \r\nProduct      : B250-HD3-CF\r\n\r\n\r\n\r\n\r\n\r\nSystemDirectory : C:\\WINDOWS\\system32\r\nOrganization    : P R C\r\nBuildNumber     : 19045\r\nRegisteredUser  : China\r\nSerialNumber    : 00391-80000-00001-AA710\r\nVersion         : 10.0.19045\r\n\r\n\r\n\r\n\r\n\r\nDomain              : WORKGROUP\r\nManufacturer        : Gigabyte Technology Co., Ltd.\r\nModel               : B250-HD3\r\nName                : DESKTOP-48RD9C2\r\nPrimaryOwnerName    : China\r\nTotalPhysicalMemory : 17135796224\r\n\r\n\r\n\r\n', '3天3夜', NULL);
 
 -- ----------------------------
 -- Table structure for jobdata
 -- ----------------------------
 DROP TABLE IF EXISTS `jobdata`;
 CREATE TABLE `jobdata`  (
-  `jobname` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `jobname` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `jobplantime` timestamp(0) NULL DEFAULT NULL COMMENT '计划执行时间',
   `jobstarttime` timestamp(0) NULL DEFAULT NULL,
   `jobstoptime` timestamp(0) NULL DEFAULT NULL,
   `jobinfo` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
-  `jobrunning` bigint(20) NULL DEFAULT NULL,
-  `joberr` text CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL
+  `jobrunning` bigint(20) NULL DEFAULT NULL COMMENT '退出码',
+  `joberr` text CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL,
+  `jobworker` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '执行的服务端实例',
+  INDEX `idx_jobname_start`(`jobname`, `jobstarttime`) USING BTREE
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin ROW_FORMAT = Dynamic;
 
 -- ----------------------------
 -- Records of jobdata
 -- ----------------------------
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:54:30', '2023-05-29 16:54:30', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:00', '2023-05-29 16:55:01', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:25', '2023-05-29 16:55:25', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:30', '2023-05-29 16:55:30', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:35', '2023-05-29 16:55:35', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:40', '2023-05-29 16:55:40', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:45', '2023-05-29 16:55:45', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:50', '2023-05-29 16:55:50', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:55', '2023-05-29 16:55:55', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:00', '2023-05-29 16:56:00', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:05', '2023-05-29 16:56:05', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:10', '2023-05-29 16:56:10', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:20', '2023-05-29 16:56:20', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:30', '2023-05-29 16:56:30', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:40', '2023-05-29 16:56:40', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:50', '2023-05-29 16:56:50', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:00', '2023-05-29 16:57:00', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:10', '2023-05-29 16:57:10', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:20', '2023-05-29 16:57:20', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:30', '2023-05-29 16:57:30', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:40', '2023-05-29 16:57:40', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:50', '2023-05-29 16:57:50', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:58:00', '2023-05-29 16:58:00', 'hello\n', 0, '');
-INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:58:10', '2023-05-29 16:58:10', 'hello\n', 0, '');
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:54:30', '2023-05-29 16:54:30', '2023-05-29 16:54:30', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:00', '2023-05-29 16:55:00', '2023-05-29 16:55:01', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:25', '2023-05-29 16:55:25', '2023-05-29 16:55:25', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:30', '2023-05-29 16:55:30', '2023-05-29 16:55:30', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:35', '2023-05-29 16:55:35', '2023-05-29 16:55:35', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:40', '2023-05-29 16:55:40', '2023-05-29 16:55:40', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:45', '2023-05-29 16:55:45', '2023-05-29 16:55:45', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:50', '2023-05-29 16:55:50', '2023-05-29 16:55:50', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:55:55', '2023-05-29 16:55:55', '2023-05-29 16:55:55', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:00', '2023-05-29 16:56:00', '2023-05-29 16:56:00', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:05', '2023-05-29 16:56:05', '2023-05-29 16:56:05', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:10', '2023-05-29 16:56:10', '2023-05-29 16:56:10', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:20', '2023-05-29 16:56:20', '2023-05-29 16:56:20', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:30', '2023-05-29 16:56:30', '2023-05-29 16:56:30', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:40', '2023-05-29 16:56:40', '2023-05-29 16:56:40', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:56:50', '2023-05-29 16:56:50', '2023-05-29 16:56:50', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:00', '2023-05-29 16:57:00', '2023-05-29 16:57:00', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:10', '2023-05-29 16:57:10', '2023-05-29 16:57:10', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:20', '2023-05-29 16:57:20', '2023-05-29 16:57:20', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:30', '2023-05-29 16:57:30', '2023-05-29 16:57:30', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:40', '2023-05-29 16:57:40', '2023-05-29 16:57:40', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:57:50', '2023-05-29 16:57:50', '2023-05-29 16:57:50', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:58:00', '2023-05-29 16:58:00', '2023-05-29 16:58:00', 'hello\n', 0, '', NULL);
+INSERT INTO `jobdata` VALUES ('job1', '2023-05-29 16:58:10', '2023-05-29 16:58:10', '2023-05-29 16:58:10', 'hello\n', 0, '', NULL);
 
 -- ----------------------------
 -- Table structure for joblist
@@ -197,7 +420,7 @@ CREATE TABLE `joblist`  (
   `id` bigint(20) NOT NULL AUTO_INCREMENT,
   `jobid` bigint(20) NULL DEFAULT NULL COMMENT '任务ID\r\n',
   `jobname` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '任务名称',
-  `jobshell` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '任务shell',
+  `jobshell` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '任务shell',
   `jobstarttime` timestamp(0) NOT NULL COMMENT '任务添加时间',
   `jobstatus` int(10) NULL DEFAULT NULL COMMENT '任务状态',
   `jobcronexpr` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL DEFAULT NULL COMMENT 'cron表达式',
@@ -245,6 +468,115 @@ CREATE TABLE `systemlog`  (
   UNIQUE INDEX `idx_alarmid`(`systemlogid`) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_bin ROW_FORMAT = Dynamic;
 
+-- ----------------------------
+-- Table structure for task_output_lines
+-- ----------------------------
+DROP TABLE IF EXISTS `task_output_lines`;
+CREATE TABLE `task_output_lines`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `run_id` bigint(20) NOT NULL COMMENT '执行ID',
+  `seq` bigint(20) NOT NULL COMMENT '执行内的行序号',
+  `attempt` int(11) NOT NULL DEFAULT 1 COMMENT '尝试序号',
+  `stream` varchar(8) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT 'stdout/stderr',
+  `line` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '输出内容',
+  `output_time` datetime(3) NOT NULL COMMENT '输出时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_run_seq`(`run_id`, `seq`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for task_outputs
+-- ----------------------------
+DROP TABLE IF EXISTS `task_outputs`;
+CREATE TABLE `task_outputs`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `run_id` bigint(20) NOT NULL COMMENT '执行ID',
+  `task_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '任务ID',
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '客户端ID',
+  `file_path` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '存储目录下的 gzip 文件路径',
+  `size` bigint(20) NOT NULL DEFAULT 0 COMMENT '保存的原始字节数',
+  `stored_size` bigint(20) NOT NULL DEFAULT 0 COMMENT '压缩后字节数',
+  `truncated` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否超过大小限制被截断',
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '上传时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_run_id`(`run_id`) USING BTREE,
+  INDEX `idx_create_time`(`create_time`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for task_records
+-- ----------------------------
+DROP TABLE IF EXISTS `task_records`;
+CREATE TABLE `task_records`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '序号',
+  `task_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '任务ID',
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '客户端ID',
+  `client_ip` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '客户端IP',
+  `script_path` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '脚本路径',
+  `remarks` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '备注',
+  `crond_expression` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '定时表达式',
+  `status` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT 'pending' COMMENT '任务状态 pending/scheduled/running/succeeded/failed/timed_out/cancelled',
+  `file_id` bigint(20) NULL DEFAULT NULL COMMENT '任务文件ID',
+  `timeout` int(11) NOT NULL DEFAULT 0 COMMENT '执行超时秒数，0表示不限制',
+  `concurrency_policy` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT 'allow' COMMENT '并发策略 allow/skip/queue/replace',
+  `max_retries` int(11) NOT NULL DEFAULT 0 COMMENT '最大重试次数',
+  `retry_backoff` int(11) NOT NULL DEFAULT 0 COMMENT '首次重试等待秒数，之后翻倍',
+  `retry_on` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '需要重试的退出码，逗号分隔，为空时任何失败都重试',
+  `update_time` timestamp(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) COMMENT '状态更新时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_task_id`(`task_id`) USING BTREE,
+  INDEX `idx_agent_id`(`agent_id`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for task_run_attempts
+-- ----------------------------
+DROP TABLE IF EXISTS `task_run_attempts`;
+CREATE TABLE `task_run_attempts`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `run_id` bigint(20) NOT NULL COMMENT '执行ID',
+  `attempt` int(11) NOT NULL COMMENT '第几次尝试，从1开始',
+  `status` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '尝试结果',
+  `start_time` datetime(3) NOT NULL COMMENT '开始时间',
+  `end_time` datetime(3) NOT NULL COMMENT '结束时间',
+  `exit_code` int(11) NULL DEFAULT NULL COMMENT '退出码',
+  `term_signal` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '终止信号',
+  `stdout_bytes` bigint(20) NOT NULL DEFAULT 0 COMMENT '标准输出字节数',
+  `stderr_bytes` bigint(20) NOT NULL DEFAULT 0 COMMENT '标准错误字节数',
+  `timed_out` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否超时',
+  `duration_ms` bigint(20) NOT NULL DEFAULT 0 COMMENT '耗时毫秒',
+  `error` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '失败原因',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_run_attempt`(`run_id`, `attempt`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for task_runs
+-- ----------------------------
+DROP TABLE IF EXISTS `task_runs`;
+CREATE TABLE `task_runs`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `run_id` bigint(20) NOT NULL COMMENT '执行ID',
+  `task_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '任务ID',
+  `agent_id` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '客户端ID',
+  `status` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '执行状态',
+  `start_time` datetime(3) NOT NULL COMMENT '开始时间',
+  `end_time` datetime(3) NULL DEFAULT NULL COMMENT '结束时间',
+  `exit_code` int(11) NULL DEFAULT NULL COMMENT '退出码',
+  `term_signal` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '终止信号',
+  `stdout_bytes` bigint(20) NOT NULL DEFAULT 0 COMMENT '标准输出字节数',
+  `stderr_bytes` bigint(20) NOT NULL DEFAULT 0 COMMENT '标准错误字节数',
+  `timed_out` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否超时',
+  `duration_ms` bigint(20) NULL DEFAULT NULL COMMENT '耗时毫秒',
+  `attempts` int(11) NOT NULL DEFAULT 1 COMMENT '执行次数，含重试',
+  `error` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '失败原因',
+  `output_id` bigint(20) NULL DEFAULT NULL COMMENT '执行输出ID，关联 task_outputs',
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `idx_run_id`(`run_id`) USING BTREE,
+  INDEX `idx_task_start`(`task_id`, `start_time`) USING BTREE,
+  INDEX `idx_task_status`(`task_id`, `status`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for user
 -- ----------------------------
@@ -258,6 +590,8 @@ CREATE TABLE `user`  (
   `gender` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL DEFAULT '0',
   `create_time` timestamp(0) NULL DEFAULT CURRENT_TIMESTAMP(0),
   `update_time` timestamp(0) NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
+  `role` varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT 'viewer' COMMENT '角色 viewer/operator/admin',
+  `status` tinyint(1) NOT NULL DEFAULT 1 COMMENT '0 停用 1 启用',
   PRIMARY KEY (`id`) USING BTREE,
   UNIQUE INDEX `idx_user_id`(`user_id`) USING BTREE,
   UNIQUE INDEX `idx_username`(`username`) USING BTREE
@@ -266,6 +600,17 @@ CREATE TABLE `user`  (
 -- ----------------------------
 -- Records of user
 -- ----------------------------
-INSERT INTO `user` VALUES (2, 2994544563810471936, 'Mark', '73696e666f74656ba7df66ea7576ba297964104c965a0200', NULL, '0', '2023-04-17 16:51:00', '2023-04-17 16:51:00');
+INSERT INTO `user` VALUES (2, 2994544563810471936, 'Mark', '73696e666f74656ba7df66ea7576ba297964104c965a0200', NULL, '0', '2023-04-17 16:51:00', '2023-04-17 16:51:00', 'admin', 1);
+
+-- ----------------------------
+-- Table structure for user_host_groups
+-- ----------------------------
+DROP TABLE IF EXISTS `user_host_groups`;
+CREATE TABLE `user_host_groups`  (
+  `user_id` bigint(20) NOT NULL,
+  `group_id` bigint(20) NOT NULL,
+  PRIMARY KEY (`user_id`, `group_id`) USING BTREE,
+  INDEX `idx_group_id`(`group_id`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
 
 SET FOREIGN_KEY_CHECKS = 1;
@@ -0,0 +1,50 @@
+package tasktype
+
+import "time"
+
+// 下发指令的状态
+const (
+	CommandPending = "pending" // 未确认，客户端重连或超时未确认时重发
+	CommandApplied = "applied" // 客户端已应用
+	CommandFailed  = "failed"  // 客户端已确认但应用失败，不再重发
+)
+
+// AgentCommand 下发给客户端的指令，对应 agent_commands 表
+type AgentCommand struct {
+	AgentID   string     `json:"agent_id" db:"agent_id"`
+	Seq       int64      `json:"seq" db:"seq"` // 同一客户端内从 1 开始递增，客户端按序应用
+	TaskID    string     `json:"task_id" db:"task_id"`
+	Action    string     `json:"action" db:"action"`
+	Payload   string     `json:"-" db:"payload"` // 发送给客户端的完整消息
+	Status    string     `json:"status" db:"status"`
+	Error     string     `json:"error" db:"error"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	AckedAt   *time.Time `json:"acked_at" db:"acked_at"`
+}
+
+// CommandAck 客户端应用指令后的确认
+type CommandAck struct {
+	Seq   int64  `json:"seq"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// TaskSync 单个任务最近下发的指令与客户端最近确认的指令
+type TaskSync struct {
+	TaskID        string `json:"task_id" db:"task_id"`
+	DesiredSeq    int64  `json:"desired_seq" db:"desired_seq"`
+	DesiredAction string `json:"desired_action" db:"desired_action"`
+	AppliedSeq    int64  `json:"applied_seq" db:"applied_seq"` // 0 表示该任务还没有确认过的指令
+	AppliedAction string `json:"applied_action" db:"applied_action"`
+	AppliedStatus string `json:"applied_status" db:"applied_status"`
+	InSync        bool   `json:"in_sync" db:"-"` // 最近下发的指令已被成功应用
+}
+
+// AgentSync 客户端的期望状态与已应用状态
+type AgentSync struct {
+	AgentID    string         `json:"agent_id"`
+	DesiredSeq int64          `json:"desired_seq"` // 最近下发的指令序号
+	AppliedSeq int64          `json:"applied_seq"` // 已确认的最大指令序号
+	Pending    []AgentCommand `json:"pending"`     // 未确认的指令
+	Tasks      []TaskSync     `json:"tasks"`
+}
@@ -75,3 +75,22 @@ type JobExecuteResult struct {
 var (
 	ERR_LOCK_ALREADY_REQUIRED = errors.New("锁已被占用")
 )
+
+// JobLog 定时任务单次执行结果，对应 jobdata 表
+type JobLog struct {
+	JobName   string    `json:"jobname" db:"jobname"`
+	PlanTime  time.Time `json:"jobplantime" db:"jobplantime"`
+	StartTime time.Time `json:"jobstarttime" db:"jobstarttime"`
+	StopTime  time.Time `json:"jobstoptime" db:"jobstoptime"`
+	Output    string    `json:"jobinfo" db:"jobinfo"`
+	ExitCode  int       `json:"jobrunning" db:"jobrunning"` // 命令退出码，未能启动或被终止时为 -1
+	Err       string    `json:"joberr" db:"joberr"`
+	Worker    string    `json:"jobworker" db:"jobworker"`
+}
+
+// JobLogQuery 查询定时任务执行记录的参数
+type JobLogQuery struct {
+	Start time.Time `form:"start" time_format:"2006-01-02 15:04:05"`
+	End   time.Time `form:"end" time_format:"2006-01-02 15:04:05"`
+	Limit int       `form:"limit"`
+}
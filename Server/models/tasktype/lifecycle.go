@@ -0,0 +1,192 @@
+package tasktype
+
+import "time"
+
+// 任务生命周期状态，任务和每次执行共用同一组状态
+const (
+	TaskPending   = "pending"   // 已创建，客户端尚未确认调度
+	TaskScheduled = "scheduled" // 客户端已按定时表达式调度，等待执行
+	TaskRunning   = "running"   // 正在执行
+	TaskSucceeded = "succeeded" // 最近一次执行成功
+	TaskFailed    = "failed"    // 最近一次执行失败
+	TaskTimedOut  = "timed_out" // 最近一次执行超时
+	TaskCancelled = "cancelled" // 已停止或删除
+)
+
+// RunSkipped 触发时因并发策略未执行，只用于执行记录，不影响任务状态
+const RunSkipped = "skipped"
+
+// 任务的并发策略，决定上一次执行未结束时如何处理新的触发
+const (
+	ConcurrencyAllow   = "allow"   // 并行执行
+	ConcurrencySkip    = "skip"    // 跳过本次触发
+	ConcurrencyQueue   = "queue"   // 等待上一次执行结束，最多排队一次
+	ConcurrencyReplace = "replace" // 终止上一次执行后执行
+)
+
+// taskTransitions 允许的状态迁移。定时任务每次执行结束后回到可再次执行的状态，
+// cancelled 的任务重新启用后回到 scheduled。旧版客户端不上报 scheduled，允许 pending 直接进入 running；
+// 客户端重启后重新调度时 running 回到 scheduled，未结束的执行记为失败
+var taskTransitions = map[string][]string{
+	TaskPending:   {TaskScheduled, TaskRunning, TaskCancelled},
+	TaskScheduled: {TaskRunning, TaskCancelled},
+	TaskRunning:   {TaskSucceeded, TaskFailed, TaskTimedOut, TaskCancelled, TaskScheduled},
+	TaskSucceeded: {TaskRunning, TaskScheduled, TaskCancelled},
+	TaskFailed:    {TaskRunning, TaskScheduled, TaskCancelled},
+	TaskTimedOut:  {TaskRunning, TaskScheduled, TaskCancelled},
+	TaskCancelled: {TaskScheduled},
+}
+
+// legacyStatus 旧版客户端上报的状态
+var legacyStatus = map[string]string{
+	"completed": TaskSucceeded,
+	"active":    TaskScheduled,
+	"stopped":   TaskCancelled,
+}
+
+// IsTaskState 判断是否为已定义的生命周期状态
+func IsTaskState(s string) bool {
+	_, ok := taskTransitions[s]
+	return ok
+}
+
+// NormalizeTaskState 将旧版客户端上报的状态转换为生命周期状态，无法识别时返回空字符串
+func NormalizeTaskState(s string) string {
+	if IsTaskState(s) {
+		return s
+	}
+	return legacyStatus[s]
+}
+
+// CanTransition 判断任务能否从 from 迁移到 to，状态不变视为允许
+func CanTransition(from, to string) bool {
+	if from == to {
+		return IsTaskState(to)
+	}
+	for _, s := range taskTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRunFinished 判断是否为一次执行的结束状态
+func IsRunFinished(s string) bool {
+	switch s {
+	case TaskSucceeded, TaskFailed, TaskTimedOut, TaskCancelled:
+		return true
+	}
+	return false
+}
+
+// TaskRun 任务的一次执行，对应 task_runs 表
+type TaskRun struct {
+	RunID       int64      `json:"run_id,string" db:"run_id"`
+	TaskID      string     `json:"task_id" db:"task_id"`
+	AgentID     string     `json:"agent_id" db:"agent_id"`
+	Status      string     `json:"status" db:"status"`
+	StartTime   time.Time  `json:"start_time" db:"start_time"`
+	EndTime     *time.Time `json:"end_time" db:"end_time"`
+	ExitCode    *int       `json:"exit_code" db:"exit_code"`
+	Signal      string     `json:"signal" db:"term_signal"`
+	StdoutBytes int64      `json:"stdout_bytes" db:"stdout_bytes"`
+	StderrBytes int64      `json:"stderr_bytes" db:"stderr_bytes"`
+	TimedOut    bool       `json:"timed_out" db:"timed_out"`
+	DurationMs  *int64     `json:"duration_ms" db:"duration_ms"`
+	Attempts    int        `json:"attempts" db:"attempts"` // 包含重试在内的执行次数
+	Error       string     `json:"error" db:"error"`
+	OutputID    *int64     `json:"output_id" db:"output_id"` // 上传的执行输出，未上传时为空
+}
+
+// RunAttempt 一次执行中的单次尝试，对应 task_run_attempts 表
+type RunAttempt struct {
+	RunID       int64     `json:"run_id,string" db:"run_id"`
+	Attempt     int       `json:"attempt" db:"attempt"` // 从 1 开始
+	Status      string    `json:"status" db:"status"`
+	StartTime   time.Time `json:"start_time" db:"start_time"`
+	EndTime     time.Time `json:"end_time" db:"end_time"`
+	ExitCode    *int      `json:"exit_code" db:"exit_code"`
+	Signal      string    `json:"signal" db:"term_signal"`
+	StdoutBytes int64     `json:"stdout_bytes" db:"stdout_bytes"`
+	StderrBytes int64     `json:"stderr_bytes" db:"stderr_bytes"`
+	TimedOut    bool      `json:"timed_out" db:"timed_out"`
+	DurationMs  int64     `json:"duration_ms" db:"duration_ms"`
+	Error       string    `json:"error" db:"error"`
+}
+
+// RunStart 客户端开始执行任务时上报
+type RunStart struct {
+	TaskID    string    `json:"task_id"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// RunSkip 客户端因并发策略跳过一次触发时上报
+type RunSkip struct {
+	TaskID   string    `json:"task_id"`
+	SkipTime time.Time `json:"skip_time"`
+	Reason   string    `json:"reason"`
+}
+
+// RunFinish 客户端执行结束时上报的结构化结果
+type RunFinish struct {
+	RunID       int64     `json:"run_id,string"`
+	TaskID      string    `json:"task_id"`
+	Status      string    `json:"status"`
+	ExitCode    *int      `json:"exit_code"`
+	Signal      string    `json:"signal"` // 被信号终止时的信号名
+	StdoutBytes int64     `json:"stdout_bytes"`
+	StderrBytes int64     `json:"stderr_bytes"`
+	TimedOut    bool      `json:"timed_out"`
+	StartTime   time.Time `json:"start_time"` // 进程实际启动时间，为空时沿用登记时间
+	EndTime     time.Time `json:"end_time"`
+	Error       string    `json:"error"`
+	// 按重试策略执行的各次尝试，最后一次为最终结果；旧版客户端不上报
+	Attempts []RunAttempt `json:"attempts"`
+}
+
+// Outcome 按执行结果校正上报的状态：超时记为 timed_out，
+// 退出码非零或被信号终止的执行不能记为成功
+func (p *RunFinish) Outcome() string {
+	if p.Status == TaskCancelled {
+		return p.Status
+	}
+	if p.TimedOut {
+		return TaskTimedOut
+	}
+	if p.Status == TaskSucceeded && ((p.ExitCode != nil && *p.ExitCode != 0) || p.Signal != "") {
+		return TaskFailed
+	}
+	return p.Status
+}
+
+// NumberAttempts 按上报顺序为各次尝试编号并计算耗时，尝试序号从 1 开始。
+// 返回包含重试在内的执行次数，旧版客户端不上报尝试时为 1
+func (p *RunFinish) NumberAttempts(runID int64) int {
+	for i := range p.Attempts {
+		a := &p.Attempts[i]
+		a.RunID, a.Attempt = runID, i+1
+		if a.DurationMs = a.EndTime.Sub(a.StartTime).Milliseconds(); a.DurationMs < 0 {
+			a.DurationMs = 0
+		}
+	}
+	if len(p.Attempts) == 0 {
+		return 1
+	}
+	return len(p.Attempts)
+}
+
+// TaskState 任务当前状态
+type TaskState struct {
+	TaskID     string    `json:"task_id" db:"task_id"`
+	AgentID    string    `json:"agent_id" db:"agent_id"`
+	Status     string    `json:"status" db:"status"`
+	UpdateTime time.Time `json:"update_time" db:"update_time"`
+}
+
+// RunQuery 执行记录查询参数
+type RunQuery struct {
+	Status string `form:"status"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
@@ -0,0 +1,113 @@
+package tasktype
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{TaskPending, TaskScheduled, true},
+		{TaskPending, TaskRunning, true},
+		{TaskPending, TaskSucceeded, false},
+		{TaskScheduled, TaskRunning, true},
+		{TaskScheduled, TaskFailed, false},
+		{TaskRunning, TaskSucceeded, true},
+		{TaskRunning, TaskTimedOut, true},
+		{TaskRunning, TaskScheduled, true},
+		{TaskSucceeded, TaskRunning, true},
+		{TaskFailed, TaskScheduled, true},
+		{TaskCancelled, TaskScheduled, true},
+		{TaskCancelled, TaskRunning, false},
+		{TaskCancelled, TaskSucceeded, false},
+		{TaskRunning, TaskRunning, true},
+		// 旧版状态必须先经 NormalizeTaskState 转换，不能直接参与迁移
+		{"completed", TaskRunning, false},
+		{TaskRunning, "completed", false},
+		{"unknown", "unknown", false},
+		{"", TaskRunning, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTaskState(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"completed", TaskSucceeded},
+		{"active", TaskScheduled},
+		{"stopped", TaskCancelled},
+		{TaskRunning, TaskRunning},
+		{"unknown", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeTaskState(tt.in); got != tt.want {
+			t.Errorf("NormalizeTaskState(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRunFinishOutcome(t *testing.T) {
+	zero, one := 0, 1
+	tests := []struct {
+		name string
+		p    RunFinish
+		want string
+	}{
+		{"succeeded", RunFinish{Status: TaskSucceeded, ExitCode: &zero}, TaskSucceeded},
+		{"non-zero exit", RunFinish{Status: TaskSucceeded, ExitCode: &one}, TaskFailed},
+		{"signal", RunFinish{Status: TaskSucceeded, Signal: "SIGKILL"}, TaskFailed},
+		{"timed out", RunFinish{Status: TaskFailed, TimedOut: true}, TaskTimedOut},
+		{"cancelled", RunFinish{Status: TaskCancelled, TimedOut: true}, TaskCancelled},
+	}
+	for _, tt := range tests {
+		if got := tt.p.Outcome(); got != tt.want {
+			t.Errorf("%s: Outcome() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNumberAttempts(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		attempts  []RunAttempt
+		want      int
+		durations []int64
+	}{
+		{"legacy client", nil, 1, nil},
+		{"single", []RunAttempt{{StartTime: start, EndTime: start.Add(time.Second)}}, 1, []int64{1000}},
+		{
+			"retries",
+			[]RunAttempt{
+				{Attempt: 7, StartTime: start, EndTime: start.Add(time.Second)},
+				{Attempt: 7, StartTime: start.Add(2 * time.Second), EndTime: start.Add(5 * time.Second)},
+				// 结束时间早于开始时间时耗时记为 0
+				{StartTime: start.Add(time.Minute), EndTime: start},
+			},
+			3,
+			[]int64{1000, 3000, 0},
+		},
+	}
+	for _, tt := range tests {
+		p := &RunFinish{Attempts: tt.attempts}
+		if got := p.NumberAttempts(42); got != tt.want {
+			t.Errorf("%s: NumberAttempts() = %d, want %d", tt.name, got, tt.want)
+		}
+		for i, a := range p.Attempts {
+			if a.Attempt != i+1 || a.RunID != 42 {
+				t.Errorf("%s: attempt %d numbered (%d, %d), want (42, %d)", tt.name, i, a.RunID, a.Attempt, i+1)
+			}
+			if a.DurationMs != tt.durations[i] {
+				t.Errorf("%s: attempt %d duration = %d, want %d", tt.name, i+1, a.DurationMs, tt.durations[i])
+			}
+		}
+	}
+}
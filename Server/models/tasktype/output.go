@@ -0,0 +1,79 @@
+package tasktype
+
+import "time"
+
+// 任务输出的来源
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// OutputLine 任务输出中的一行，对应 task_output_lines 表
+type OutputLine struct {
+	RunID   int64     `json:"run_id,string" db:"run_id"`
+	Seq     int64     `json:"seq" db:"seq"`         // 同一执行内从 1 开始递增
+	Attempt int       `json:"attempt" db:"attempt"` // 所属的尝试序号
+	Stream  string    `json:"stream" db:"stream"`
+	Line    string    `json:"line" db:"line"`
+	Time    time.Time `json:"time" db:"output_time"`
+}
+
+// TaskOutput 客户端批量上报的输出
+type TaskOutput struct {
+	RunID  int64        `json:"run_id,string"`
+	TaskID string       `json:"task_id"`
+	Lines  []OutputLine `json:"lines"`
+}
+
+// RunOutput 执行结束后上传的完整输出，对应 task_outputs 表
+type RunOutput struct {
+	ID         int64     `json:"output_id" db:"id"`
+	RunID      int64     `json:"run_id,string" db:"run_id"`
+	TaskID     string    `json:"task_id" db:"task_id"`
+	AgentID    string    `json:"agent_id" db:"agent_id"`
+	FilePath   string    `json:"-" db:"file_path"`
+	Size       int64     `json:"size" db:"size"`               // 保存的原始字节数
+	StoredSize int64     `json:"stored_size" db:"stored_size"` // 压缩后字节数
+	Truncated  bool      `json:"truncated" db:"truncated"`     // 超过大小限制被截断
+	CreateTime time.Time `json:"create_time" db:"create_time"`
+}
+
+// OutputSearch 执行输出搜索参数，时间格式为 2006-01-02 15:04:05，按执行开始时间过滤
+type OutputSearch struct {
+	Query      string    `form:"q" binding:"required"`
+	Regex      bool      `form:"regex"`       // 按正则表达式匹配，否则按子串匹配
+	IgnoreCase bool      `form:"ignore_case"` // 忽略大小写
+	TaskID     string    `form:"task_id"`
+	HostID     int64     `form:"host_id"`
+	Status     string    `form:"status"` // 执行状态
+	Start      time.Time `form:"start" time_format:"2006-01-02 15:04:05"`
+	End        time.Time `form:"end" time_format:"2006-01-02 15:04:05"`
+	Context    int       `form:"context" binding:"min=0,max=10"` // 匹配行前后各返回的行数
+	Limit      int       `form:"limit"`                          // 最多返回的匹配行数
+	HostIDs    []int64   `form:"-"`                              // 非管理员可访问的主机，为 nil 时不限制
+}
+
+// OutputCandidate 需要搜索的一次执行输出
+type OutputCandidate struct {
+	RunID     int64     `db:"run_id"`
+	TaskID    string    `db:"task_id"`
+	AgentID   string    `db:"agent_id"`
+	HostID    *int64    `db:"hostid"`
+	Status    string    `db:"status"`
+	StartTime time.Time `db:"start_time"`
+	FilePath  string    `db:"file_path"`
+}
+
+// OutputMatch 执行输出中匹配的一行及其上下文
+type OutputMatch struct {
+	RunID     int64     `json:"run_id,string"`
+	TaskID    string    `json:"task_id"`
+	AgentID   string    `json:"agent_id"`
+	HostID    *int64    `json:"host_id"`
+	Status    string    `json:"status"`
+	StartTime time.Time `json:"start_time"`
+	LineNo    int       `json:"line_no"` // 从 1 开始
+	Line      string    `json:"line"`
+	Before    []string  `json:"before"`
+	After     []string  `json:"after"`
+}
@@ -1,25 +1,52 @@
 package tasktype
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type TaskRequest struct {
 	TaskID     string `json:"task_id"`
 	TaskName   string `json:"task_name"`
+	AgentID    string `json:"agent_id"`
 	ClientIP   string `json:"client_ip"`
 	ScriptPath string `json:"script_path"`
-	Action     string `json:"action"`
+	Action     string `json:"action"` // stop 停止调度，kill 终止执行中的实例
 }
 
 type TaskRecord struct {
 	TaskID          string `json:"task_id" db:"task_id"`
+	AgentID         string `json:"agent_id" db:"agent_id"`
 	ClientIP        string `json:"client_ip" db:"client_ip"`
 	ScriptPath      string `json:"script_path" db:"script_path"`
 	Remarks         string `json:"remarks" db:"remarks"`
 	CrondExpression string `json:"crond_expression" db:"crond_expression"`
 	Status          string `json:"status" db:"status"` // 任务状态
 	FileId          int64  `json:"file_id" db:"file_id"`
+	Timeout         int    `json:"timeout" db:"timeout" binding:"min=0"` // 执行超时秒数，0 表示不限制
+	// 上一次执行未结束时的处理方式，为空时为 allow
+	ConcurrencyPolicy string `json:"concurrency_policy" db:"concurrency_policy" binding:"omitempty,oneof=allow skip queue replace"`
+	MaxRetries        int    `json:"max_retries" db:"max_retries" binding:"min=0,max=10"` // 失败后的最大重试次数
+	RetryBackoff      int    `json:"retry_backoff" db:"retry_backoff" binding:"min=0"`    // 首次重试前等待的秒数，之后每次翻倍
+	RetryOn           string `json:"retry_on" db:"retry_on"`                              // 逗号分隔的需要重试的退出码，为空时任何失败都重试
+}
+
+// ParseExitCodes 解析逗号分隔的退出码列表
+func ParseExitCodes(s string) ([]int, error) {
+	var codes []int
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 0 || code > 255 {
+			return nil, fmt.Errorf("无效的退出码: %s", part)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
 }
 
 type ClientTaskLog struct {
@@ -37,6 +64,19 @@ type TaskReceive struct {
 	TaskBash    string `json:"task_bash"`
 	TaskWorkDir string `json:"task_work_dir"`
 }
+
+// CommandDelivery 任务指令的投递结果
+type CommandDelivery struct {
+	Record    int64  `json:"record,omitempty"` // 创建任务时写入的任务记录
+	TaskID    string `json:"task_id"`
+	AgentID   string `json:"agent_id"`
+	Action    string `json:"action"`
+	Seq       int64  `json:"seq"`             // 指令序号，确认情况见 /agents/:id/commands
+	Delivered bool   `json:"delivered"`       // 已发送给客户端
+	Queued    bool   `json:"queued"`          // 未送达，客户端重连或超时未确认时重发
+	Error     string `json:"error,omitempty"` // 指令未能保存的原因，客户端重连后按任务列表同步
+}
+
 type TaskRequestOption struct {
 	Option      string      `json:"option" binding:"required"`
 	Info        TaskReceive `json:"task_info"`
@@ -0,0 +1,61 @@
+package aggregate
+
+import (
+	"math"
+	"sort"
+)
+
+// Summary 一组数值的统计结果
+type Summary struct {
+	Avg   float64 `json:"avg"`
+	Max   float64 `json:"max"`
+	Min   float64 `json:"min"`
+	P95   float64 `json:"p95"`
+	Count int64   `json:"count"`
+}
+
+// Summarize 计算平均值、最大值、最小值和 95 分位
+func Summarize(values []float64) Summary {
+	if len(values) == 0 {
+		return Summary{}
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+
+	return Summary{
+		Avg:   sum / float64(len(sorted)),
+		Max:   sorted[len(sorted)-1],
+		Min:   sorted[0],
+		P95:   percentileSorted(sorted, 95),
+		Count: int64(len(sorted)),
+	}
+}
+
+// Percentile 计算 p 分位数（0-100），采用最近秩法
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	return percentileSorted(sorted, p)
+}
+
+func percentileSorted(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,20 @@
+// Package instance 标识当前服务端进程，多实例部署时用于区分在线连接和定时任务执行的归属
+package instance
+
+import (
+	"fmt"
+	"os"
+)
+
+var id = func() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}()
+
+// ID 返回 主机名:进程号
+func ID() string {
+	return id
+}
@@ -6,38 +6,67 @@ import (
 	"time"
 )
 
-var mySecret = []byte("海韵")
+// mySecret 由 Init 从配置设置，未设置时拒绝签发和校验
+var mySecret []byte
 
-const TokenExpireDuration = time.Hour * 24
+// ErrNoSecret 未配置签名密钥
+var ErrNoSecret = errors.New("未配置 JWT 签名密钥")
+
+var TokenExpireDuration = time.Hour * 24
 
 type MyClaims struct {
 	UserId   int64  `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
-// GenRegisteredClaims 使用默认声明创建jwt
-func GenToken(userId int64, username string) (string, error) {
+// Init 设置签名密钥和有效期，密钥为空时返回错误
+func Init(secret string, expire time.Duration) error {
+	if secret == "" {
+		return ErrNoSecret
+	}
+	mySecret = []byte(secret)
+	if expire > 0 {
+		TokenExpireDuration = expire
+	}
+	return nil
+}
+
+// GenToken 使用默认声明创建jwt，返回token和过期时间
+func GenToken(userId int64, username, role string) (string, time.Time, error) {
+	if len(mySecret) == 0 {
+		return "", time.Time{}, ErrNoSecret
+	}
+	expiresAt := time.Now().Add(TokenExpireDuration)
 	// 创建一个我们自己的声明
 	claims := MyClaims{
 		userId,
 		username, // 自定义字段
+		role,
 		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExpireDuration)),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			Issuer:    "Mark", // 签发人
 		},
 	}
 	// 生成token对象
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	// 生成签名字符串
-	return token.SignedString(mySecret)
+	signed, err := token.SignedString(mySecret)
+	return signed, expiresAt, err
 }
 
 // ParseToken 解析JWT
 func ParseToken(tokenString string) (*MyClaims, error) {
+	if len(mySecret) == 0 {
+		return nil, ErrNoSecret
+	}
 	// 解析token
 	// 如果是自定义Claim结构体则需要使用 ParseWithClaims 方法
 	token, err := jwt.ParseWithClaims(tokenString, &MyClaims{}, func(token *jwt.Token) (i interface{}, err error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
 		// 直接使用标准的Claim则可以直接使用Parse方法
 		//clienttoken, err := jwt.Parse(tokenString, func(clienttoken *jwt.Token) (i interface{}, err error) {
 		return mySecret, nil
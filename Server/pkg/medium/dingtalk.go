@@ -0,0 +1,57 @@
+package medium
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DingTalk 钉钉群机器人，Secret 不为空时使用加签模式
+type DingTalk struct {
+	URL       string
+	Secret    string
+	AtMobiles []string
+}
+
+func (d *DingTalk) Name() string { return "dingtalk" }
+
+func (d *DingTalk) Send(ctx context.Context, msg *Message) error {
+	target := d.URL
+	if d.Secret != "" {
+		timestamp, sign := d.sign(time.Now())
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target = fmt.Sprintf("%s%stimestamp=%d&sign=%s", target, sep, timestamp, url.QueryEscape(sign))
+	}
+
+	content := msg.Content
+	// @ 手机号需要出现在正文中才会高亮
+	for _, m := range d.AtMobiles {
+		content += " @" + m
+	}
+	payload := map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": msg.Title, "text": fmt.Sprintf("### %s\n%s", msg.Title, content)},
+		"at":       map[string]interface{}{"atMobiles": d.AtMobiles, "isAtAll": false},
+	}
+	body, err := postJSON(ctx, target, payload, nil)
+	if err != nil {
+		return err
+	}
+	return checkRobotResult(body)
+}
+
+// sign 计算加签：HmacSHA256(timestamp + "\n" + secret)，再做 base64
+func (d *DingTalk) sign(now time.Time) (int64, string) {
+	timestamp := now.UnixMilli()
+	mac := hmac.New(sha256.New, []byte(d.Secret))
+	mac.Write([]byte(fmt.Sprintf("%d\n%s", timestamp, d.Secret)))
+	return timestamp, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,149 @@
+package medium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Dispatcher 将消息发送到所有通知渠道，并按报警 Key 去重和限流：
+// 同一 Key 同一状态在 DedupWindow 内每个渠道只成功发送一次，同一 Key 在一小时内最多发送 RateLimit 条。
+// 发送成功后才记录，发送失败的渠道在报警再次触发时重新发送
+type Dispatcher struct {
+	DedupWindow time.Duration
+	RateLimit   int
+	Timeout     time.Duration
+
+	mu        sync.Mutex
+	notifiers []Notifier
+	lastSent  map[string]time.Time   // Key + 状态 + 渠道 -> 上次成功发送时间
+	history   map[string][]time.Time // Key -> 最近一小时的发送时间
+}
+
+func NewDispatcher(dedupWindow time.Duration, rateLimit int) *Dispatcher {
+	return &Dispatcher{
+		DedupWindow: dedupWindow,
+		RateLimit:   rateLimit,
+		Timeout:     time.Minute,
+		lastSent:    make(map[string]time.Time),
+		history:     make(map[string][]time.Time),
+	}
+}
+
+// SetNotifiers 替换通知渠道，配置变更后调用
+func (d *Dispatcher) SetNotifiers(notifiers []Notifier) {
+	d.mu.Lock()
+	d.notifiers = notifiers
+	d.mu.Unlock()
+}
+
+// dedupKey 去重记录的键，按渠道区分
+func dedupKey(msg *Message, n Notifier) string {
+	return msg.Key + "|" + msg.Status + "|" + n.Name()
+}
+
+// pending 返回消息需要发送的渠道，去掉去重窗口内已成功发送的渠道，被限流时返回空
+func (d *Dispatcher) pending(msg *Message, notifiers []Notifier, now time.Time) []Notifier {
+	if msg.Key == "" {
+		return notifiers
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var targets []Notifier
+	for _, n := range notifiers {
+		if last, ok := d.lastSent[dedupKey(msg, n)]; ok && d.DedupWindow > 0 && now.Sub(last) < d.DedupWindow {
+			continue
+		}
+		targets = append(targets, n)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	recent := d.history[msg.Key][:0]
+	for _, t := range d.history[msg.Key] {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	d.history[msg.Key] = recent
+	if d.RateLimit > 0 && len(recent) >= d.RateLimit {
+		return nil
+	}
+	return targets
+}
+
+// record 记录发送成功的渠道，至少一个渠道成功时计入限流
+func (d *Dispatcher) record(msg *Message, delivered []Notifier, now time.Time) {
+	if msg.Key == "" || len(delivered) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, n := range delivered {
+		d.lastSent[dedupKey(msg, n)] = now
+	}
+	d.history[msg.Key] = append(d.history[msg.Key], now)
+	d.cleanup(now)
+}
+
+// cleanup 清理过期的去重记录，避免 Key 持续增长
+func (d *Dispatcher) cleanup(now time.Time) {
+	expire := d.DedupWindow
+	if expire < time.Hour {
+		expire = time.Hour
+	}
+	for k, t := range d.lastSent {
+		if now.Sub(t) > expire {
+			delete(d.lastSent, k)
+		}
+	}
+	for k, list := range d.history {
+		if len(list) == 0 || now.Sub(list[len(list)-1]) > time.Hour {
+			delete(d.history, k)
+		}
+	}
+}
+
+// Dispatch 发送消息，被去重或限流时 sent 为 false；各渠道的错误合并返回
+func (d *Dispatcher) Dispatch(msg *Message) (sent bool, err error) {
+	d.mu.Lock()
+	notifiers := d.notifiers
+	d.mu.Unlock()
+	if len(notifiers) == 0 {
+		return false, errors.New("no notifier configured")
+	}
+	targets := d.pending(msg, notifiers, time.Now())
+	if len(targets) == 0 {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(targets))
+	for i, n := range targets {
+		wg.Add(1)
+		go func(i int, n Notifier) {
+			defer wg.Done()
+			if err := n.Send(ctx, msg); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", n.Name(), err)
+			}
+		}(i, n)
+	}
+	wg.Wait()
+
+	var delivered []Notifier
+	for i, n := range targets {
+		if errs[i] == nil {
+			delivered = append(delivered, n)
+		}
+	}
+	d.record(msg, delivered, time.Now())
+	return true, errors.Join(errs...)
+}
@@ -0,0 +1,65 @@
+package medium
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubNotifier 按 fail 决定发送是否失败，记录发送次数
+type stubNotifier struct {
+	name  string
+	fail  bool
+	sends int
+}
+
+func (n *stubNotifier) Name() string { return n.name }
+
+func (n *stubNotifier) Send(ctx context.Context, msg *Message) error {
+	n.sends++
+	if n.fail {
+		return errors.New("send failed")
+	}
+	return nil
+}
+
+// 发送失败的渠道不记录去重，报警再次触发时重新发送；发送成功的渠道在窗口内不重复发送
+func TestDispatchDedupAfterSuccess(t *testing.T) {
+	ok, bad := &stubNotifier{name: "ok"}, &stubNotifier{name: "bad", fail: true}
+	d := NewDispatcher(time.Hour, 0)
+	d.SetNotifiers([]Notifier{ok, bad})
+	msg := &Message{Key: "rule|agent", Status: StatusFiring}
+
+	if sent, err := d.Dispatch(msg); !sent || err == nil {
+		t.Fatalf("first Dispatch() = %v, %v, want true and the bad channel's error", sent, err)
+	}
+	bad.fail = false
+	if sent, err := d.Dispatch(msg); !sent || err != nil {
+		t.Fatalf("second Dispatch() = %v, %v, want true, nil", sent, err)
+	}
+	if ok.sends != 1 || bad.sends != 2 {
+		t.Errorf("sends ok=%d bad=%d, want ok=1 bad=2", ok.sends, bad.sends)
+	}
+	if sent, _ := d.Dispatch(msg); sent {
+		t.Error("third Dispatch() sent, want deduplicated")
+	}
+
+	// 状态变化后重新发送
+	if sent, err := d.Dispatch(&Message{Key: msg.Key, Status: StatusResolved}); !sent || err != nil {
+		t.Errorf("resolved Dispatch() = %v, %v, want true, nil", sent, err)
+	}
+}
+
+func TestDispatchRateLimit(t *testing.T) {
+	n := &stubNotifier{name: "ok"}
+	d := NewDispatcher(0, 2)
+	d.SetNotifiers([]Notifier{n})
+	msg := &Message{Key: "k", Status: StatusFiring}
+	for i := 0; i < 3; i++ {
+		d.Dispatch(msg)
+	}
+	if n.sends != 2 {
+		t.Errorf("sends = %d, want 2", n.sends)
+	}
+}
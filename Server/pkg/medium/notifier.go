@@ -0,0 +1,87 @@
+package medium
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// 报警状态，用于去重时区分触发和恢复
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Message 通知内容，Key 标识同一个报警，用于去重和限流
+type Message struct {
+	Key     string
+	Status  string
+	Title   string
+	Content string
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, msg *Message) error
+}
+
+// PartialSender 拆分为多次请求发送的通知渠道。sent 记录已送达的请求数，
+// 重试时从第一个未送达的请求继续，避免接收者重复收到已送达的部分
+type PartialSender interface {
+	SendFrom(ctx context.Context, msg *Message, sent *int) error
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON 以 JSON 格式发送请求，返回响应体；HTTP 状态码非 2xx 时返回错误
+func postJSON(ctx context.Context, url string, payload interface{}, header map[string]string) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, fmt.Errorf("failed to send message, status code: %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// robotResult 企业微信和钉钉机器人的返回结果，errcode 非 0 表示发送失败
+type robotResult struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func checkRobotResult(body []byte) error {
+	var result robotResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("errcode %d: %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package medium
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Retry 为通知渠道增加失败重试，每次重试的等待时间翻倍。
+// 渠道实现 PartialSender 时从失败的部分继续发送
+type Retry struct {
+	Notifier
+	Attempts int
+	Backoff  time.Duration
+}
+
+// WithRetry 包装通知渠道，attempts 为总尝试次数
+func WithRetry(n Notifier, attempts int, backoff time.Duration) Notifier {
+	if attempts <= 1 {
+		return n
+	}
+	return &Retry{Notifier: n, Attempts: attempts, Backoff: backoff}
+}
+
+func (r *Retry) Send(ctx context.Context, msg *Message) error {
+	var err error
+	wait := r.Backoff
+	partial, resumable := r.Notifier.(PartialSender)
+	sent := 0
+	for attempt := 1; attempt <= r.Attempts; attempt++ {
+		if resumable {
+			err = partial.SendFrom(ctx, msg, &sent)
+		} else {
+			err = r.Notifier.Send(ctx, msg)
+		}
+		if err == nil {
+			return nil
+		}
+		if attempt == r.Attempts {
+			break
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		}
+		wait *= 2
+	}
+	return fmt.Errorf("failed after %d attempts: %w", r.Attempts, err)
+}
@@ -0,0 +1,34 @@
+package medium
+
+import (
+	"bytes"
+	"sync"
+	"text/template"
+)
+
+var (
+	templateMu    sync.Mutex
+	templateCache = make(map[string]*template.Template)
+)
+
+// Render 使用 text/template 渲染消息，相同模板文本只解析一次
+func Render(text string, data interface{}) (string, error) {
+	templateMu.Lock()
+	tmpl, ok := templateCache[text]
+	if !ok {
+		var err error
+		tmpl, err = template.New("message").Parse(text)
+		if err != nil {
+			templateMu.Unlock()
+			return "", err
+		}
+		templateCache[text] = tmpl
+	}
+	templateMu.Unlock()
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
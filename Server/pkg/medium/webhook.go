@@ -0,0 +1,26 @@
+package medium
+
+import (
+	"context"
+	"time"
+)
+
+// Webhook 通用 HTTP 回调，以 JSON 格式推送通知
+type Webhook struct {
+	URL    string
+	Header map[string]string
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+func (w *Webhook) Send(ctx context.Context, msg *Message) error {
+	payload := map[string]interface{}{
+		"key":     msg.Key,
+		"status":  msg.Status,
+		"title":   msg.Title,
+		"content": msg.Content,
+		"time":    time.Now(),
+	}
+	_, err := postJSON(ctx, w.URL, payload, w.Header)
+	return err
+}
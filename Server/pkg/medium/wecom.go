@@ -0,0 +1,108 @@
+package medium
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+)
+
+const defaultWeComMaxBytes = 4096
+
+// WeCom 企业微信群机器人，超出长度的消息拆分为多条发送
+type WeCom struct {
+	URL       string
+	Mentioned []string // 需要 @ 的手机号
+	MaxBytes  int
+}
+
+// NewWeComWithKey 通过机器人 key 构造企业微信通知
+func NewWeComWithKey(key string, maxBytes int) *WeCom {
+	return &WeCom{
+		URL:      fmt.Sprintf("https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=%s", key),
+		MaxBytes: maxBytes,
+	}
+}
+
+func (w *WeCom) Name() string { return "wecom" }
+
+func (w *WeCom) Send(ctx context.Context, msg *Message) error {
+	sent := 0
+	return w.SendFrom(ctx, msg, &sent)
+}
+
+// SendFrom 依次发送各个片段和 @ 提醒，跳过前 sent 条已送达的请求，每送达一条 sent 加一
+func (w *WeCom) SendFrom(ctx context.Context, msg *Message, sent *int) error {
+	maxBytes := w.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultWeComMaxBytes
+	}
+
+	content := msg.Content
+	if msg.Title != "" {
+		content = fmt.Sprintf("### %s\n%s", msg.Title, content)
+	}
+	segments := SplitMessage(content, maxBytes)
+	for ; *sent < len(segments); *sent++ {
+		if err := w.sendMarkdown(ctx, segments[*sent]); err != nil {
+			return fmt.Errorf("segment %d/%d: %w", *sent+1, len(segments), err)
+		}
+	}
+
+	// markdown 消息不支持 @ 手机号，需要时追加一条文本消息
+	if len(w.Mentioned) > 0 && *sent == len(segments) {
+		payload := map[string]interface{}{
+			"msgtype": "text",
+			"text": map[string]interface{}{
+				"content":               msg.Title,
+				"mentioned_mobile_list": w.Mentioned,
+			},
+		}
+		body, err := postJSON(ctx, w.URL, payload, nil)
+		if err != nil {
+			return err
+		}
+		if err := checkRobotResult(body); err != nil {
+			return err
+		}
+		*sent++
+	}
+	return nil
+}
+
+func (w *WeCom) sendMarkdown(ctx context.Context, content string) error {
+	payload := map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": content},
+	}
+	body, err := postJSON(ctx, w.URL, payload, nil)
+	if err != nil {
+		return err
+	}
+	return checkRobotResult(body)
+}
+
+// SplitMessage 将消息内容按照指定的长度拆分成多个小片段，不会拆开多字节字符。
+// maxBytes 小于 utf8.UTFMax 时按 utf8.UTFMax 处理，保证每个片段至少包含一个字符
+func SplitMessage(content string, maxBytes int) []string {
+	if maxBytes < utf8.UTFMax {
+		maxBytes = utf8.UTFMax
+	}
+	var segments []string
+	for len(content) > maxBytes {
+		// 从 maxBytes 处向前找到字符边界
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			// 无效的 UTF-8 序列，按字节截断
+			cut = maxBytes
+		}
+		segments = append(segments, content[:cut])
+		content = content[cut:]
+	}
+	if content != "" {
+		segments = append(segments, content)
+	}
+	return segments
+}
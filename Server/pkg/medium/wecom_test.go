@@ -0,0 +1,123 @@
+package medium
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		maxBytes int
+		want     []string
+	}{
+		{"empty", "", 10, nil},
+		{"fits", "hello", 10, []string{"hello"}},
+		{"exact", "hello", 5, []string{"hello"}},
+		{"ascii", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		// 不拆开多字节字符
+		{"cjk", "中文消息", 7, []string{"中文", "消息"}},
+		{"mixed", "a中b文", 4, []string{"a中", "b文"}},
+		// maxBytes 小于一个字符的字节数时按 utf8.UTFMax 处理
+		{"cjk tiny limit", "中文", 1, []string{"中", "文"}},
+		{"zero limit", "abcdef", 0, []string{"abcd", "ef"}},
+		{"emoji tiny limit", "😀😀", 2, []string{"😀", "😀"}},
+	}
+	for _, tt := range tests {
+		got := SplitMessage(tt.content, tt.maxBytes)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: SplitMessage(%q, %d) = %q, want %q", tt.name, tt.content, tt.maxBytes, got, tt.want)
+		}
+		if strings.Join(got, "") != tt.content {
+			t.Errorf("%s: segments do not rejoin to the content", tt.name)
+		}
+		for _, seg := range got {
+			if !utf8.ValidString(seg) {
+				t.Errorf("%s: segment %q is not valid UTF-8", tt.name, seg)
+			}
+		}
+	}
+}
+
+// robotServer 记录收到的消息，failAt 中的请求序号（从 1 开始）返回错误
+type robotServer struct {
+	mu       sync.Mutex
+	requests int
+	failAt   map[int]bool
+	received []string
+}
+
+func (s *robotServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		MsgType  string            `json:"msgtype"`
+		Markdown map[string]string `json:"markdown"`
+		Text     map[string]any    `json:"text"`
+	}
+	json.NewDecoder(r.Body).Decode(&payload)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.failAt[s.requests] {
+		w.Write([]byte(`{"errcode":45009,"errmsg":"api freq out of limit"}`))
+		return
+	}
+	if payload.MsgType == "markdown" {
+		s.received = append(s.received, payload.Markdown["content"])
+	} else {
+		s.received = append(s.received, "@"+payload.MsgType)
+	}
+	w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+}
+
+func TestRetryResumesFromFailedSegment(t *testing.T) {
+	tests := []struct {
+		name      string
+		mentioned []string
+		failAt    map[int]bool
+		want      []string
+	}{
+		{"no failure", nil, nil, []string{"### t\n", "aaaabb", "bb"}},
+		// 第二个片段失败后重试只发送第二个及之后的片段
+		{"segment fails once", nil, map[int]bool{2: true}, []string{"### t\n", "aaaabb", "bb"}},
+		{"last segment fails twice", nil, map[int]bool{3: true, 4: true}, []string{"### t\n", "aaaabb", "bb"}},
+		// @ 提醒失败时不重发已送达的片段
+		{"mention fails", []string{"13800000000"}, map[int]bool{4: true}, []string{"### t\n", "aaaabb", "bb", "@text"}},
+	}
+	for _, tt := range tests {
+		srv := &robotServer{failAt: tt.failAt}
+		ts := httptest.NewServer(srv)
+		w := &WeCom{URL: ts.URL, Mentioned: tt.mentioned, MaxBytes: 6}
+		n := WithRetry(w, 3, time.Millisecond)
+		err := n.Send(context.Background(), &Message{Title: "t", Content: "aaaabbbb"})
+		ts.Close()
+		if err != nil {
+			t.Fatalf("%s: Send() error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(srv.received, tt.want) {
+			t.Errorf("%s: received %q, want %q", tt.name, srv.received, tt.want)
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	srv := &robotServer{failAt: map[int]bool{2: true, 3: true, 4: true}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	n := WithRetry(&WeCom{URL: ts.URL, MaxBytes: 6}, 3, time.Millisecond)
+	if err := n.Send(context.Background(), &Message{Title: "t", Content: "aaaabbbb"}); err == nil {
+		t.Fatal("Send() error = nil, want error after all attempts fail")
+	}
+	// 第一个片段只发送一次
+	if !reflect.DeepEqual(srv.received, []string{"### t\n"}) {
+		t.Errorf("received %q, want only the first segment", srv.received)
+	}
+}
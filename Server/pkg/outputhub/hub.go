@@ -0,0 +1,74 @@
+// Package outputhub 将客户端上报的任务输出分发给正在查看该执行的订阅者
+package outputhub
+
+import (
+	"Server/models/tasktype"
+	"sync"
+)
+
+// 订阅者的缓冲批数，消费过慢时丢弃，订阅者按序号发现缺口后从数据库补齐
+const subscriberBuffer = 64
+
+// Subscription 一次执行输出的订阅，执行结束时 C 被关闭
+type Subscription struct {
+	C     <-chan []tasktype.OutputLine
+	ch    chan []tasktype.OutputLine
+	runID int64
+}
+
+var (
+	mu   sync.Mutex
+	subs = make(map[int64]map[*Subscription]struct{})
+)
+
+// Subscribe 订阅一次执行的输出，使用完毕后需调用 Close
+func Subscribe(runID int64) *Subscription {
+	ch := make(chan []tasktype.OutputLine, subscriberBuffer)
+	s := &Subscription{C: ch, ch: ch, runID: runID}
+	mu.Lock()
+	if subs[runID] == nil {
+		subs[runID] = make(map[*Subscription]struct{})
+	}
+	subs[runID][s] = struct{}{}
+	mu.Unlock()
+	return s
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := subs[s.runID][s]; !ok {
+		return
+	}
+	delete(subs[s.runID], s)
+	if len(subs[s.runID]) == 0 {
+		delete(subs, s.runID)
+	}
+	close(s.ch)
+}
+
+// Publish 将一批输出发给所有订阅者，不阻塞
+func Publish(runID int64, lines []tasktype.OutputLine) {
+	if len(lines) == 0 {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for s := range subs[runID] {
+		select {
+		case s.ch <- lines:
+		default:
+		}
+	}
+}
+
+// Finish 执行结束，关闭所有订阅
+func Finish(runID int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	for s := range subs[runID] {
+		close(s.ch)
+	}
+	delete(subs, runID)
+}
@@ -0,0 +1,107 @@
+package outputstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+)
+
+const (
+	// 单行的最大长度，超出时停止搜索该文件
+	maxScanLine = 1 << 20
+	// 每搜索多少行检查一次请求是否已取消
+	cancelCheckLines = 1024
+)
+
+// ErrSearchBudget 本次搜索读取的字节数达到上限
+var ErrSearchBudget = errors.New("搜索读取的字节数达到上限")
+
+// budgetReader 最多读取 remain 字节，用尽后返回 ErrSearchBudget
+type budgetReader struct {
+	r      io.Reader
+	remain int64
+	read   int64
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if b.remain <= 0 {
+		return 0, ErrSearchBudget
+	}
+	if int64(len(p)) > b.remain {
+		p = p[:b.remain]
+	}
+	n, err := b.r.Read(p)
+	b.remain -= int64(n)
+	b.read += int64(n)
+	return n, err
+}
+
+// Hit 一行匹配及其上下文
+type Hit struct {
+	LineNo int
+	Line   string
+	Before []string
+	After  []string
+}
+
+// Search 逐行搜索保存的输出，返回最多 limit 个匹配行，每行附带前后各 around 行。
+// 匹配行的上下文可以互相重叠。最多读取解压后的 budget 字节，超出时返回已找到的匹配和 ErrSearchBudget；
+// ctx 取消时返回 ctx 的错误。返回值包含实际读取的字节数
+func Search(ctx context.Context, dir, rel string, match func(string) bool, around, limit int, budget int64) ([]Hit, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	r, err := Open(dir, rel)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+
+	br := &budgetReader{r: r, remain: budget}
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxScanLine)
+	var (
+		hits   []Hit
+		before []string // 最近的 around 行
+		open   []int    // 仍在收集后文的匹配
+	)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if lineNo%cancelCheckLines == 0 && ctx.Err() != nil {
+			return hits, br.read, ctx.Err()
+		}
+		line := scanner.Text()
+
+		remain := open[:0]
+		for _, i := range open {
+			hits[i].After = append(hits[i].After, line)
+			if len(hits[i].After) < around {
+				remain = append(remain, i)
+			}
+		}
+		open = remain
+
+		if len(hits) < limit && match(line) {
+			hits = append(hits, Hit{LineNo: lineNo, Line: line, Before: append([]string{}, before...), After: []string{}})
+			if around > 0 {
+				open = append(open, len(hits)-1)
+			}
+		}
+		if len(hits) >= limit && len(open) == 0 {
+			break
+		}
+
+		if around > 0 {
+			if len(before) == around {
+				before = append(before[:0], before[1:]...)
+			}
+			before = append(before, line)
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return hits, br.read, err
+	}
+	return hits, br.read, nil
+}
@@ -0,0 +1,105 @@
+package outputstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// saveLines 保存按行拼接的输出，返回相对路径
+func saveLines(t *testing.T, dir string, lines []string) string {
+	t.Helper()
+	res, err := Save(dir, "task", 1, strings.NewReader(strings.Join(lines, "\n")+"\n"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Path
+}
+
+func equals(s string) func(string) bool {
+	return func(line string) bool { return line == s }
+}
+
+func TestSearchContext(t *testing.T) {
+	dir := t.TempDir()
+	rel := saveLines(t, dir, []string{"a", "b", "c", "d", "e"})
+	tests := []struct {
+		name   string
+		match  string
+		around int
+		want   Hit
+	}{
+		// 文件开头和结尾的上下文按实际行数截断
+		{"first line", "a", 2, Hit{LineNo: 1, Line: "a", Before: []string{}, After: []string{"b", "c"}}},
+		{"last line", "e", 2, Hit{LineNo: 5, Line: "e", Before: []string{"c", "d"}, After: []string{}}},
+		{"middle", "c", 1, Hit{LineNo: 3, Line: "c", Before: []string{"b"}, After: []string{"d"}}},
+		{"no context", "c", 0, Hit{LineNo: 3, Line: "c", Before: []string{}, After: []string{}}},
+	}
+	for _, tt := range tests {
+		hits, _, err := Search(context.Background(), dir, rel, equals(tt.match), tt.around, 10, 1<<20)
+		if err != nil {
+			t.Fatalf("%s: Search() error = %v", tt.name, err)
+		}
+		if len(hits) != 1 || !reflect.DeepEqual(hits[0], tt.want) {
+			t.Errorf("%s: hits = %+v, want %+v", tt.name, hits, tt.want)
+		}
+	}
+}
+
+func TestSearchLimitAndOverlap(t *testing.T) {
+	dir := t.TempDir()
+	rel := saveLines(t, dir, []string{"x1", "y", "x2", "y", "x3"})
+	match := func(line string) bool { return strings.HasPrefix(line, "x") }
+
+	hits, _, err := Search(context.Background(), dir, rel, match, 1, 2, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("hits = %d, want 2", len(hits))
+	}
+	// 第二个匹配收集完后文后才停止
+	if !reflect.DeepEqual(hits[1].After, []string{"y"}) || !reflect.DeepEqual(hits[1].Before, []string{"y"}) {
+		t.Errorf("second hit context = %v / %v", hits[1].Before, hits[1].After)
+	}
+}
+
+func TestSearchBudget(t *testing.T) {
+	dir := t.TempDir()
+	lines := make([]string, 1000)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %04d", i)
+	}
+	rel := saveLines(t, dir, lines)
+
+	// 每行 10 字节，预算只够读到前 100 行
+	hits, read, err := Search(context.Background(), dir, rel, func(string) bool { return true }, 0, 10000, 1000)
+	if !errors.Is(err, ErrSearchBudget) {
+		t.Fatalf("error = %v, want ErrSearchBudget", err)
+	}
+	if read != 1000 {
+		t.Errorf("read = %d, want 1000", read)
+	}
+	if len(hits) == 0 || len(hits) > 100 {
+		t.Errorf("hits = %d, want 1..100", len(hits))
+	}
+
+	// 预算足够时读完整个文件
+	_, read, err = Search(context.Background(), dir, rel, func(string) bool { return false }, 0, 10, 1<<20)
+	if err != nil || read != 10000 {
+		t.Errorf("read, err = %d, %v, want 10000, nil", read, err)
+	}
+}
+
+func TestSearchCancelled(t *testing.T) {
+	dir := t.TempDir()
+	rel := saveLines(t, dir, []string{"a"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := Search(ctx, dir, rel, equals("a"), 0, 10, 1<<20); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Package outputstore 以 gzip 压缩保存任务执行输出，按任务 ID 和执行 ID 组织文件
+package outputstore
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// DefaultDir 未配置时的存储目录
+const DefaultDir = "./static/output/"
+
+// 任务 ID 作为目录名，只允许安全字符
+var safeName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ErrInvalidTaskID 任务 ID 不能用作目录名
+var ErrInvalidTaskID = errors.New("任务 ID 含有非法字符")
+
+// Result 一次保存的结果
+type Result struct {
+	Path       string // 相对存储目录的路径
+	Size       int64  // 保存的原始字节数
+	StoredSize int64  // 压缩后字节数
+	Truncated  bool   // 超过 maxSize 被截断
+}
+
+// Path 返回执行输出在存储目录下的相对路径
+func Path(taskID string, runID int64) (string, error) {
+	if !safeName.MatchString(taskID) || taskID == "." || taskID == ".." {
+		return "", ErrInvalidTaskID
+	}
+	return filepath.Join(taskID, strconv.FormatInt(runID, 10)+".log.gz"), nil
+}
+
+// Save 压缩保存 r 中最多 maxSize 字节的内容，maxSize 不大于 0 时不限制。
+// 先写入临时文件再改名，重复上传时覆盖之前的内容
+func Save(dir, taskID string, runID int64, r io.Reader, maxSize int64) (*Result, error) {
+	rel, err := Path(taskID, runID)
+	if err != nil {
+		return nil, err
+	}
+	full := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建输出文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	src := r
+	if maxSize > 0 {
+		src = io.LimitReader(r, maxSize)
+	}
+	zw := gzip.NewWriter(tmp)
+	n, err := io.Copy(zw, src)
+	if err != nil {
+		return nil, fmt.Errorf("写入输出文件失败: %w", err)
+	}
+	res := &Result{Path: rel, Size: n}
+	if maxSize > 0 && n == maxSize {
+		// 还能读到数据说明超出了限制
+		if m, _ := io.ReadFull(r, make([]byte, 1)); m > 0 {
+			res.Truncated = true
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("写入输出文件失败: %w", err)
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	res.StoredSize = info.Size()
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), full); err != nil {
+		return nil, fmt.Errorf("保存输出文件失败: %w", err)
+	}
+	return res, nil
+}
+
+// Open 打开保存的输出，读取时解压
+func Open(dir, rel string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(dir, rel))
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("读取输出文件失败: %w", err)
+	}
+	return &reader{Reader: zr, f: f}, nil
+}
+
+// reader 关闭时同时关闭底层文件
+type reader struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (r *reader) Close() error {
+	r.Reader.Close()
+	return r.f.Close()
+}
+
+// Remove 删除保存的输出，文件不存在时忽略
+func Remove(dir, rel string) error {
+	if err := os.Remove(filepath.Join(dir, rel)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
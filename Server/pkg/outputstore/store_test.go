@@ -0,0 +1,94 @@
+package outputstore
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		maxSize   int64
+		want      string
+		truncated bool
+	}{
+		{"empty", "", 16, "", false},
+		{"unlimited", strings.Repeat("line\n", 1000), 0, strings.Repeat("line\n", 1000), false},
+		{"below limit", "hello\n", 16, "hello\n", false},
+		{"exactly at limit", "0123456789", 10, "0123456789", false},
+		{"over limit", "0123456789abc", 10, "0123456789", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			res, err := Save(dir, "task-1", 42, strings.NewReader(tt.content), tt.maxSize)
+			if err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			if res.Path != filepath.Join("task-1", "42.log.gz") {
+				t.Errorf("Path = %q", res.Path)
+			}
+			if res.Size != int64(len(tt.want)) || res.Truncated != tt.truncated {
+				t.Errorf("Size, Truncated = %d, %v, want %d, %v", res.Size, res.Truncated, len(tt.want), tt.truncated)
+			}
+			info, err := os.Stat(filepath.Join(dir, res.Path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != res.StoredSize {
+				t.Errorf("StoredSize = %d, file size %d", res.StoredSize, info.Size())
+			}
+
+			r, err := Open(dir, res.Path)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveOverwrites(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Save(dir, "t", 1, strings.NewReader("first"), 0); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Save(dir, "t", 1, strings.NewReader("second"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(dir, res.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got, _ := io.ReadAll(r); !bytes.Equal(got, []byte("second")) {
+		t.Errorf("content = %q, want %q", got, "second")
+	}
+	// 临时文件不应残留
+	entries, _ := os.ReadDir(filepath.Join(dir, "t"))
+	if len(entries) != 1 {
+		t.Errorf("files in task dir = %d, want 1", len(entries))
+	}
+}
+
+func TestSaveInvalidTaskID(t *testing.T) {
+	for _, id := range []string{"", ".", "..", "../x", "a/b", "a b"} {
+		if _, err := Save(t.TempDir(), id, 1, strings.NewReader("x"), 0); !errors.Is(err, ErrInvalidTaskID) {
+			t.Errorf("Save(%q) error = %v, want ErrInvalidTaskID", id, err)
+		}
+	}
+}
@@ -10,7 +10,7 @@ var node *sf.Node
 
 func Init(startTime string, machineId int64) (err error) {
 	var st time.Time
-	st, err = time.Parse("2006-01-01", startTime)
+	st, err = time.Parse("2006-01-02", startTime)
 	if err != nil {
 		return
 	}
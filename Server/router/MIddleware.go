@@ -1,10 +1,14 @@
 package router
 
 import (
-	"Server/common"
+	"Server/dao/enrolloption"
+	"Server/middlewares"
+	"Server/ws"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"net/http"
 )
 
 func DBMiddleware(db *sqlx.DB) gin.HandlerFunc {
@@ -21,9 +25,39 @@ func ETCDMiddleware(cli *clientv3.Client) gin.HandlerFunc {
 		c.Next()
 	}
 }
-func WebSocketManagerMiddleware(wsManager *common.WebSocketManager) gin.HandlerFunc {
+
+// AgentAuthMiddleware 客户端下载脚本和上传日志时以客户端证书或会话 token 认证。
+// 出示的证书必须已登记且未吊销；requireCert 为 true 时客户端必须出示证书。
+// 非客户端请求按登录用户校验，角色不低于 userRole
+func AgentAuthMiddleware(db *sqlx.DB, requireCert bool, userRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Set("wsManager", wsManager) // 正确存储 WebSocketManager
+		if c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) > 0 {
+			agentID, err := enrolloption.CheckCertificate(db, c.Request.TLS.PeerCertificates[0])
+			if err != nil {
+				zap.L().Warn("拒绝无效的客户端证书", zap.String("path", c.Request.URL.Path), zap.Error(err))
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": enrolloption.ErrCertificateInvalid.Error()})
+				return
+			}
+			c.Set("agent_id", agentID)
+			c.Next()
+			return
+		}
+
+		agentID := c.GetHeader("X-Agent-ID")
+		if agentID != "" {
+			if requireCert || !ws.CheckAgentToken(agentID, c.GetHeader("X-Agent-Token")) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "agent authentication failed"})
+				return
+			}
+			c.Set("agent_id", agentID)
+			c.Next()
+			return
+		}
+
+		if !middlewares.CheckUser(c, db, userRole) {
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -1,13 +1,22 @@
 package router
 
 import (
-	"Server/common"
 	"Server/controller"
+	"Server/controller/alarmwithgui"
+	"Server/controller/auditwithgui"
+	"Server/controller/authwithgui"
+	"Server/controller/clusterwithgui"
+	"Server/controller/cronwithgui"
+	"Server/controller/enrollwithgui"
+	"Server/controller/hostwithgui"
+	"Server/controller/metricwithgui"
 	"Server/controller/taskwithgui"
 	"Server/dao/mysql"
 	"Server/logger"
 	"Server/middlewares"
 	"Server/models"
+	"Server/models/audittype"
+	"Server/models/authtype"
 	"Server/ws"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -18,12 +27,11 @@ import (
 	"net/http"
 )
 
-func Setup(mode, ClientUrl string, size int64, savedir string, db *sqlx.DB, cli *clientv3.Client, wsManager *common.WebSocketManager) *gin.Engine {
+func Setup(mode, ClientUrl string, size int64, savedir string, db *sqlx.DB, cli *clientv3.Client, requireAgentCert bool) *gin.Engine {
 	if mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	r := gin.New()
-	r.Use(WebSocketManagerMiddleware(wsManager))
 	r.Use(DBMiddleware(db))
 	r.Use(ETCDMiddleware(cli))
 	r.Use(middlewares.Cors(ClientUrl))
@@ -36,13 +44,74 @@ func Setup(mode, ClientUrl string, size int64, savedir string, db *sqlx.DB, cli
 	r.StaticFile("/swagger.json", "./docs/swagger.json")
 
 	url := ginSwagger.URL("/swagger.json")
-	r.POST("/TaskManager", taskwithgui.TaskManager)
 	r.GET("/wsclient", ws.WebsocketHandler)
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
-	r.POST("/login", controller.LoginUserVerif)
-	r.POST("/download", controller.DownloadHandler)
-	r.POST("/control", ws.ControlClientTask)
-	r.POST("/upload", func(ctx *gin.Context) {
+	// 管理操作记录审计日志
+	audit := func(action string) gin.HandlerFunc { return middlewares.Audit(db, action) }
+
+	r.POST("/login", audit(audittype.ActionLogin), controller.LoginUserVerif)
+
+	// 管理接口需登录，按角色授权：viewer 只读，operator 可下发任务和修改主机，admin 可管理用户和客户端注册
+	viewer := r.Group("", middlewares.JWTAuthMiddleware(), middlewares.RequireRole(db, authtype.RoleViewer))
+	operator := r.Group("", middlewares.JWTAuthMiddleware(), middlewares.RequireRole(db, authtype.RoleOperator))
+	admin := r.Group("", middlewares.JWTAuthMiddleware(), middlewares.RequireRole(db, authtype.RoleAdmin))
+
+	viewer.GET("/auth/me", authwithgui.Me)
+	viewer.GET("/metrics/query", metricwithgui.QueryMetrics)
+	viewer.GET("/metrics/aggregate", metricwithgui.AggregateMetrics)
+	viewer.GET("/alarm/rules", alarmwithgui.ListRules)
+	viewer.GET("/alarm/events", alarmwithgui.ListEvents)
+	viewer.GET("/hosts", hostwithgui.ListHosts)
+	viewer.GET("/hosts/:id", hostwithgui.GetHost)
+	viewer.GET("/tasks/output/search", taskwithgui.SearchRunOutput)
+	viewer.GET("/tasks/:id", taskwithgui.GetTaskState)
+	viewer.GET("/tasks/:id/runs", taskwithgui.ListTaskRuns)
+	viewer.GET("/tasks/:id/runs/:run_id/attempts", taskwithgui.ListRunAttempts)
+	viewer.GET("/tasks/:id/runs/:run_id/output", taskwithgui.GetRunOutput)
+	viewer.GET("/tasks/:id/runs/:run_id/output/download", taskwithgui.DownloadRunOutput)
+	viewer.GET("/tasks/:id/runs/:run_id/output/stream", taskwithgui.TailRunOutput)
+	viewer.GET("/agents/:id/commands", taskwithgui.GetAgentCommands)
+	viewer.GET("/cron/jobs", cronwithgui.ListJobs)
+	viewer.GET("/cron/jobs/:name/logs", cronwithgui.ListJobLogs)
+
+	operator.POST("/TaskManager", audit(audittype.ActionTask), taskwithgui.TaskManager)
+	operator.POST("/control", audit(audittype.ActionTaskControl), ws.ControlClientTask)
+	operator.POST("/alarm/rules", audit(audittype.ActionAlarmRuleSave), alarmwithgui.SaveRule)
+	operator.DELETE("/alarm/rules/:id", audit(audittype.ActionAlarmRuleDelete), alarmwithgui.DeleteRule)
+	operator.PUT("/hosts/:id", audit(audittype.ActionHostUpdate), hostwithgui.UpdateHost)
+	operator.PUT("/hosts/:id/tags", audit(audittype.ActionHostTags), hostwithgui.SetHostTags)
+	operator.POST("/cron/jobs/:name/kill", audit(audittype.ActionCronJobKill), cronwithgui.KillJob)
+
+	admin.DELETE("/hosts/:id", audit(audittype.ActionHostDecommission), hostwithgui.DecommissionHost)
+	// 定时任务命令在服务端执行，只允许管理员增删
+	admin.POST("/cron/jobs", audit(audittype.ActionCronJobSave), cronwithgui.SaveJob)
+	admin.DELETE("/cron/jobs/:name", audit(audittype.ActionCronJobDelete), cronwithgui.DeleteJob)
+	admin.GET("/enroll/tokens", enrollwithgui.ListTokens)
+	admin.POST("/enroll/tokens", audit(audittype.ActionEnrollTokenAdd), enrollwithgui.CreateToken)
+	admin.DELETE("/enroll/tokens/:id", audit(audittype.ActionEnrollTokenDel), enrollwithgui.RevokeToken)
+	admin.GET("/agents/online", hostwithgui.ListOnlineAgents)
+	admin.GET("/servers", clusterwithgui.ListServers)
+	admin.GET("/agents/credentials", enrollwithgui.ListCredentials)
+	admin.DELETE("/agents/:id/credential", audit(audittype.ActionCredentialRevoke), enrollwithgui.RevokeCredential)
+	admin.POST("/agents/:id/credential/reset", audit(audittype.ActionCredentialReset), enrollwithgui.ResetCredential)
+	admin.GET("/agents/:id/certificates", enrollwithgui.ListCertificates)
+	admin.POST("/agents/:id/certificates/rotate", audit(audittype.ActionCertRotate), enrollwithgui.RotateCertificate)
+	admin.DELETE("/certificates/:serial", audit(audittype.ActionCertRevoke), enrollwithgui.RevokeCertificate)
+	admin.GET("/auth/users", authwithgui.ListUsers)
+	admin.POST("/auth/users", audit(audittype.ActionUserCreate), authwithgui.CreateUser)
+	admin.PUT("/auth/users/:id", audit(audittype.ActionUserUpdate), authwithgui.UpdateUser)
+	admin.PUT("/auth/users/:id/groups", audit(audittype.ActionUserGroups), authwithgui.SetUserGroups)
+	admin.GET("/auth/groups", authwithgui.ListGroups)
+	admin.POST("/auth/groups", audit(audittype.ActionGroupCreate), authwithgui.CreateGroup)
+	admin.DELETE("/auth/groups/:id", audit(audittype.ActionGroupDelete), authwithgui.DeleteGroup)
+	admin.PUT("/auth/groups/:id/hosts", audit(audittype.ActionGroupHosts), authwithgui.SetGroupHosts)
+	admin.GET("/audit/logs", auditwithgui.ListAuditLogs)
+
+	// 客户端以证书或会话 token 下载脚本、上传日志，管理端用户需 operator 角色
+	agentAuth := AgentAuthMiddleware(db, requireAgentCert, authtype.RoleOperator)
+	r.POST("/download", agentAuth, controller.DownloadHandler)
+	r.POST("/tasks/:id/runs/:run_id/output", agentAuth, taskwithgui.UploadRunOutput)
+	r.POST("/upload", agentAuth, audit(audittype.ActionFileUpload), func(ctx *gin.Context) {
 		forms, err := ctx.MultipartForm()
 		if err != nil {
 			fmt.Println("error", err)
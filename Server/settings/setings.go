@@ -9,18 +9,25 @@ import (
 var Conf = new(AppConfig)
 
 type AppConfig struct {
-	Name         string `mapstructure:"name"`
-	Mode         string `mapstructure:"mode"`
-	Version      string `mapstructure:"version"`
-	Port         int    `mapstructure:"port"`
-	StartTime    string `mapstructure:"start_time"`
-	MachineId    int64  `mapstructure:"machine_id"`
-	ClientUrl    string `mapstructure:"client_url"`
-	*LogConfig   `mapstructure:"log"`
-	*MySQLConfig `mapstructure:"mysql"`
-	*FileConfig  `mapstructure:"file"`
-	*WXworkToke  `mapstructure:"WXWork"`
-	*EtcdConfig  `mapstructure:"etcd"`
+	Name           string `mapstructure:"name"`
+	Mode           string `mapstructure:"mode"`
+	Version        string `mapstructure:"version"`
+	Port           int    `mapstructure:"port"`
+	StartTime      string `mapstructure:"start_time"`
+	MachineId      int64  `mapstructure:"machine_id"`
+	ClientUrl      string `mapstructure:"client_url"`
+	*LogConfig     `mapstructure:"log"`
+	*MySQLConfig   `mapstructure:"mysql"`
+	*FileConfig    `mapstructure:"file"`
+	*WXworkToke    `mapstructure:"WXWork"`
+	*EtcdConfig    `mapstructure:"etcd"`
+	*MetricsConfig `mapstructure:"metrics"`
+	*AlarmConfig   `mapstructure:"alarm"`
+	*NotifyConfig  `mapstructure:"notify"`
+	TLS            *TLSConfig `mapstructure:"tls"`
+	*AuthConfig    `mapstructure:"auth"`
+	*OutputConfig  `mapstructure:"output"`
+	*CronConfig    `mapstructure:"cron"`
 }
 type FileConfig struct {
 	Filemaxsize int64  `mapstructure:"filemaxsize"`
@@ -61,6 +68,73 @@ type EtcdConfig struct {
 	ServerName string `mapstructure:"server_name"`
 }
 
+type MetricsConfig struct {
+	RawRetention    int `mapstructure:"raw_retention"`    // 原始样本保留小时数
+	MinuteRetention int `mapstructure:"minute_retention"` // 1 分钟汇总保留天数
+	HourRetention   int `mapstructure:"hour_retention"`   // 1 小时汇总保留天数
+}
+
+type AlarmConfig struct {
+	Interval        int `mapstructure:"interval"`         // 规则评估间隔秒数
+	DefaultDuration int `mapstructure:"default_duration"` // notification 表旧阈值的持续分钟数
+}
+
+type NotifyConfig struct {
+	RetryAttempts    int               `mapstructure:"retry_attempts"`    // 每个渠道的总尝试次数
+	RetryBackoff     int               `mapstructure:"retry_backoff"`     // 首次重试等待秒数，之后翻倍
+	DedupWindow      int               `mapstructure:"dedup_window"`      // 同一报警同一状态的去重分钟数
+	RateLimit        int               `mapstructure:"rate_limit"`        // 同一报警每小时最多发送条数
+	WeComMaxBytes    int               `mapstructure:"wecom_max_bytes"`   // 单条企业微信消息最大字节数
+	DingTalkSecret   string            `mapstructure:"dingtalk_secret"`   // 钉钉机器人加签密钥
+	WebhookURL       string            `mapstructure:"webhook_url"`       // 通用回调地址
+	WebhookHeader    map[string]string `mapstructure:"webhook_header"`    // 通用回调附加请求头
+	FiringTemplate   string            `mapstructure:"firing_template"`   // 报警消息模板
+	ResolvedTemplate string            `mapstructure:"resolved_template"` // 恢复消息模板
+	SMTP             *SMTPConfig       `mapstructure:"smtp"`
+}
+
+// OutputConfig 任务执行输出的存储配置
+type OutputConfig struct {
+	Dir       string `mapstructure:"dir"`       // 存储目录，按任务 ID 分子目录
+	MaxSize   int64  `mapstructure:"max_size"`  // 单次执行保存的最大 MB 数，超出部分截断
+	Retention int    `mapstructure:"retention"` // 保留天数
+}
+
+// CronConfig 分布式定时任务配置
+type CronConfig struct {
+	Worker   bool   `mapstructure:"worker"`    // 本实例是否参与执行定时任务
+	Shell    string `mapstructure:"shell"`     // 执行命令使用的 shell
+	KillTTL  int    `mapstructure:"kill_ttl"`  // 终止标记的存活秒数，最少 5 秒
+	LockHold int    `mapstructure:"lock_hold"` // 任务锁至少持有到计划时间之后的秒数，需大于 worker 之间的最大时钟偏差，最少 2 秒
+}
+
+// AuthConfig 管理后台登录配置
+type AuthConfig struct {
+	JWTSecret   string `mapstructure:"jwt_secret"`   // JWT 签名密钥
+	TokenExpire int    `mapstructure:"token_expire"` // 登录有效小时数
+}
+
+// TLSConfig 服务端 https/wss 与客户端 mTLS 配置
+type TLSConfig struct {
+	Enable           bool     `mapstructure:"enable"`
+	CertFile         string   `mapstructure:"cert_file"`          // 服务端证书，不存在且配置了 CA 时自动签发
+	KeyFile          string   `mapstructure:"key_file"`           // 服务端私钥
+	Hosts            []string `mapstructure:"hosts"`              // 自动签发服务端证书时写入的域名或 IP
+	CACert           string   `mapstructure:"ca_cert"`            // 内置 CA 证书，用于校验客户端证书
+	CAKey            string   `mapstructure:"ca_key"`             // 内置 CA 私钥，两个文件都不存在时自动生成
+	RequireAgentCert bool     `mapstructure:"require_agent_cert"` // 客户端必须出示有效证书才能获取会话 token
+	CertValidity     int      `mapstructure:"cert_validity"`      // 签发证书的有效天数
+}
+
+type SMTPConfig struct {
+	Host     string   `mapstructure:"host"`
+	Port     int      `mapstructure:"port"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+}
+
 func Init(configfile string) (err error) {
 	viper.SetConfigFile(configfile)
 	//指定配置文件
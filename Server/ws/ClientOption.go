@@ -1,86 +1,81 @@
 package ws
 
 import (
-	"Server/common"
+	"Server/controller"
+	"Server/dao/task/mysqloption"
+	"Server/models/tasktype"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
 	"log"
 	"net/http"
-	"time"
 )
 
 // 控制指定客户端的指定任务
 
 func ControlClientTask(c *gin.Context) {
-	clientID := c.Param("client_ip") // 从 URL 中获取客户端 ID
-	taskID := c.Query("task_id")     // 从查询参数中获取任务 ID
+	agentID := c.Query("agent_id") // 目标客户端 ID
+	taskID := c.Query("task_id")   // 从查询参数中获取任务 ID
 	action := c.Query("action")
 	crondExpression := c.Query("crondExpression")
 	scriptPath := c.Query("scriptPath")
-	if err := SendTaskToClient(clientID, taskID, action, crondExpression, scriptPath); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if agentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "agent_id 不能为空"})
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"status": "任务发送成功"})
-}
-
-// SendTaskToClient 发送任务指令到指定客户端
-// SendTaskToClient 发送任务指令到指定客户端
-func SendTaskToClient(clientIP, taskID, action, crondExpression, scriptPath string) error {
-	common.ClientsMutex.Lock()
-
-	// 查找目标客户端
-	var targetClient *common.WebSocketClient
-	for _, client := range common.Clients {
-		fmt.Println(client)
-		// 如果 WebSocket 连接的地址匹配，就认为是目标客户端
-		if client.Conn.RemoteAddr().String() == clientIP {
-			targetClient = client
-			break
-		}
+	controller.SetAuditTarget(c, fmt.Sprintf("agent_id=%s,task_id=%s,action=%s", agentID, taskID, action))
+	db, exists := c.Get("db")
+	if !exists {
+		controller.ResopnseError(c, controller.CodeServerApiType)
+		return
 	}
-
-	// 如果未找到目标客户端，打印当前客户端列表
-	if targetClient == nil {
-		log.Printf("客户端未找到：IP %s", clientIP)
-		log.Println("当前连接的客户端列表：")
-
-		// 打印所有当前连接的客户端
-		for _, client := range common.Clients {
-			log.Printf("客户端 IP: %s, WebSocket地址: %s, 过期时间: %s",
-				client.ClientIP, client.Conn.RemoteAddr().String(), client.ExpiresAt.Format(time.RFC3339))
+	if !controller.CheckAgentScope(c, db.(*sqlx.DB), agentID) {
+		return
+	}
+	// 任务必须属于指定的客户端，停止调度的任务进入 cancelled
+	if action == "stop" {
+		if _, err := mysqloption.TransitionTask(db.(*sqlx.DB), agentID, taskID, tasktype.TaskCancelled); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if taskID != "" {
+		if err := mysqloption.CheckTaskAgent(db.(*sqlx.DB), agentID, taskID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-
-		common.ClientsMutex.Unlock() // 解锁
-		return fmt.Errorf("客户端未找到：IP %s", clientIP)
+	}
+	seq, delivered, err := SendTaskToClient(agentID, taskID, action, crondExpression, scriptPath)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !delivered {
+		c.JSON(http.StatusAccepted, gin.H{"status": "客户端不在线，任务已排队", "seq": seq, "delivered": false, "queued": true})
+		return
 	}
 
-	conn := targetClient.Conn
-	common.ClientsMutex.Unlock() // 提前解锁
+	c.JSON(http.StatusOK, gin.H{"status": "任务发送成功", "seq": seq, "delivered": true, "queued": false})
+}
 
+// SendTaskToClient 按客户端 ID 发送任务指令到指定客户端，客户端不在线时排队等待重连，返回指令序号和是否已送达
+func SendTaskToClient(agentID, taskID, action, crondExpression, scriptPath string) (int64, bool, error) {
 	// 创建任务消息
 	taskMessage := map[string]interface{}{
 		"action":           action,          // add, stop 等操作
 		"task_id":          taskID,          // 任务 ID
+		"agent_id":         agentID,         // 目标客户端 ID
 		"crond_expression": crondExpression, // 定时任务表达式
 		"script_path":      scriptPath,      // 脚本路径
 	}
-
-	// 将任务消息编码为 JSON 并发送
-	err := conn.WriteJSON(taskMessage)
+	seq, delivered, err := DeliverCommand(agentID, taskMessage)
 	if err != nil {
-		// 记录发送失败的错误信息
-		log.Printf("发送任务给客户端 %s 失败: %v", clientIP, err)
-
-		// WebSocket 出现问题后，可以考虑移除该客户端连接
-		common.ClientsMutex.Lock()
-		delete(common.Clients, conn)
-		common.ClientsMutex.Unlock()
-
-		return fmt.Errorf("发送任务给客户端 %s 失败: %v", clientIP, err)
+		log.Printf("发送任务给客户端 %s 失败: %v", agentID, err)
+		return 0, false, err
 	}
-
-	log.Printf("任务 %s 已成功发送给客户端 %s", taskID, clientIP)
-	return nil
+	if delivered {
+		log.Printf("任务 %s 已成功发送给客户端 %s", taskID, agentID)
+	} else {
+		log.Printf("客户端 %s 不在线，任务 %s 已排队", agentID, taskID)
+	}
+	return seq, delivered, nil
 }
@@ -0,0 +1,145 @@
+package ws
+
+import (
+	"Server/common"
+	"Server/controller"
+	"Server/dao/clientoption"
+	"Server/dao/clusteroption"
+	"Server/dao/task/mysqloption"
+	"Server/models/clustertype"
+	"Server/pkg/instance"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// ErrAgentOffline 客户端未连接到任何实例
+var ErrAgentOffline = errors.New("客户端不在线")
+
+// 由 InitHandlers 设置：cluster 用于查询客户端所在实例和转发消息，store 保存下发的指令
+var (
+	cluster *clientv3.Client
+	store   *sqlx.DB
+)
+
+// SendToAgent 把消息发送给客户端：连接在本实例时直接发送，
+// 否则按 /agents/online/<id> 找到所在实例，写入该实例的收件箱
+func SendToAgent(agentID string, msg map[string]interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+	return sendData(agentID, data)
+}
+
+// DeliverCommand 保存指令并分配序号后只发送给目标客户端，返回序号和是否已写入客户端所在的连接或实例收件箱。
+// 未送达的指令保持未确认，在客户端下次握手后或超时未确认时重发，因此发送失败不作为错误返回
+func DeliverCommand(agentID string, msg map[string]interface{}) (int64, bool, error) {
+	seq, data, err := mysqloption.EnqueueCommand(store, agentID, msg)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := sendData(agentID, data); err != nil {
+		if !errors.Is(err, ErrAgentOffline) {
+			zap.L().Warn("发送指令失败，等待重发", zap.String("agent_id", agentID), zap.Int64("seq", seq), zap.Error(err))
+		}
+		return seq, false, nil
+	}
+	return seq, true, nil
+}
+
+// DisconnectAgent 断开客户端在本实例的连接，客户端连接在其他实例时转发断开请求。
+// 返回本实例断开的连接数，已转发给其他实例时计为 1
+func DisconnectAgent(agentID, reason string) int {
+	closed := common.DisconnectClient(agentID, reason)
+	owner, err := ownerOf(agentID)
+	if err != nil {
+		zap.L().Warn("查询客户端所在实例失败", zap.String("agent_id", agentID), zap.Error(err))
+		return closed
+	}
+	if owner == "" {
+		return closed
+	}
+	env := &clustertype.Envelope{Kind: clustertype.EnvelopeDisconnect, AgentID: agentID, Reason: reason}
+	if err := clusteroption.SendToInbox(cluster, owner, env); err != nil {
+		zap.L().Warn("转发断开请求失败", zap.String("agent_id", agentID), zap.String("instance", owner), zap.Error(err))
+		return closed
+	}
+	return closed + 1
+}
+
+// CheckAgentToken 校验客户端 HTTP 请求携带的会话 token。客户端连接在本实例时按连接校验，
+// 连接在其他实例时按 etcd 中的会话 token 校验，客户端必须在线
+func CheckAgentToken(agentID, token string) bool {
+	if common.CheckAgentToken(agentID, token) {
+		return true
+	}
+	if !controller.VerifyTokenForClient(agentID, token) {
+		return false
+	}
+	p, err := clientoption.GetPresence(cluster, agentID)
+	return err == nil && p != nil
+}
+
+// HandleEnvelope 处理其他实例转发到本实例收件箱的消息
+func HandleEnvelope(env *clustertype.Envelope) {
+	switch env.Kind {
+	case clustertype.EnvelopeSend:
+		// 转发途中客户端断开或已切换到其他实例时，未确认的指令由重发补齐
+		client := common.FindClientByID(env.AgentID)
+		if client == nil {
+			zap.L().Warn("转发的消息到达时客户端已不在本实例", zap.String("agent_id", env.AgentID), zap.String("from", env.From))
+			return
+		}
+		if err := writeLocal(client, env.Message); err != nil {
+			zap.L().Warn("发送转发的消息失败", zap.String("agent_id", env.AgentID), zap.Error(err))
+		}
+	case clustertype.EnvelopeDisconnect:
+		common.DisconnectClient(env.AgentID, env.Reason)
+	default:
+		zap.L().Warn("未知的转发消息类型", zap.String("kind", env.Kind), zap.String("from", env.From))
+	}
+}
+
+// sendData 把已序列化的消息写入客户端在本实例的连接，或转发到客户端所在实例
+func sendData(agentID string, data []byte) error {
+	if client := common.FindClientByID(agentID); client != nil {
+		return writeLocal(client, data)
+	}
+	owner, err := ownerOf(agentID)
+	if err != nil {
+		return err
+	}
+	if owner == "" {
+		return fmt.Errorf("%w: %s", ErrAgentOffline, agentID)
+	}
+	return clusteroption.SendToInbox(cluster, owner, &clustertype.Envelope{
+		Kind:    clustertype.EnvelopeSend,
+		AgentID: agentID,
+		Message: data,
+	})
+}
+
+// ownerOf 返回客户端所在的其他实例，不在线或在线记录指向本实例时为空
+func ownerOf(agentID string) (string, error) {
+	p, err := clientoption.GetPresence(cluster, agentID)
+	if err != nil {
+		return "", err
+	}
+	if p == nil || p.Server == "" || p.Server == instance.ID() {
+		return "", nil
+	}
+	return p.Server, nil
+}
+
+// writeLocal 写入本实例的连接，失败时关闭连接，由读循环清理
+func writeLocal(client *common.WebSocketClient, data []byte) error {
+	if err := common.WriteMessage(client.Conn, data); err != nil {
+		client.Conn.Close()
+		return fmt.Errorf("发送消息给客户端 %s 失败: %w", client.ClientID, err)
+	}
+	return nil
+}
@@ -2,24 +2,40 @@ package ws
 
 import (
 	"Server/common"
-	"Server/controller"
-	"Server/dao/clientoption"
 	"Server/dao/task"
 	"Server/wshandler"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/jmoiron/sqlx"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"log"
+	"net"
 	"time"
 )
 
-func InitHandlers(taskManager *task.Manager, db *sqlx.DB, etcd *clientv3.Client) {
+func InitHandlers(taskManager *task.Manager, db *sqlx.DB, etcd *clientv3.Client, issuer *wshandler.CertIssuer, requireCert bool,
+	alerter wshandler.RunAlerter) {
+	cluster, store = etcd, db
 	common.RegisterHandler("ping", &wshandler.PingHandler{})
 	common.RegisterHandler("update", &wshandler.UpdateHandler{})
-	common.RegisterHandler("request_token", &wshandler.TokenHandler{DB: db})
+	common.RegisterHandler("request_token", &wshandler.TokenHandler{DB: db, Etcd: etcd, RequireCert: requireCert})
+	common.RegisterHandler("enroll", &wshandler.EnrollHandler{DB: db, Issuer: issuer})
+	common.RegisterHandler("renew_cert", &wshandler.CertRenewHandler{DB: db, Issuer: issuer})
 	common.RegisterHandler("demo", &wshandler.DemoHandle{})
-	common.RegisterHandler("task_request", &wshandler.DispatchTaskHandler{TaskManager: taskManager, Db: db})
+	common.RegisterHandler("task_request", &wshandler.DispatchTaskHandler{TaskManager: taskManager, Db: db, Alerter: alerter})
+	common.RegisterHandler("task_output", &wshandler.TaskOutputHandler{Db: db})
+	common.RegisterHandler("metrics_report", &wshandler.MetricsReportHandler{Db: db})
+	common.RegisterHandler("host_register", &wshandler.HostRegisterHandler{Db: db})
+	common.RegisterHandler("command_ack", &wshandler.CommandAckHandler{Db: db})
+}
+
+// 握手阶段的消息无需会话 token，其余消息必须携带握手时签发的 token
+var publicMessageTypes = map[string]bool{
+	"ping":          true,
+	"enroll":        true,
+	"request_token": true,
 }
 
 // WebSocketHandler 处理 WebSocket 连接
@@ -31,14 +47,14 @@ func WebsocketHandler(c *gin.Context) {
 		return
 	}
 	defer conn.Close()
-	db, exists := c.Get("db")
+	cli, exists := c.Get("etcd")
 	if !exists {
-		log.Println("Database connection not found")
+		log.Println("Etcd client not found")
 		return
 	}
-	sqlDB, ok := db.(*sqlx.DB) // 进行类型断言，确保是 *sqlx.DB
+	etcdCli, ok := cli.(*clientv3.Client)
 	if !ok {
-		log.Println("Invalid database connection")
+		log.Println("Invalid etcd client")
 		return
 	}
 	clientIP := c.ClientIP() // 获取客户端的 IP 地址
@@ -48,30 +64,25 @@ func WebsocketHandler(c *gin.Context) {
 		ExpiresAt: time.Now().Add(24 * time.Hour),
 	}
 
-	// 将客户端添加到 Clients 映射
-	common.ClientsMutex.Lock()
-	common.Clients[conn] = client
-	common.ClientsMutex.Unlock()
-	token, expiresAt, _ := controller.GetTokenForClientFromController(clientIP)
+	// 将客户端添加到 Clients 映射，客户端 ID 在 request_token 握手时记录，在线状态也在握手时登记
+	common.AddClient(conn, client)
 
-	// 插入连接记录到数据库
-	err = clientoption.InsertOrUpdateClientConnection(sqlDB, clientIP, token, expiresAt) // 假设 auth_code 传入
-	if err != nil {
-		log.Printf("Failed to insert client connection: %v", err)
-	}
+	// 断开时不撤销在线租约，由租约过期判定离线，客户端在 TTL 内重连到任一实例不会产生离线事件
+	defer common.RemoveClient(conn)
 
-	defer func() {
-		// 删除数据库中的客户端记录
-		err = clientoption.DeleteClientConnection(sqlDB, clientIP)
-		if err != nil {
-			log.Printf("Failed to delete client connection: %v", err)
+	// 客户端的 ping 即心跳，续约后按默认行为回复 pong
+	conn.SetPingHandler(func(appData string) error {
+		go wshandler.RefreshPresence(etcdCli, conn)
+		err := conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(time.Second))
+		if err == websocket.ErrCloseSent {
+			return nil
 		}
-
-		// 客户端断开时移除连接
-		common.ClientsMutex.Lock()
-		delete(common.Clients, conn)
-		common.ClientsMutex.Unlock()
-	}()
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil
+		}
+		return err
+	})
 
 	// 监听 WebSocket 消息
 	for {
@@ -93,6 +104,15 @@ func WebsocketHandler(c *gin.Context) {
 			continue
 		}
 
+		if !publicMessageTypes[msgType] {
+			token, _ := msg["Token"].(string)
+			if !common.CheckSessionToken(conn, token) {
+				log.Printf("Rejected %s message from %s: invalid session token", msgType, conn.RemoteAddr())
+				_ = common.SendJSONResponse(conn, map[string]interface{}{"error": "unauthorized"})
+				continue
+			}
+		}
+
 		handler, exists := common.GetHandler(msgType)
 		if !exists {
 			log.Printf("No handler found for message type: %s", msgType)
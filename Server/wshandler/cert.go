@@ -0,0 +1,67 @@
+package wshandler
+
+import (
+	"Server/common"
+	"Server/dao/enrolloption"
+	"Server/pkg/minica"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"time"
+)
+
+// CertIssuer 使用内置 CA 为客户端签发证书，未启用 TLS 时为 nil
+type CertIssuer struct {
+	CA       *minica.CA
+	Validity time.Duration
+}
+
+// Issue 签发并登记客户端证书，返回 PEM 编码的证书
+func (i *CertIssuer) Issue(db *sqlx.DB, agentID, csrPEM string) (string, time.Time, error) {
+	if i == nil || i.CA == nil {
+		return "", time.Time{}, errors.New("服务端未启用证书签发")
+	}
+	cert, certPEM, err := i.CA.SignCSR([]byte(csrPEM), agentID, i.Validity)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := enrolloption.SaveCertificate(db, agentID, cert); err != nil {
+		return "", time.Time{}, err
+	}
+	return string(certPEM), cert.NotAfter, nil
+}
+
+// CertRenewHandler 已建立会话的客户端提交新的证书请求以轮换证书
+type CertRenewHandler struct {
+	DB     *sqlx.DB
+	Issuer *CertIssuer
+}
+
+func (h *CertRenewHandler) HandleMessage(conn *websocket.Conn, msg map[string]interface{}) error {
+	var req struct {
+		CSR string `json:"csr"`
+	}
+	if err := decodeClientMsg(msg, &req); err != nil {
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": err.Error()})
+		return err
+	}
+	agentID := common.GetClientID(conn)
+	if agentID == "" || req.CSR == "" {
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": "csr 不能为空"})
+		return fmt.Errorf("renew_cert request missing csr")
+	}
+
+	certPEM, notAfter, err := h.Issuer.Issue(h.DB, agentID, req.CSR)
+	if err != nil {
+		zap.L().Error("签发客户端证书失败", zap.String("agent_id", agentID), zap.Error(err))
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": err.Error()})
+		return err
+	}
+	zap.L().Info("客户端证书已轮换", zap.String("agent_id", agentID), zap.Time("not_after", notAfter))
+	return common.SendJSONResponse(conn, map[string]interface{}{
+		"certificate": certPEM,
+		"expires_at":  notAfter.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,68 @@
+package wshandler
+
+import (
+	"Server/common"
+	"Server/dao/task/mysqloption"
+	"Server/models/tasktype"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// RedeliverInterval 未确认指令的重发周期，下发超过该时间仍未确认的指令会再次发送
+const RedeliverInterval = 30 * time.Second
+
+// CommandAckHandler 记录客户端应用指令后的确认
+type CommandAckHandler struct {
+	Db *sqlx.DB
+}
+
+func (h *CommandAckHandler) HandleMessage(conn *websocket.Conn, msg map[string]interface{}) error {
+	var ack tasktype.CommandAck
+	if err := decodeClientMsg(msg, &ack); err != nil {
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": err.Error()})
+		return err
+	}
+	// 只接受连接所属客户端对自己指令的确认
+	agentID := common.GetClientID(conn)
+	if err := mysqloption.AckCommand(h.Db, agentID, &ack); err != nil {
+		zap.L().Error("记录指令确认失败", zap.String("agent_id", agentID), zap.Int64("seq", ack.Seq), zap.Error(err))
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": "记录确认失败"})
+		return fmt.Errorf("记录指令确认失败: %v", err)
+	}
+	return common.SendJSONResponse(conn, map[string]interface{}{"status": "已确认", "seq": ack.Seq})
+}
+
+// RedeliverCommands 按序号把客户端未确认的指令发送到 conn，olderThan 大于 0 时只发送下发超过该时间的指令
+func RedeliverCommands(db *sqlx.DB, conn *websocket.Conn, agentID string, olderThan time.Duration) {
+	commands, err := mysqloption.ListPendingCommands(db, agentID, olderThan)
+	if err != nil {
+		zap.L().Error("查询未确认指令失败", zap.String("agent_id", agentID), zap.Error(err))
+		return
+	}
+	for _, cmd := range commands {
+		if err := common.WriteMessage(conn, []byte(cmd.Payload)); err != nil {
+			zap.L().Warn("重发指令失败", zap.String("agent_id", agentID), zap.Int64("seq", cmd.Seq), zap.Error(err))
+			return
+		}
+	}
+	if len(commands) > 0 {
+		zap.L().Info("已重发未确认的指令", zap.String("agent_id", agentID), zap.Int("count", len(commands)))
+	}
+}
+
+// StartRedelivery 周期性地向连接在本实例的客户端重发超时未确认的指令，阻塞运行
+func StartRedelivery(db *sqlx.DB) {
+	ticker := time.NewTicker(RedeliverInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, agentID := range common.ConnectedAgentIDs() {
+			if client := common.FindClientByID(agentID); client != nil {
+				RedeliverCommands(db, client.Conn, agentID, RedeliverInterval)
+			}
+		}
+	}
+}
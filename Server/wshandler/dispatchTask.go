@@ -5,6 +5,7 @@ import (
 	"Server/dao/task" // 确认导入路径
 	"Server/dao/task/mysqloption"
 	"Server/models/tasktype"
+	"Server/pkg/outputhub"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -13,14 +14,21 @@ import (
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// RunAlerter 接收执行结果，对失败或超时的执行发出报警
+type RunAlerter interface {
+	TaskRunFinished(run *tasktype.TaskRun)
+}
+
 // DispatchTaskHandler 处理任务下发的请求
 type DispatchTaskHandler struct {
 	TaskManager *task.Manager // 任务管理器
 	Db          *sqlx.DB
+	Alerter     RunAlerter // 为空时不报警
 }
 
 // NewDispatchTaskHandler 构造函数，用于初始化 DispatchTaskHandler
@@ -32,6 +40,8 @@ func NewDispatchTaskHandler(tm *task.Manager, db *sqlx.DB) *DispatchTaskHandler
 }
 
 func (h *DispatchTaskHandler) HandleMessage(conn *websocket.Conn, msg map[string]interface{}) error {
+	// 客户端 ID 需在持有 ClientsMutex 之前取得
+	agentID := common.GetClientID(conn)
 	common.ClientsMutex.Lock()
 	defer common.ClientsMutex.Unlock()
 
@@ -64,7 +74,7 @@ func (h *DispatchTaskHandler) HandleMessage(conn *websocket.Conn, msg map[string
 		if !ok {
 			return errors.New("request_id 无效")
 		}
-		taskinfo, err := h.TaskManager.GetTasksByClientIP(client, "inactive")
+		taskinfo, err := h.TaskManager.GetTasksByAgentID(client, "inactive")
 		if err != nil {
 			zap.L().Info("任务获取失败", zap.Error(err))
 			return fmt.Errorf("任务获取失败: %v", err)
@@ -78,7 +88,7 @@ func (h *DispatchTaskHandler) HandleMessage(conn *websocket.Conn, msg map[string
 		if !ok {
 			return errors.New("request_id 无效")
 		}
-		taskinfo, err := h.TaskManager.GetTasksByClientIP(client, "inactive")
+		taskinfo, err := h.TaskManager.GetTasksByAgentID(client, "inactive")
 		if err != nil {
 			zap.L().Info("任务获取失败", zap.Error(err))
 		}
@@ -107,13 +117,70 @@ func (h *DispatchTaskHandler) HandleMessage(conn *websocket.Conn, msg map[string
 		if !ok {
 			return errors.New("new_status 无效")
 		}
-		err := h.TaskManager.UpdateTaskStatus(taskID, newStatus)
+		// 客户端只能更新自己的任务，上报的状态按生命周期校验迁移，旧版客户端的状态先做转换
+		state := tasktype.NormalizeTaskState(newStatus)
+		from, err := mysqloption.TransitionTask(h.Db, agentID, taskID, state)
 		if err != nil {
-			return fmt.Errorf("更新任务状态失败: %v", err)
+			zap.L().Warn("拒绝任务状态更新", zap.String("agent_id", agentID), zap.String("task_id", taskID),
+				zap.String("from", from), zap.String("to", newStatus), zap.Error(err))
+			response = map[string]interface{}{"task_id": taskID, "error": err.Error()}
+			break
 		}
 		response = map[string]interface{}{
 			"task_id": taskID,
 			"status":  "任务状态更新成功",
+			"state":   state,
+		}
+	case "run_start":
+		var p tasktype.RunStart
+		if err := decodeClientMsg(msg, &p); err != nil {
+			return err
+		}
+		runID, err := mysqloption.StartRun(h.Db, agentID, &p)
+		if err != nil {
+			zap.L().Warn("登记任务执行失败", zap.String("agent_id", agentID), zap.String("task_id", p.TaskID), zap.Error(err))
+			response = map[string]interface{}{"task_id": p.TaskID, "error": err.Error()}
+			break
+		}
+		response = map[string]interface{}{
+			"task_id": p.TaskID,
+			"run_id":  strconv.FormatInt(runID, 10),
+		}
+	case "run_skip":
+		var p tasktype.RunSkip
+		if err := decodeClientMsg(msg, &p); err != nil {
+			return err
+		}
+		runID, err := mysqloption.SkipRun(h.Db, agentID, &p)
+		if err != nil {
+			zap.L().Warn("记录跳过的执行失败", zap.String("agent_id", agentID), zap.String("task_id", p.TaskID), zap.Error(err))
+			response = map[string]interface{}{"task_id": p.TaskID, "error": err.Error()}
+			break
+		}
+		response = map[string]interface{}{
+			"task_id": p.TaskID,
+			"run_id":  strconv.FormatInt(runID, 10),
+			"status":  tasktype.RunSkipped,
+		}
+	case "run_finish":
+		var p tasktype.RunFinish
+		if err := decodeClientMsg(msg, &p); err != nil {
+			return err
+		}
+		run, err := mysqloption.FinishRun(h.Db, agentID, &p)
+		if err != nil {
+			zap.L().Warn("记录任务执行结果失败", zap.String("agent_id", agentID), zap.Int64("run_id", p.RunID), zap.Error(err))
+			response = map[string]interface{}{"run_id": strconv.FormatInt(p.RunID, 10), "error": err.Error()}
+			break
+		}
+		// 执行结束后关闭正在查看输出的连接
+		outputhub.Finish(run.RunID)
+		if h.Alerter != nil {
+			go h.Alerter.TaskRunFinished(run)
+		}
+		response = map[string]interface{}{
+			"run_id": strconv.FormatInt(p.RunID, 10),
+			"status": run.Status,
 		}
 	case "query_task":
 		taskidlist, ok := parsedMsg["task_id"].([]interface{})
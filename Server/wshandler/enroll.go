@@ -0,0 +1,59 @@
+package wshandler
+
+import (
+	"Server/common"
+	"Server/dao/enrolloption"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"net"
+)
+
+// EnrollHandler 客户端首次连接时使用注册令牌换取长期凭证
+type EnrollHandler struct {
+	DB     *sqlx.DB
+	Issuer *CertIssuer
+}
+
+func (h *EnrollHandler) HandleMessage(conn *websocket.Conn, msg map[string]interface{}) error {
+	agentID, _ := msg["agent_id"].(string)
+	enrollToken, _ := msg["enrollment_token"].(string)
+	if agentID == "" || enrollToken == "" {
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": "agent_id 和 enrollment_token 不能为空"})
+		return fmt.Errorf("enroll request missing agent_id or enrollment_token")
+	}
+	clientIP, _ := msg["client_ip"].(string)
+	if clientIP == "" {
+		if host, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+			clientIP = host
+		}
+	}
+
+	credential, err := enrolloption.Enroll(h.DB, enrollToken, agentID, clientIP)
+	if err != nil {
+		if errors.Is(err, enrolloption.ErrEnrollDenied) || errors.Is(err, enrolloption.ErrAgentEnrolled) {
+			zap.L().Warn("客户端注册被拒绝", zap.String("agent_id", agentID), zap.String("client_ip", clientIP), zap.Error(err))
+			_ = common.SendJSONResponse(conn, map[string]interface{}{"error": err.Error()})
+			return err
+		}
+		zap.L().Error("客户端注册失败", zap.String("agent_id", agentID), zap.Error(err))
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": "客户端注册失败"})
+		return err
+	}
+
+	zap.L().Info("客户端注册成功", zap.String("agent_id", agentID), zap.String("client_ip", clientIP))
+	response := map[string]interface{}{"credential": credential}
+
+	// 携带证书请求时同时签发客户端证书，签发失败不影响注册结果，客户端可稍后轮换
+	if csr, _ := msg["csr"].(string); csr != "" && h.Issuer != nil {
+		certPEM, _, err := h.Issuer.Issue(h.DB, agentID, csr)
+		if err != nil {
+			zap.L().Error("签发客户端证书失败", zap.String("agent_id", agentID), zap.Error(err))
+		} else {
+			response["certificate"] = certPEM
+		}
+	}
+	return common.SendJSONResponse(conn, response)
+}
@@ -3,11 +3,19 @@ package wshandler
 import (
 	"Server/common"
 	"Server/controller"
-	"Server/dao/clientoption"
+	"Server/dao/enrolloption"
+	"Server/dao/task/mysqloption"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"github.com/jmoiron/sqlx"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"log"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -37,26 +45,50 @@ func (h *PingHandler) HandleMessage(conn *websocket.Conn, msg map[string]interfa
 // Token 消息处理器
 
 type TokenHandler struct {
-	DB *sqlx.DB
+	DB          *sqlx.DB
+	Etcd        *clientv3.Client
+	RequireCert bool // 是否要求客户端出示内置 CA 签发的证书
 }
 
 func (h *TokenHandler) HandleMessage(conn *websocket.Conn, msg map[string]interface{}) error {
-	clientIP, ok := msg["client_ip"].(string)
-	if !ok || clientIP == "" {
-		return fmt.Errorf("client_ip field missing or invalid")
+	agentID, ok := msg["agent_id"].(string)
+	if !ok || agentID == "" {
+		return fmt.Errorf("agent_id field missing or invalid")
+	}
+	// IP 只作为属性记录，客户端未上报时使用连接的远端地址
+	clientIP, _ := msg["client_ip"].(string)
+	if clientIP == "" {
+		if host, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+			clientIP = host
+		}
+	}
+
+	// 只有持有有效长期凭证的客户端才能获取会话 token
+	credential, _ := msg["credential"].(string)
+	if err := enrolloption.VerifyCredential(h.DB, agentID, credential); err != nil {
+		log.Printf("Rejected token request from agent %s: %v", agentID, err)
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": enrolloption.ErrCredentialInvalid.Error()})
+		return err
+	}
+
+	// 出示了证书时必须是该客户端未吊销的证书
+	if err := h.checkCertificate(conn, agentID); err != nil {
+		log.Printf("Rejected token request from agent %s: %v", agentID, err)
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": enrolloption.ErrCertificateInvalid.Error()})
+		return err
 	}
 
 	// 获取 token 和 expiresAt
-	token, expiresAt, err := controller.GetTokenForClientFromController(clientIP)
+	token, expiresAt, err := controller.GetTokenForClientFromController(agentID)
 	if err != nil {
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": "获取 token 失败"})
 		return fmt.Errorf("failed to get token for client: %v", err)
 	}
+	common.BindClientSession(conn, agentID, clientIP, token)
 
-	// 更新客户端连接信息到数据库
-	err = clientoption.InsertOrUpdateClientConnection(h.DB, clientIP, token, expiresAt)
-	if err != nil {
-		log.Printf("Failed to update client connection for client IP %s: %v", clientIP, err)
-		return err
+	// 登记在线状态失败不影响握手，下一次心跳时重试
+	if err := JoinPresence(h.Etcd, conn, agentID, clientIP); err != nil {
+		log.Printf("Failed to register presence for agent %s: %v", agentID, err)
 	}
 
 	// 返回 token 和过期时间给客户端
@@ -64,8 +96,38 @@ func (h *TokenHandler) HandleMessage(conn *websocket.Conn, msg map[string]interf
 		"Token":     token,
 		"ExpiresAt": expiresAt.Format(time.RFC3339),
 	}
+	// 客户端从已确认的序号之后开始按序应用指令，查询失败时由客户端沿用自己记录的序号
+	if appliedSeq, err := mysqloption.AppliedSeq(h.DB, agentID); err != nil {
+		log.Printf("Failed to get applied command seq for agent %s: %v", agentID, err)
+	} else {
+		response["AppliedSeq"] = strconv.FormatInt(appliedSeq, 10)
+	}
 
-	return common.SendJSONResponse(conn, response)
+	if err := common.SendJSONResponse(conn, response); err != nil {
+		return err
+	}
+	// 客户端收到 token 后再重发未确认的指令
+	RedeliverCommands(h.DB, conn, agentID, 0)
+	return nil
+}
+
+// checkCertificate 校验 wss 连接上的客户端证书
+func (h *TokenHandler) checkCertificate(conn *websocket.Conn, agentID string) error {
+	cert := common.PeerCertificate(conn)
+	if cert == nil {
+		if h.RequireCert {
+			return errors.New("client certificate required")
+		}
+		return nil
+	}
+	certAgentID, err := enrolloption.CheckCertificate(h.DB, cert)
+	if err != nil {
+		return err
+	}
+	if certAgentID != agentID {
+		return fmt.Errorf("certificate belongs to agent %s", certAgentID)
+	}
+	return nil
 }
 
 type DemoHandle struct{}
@@ -74,3 +136,20 @@ func (h *DemoHandle) HandleMessage(conn *websocket.Conn, msg map[string]interfac
 	response := map[string]interface{}{"response": "接收数据"}
 	return common.SendJSONResponse(conn, response)
 }
+
+// decodeClientMsg 解析客户端发送的 Base64 编码的 Msg 字段
+func decodeClientMsg(msg map[string]interface{}, v interface{}) error {
+	base64Msg, ok := msg["Msg"].(string)
+	if !ok {
+		return errors.New("Msg 字段无效")
+	}
+	base64Msg = strings.Trim(base64Msg, `"`)
+	decodedMsg, err := base64.StdEncoding.DecodeString(base64Msg)
+	if err != nil {
+		return fmt.Errorf("解码 Base64 字符串失败: %v", err)
+	}
+	if err := json.Unmarshal(decodedMsg, v); err != nil {
+		return fmt.Errorf("解析解码后的 JSON 消息失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package wshandler
+
+import (
+	"Server/common"
+	"Server/dao/hostoption"
+	"Server/models/hosttype"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"net"
+)
+
+// HostRegisterHandler 客户端连接后上报主机信息，登记到主机清单
+type HostRegisterHandler struct {
+	Db *sqlx.DB
+}
+
+func (h *HostRegisterHandler) HandleMessage(conn *websocket.Conn, msg map[string]interface{}) error {
+	var facts hosttype.HostFacts
+	if err := decodeClientMsg(msg, &facts); err != nil {
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": err.Error()})
+		return err
+	}
+
+	// 客户端 ID 以令牌握手时绑定的为准
+	if id := common.GetClientID(conn); id != "" {
+		facts.AgentID = id
+	}
+	if facts.AgentID == "" {
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": "agent_id 不能为空"})
+		return fmt.Errorf("主机注册缺少 agent_id")
+	}
+
+	// 客户端未携带 IP 时使用连接的远端地址
+	if facts.ClientIP == "" {
+		if host, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+			facts.ClientIP = host
+		}
+	}
+	if facts.HostName == "" {
+		facts.HostName = facts.ClientIP
+	}
+
+	hostID, err := hostoption.RegisterHost(h.Db, &facts)
+	if err != nil {
+		zap.L().Error("主机注册失败", zap.String("agent_id", facts.AgentID), zap.Error(err))
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": "主机注册失败"})
+		return fmt.Errorf("主机注册失败: %v", err)
+	}
+
+	response := map[string]interface{}{
+		"status": "主机已注册",
+		"hostid": hostID,
+	}
+	return common.SendJSONResponse(conn, response)
+}
@@ -0,0 +1,55 @@
+package wshandler
+
+import (
+	"Server/common"
+	"Server/dao/metricoption"
+	"Server/models/metrictype"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"net"
+	"time"
+)
+
+// MetricsReportHandler 接收客户端推送的主机指标并持久化
+type MetricsReportHandler struct {
+	Db *sqlx.DB
+}
+
+func (h *MetricsReportHandler) HandleMessage(conn *websocket.Conn, msg map[string]interface{}) error {
+	var report metrictype.MetricsReport
+	if err := decodeClientMsg(msg, &report); err != nil {
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": err.Error()})
+		return err
+	}
+
+	// 指标按握手时绑定的客户端 ID 保存，不采用消息中的 agent_id，IP 只作记录
+	report.AgentID = common.GetClientID(conn)
+	if report.AgentID == "" {
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": "agent_id 不能为空"})
+		return fmt.Errorf("指标上报缺少 agent_id")
+	}
+	// 客户端未携带 IP 时使用连接的远端地址
+	if report.ClientIP == "" {
+		if host, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil {
+			report.ClientIP = host
+		}
+	}
+	if report.Timestamp.IsZero() {
+		report.Timestamp = time.Now()
+	}
+
+	count, err := metricoption.InsertMetricSamples(h.Db, report.Samples())
+	if err != nil {
+		zap.L().Error("保存主机指标失败", zap.String("agent_id", report.AgentID), zap.Error(err))
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": "保存指标失败"})
+		return fmt.Errorf("保存主机指标失败: %v", err)
+	}
+
+	response := map[string]interface{}{
+		"status": "指标已接收",
+		"count":  count,
+	}
+	return common.SendJSONResponse(conn, response)
+}
@@ -0,0 +1,43 @@
+package wshandler
+
+import (
+	"Server/common"
+	"Server/dao/task/mysqloption"
+	"Server/models/tasktype"
+	"Server/pkg/outputhub"
+	"github.com/gorilla/websocket"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"strconv"
+)
+
+// TaskOutputHandler 接收客户端在执行过程中分批上报的输出，保存后推送给正在查看的用户
+type TaskOutputHandler struct {
+	Db *sqlx.DB
+}
+
+func (h *TaskOutputHandler) HandleMessage(conn *websocket.Conn, msg map[string]interface{}) error {
+	var p tasktype.TaskOutput
+	if err := decodeClientMsg(msg, &p); err != nil {
+		_ = common.SendJSONResponse(conn, map[string]interface{}{"error": err.Error()})
+		return err
+	}
+	agentID := common.GetClientID(conn)
+	if err := mysqloption.AppendOutput(h.Db, agentID, &p); err != nil {
+		zap.L().Warn("保存任务输出失败", zap.String("agent_id", agentID), zap.Int64("run_id", p.RunID), zap.Error(err))
+		return common.SendJSONResponse(conn, map[string]interface{}{
+			"run_id": strconv.FormatInt(p.RunID, 10),
+			"error":  err.Error(),
+		})
+	}
+	outputhub.Publish(p.RunID, p.Lines)
+
+	var lastSeq int64
+	if n := len(p.Lines); n > 0 {
+		lastSeq = p.Lines[n-1].Seq
+	}
+	return common.SendJSONResponse(conn, map[string]interface{}{
+		"run_id":   strconv.FormatInt(p.RunID, 10),
+		"last_seq": lastSeq,
+	})
+}
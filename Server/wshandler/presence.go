@@ -0,0 +1,54 @@
+package wshandler
+
+import (
+	"Server/common"
+	"Server/dao/clientoption"
+	"Server/models/hosttype"
+	"Server/pkg/instance"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// PresenceTTL 在线租约秒数，客户端每 30 秒发送一次 ping，连续 3 次未收到视为离线
+const PresenceTTL = 90
+
+// JoinPresence 握手成功后登记客户端在线
+func JoinPresence(cli *clientv3.Client, conn *websocket.Conn, agentID, clientIP string) error {
+	p := &hosttype.Presence{
+		AgentID:     agentID,
+		ClientIP:    clientIP,
+		Server:      instance.ID(),
+		ConnectedAt: time.Now(),
+	}
+	leaseID, err := clientoption.RegisterPresence(cli, p, PresenceTTL)
+	if err != nil {
+		return err
+	}
+	common.SetPresenceLease(conn, int64(leaseID))
+	return nil
+}
+
+// RefreshPresence 收到客户端心跳时续约，租约已过期或握手时未能登记时重新登记
+func RefreshPresence(cli *clientv3.Client, conn *websocket.Conn) {
+	agentID, clientIP, leaseID := common.GetPresence(conn)
+	if agentID == "" {
+		return
+	}
+	if leaseID != 0 {
+		err := clientoption.RefreshPresence(cli, clientv3.LeaseID(leaseID))
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, clientoption.ErrPresenceExpired) {
+			log.Printf("Failed to refresh presence for agent %s: %v", agentID, err)
+			return
+		}
+	}
+	if err := JoinPresence(cli, conn, agentID, clientIP); err != nil {
+		log.Printf("Failed to register presence for agent %s: %v", agentID, err)
+	}
+}